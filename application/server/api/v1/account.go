@@ -43,5 +43,13 @@ func QueryAccountList(c *gin.Context) {
 		appG.Response(http.StatusInternalServerError, "失败", err.Error())
 		return
 	}
+	//非管理员只能看到自己账户的余额
+	if viewer := privateViewer(c); viewer != "" {
+		for _, account := range data {
+			if account["accountId"] != viewer {
+				account["balance"] = 0
+			}
+		}
+	}
 	appG.Response(http.StatusOK, "成功", data)
 }
@@ -0,0 +1,232 @@
+package v1
+
+import (
+	bc "application/blockchain"
+	"application/model"
+	"application/pkg/app"
+	"application/pkg/auth"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type LoginRequestBody struct {
+	AccountId   string `json:"accountId"`   //账号ID
+	Password    string `json:"password"`    //密码或管理员生成的一次性密码
+	NewPassword string `json:"newPassword"` //使用一次性密码登录时设置的新密码，至少6位
+}
+
+type ProvisionRequestBody struct {
+	AccountId string `json:"accountId"` //生成一次性密码的账户
+}
+
+type ProvisionResponse struct {
+	AccountId string `json:"accountId"` //账号ID
+	Secret    string `json:"secret"`    //一次性密码，只返回这一次
+	ExpiresAt string `json:"expiresAt"` //过期时间
+}
+
+type RefreshRequestBody struct {
+	RefreshToken string `json:"refreshToken"` //刷新令牌
+}
+
+type ChangePasswordRequestBody struct {
+	OldPassword string `json:"oldPassword"` //原密码
+	NewPassword string `json:"newPassword"` //新密码，至少6位
+}
+
+type LoginAccount struct {
+	AccountId string `json:"accountId"` //账号ID
+	UserName  string `json:"userName"`  //账号名
+}
+
+// LoginAccounts 账户选择列表(不含余额)，需要登录
+func LoginAccounts(c *gin.Context) {
+	appG := app.Gin{C: c}
+	accounts, err := queryAccounts()
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	data := make([]LoginAccount, 0, len(accounts))
+	for _, v := range accounts {
+		data = append(data, LoginAccount{AccountId: v.AccountId, UserName: v.UserName})
+	}
+	appG.Response(http.StatusOK, "成功", data)
+}
+
+func Login(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(LoginRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	if body.AccountId == "" || body.Password == "" {
+		appG.Response(http.StatusBadRequest, "失败", "账号和密码不能为空")
+		return
+	}
+	//确认账户存在于链上
+	accounts, err := queryAccounts(body.AccountId)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	if len(accounts) == 0 {
+		appG.Response(http.StatusUnauthorized, "失败", auth.ErrInvalidCredential.Error())
+		return
+	}
+	err = auth.Default().Verify(body.AccountId, body.Password)
+	if err == auth.ErrPasswordChangeRequired {
+		//一次性密码只能用于设置账户自己的密码
+		if len(body.NewPassword) < 6 {
+			appG.Response(http.StatusForbidden, "失败", fmt.Sprintf("%s(newPassword，至少6位)", err.Error()))
+			return
+		}
+		err = auth.Default().Activate(body.AccountId, body.Password, body.NewPassword)
+	}
+	if err != nil {
+		if err == auth.ErrInvalidCredential {
+			appG.Response(http.StatusUnauthorized, "失败", err.Error())
+			return
+		}
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	tokens, err := auth.Issue(accounts[0].AccountId, accounts[0].UserName, accounts[0].Role)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", tokens)
+}
+
+func Refresh(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(RefreshRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	tokens, err := auth.Refresh(body.RefreshToken)
+	if err != nil {
+		appG.Response(http.StatusUnauthorized, "失败", fmt.Sprintf("刷新令牌无效%s", err.Error()))
+		return
+	}
+	appG.Response(http.StatusOK, "成功", tokens)
+}
+
+// Logout 注销当前账户的全部刷新令牌，访问令牌在有效期后自然失效
+func Logout(c *gin.Context) {
+	appG := app.Gin{C: c}
+	if err := auth.Default().RevokeRefreshTokens(auth.AccountId(c)); err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", nil)
+}
+
+// Me 当前登录账户的信息
+func Me(c *gin.Context) {
+	appG := app.Gin{C: c}
+	accounts, err := queryAccounts(auth.AccountId(c))
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	if len(accounts) == 0 {
+		appG.Response(http.StatusNotFound, "失败", "账户不存在")
+		return
+	}
+	appG.Response(http.StatusOK, "成功", accounts[0])
+}
+
+func ChangePassword(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(ChangePasswordRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	if len(body.NewPassword) < 6 {
+		appG.Response(http.StatusBadRequest, "失败", "新密码至少6位")
+		return
+	}
+	accountId := auth.AccountId(c)
+	if err := auth.Default().Verify(accountId, body.OldPassword); err != nil && err != auth.ErrPasswordChangeRequired {
+		if err == auth.ErrInvalidCredential {
+			appG.Response(http.StatusForbidden, "失败", "原密码错误")
+			return
+		}
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	if err := auth.Default().SetPassword(accountId, body.NewPassword); err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	//修改密码后其他设备需要重新登录
+	if err := auth.Default().RevokeRefreshTokens(accountId); err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", nil)
+}
+
+// Provision 管理员为账户生成一次性密码，覆盖原有密码并注销其刷新令牌，用于新账户首次登录或重置密码
+func Provision(c *gin.Context) {
+	appG := app.Gin{C: c}
+	if !requireAdmin(appG) {
+		return
+	}
+	body := new(ProvisionRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	if body.AccountId == "" {
+		appG.Response(http.StatusBadRequest, "失败", "accountId不能为空")
+		return
+	}
+	accounts, err := queryAccounts(body.AccountId)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	if len(accounts) == 0 {
+		appG.Response(http.StatusNotFound, "失败", "账户不存在")
+		return
+	}
+	secret, expiresAt, err := auth.Default().Provision(body.AccountId, auth.SecretTTL())
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	if err := auth.Default().RevokeRefreshTokens(body.AccountId); err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", ProvisionResponse{AccountId: body.AccountId, Secret: secret, ExpiresAt: expiresAt.Format("2006-01-02 15:04:05")})
+}
+
+func queryAccounts(accountIds ...string) ([]model.Account, error) {
+	var bodyBytes [][]byte
+	for _, v := range accountIds {
+		bodyBytes = append(bodyBytes, []byte(v))
+	}
+	resp, err := bc.ChannelQuery("queryAccountList", bodyBytes)
+	if err != nil {
+		return nil, err
+	}
+	var accounts []model.Account
+	if err := json.Unmarshal(resp.Payload, &accounts); err != nil {
+		return nil, err
+	}
+	return accounts, nil
+}
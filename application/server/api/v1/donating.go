@@ -3,6 +3,7 @@ package v1
 import (
 	bc "application/blockchain"
 	"application/pkg/app"
+	"application/pkg/auth"
 	"bytes"
 	"encoding/json"
 	"fmt"
@@ -49,7 +50,7 @@ func CreateDonating(c *gin.Context) {
 	bodyBytes = append(bodyBytes, []byte(body.Donor))
 	bodyBytes = append(bodyBytes, []byte(body.Grantee))
 	//调用智能合约
-	resp, err := bc.ChannelExecute("createDonating", bodyBytes)
+	resp, err := bc.ChannelExecuteAs(auth.AccountId(c), "createDonating", bodyBytes)
 	if err != nil {
 		appG.Response(http.StatusInternalServerError, "失败", err.Error())
 		return
@@ -136,7 +137,7 @@ func UpdateDonating(c *gin.Context) {
 	bodyBytes = append(bodyBytes, []byte(body.Grantee))
 	bodyBytes = append(bodyBytes, []byte(body.Status))
 	//调用智能合约
-	resp, err := bc.ChannelExecute("updateDonating", bodyBytes)
+	resp, err := bc.ChannelExecuteAs(auth.AccountId(c), "updateDonating", bodyBytes)
 	if err != nil {
 		appG.Response(http.StatusInternalServerError, "失败", err.Error())
 		return
@@ -0,0 +1,91 @@
+package v1
+
+import (
+	"application/pkg/app"
+	"application/pkg/export"
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultReconcileLimit 查询对账结果的默认条数
+const defaultReconcileLimit = 20
+
+// QueryReport 导出报表(管理员)，format为csv(默认)、json或xlsx，from、to为YYYY-MM-DD格式的日期
+// 响应头X-Block-Height、X-Block-Hash为数据所在的区块，X-Report-Digest为报表摘要
+func QueryReport(c *gin.Context) {
+	appG := app.Gin{C: c}
+	if !requireAdmin(appG) {
+		return
+	}
+	name := c.Param("name")
+	if !export.ValidReport(name) {
+		appG.Response(http.StatusNotFound, "失败", fmt.Sprintf("报表%s不存在，可选%v", name, export.Reports))
+		return
+	}
+	format, err := export.LookupFormat(c.Query("format"))
+	if err != nil {
+		appG.Response(http.StatusBadRequest, "失败", err.Error())
+		return
+	}
+	from, to := c.Query("from"), c.Query("to")
+	if err := export.CheckRange(from, to); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", err.Error())
+		return
+	}
+	report, err := export.Generate(name, from, to)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	var body bytes.Buffer
+	if err := format.Write(&body, report); err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	c.Header("Content-Disposition", "attachment; filename="+export.FileName(report, format))
+	c.Header("X-Block-Height", strconv.FormatUint(report.BlockHeight, 10))
+	c.Header("X-Block-Hash", report.BlockHash)
+	c.Header("X-Report-Digest", report.Digest)
+	c.Data(http.StatusOK, format.ContentType, body.Bytes())
+}
+
+// QueryReconciliationList 查询最近的对账结果(管理员)，limit默认20
+func QueryReconciliationList(c *gin.Context) {
+	appG := app.Gin{C: c}
+	if !requireAdmin(appG) {
+		return
+	}
+	limit := defaultReconcileLimit
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("limit必须为正整数: %s", v))
+			return
+		}
+		limit = n
+	}
+	list, err := export.Default().List(limit)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", list)
+}
+
+// CreateReconciliation 立即对账并返回结果(管理员)
+func CreateReconciliation(c *gin.Context) {
+	appG := app.Gin{C: c}
+	if !requireAdmin(appG) {
+		return
+	}
+	rec, err := export.Run(export.TriggerManual)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", rec)
+}
@@ -0,0 +1,22 @@
+package v1
+
+import (
+	bc "application/blockchain"
+	"application/pkg/app"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Health 通道客户端的健康状态，存在连接出错的客户端时返回503
+func Health(c *gin.Context) {
+	appG := app.Gin{C: c}
+	clients := bc.Health()
+	for _, v := range clients {
+		if !v.Healthy {
+			appG.Response(http.StatusServiceUnavailable, "失败", clients)
+			return
+		}
+	}
+	appG.Response(http.StatusOK, "成功", clients)
+}
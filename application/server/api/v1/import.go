@@ -0,0 +1,145 @@
+package v1
+
+import (
+	"application/pkg/app"
+	"application/pkg/auth"
+	"application/pkg/importer"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxImportFileSize 上传文件的大小上限
+const maxImportFileSize = 32 << 20
+
+// CreateImport 上传CSV或JSON文件(表单字段file)，新建导入任务并在后台分批提交(管理员)
+func CreateImport(c *gin.Context) {
+	appG := app.Gin{C: c}
+	if !requireAdmin(appG) {
+		return
+	}
+	header, err := c.FormFile("file")
+	if err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	if header.Size > maxImportFileSize {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("文件不能超过%dMB", maxImportFileSize>>20))
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		appG.Response(http.StatusBadRequest, "失败", err.Error())
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		appG.Response(http.StatusBadRequest, "失败", err.Error())
+		return
+	}
+	rows, err := importer.Parse(header.Filename, data)
+	if err != nil {
+		appG.Response(http.StatusBadRequest, "失败", err.Error())
+		return
+	}
+	job, err := importer.Submit(auth.AccountId(c), header.Filename, rows)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", job)
+}
+
+// QueryImportList 查询全部导入任务(管理员)
+func QueryImportList(c *gin.Context) {
+	appG := app.Gin{C: c}
+	if !requireAdmin(appG) {
+		return
+	}
+	jobs, err := importer.Default().Jobs()
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", jobs)
+}
+
+// QueryImport 查询导入任务的进度(管理员)
+func QueryImport(c *gin.Context) {
+	appG := app.Gin{C: c}
+	if !requireAdmin(appG) {
+		return
+	}
+	job, err := importer.Default().Job(c.Param("id"))
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	if job == nil {
+		appG.Response(http.StatusNotFound, "失败", importer.ErrNotFound.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", job)
+}
+
+// ResumeImport 从中断的批次继续导入(管理员)
+func ResumeImport(c *gin.Context) {
+	appG := app.Gin{C: c}
+	if !requireAdmin(appG) {
+		return
+	}
+	job, err := importer.Resume(c.Param("id"))
+	switch err {
+	case nil:
+		appG.Response(http.StatusOK, "成功", job)
+	case importer.ErrNotFound:
+		appG.Response(http.StatusNotFound, "失败", err.Error())
+	case importer.ErrRunning, importer.ErrDone:
+		appG.Response(http.StatusConflict, "失败", err.Error())
+	default:
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+	}
+}
+
+// QueryImportErrors 下载导入任务的错误报告，CSV格式(管理员)
+func QueryImportErrors(c *gin.Context) {
+	appG := app.Gin{C: c}
+	if !requireAdmin(appG) {
+		return
+	}
+	id := c.Param("id")
+	job, err := importer.Default().Job(id)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	if job == nil {
+		appG.Response(http.StatusNotFound, "失败", importer.ErrNotFound.Error())
+		return
+	}
+	failures, err := importer.Default().Failures(id)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	var report bytes.Buffer
+	if err := importer.WriteReport(&report, failures); err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=import-%s-errors.csv", id))
+	c.Data(http.StatusOK, "text/csv; charset=utf-8", report.Bytes())
+}
+
+// requireAdmin 导入、导出、对账和账本状态接口仅限管理员
+func requireAdmin(appG app.Gin) bool {
+	if claims := auth.Current(appG.C); claims == nil || !claims.IsAdmin() {
+		appG.Response(http.StatusForbidden, "失败", "该操作仅限管理员")
+		return false
+	}
+	return true
+}
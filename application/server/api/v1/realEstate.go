@@ -3,6 +3,7 @@ package v1
 import (
 	bc "application/blockchain"
 	"application/pkg/app"
+	"application/pkg/auth"
 	"bytes"
 	"encoding/json"
 	"fmt"
@@ -23,6 +24,15 @@ type RealEstateQueryRequestBody struct {
 	Proprietor string `json:"proprietor"` //所有者(业主)(业主AccountId)
 }
 
+type RealEstateFilterRequestBody struct {
+	Proprietor     string  `json:"proprietor"`     //所有者(业主)(业主AccountId)
+	Encumbrance    *bool   `json:"encumbrance"`    //是否作为担保，不传则不限制
+	MinTotalArea   float64 `json:"minTotalArea"`   //最小总面积
+	MaxTotalArea   float64 `json:"maxTotalArea"`   //最大总面积
+	MinLivingSpace float64 `json:"minLivingSpace"` //最小生活空间
+	MaxLivingSpace float64 `json:"maxLivingSpace"` //最大生活空间
+}
+
 func CreateRealEstate(c *gin.Context) {
 	appG := app.Gin{C: c}
 	body := new(RealEstateRequestBody)
@@ -41,7 +51,7 @@ func CreateRealEstate(c *gin.Context) {
 	bodyBytes = append(bodyBytes, []byte(strconv.FormatFloat(body.TotalArea, 'E', -1, 64)))
 	bodyBytes = append(bodyBytes, []byte(strconv.FormatFloat(body.LivingSpace, 'E', -1, 64)))
 	//调用智能合约
-	resp, err := bc.ChannelExecute("createRealEstate", bodyBytes)
+	resp, err := bc.ChannelExecuteAs(auth.AccountId(c), "createRealEstate", bodyBytes)
 	if err != nil {
 		appG.Response(http.StatusInternalServerError, "失败", err.Error())
 		return
@@ -80,3 +90,31 @@ func QueryRealEstateList(c *gin.Context) {
 	}
 	appG.Response(http.StatusOK, "成功", data)
 }
+
+func QueryRealEstatesByFilter(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(RealEstateFilterRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	filter, err := json.Marshal(body)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	//调用智能合约
+	resp, err := bc.ChannelQuery("queryRealEstatesByFilter", [][]byte{filter})
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	// 反序列化json
+	var data []map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", data)
+}
@@ -0,0 +1,124 @@
+package v1
+
+import (
+	"application/model"
+	"application/pkg/app"
+	"application/pkg/auth"
+	"application/pkg/readmodel"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 以下接口由链下读模型提供，数据随链码事件同步，与链上可能存在短暂延迟
+
+type SearchRequestBody struct {
+	Keyword   string `json:"keyword"`   //全文检索关键字，多个关键字用空格分隔
+	AccountId string `json:"accountId"` //只查询与该账户相关的记录
+	SortBy    string `json:"sortBy"`    //排序字段
+	Desc      bool   `json:"desc"`      //是否倒序
+	Offset    int    `json:"offset"`    //分页偏移
+	Limit     int    `json:"limit"`     //分页大小，0表示不分页
+}
+
+type EventQueryRequestBody struct {
+	AccountId string `json:"accountId"` //只查询与该账户相关的事件
+	AfterSeq  uint64 `json:"afterSeq"`  //查询该序号之后的事件
+	Limit     int    `json:"limit"`     //最多返回条数，0表示不限制
+}
+
+// 账户余额和销售价格是私有数据，非管理员只能看到自己账户的余额和自己参与的销售的价格，其他记录中为0
+// 按余额、价格排序同样会泄露其他账户的数据，非管理员只能在只查询自己的记录时使用
+
+func SearchAccounts(c *gin.Context) {
+	search(c, "balance", func(q readmodel.Query, viewer string) (interface{}, error) {
+		page, err := readmodel.Default().SearchAccounts(q)
+		hideBalances(page.Items, viewer)
+		return page, err
+	})
+}
+
+func SearchRealEstates(c *gin.Context) {
+	search(c, "", func(q readmodel.Query, viewer string) (interface{}, error) {
+		return readmodel.Default().SearchRealEstates(q)
+	})
+}
+
+func SearchSellings(c *gin.Context) {
+	search(c, "price", func(q readmodel.Query, viewer string) (interface{}, error) {
+		page, err := readmodel.Default().SearchSellings(q)
+		hidePrices(page.Items, viewer)
+		return page, err
+	})
+}
+
+func SearchDonatings(c *gin.Context) {
+	search(c, "", func(q readmodel.Query, viewer string) (interface{}, error) {
+		return readmodel.Default().SearchDonatings(q)
+	})
+}
+
+// search privateSort为按私有字段排序的字段名，viewer为非管理员的登录账户，管理员为空
+func search(c *gin.Context, privateSort string, query func(q readmodel.Query, viewer string) (interface{}, error)) {
+	appG := app.Gin{C: c}
+	body := new(SearchRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	viewer := privateViewer(c)
+	if viewer != "" && privateSort != "" && body.SortBy == privateSort && body.AccountId != viewer {
+		appG.Response(http.StatusForbidden, "失败", fmt.Sprintf("按%s排序时accountId必须为当前登录账户", privateSort))
+		return
+	}
+	data, err := query(readmodel.Query(*body), viewer)
+	if err != nil {
+		appG.Response(http.StatusBadRequest, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", data)
+}
+
+// privateViewer 需要隐藏他人私有数据时返回登录账户，管理员返回空
+func privateViewer(c *gin.Context) string {
+	if claims := auth.Current(c); claims != nil && !claims.IsAdmin() {
+		return claims.AccountId
+	}
+	return ""
+}
+
+// hideBalances 隐藏viewer以外账户的余额，viewer为空时不隐藏
+func hideBalances(accounts []model.Account, viewer string) {
+	for i := range accounts {
+		if viewer != "" && accounts[i].AccountId != viewer {
+			accounts[i].Balance = 0
+		}
+	}
+}
+
+// hidePrices 隐藏viewer不是卖家或买家的销售的价格，viewer为空时不隐藏
+func hidePrices(sellings []model.Selling, viewer string) {
+	for i := range sellings {
+		if viewer != "" && sellings[i].Seller != viewer && sellings[i].Buyer != viewer {
+			sellings[i].Price = 0
+		}
+	}
+}
+
+func QueryEvents(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(EventQueryRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	data, err := readmodel.Default().Events(body.AccountId, body.AfterSeq, body.Limit)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", data)
+}
@@ -0,0 +1,121 @@
+package v1
+
+import (
+	bc "application/blockchain"
+	"application/model"
+	"application/pkg/auth"
+	"application/pkg/readmodel"
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// searchAs 以accountId登录调用检索接口，返回响应中的data
+func searchAs(t *testing.T, r *gin.Engine, accountId string, role string, path string, body SearchRequestBody, wantCode int) json.RawMessage {
+	t.Helper()
+	tokens, err := auth.Issue(accountId, accountId, role)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != wantCode {
+		t.Fatalf("期望%d，实际%d: %s", wantCode, w.Code, w.Body.String())
+	}
+	var resp struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp.Data
+}
+
+// 测试检索接口的私有数据：非管理员只能看到自己账户的余额和自己参与的销售的价格，管理员不受限制
+func TestSearchPrivate(t *testing.T) {
+	r := gin.New()
+	r.POST("/searchAccounts", auth.Required(), SearchAccounts)
+	r.POST("/searchSellings", auth.Required(), SearchSellings)
+
+	resp, err := bc.ChannelExecuteAs(admin, "createRealEstate", [][]byte{
+		[]byte(admin), []byte(owner1), []byte(strconv.FormatFloat(100, 'E', -1, 64)), []byte(strconv.FormatFloat(80, 'E', -1, 64)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var realEstate model.RealEstate
+	if err := json.Unmarshal(resp.Payload, &realEstate); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := bc.ChannelExecuteAs(owner1, "createSelling", [][]byte{
+		[]byte(realEstate.RealEstateID), []byte(owner1), []byte("50"), []byte("30"),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		page, err := readmodel.Default().SearchSellings(readmodel.Query{Keyword: realEstate.RealEstateID})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if page.Total == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("销售未同步到读模型")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	balances := func(accountId string, role string) map[string]float64 {
+		var page readmodel.Page[model.Account]
+		if err := json.Unmarshal(searchAs(t, r, accountId, role, "/searchAccounts", SearchRequestBody{}, http.StatusOK), &page); err != nil {
+			t.Fatal(err)
+		}
+		result := make(map[string]float64)
+		for _, account := range page.Items {
+			result[account.AccountId] = account.Balance
+		}
+		return result
+	}
+	if all := balances(admin, "admin"); all[owner1] == 0 || all[owner2] == 0 {
+		t.Fatalf("管理员应看到全部账户的余额: %v", all)
+	}
+	if own := balances(owner1, "owner"); own[owner1] == 0 || own[owner2] != 0 {
+		t.Fatalf("业主只应看到自己账户的余额: %v", own)
+	}
+
+	price := func(accountId string, role string) float64 {
+		var page readmodel.Page[model.Selling]
+		body := SearchRequestBody{Keyword: realEstate.RealEstateID}
+		if err := json.Unmarshal(searchAs(t, r, accountId, role, "/searchSellings", body, http.StatusOK), &page); err != nil {
+			t.Fatal(err)
+		}
+		if len(page.Items) != 1 {
+			t.Fatalf("应检索到1个销售: %+v", page)
+		}
+		return page.Items[0].Price
+	}
+	if price(owner1, "owner") != 50 || price(admin, "admin") != 50 {
+		t.Fatal("卖家和管理员应看到销售价格")
+	}
+	if price(owner2, "owner") != 0 {
+		t.Fatal("不是卖家或买家时不应看到销售价格")
+	}
+
+	//按私有字段排序只能查询自己的记录
+	searchAs(t, r, owner2, "owner", "/searchAccounts", SearchRequestBody{SortBy: "balance"}, http.StatusForbidden)
+	searchAs(t, r, owner2, "owner", "/searchSellings", SearchRequestBody{SortBy: "price"}, http.StatusForbidden)
+	searchAs(t, r, owner2, "owner", "/searchSellings", SearchRequestBody{SortBy: "price", AccountId: owner2}, http.StatusOK)
+	searchAs(t, r, admin, "admin", "/searchAccounts", SearchRequestBody{SortBy: "balance"}, http.StatusOK)
+}
@@ -2,7 +2,9 @@ package v1
 
 import (
 	bc "application/blockchain"
+	"application/model"
 	"application/pkg/app"
+	"application/pkg/auth"
 	"bytes"
 	"encoding/json"
 	"fmt"
@@ -33,6 +35,15 @@ type SellingListQueryByBuyRequestBody struct {
 	Buyer string `json:"buyer"` //买家(买家AccountId)
 }
 
+type SellingFilterRequestBody struct {
+	Seller        string  `json:"seller"`        //发起销售人、卖家(卖家AccountId)
+	SellingStatus string  `json:"sellingStatus"` //销售状态(saleStart/cancelled/expired/delivery/done)
+	MinPrice      float64 `json:"minPrice"`      //最低价格
+	MaxPrice      float64 `json:"maxPrice"`      //最高价格
+	MinArea       float64 `json:"minArea"`       //销售对象最小总面积
+	MaxArea       float64 `json:"maxArea"`       //销售对象最大总面积
+}
+
 type UpdateSellingRequestBody struct {
 	ObjectOfSale string `json:"objectOfSale"` //销售对象(正在出售的房地产RealEstateID)
 	Seller       string `json:"seller"`       //发起销售人、卖家(卖家AccountId)
@@ -59,10 +70,16 @@ func CreateSelling(c *gin.Context) {
 	var bodyBytes [][]byte
 	bodyBytes = append(bodyBytes, []byte(body.ObjectOfSale))
 	bodyBytes = append(bodyBytes, []byte(body.Seller))
-	bodyBytes = append(bodyBytes, []byte(strconv.FormatFloat(body.Price, 'E', -1, 64)))
 	bodyBytes = append(bodyBytes, []byte(strconv.Itoa(body.SalePeriod)))
+	//价格通过临时数据传入，不写入区块
+	offer, err := json.Marshal(model.OfferInput{Price: body.Price})
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
 	//调用智能合约
-	resp, err := bc.ChannelExecute("createSelling", bodyBytes)
+	resp, err := bc.ChannelExecuteAsPrivate(auth.AccountId(c), "Selling:CreateSellingPrivate", bodyBytes,
+		map[string][]byte{model.TransientOffer: offer})
 	if err != nil {
 		appG.Response(http.StatusInternalServerError, "失败", err.Error())
 		return
@@ -72,6 +89,8 @@ func CreateSelling(c *gin.Context) {
 		appG.Response(http.StatusInternalServerError, "失败", err.Error())
 		return
 	}
+	//链码返回的销售中没有价格
+	data["price"] = body.Price
 	appG.Response(http.StatusOK, "成功", data)
 }
 
@@ -92,7 +111,7 @@ func CreateSellingByBuy(c *gin.Context) {
 	bodyBytes = append(bodyBytes, []byte(body.Seller))
 	bodyBytes = append(bodyBytes, []byte(body.Buyer))
 	//调用智能合约
-	resp, err := bc.ChannelExecute("createSellingByBuy", bodyBytes)
+	resp, err := bc.ChannelExecuteAs(auth.AccountId(c), "createSellingByBuy", bodyBytes)
 	if err != nil {
 		appG.Response(http.StatusInternalServerError, "失败", err.Error())
 		return
@@ -161,6 +180,34 @@ func QuerySellingListByBuyer(c *gin.Context) {
 	appG.Response(http.StatusOK, "成功", data)
 }
 
+func QuerySellingsByFilter(c *gin.Context) {
+	appG := app.Gin{C: c}
+	body := new(SellingFilterRequestBody)
+	//解析Body参数
+	if err := c.ShouldBind(body); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	filter, err := json.Marshal(body)
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	//调用智能合约
+	resp, err := bc.ChannelQuery("querySellingsByFilter", [][]byte{filter})
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	// 反序列化json
+	var data []map[string]interface{}
+	if err = json.Unmarshal(bytes.NewBuffer(resp.Payload).Bytes(), &data); err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", data)
+}
+
 func UpdateSelling(c *gin.Context) {
 	appG := app.Gin{C: c}
 	body := new(UpdateSellingRequestBody)
@@ -179,7 +226,7 @@ func UpdateSelling(c *gin.Context) {
 	bodyBytes = append(bodyBytes, []byte(body.Buyer))
 	bodyBytes = append(bodyBytes, []byte(body.Status))
 	//调用智能合约
-	resp, err := bc.ChannelExecute("updateSelling", bodyBytes)
+	resp, err := bc.ChannelExecuteAs(auth.AccountId(c), "updateSelling", bodyBytes)
 	if err != nil {
 		appG.Response(http.StatusInternalServerError, "失败", err.Error())
 		return
@@ -0,0 +1,75 @@
+package v1
+
+import (
+	bc "application/blockchain"
+	"application/model"
+	"application/pkg/app"
+	"application/pkg/auth"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExportState 下载链码全部状态的导出文件(管理员)，相同的账本状态导出相同的内容
+func ExportState(c *gin.Context) {
+	appG := app.Gin{C: c}
+	if !requireAdmin(appG) {
+		return
+	}
+	operator := auth.AccountId(c)
+	resp, err := bc.ChannelQueryAs(operator, "exportState", [][]byte{[]byte(operator)})
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	c.Header("Content-Disposition", "attachment; filename=realty-state.json")
+	c.Data(http.StatusOK, "application/json; charset=utf-8", resp.Payload)
+}
+
+// ImportState 上传导出文件(表单字段file)初始化新网络(管理员)，只能在首次运行时执行一次
+func ImportState(c *gin.Context) {
+	appG := app.Gin{C: c}
+	if !requireAdmin(appG) {
+		return
+	}
+	header, err := c.FormFile("file")
+	if err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+		return
+	}
+	if header.Size > maxImportFileSize {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("文件不能超过%dMB", maxImportFileSize>>20))
+		return
+	}
+	file, err := header.Open()
+	if err != nil {
+		appG.Response(http.StatusBadRequest, "失败", err.Error())
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		appG.Response(http.StatusBadRequest, "失败", err.Error())
+		return
+	}
+	var dump model.StateDump
+	if err := json.Unmarshal(data, &dump); err != nil {
+		appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("导出文件格式出错%s", err.Error()))
+		return
+	}
+	operator := auth.AccountId(c)
+	resp, err := bc.ChannelExecuteAs(operator, "importState", [][]byte{[]byte(operator), data})
+	if err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	var imported model.StateImport
+	if err := json.Unmarshal(resp.Payload, &imported); err != nil {
+		appG.Response(http.StatusInternalServerError, "失败", err.Error())
+		return
+	}
+	appG.Response(http.StatusOK, "成功", imported)
+}
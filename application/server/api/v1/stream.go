@@ -0,0 +1,105 @@
+package v1
+
+import (
+	"application/pkg/app"
+	"application/pkg/auth"
+	"application/pkg/readmodel"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	streamKeepAlive = 15 * time.Second // 心跳间隔，防止代理断开空闲连接
+	streamBatchSize = 100              // 每次从读模型读取的事件数
+)
+
+// Stream 以SSE推送与登录账户相关的通知(销售被购买、捐赠被接收、销售过期等)
+// EventSource无法设置请求头，访问令牌通过access_token查询参数传递
+// 断线重连时浏览器会带上Last-Event-ID，从该事件之后继续推送；未带时只推送连接之后的新事件
+func Stream(c *gin.Context) {
+	appG := app.Gin{C: c}
+	accountId := auth.AccountId(c)
+	store := readmodel.Default()
+	//先订阅再读取，避免读取和订阅之间的事件丢失
+	notify, cancel := store.Subscribe()
+	defer cancel()
+
+	lastEventId := c.GetHeader("Last-Event-ID")
+	if lastEventId == "" {
+		lastEventId = c.Query("lastEventId")
+	}
+	var lastSeq uint64
+	if lastEventId != "" {
+		seq, err := strconv.ParseUint(lastEventId, 10, 64)
+		if err != nil {
+			appG.Response(http.StatusBadRequest, "失败", "Last-Event-ID格式出错")
+			return
+		}
+		lastSeq = seq
+	} else {
+		seq, err := store.LastSeq()
+		if err != nil {
+			appG.Response(http.StatusInternalServerError, "失败", err.Error())
+			return
+		}
+		lastSeq = seq
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") //关闭nginx缓冲
+	c.Status(http.StatusOK)
+	c.Writer.Flush()
+
+	ticker := time.NewTicker(streamKeepAlive)
+	defer ticker.Stop()
+	for {
+		var err error
+		if lastSeq, err = pushNotifications(c, store, accountId, lastSeq); err != nil {
+			return
+		}
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-notify:
+		case <-ticker.C:
+			if _, err := c.Writer.Write([]byte(": ping\n\n")); err != nil {
+				return
+			}
+			c.Writer.Flush()
+		}
+	}
+}
+
+// pushNotifications 推送lastSeq之后的通知，返回已推送到的事件序号
+func pushNotifications(c *gin.Context, store *readmodel.Store, accountId string, lastSeq uint64) (uint64, error) {
+	for {
+		records, err := store.Events(accountId, lastSeq, streamBatchSize)
+		if err != nil {
+			return lastSeq, err
+		}
+		for _, record := range records {
+			lastSeq = record.Seq
+			notification, ok := readmodel.NotificationFor(record, accountId)
+			if !ok {
+				continue
+			}
+			if err := sse.Encode(c.Writer, sse.Event{
+				Id:    strconv.FormatUint(record.Seq, 10),
+				Event: "notification",
+				Data:  notification,
+			}); err != nil {
+				return lastSeq, err
+			}
+		}
+		c.Writer.Flush()
+		if len(records) < streamBatchSize {
+			return lastSeq, nil
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package v1
+
+import (
+	bc "application/blockchain"
+	_ "application/blockchain/inprocess"
+	"application/pkg/auth"
+	"application/pkg/readmodel"
+	"application/pkg/setting"
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 链码初始化的账户
+const (
+	admin  = "5feceb66ffc8"
+	owner1 = "6b86b273ff34"
+	owner2 = "d4735e3a265e"
+)
+
+var streamServer *httptest.Server
+
+// TestMain 在进程内账本上同步读模型，并以与路由相同的中间件挂载Stream
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	dir, err := os.MkdirTemp("", "realty-stream")
+	if err != nil {
+		log.Fatal(err)
+	}
+	authCfg := setting.Default().Auth
+	authCfg.DBPath = filepath.Join(dir, "auth.db")
+	authCfg.JWTSecret = "stream"
+	auth.Init(authCfg)
+	fabricCfg := setting.Default().Fabric
+	fabricCfg.Ledger = "inprocess"
+	bc.Init(fabricCfg, "error")
+	readModelCfg := setting.Default().ReadModel
+	readModelCfg.DBPath = filepath.Join(dir, "readmodel.db")
+	readmodel.Init(readModelCfg)
+	go readmodel.Run()
+	//等待读模型导入快照，之后的交易才会产生事件
+	for {
+		if checkpoint, err := readmodel.Default().Checkpoint(); err != nil {
+			log.Fatal(err)
+		} else if checkpoint != nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	r := gin.New()
+	r.GET("/api/v1/stream", auth.QueryToken(), auth.Required(), Stream)
+	streamServer = httptest.NewServer(r)
+	code := m.Run()
+	streamServer.Close()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// createRealEstate 管理员为proprietor创建房产，等待读模型同步后返回房产创建事件的序号
+func createRealEstate(t *testing.T, proprietor string) uint64 {
+	t.Helper()
+	resp, err := bc.ChannelExecuteAs(admin, "createRealEstate", [][]byte{
+		[]byte(admin), []byte(proprietor), []byte(strconv.FormatFloat(100, 'E', -1, 64)), []byte(strconv.FormatFloat(80, 'E', -1, 64)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		records, err := readmodel.Default().Events(proprietor, 0, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, record := range records {
+			if record.TxID == resp.TxID {
+				return record.Seq
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("交易%s未同步到读模型", resp.TxID)
+	return 0
+}
+
+// event 收到的SSE事件
+type event struct {
+	id           uint64
+	notification readmodel.Notification
+}
+
+// stream 以accountId连接Stream，返回响应头到达后的事件通道，测试结束时断开
+func stream(t *testing.T, accountId string, query string, lastEventId string) <-chan event {
+	t.Helper()
+	tokens, err := auth.Issue(accountId, accountId, "owner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	url := streamServer.URL + "/api/v1/stream?access_token=" + tokens.AccessToken + query
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastEventId != "" {
+		req.Header.Set("Last-Event-ID", lastEventId)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("Content-Type") != "text/event-stream" {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		t.Fatalf("连接失败(%d): %s", resp.StatusCode, body)
+	}
+	events := make(chan event, 16)
+	go func() {
+		defer resp.Body.Close()
+		defer close(events)
+		var e event
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case strings.HasPrefix(line, "id:"):
+				e.id, _ = strconv.ParseUint(strings.TrimSpace(line[3:]), 10, 64)
+			case strings.HasPrefix(line, "data:"):
+				json.Unmarshal([]byte(strings.TrimSpace(line[5:])), &e.notification)
+			case line == "" && e.id != 0:
+				events <- e
+				e = event{}
+			}
+		}
+	}()
+	return events
+}
+
+// next 读取下一个事件，超时则测试失败
+func next(t *testing.T, events <-chan event) event {
+	t.Helper()
+	select {
+	case e, ok := <-events:
+		if !ok {
+			t.Fatal("连接已断开")
+		}
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("等待通知超时")
+	}
+	return event{}
+}
+
+// 测试按账户过滤：只推送与登录账户相关的通知，跳过其他账户的事件
+func TestStreamFilter(t *testing.T) {
+	first1 := createRealEstate(t, owner1)
+	first2 := createRealEstate(t, owner2)
+	createRealEstate(t, owner1)
+	second2 := createRealEstate(t, owner2)
+
+	events := stream(t, owner2, "", strconv.FormatUint(first1-1, 10))
+	for _, want := range []uint64{first2, second2} {
+		e := next(t, events)
+		if e.id != want || e.notification.Seq != want || !strings.Contains(e.notification.Message, "管理员为您创建了房产") {
+			t.Fatalf("期望事件%d，实际%+v", want, e)
+		}
+	}
+	//之后产生的通知同样只推送相关账户的
+	createRealEstate(t, owner1)
+	want := createRealEstate(t, owner2)
+	if e := next(t, events); e.id != want {
+		t.Fatalf("期望事件%d，实际%+v", want, e)
+	}
+}
+
+// 测试断线重连：带Last-Event-ID时从该事件之后继续推送，未带时只推送连接之后的新事件
+func TestStreamResume(t *testing.T) {
+	first := createRealEstate(t, owner1)
+	second := createRealEstate(t, owner1)
+
+	if e := next(t, stream(t, owner1, "", strconv.FormatUint(first, 10))); e.id != second {
+		t.Fatalf("应从Last-Event-ID之后的事件%d继续推送，实际%+v", second, e)
+	}
+	//EventSource不能设置请求头时通过lastEventId查询参数传递
+	if e := next(t, stream(t, owner1, "&lastEventId="+strconv.FormatUint(first, 10), "")); e.id != second {
+		t.Fatalf("应从lastEventId之后的事件%d继续推送，实际%+v", second, e)
+	}
+
+	events := stream(t, owner1, "", "")
+	third := createRealEstate(t, owner1)
+	if e := next(t, events); e.id != third {
+		t.Fatalf("未带Last-Event-ID时应只推送连接之后的事件%d，实际%+v", third, e)
+	}
+}
+
+// 测试参数校验：Last-Event-ID格式错误返回400，令牌只能通过查询参数或请求头传递
+func TestStreamRejected(t *testing.T) {
+	tokens, err := auth.Issue(owner1, owner1, "owner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name     string
+		query    string
+		header   map[string]string
+		wantCode int
+	}{
+		{"没有令牌", "", nil, http.StatusUnauthorized},
+		{"Last-Event-ID格式出错", "?access_token=" + tokens.AccessToken, map[string]string{"Last-Event-ID": "abc"}, http.StatusBadRequest},
+		{"请求头中的令牌，Last-Event-ID为负数", "", map[string]string{"Authorization": "Bearer " + tokens.AccessToken, "Last-Event-ID": "-1"}, http.StatusBadRequest},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodGet, streamServer.URL+"/api/v1/stream"+c.query, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for k, v := range c.header {
+				req.Header.Set(k, v)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != c.wantCode {
+				t.Fatalf("期望%d，实际%d", c.wantCode, resp.StatusCode)
+			}
+		})
+	}
+}
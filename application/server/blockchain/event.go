@@ -0,0 +1,9 @@
+package blockchain
+
+// EventHandler 事件处理，返回错误时停止监听，由调用方从检查点重新订阅
+type EventHandler interface {
+	// HandleEvent 处理一个链码事件，同一交易可能因重新订阅而重复送达
+	HandleEvent(blockNumber uint64, txID string, payload []byte) error
+	// HandleBlock 区块内的事件已全部处理(仅区块事件模式下调用)
+	HandleBlock(blockNumber uint64) error
+}
@@ -0,0 +1,148 @@
+package fabric
+
+import (
+	"application/blockchain"
+	"application/model"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/golang/protobuf/proto"
+	cb "github.com/hyperledger/fabric-protos-go/common"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/event"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/ledger"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fab/events/deliverclient/seek"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fabsdk"
+)
+
+// Listen 从from区块开始订阅链码事件，直到出错返回
+// 优先订阅链码事件，订阅失败或节点只提供过滤后的事件(不含Payload)时，降级为订阅区块事件并自行解析
+func Listen(from uint64, handler blockchain.EventHandler) error {
+	ctx := sdk.ChannelContext(conf.Default.Channel, fabsdk.WithUser(conf.User))
+	cli, err := event.New(ctx, event.WithBlockEvents(), event.WithSeekType(seek.FromBlock), event.WithBlockNum(from))
+	if err != nil {
+		return err
+	}
+	reg, ch, err := cli.RegisterChaincodeEvent(conf.Default.Chaincode, "^"+model.EventName+"$")
+	if err != nil {
+		log.Printf("订阅链码事件失败，改为订阅区块事件: %s", err)
+		return listenBlocks(cli, handler)
+	}
+	for e := range ch {
+		if e.Payload == nil {
+			cli.Unregister(reg)
+			log.Printf("链码事件不含Payload，改为订阅区块事件")
+			return listenBlocks(cli, handler)
+		}
+		if err := handler.HandleEvent(e.BlockNumber, e.TxID, e.Payload); err != nil {
+			cli.Unregister(reg)
+			return err
+		}
+	}
+	return errors.New("链码事件订阅已关闭")
+}
+
+func listenBlocks(cli *event.Client, handler blockchain.EventHandler) error {
+	reg, ch, err := cli.RegisterBlockEvent()
+	if err != nil {
+		return err
+	}
+	defer cli.Unregister(reg)
+	for e := range ch {
+		blockNumber := e.Block.GetHeader().GetNumber()
+		events, err := chaincodeEvents(e.Block)
+		if err != nil {
+			return errors.New(fmt.Sprintf("解析区块%d出错: %s", blockNumber, err))
+		}
+		for _, ccEvent := range events {
+			if err := handler.HandleEvent(blockNumber, ccEvent.TxId, ccEvent.Payload); err != nil {
+				return err
+			}
+		}
+		if err := handler.HandleBlock(blockNumber); err != nil {
+			return err
+		}
+	}
+	return errors.New("区块事件订阅已关闭")
+}
+
+// chaincodeEvents 解析区块中有效交易写出的本链码事件
+func chaincodeEvents(block *cb.Block) ([]*pb.ChaincodeEvent, error) {
+	var txFilter []byte
+	if metadata := block.GetMetadata().GetMetadata(); len(metadata) > int(cb.BlockMetadataIndex_TRANSACTIONS_FILTER) {
+		txFilter = metadata[cb.BlockMetadataIndex_TRANSACTIONS_FILTER]
+	}
+	var events []*pb.ChaincodeEvent
+	for i, data := range block.GetData().GetData() {
+		if i < len(txFilter) && pb.TxValidationCode(txFilter[i]) != pb.TxValidationCode_VALID {
+			continue
+		}
+		envelope := &cb.Envelope{}
+		if err := proto.Unmarshal(data, envelope); err != nil {
+			return nil, err
+		}
+		payload := &cb.Payload{}
+		if err := proto.Unmarshal(envelope.Payload, payload); err != nil {
+			return nil, err
+		}
+		channelHeader := &cb.ChannelHeader{}
+		if err := proto.Unmarshal(payload.GetHeader().GetChannelHeader(), channelHeader); err != nil {
+			return nil, err
+		}
+		if cb.HeaderType(channelHeader.Type) != cb.HeaderType_ENDORSER_TRANSACTION {
+			continue
+		}
+		tx := &pb.Transaction{}
+		if err := proto.Unmarshal(payload.Data, tx); err != nil {
+			return nil, err
+		}
+		for _, action := range tx.Actions {
+			actionPayload := &pb.ChaincodeActionPayload{}
+			if err := proto.Unmarshal(action.Payload, actionPayload); err != nil {
+				return nil, err
+			}
+			responsePayload := &pb.ProposalResponsePayload{}
+			if err := proto.Unmarshal(actionPayload.GetAction().GetProposalResponsePayload(), responsePayload); err != nil {
+				return nil, err
+			}
+			chaincodeAction := &pb.ChaincodeAction{}
+			if err := proto.Unmarshal(responsePayload.Extension, chaincodeAction); err != nil {
+				return nil, err
+			}
+			ccEvent := &pb.ChaincodeEvent{}
+			if err := proto.Unmarshal(chaincodeAction.Events, ccEvent); err != nil {
+				return nil, err
+			}
+			if ccEvent.ChaincodeId == conf.Default.Chaincode && ccEvent.EventName == model.EventName {
+				events = append(events, ccEvent)
+			}
+		}
+	}
+	return events, nil
+}
+
+// BlockHeight 查询当前区块高度
+func BlockHeight() (uint64, error) {
+	info, err := ChainInfo()
+	return info.Height, err
+}
+
+// ChainInfo 查询当前区块高度和最新区块的哈希
+func ChainInfo() (blockchain.ChainInfo, error) {
+	ctx := sdk.ChannelContext(conf.Default.Channel, fabsdk.WithUser(conf.User))
+	cli, err := ledger.New(ctx)
+	if err != nil {
+		return blockchain.ChainInfo{}, err
+	}
+	var opts []ledger.RequestOption
+	if endpoints := conf.Endpoints(conf.Default.Channel); len(endpoints) > 0 {
+		opts = append(opts, ledger.WithTargetEndpoints(endpoints...))
+	}
+	info, err := cli.QueryInfo(opts...)
+	if err != nil {
+		return blockchain.ChainInfo{}, err
+	}
+	return blockchain.ChainInfo{Height: info.BCI.Height, CurrentBlockHash: hex.EncodeToString(info.BCI.CurrentBlockHash)}, nil
+}
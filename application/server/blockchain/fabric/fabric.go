@@ -0,0 +1,133 @@
+package fabric
+
+import (
+	"application/blockchain"
+	"application/pkg/setting"
+	"application/pkg/wallet"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/logging"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/fab"
+	"github.com/hyperledger/fabric-sdk-go/pkg/core/config"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fabsdk"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fabsdk/factory/defcore"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fabsdk/factory/defmsp"
+)
+
+var (
+	sdk        *fabsdk.FabricSDK // Fabric SDK
+	conf       setting.Fabric    // 区块链配置
+	userWallet wallet.Wallet     // 平台账户的Fabric身份
+	pool       *ClientPool       // 通道客户端池
+)
+
+// sdkLogLevels 应用日志级别对应的SDK日志级别
+var sdkLogLevels = map[string]logging.Level{
+	"debug": logging.DEBUG,
+	"info":  logging.INFO,
+	"warn":  logging.WARNING,
+	"error": logging.ERROR,
+}
+
+func init() {
+	blockchain.Register("fabric", New)
+}
+
+// New 初始化SDK，通过fabric-sdk-go连接区块链网络
+func New(cfg setting.Fabric, logLevel string) (blockchain.LedgerClient, error) {
+	conf = cfg
+	var err error
+	userWallet, err = wallet.New(cfg.Wallet.Type, cfg.Wallet.Path, cfg.Wallet.Passphrase)
+	if err != nil {
+		return nil, err
+	}
+	// 通过配置文件初始化SDK，用户身份从钱包加载，私钥只保存在钱包和内存中
+	keys := newKeyStore()
+	sdk, err = fabsdk.New(config.FromFile(cfg.SDKConfig),
+		fabsdk.WithCorePkg(&coreProviderFactory{ProviderFactory: defcore.NewProviderFactory(), keys: keys}),
+		fabsdk.WithMSPPkg(&mspProviderFactory{ProviderFactory: defmsp.NewProviderFactory(), wallet: userWallet, keys: keys}))
+	if err != nil {
+		return nil, err
+	}
+	// SDK初始化时会按其配置文件设置日志级别，这里以应用配置为准
+	logging.SetLevel("", sdkLogLevels[logLevel])
+	pool = NewClientPool(newChannelClient, cfg.Pool.IdleTimeout)
+	go pool.Run(cfg.Pool.HealthCheck, probe)
+	return client{}, nil
+}
+
+// client 以包内的SDK和客户端池实现blockchain.LedgerClient
+type client struct{}
+
+func (client) Execute(target setting.Target, user string, fcn string, args [][]byte, transient map[string][]byte) (blockchain.Response, error) {
+	return response(Execute(target, user, fcn, args, transient))
+}
+
+func (client) Query(target setting.Target, user string, fcn string, args [][]byte) (blockchain.Response, error) {
+	return response(Query(target, user, fcn, args))
+}
+
+func (client) EnsureIdentity(accountId string) error {
+	return EnsureIdentity(accountId)
+}
+
+func (client) Listen(from uint64, handler blockchain.EventHandler) error {
+	return Listen(from, handler)
+}
+
+func (client) BlockHeight() (uint64, error) {
+	return BlockHeight()
+}
+
+func (client) ChainInfo() (blockchain.ChainInfo, error) {
+	return ChainInfo()
+}
+
+func (client) Health() []blockchain.ClientHealth {
+	return pool.Health()
+}
+
+func response(resp channel.Response, err error) (blockchain.Response, error) {
+	return blockchain.Response{TxID: string(resp.TransactionID), Payload: resp.Payload}, err
+}
+
+// newChannelClient 创建通道客户端，表明在通道的身份
+func newChannelClient(key blockchain.ClientKey) (channelClient, error) {
+	return channel.New(sdk.ChannelContext(key.Channel, fabsdk.WithUser(key.User)))
+}
+
+// probe 调用链码的hello检查节点是否可用
+func probe(key blockchain.ClientKey, client channelClient) error {
+	_, err := client.Query(channel.Request{ChaincodeID: key.Chaincode, Fcn: "hello"},
+		targetEndpoints(key.Channel, channel.WithTimeout(fab.Query, conf.Pool.ProbeTimeout))...)
+	return err
+}
+
+// Execute 以user身份向指定通道的链码提交交易，transient为临时数据
+func Execute(target setting.Target, user string, fcn string, args [][]byte, transient map[string][]byte) (channel.Response, error) {
+	// 对区块链账本的写操作（调用了链码的invoke）
+	return pool.Execute(blockchain.ClientKey{Channel: target.Channel, User: user, Chaincode: target.Chaincode}, channel.Request{
+		ChaincodeID:  target.Chaincode,
+		Fcn:          fcn,
+		Args:         args,
+		TransientMap: transient,
+	}, targetEndpoints(target.Channel)...)
+}
+
+// Query 以user身份查询指定通道的链码
+func Query(target setting.Target, user string, fcn string, args [][]byte) (channel.Response, error) {
+	// 对区块链账本查询的操作（调用了链码的invoke），只返回结果
+	return pool.Query(blockchain.ClientKey{Channel: target.Channel, User: user, Chaincode: target.Chaincode}, channel.Request{
+		ChaincodeID: target.Chaincode,
+		Fcn:         fcn,
+		Args:        args,
+	}, targetEndpoints(target.Channel)...)
+}
+
+// targetEndpoints 通道配置了目标节点时发送到这些节点，否则由SDK根据背书策略选择
+func targetEndpoints(channelName string, options ...channel.RequestOption) []channel.RequestOption {
+	if endpoints := conf.Endpoints(channelName); len(endpoints) > 0 {
+		options = append(options, channel.WithTargetEndpoints(endpoints...))
+	}
+	return options
+}
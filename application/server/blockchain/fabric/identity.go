@@ -0,0 +1,171 @@
+package fabric
+
+import (
+	"application/pkg/wallet"
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	mspclient "github.com/hyperledger/fabric-sdk-go/pkg/client/msp"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/msp"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fabsdk/factory/defmsp"
+)
+
+// AccountIdAttr 写入登记证书的平台账户ID属性，链码可通过客户端身份读取
+const AccountIdAttr = "realty.accountId"
+
+var (
+	enrollMu sync.Mutex
+	enrolled sync.Map // 已确认钱包中存在身份的账户ID
+)
+
+// EnsureIdentity 确保平台账户在钱包中有Fabric身份，没有则通过Fabric CA注册并登记
+// 钱包丢失但CA中已注册过的账户，重置登记密码后重新登记
+func EnsureIdentity(accountId string) error {
+	if _, ok := enrolled.Load(accountId); ok {
+		return nil
+	}
+	enrollMu.Lock()
+	defer enrollMu.Unlock()
+	if _, err := userWallet.Get(accountId); err == nil {
+		enrolled.Store(accountId, true)
+		return nil
+	} else if err != wallet.ErrNotFound {
+		return err
+	}
+	cli, err := mspclient.New(sdk.Context())
+	if err != nil {
+		return err
+	}
+	secret, err := randomSecret()
+	if err != nil {
+		return err
+	}
+	attributes := []mspclient.Attribute{{Name: AccountIdAttr, Value: accountId, ECert: true}}
+	if _, err := cli.GetIdentity(accountId); err == nil {
+		_, err = cli.ModifyIdentity(&mspclient.IdentityRequest{
+			ID:         accountId,
+			Type:       "client",
+			Attributes: attributes,
+			Secret:     secret,
+		})
+		if err != nil {
+			return fmt.Errorf("重置身份%s的登记密码失败: %s", accountId, err)
+		}
+	} else {
+		_, err = cli.Register(&mspclient.RegistrationRequest{
+			Name:       accountId,
+			Type:       "client",
+			Attributes: attributes,
+			Secret:     secret,
+		})
+		if err != nil {
+			return fmt.Errorf("注册身份%s失败: %s", accountId, err)
+		}
+	}
+	//登记成功后SDK调用walletUserStore.Store将证书和私钥写入钱包
+	err = cli.Enroll(accountId, mspclient.WithSecret(secret),
+		mspclient.WithAttributeRequests([]*mspclient.AttributeRequest{{Name: AccountIdAttr}}))
+	if err != nil {
+		return fmt.Errorf("登记身份%s失败: %s", accountId, err)
+	}
+	enrolled.Store(accountId, true)
+	log.Printf("已为账户%s登记Fabric身份", accountId)
+	return nil
+}
+
+// mspProviderFactory 使用钱包作为SDK的用户存储，fabsdk.WithUser按账户ID从钱包加载身份
+type mspProviderFactory struct {
+	*defmsp.ProviderFactory
+	wallet wallet.Wallet
+	keys   *keyStore
+}
+
+func (f *mspProviderFactory) CreateUserStore(config msp.IdentityConfig) (msp.UserStore, error) {
+	return &walletUserStore{wallet: f.wallet, keys: f.keys}, nil
+}
+
+// walletUserStore 以钱包实现msp.UserStore
+// SDK签名时按SKI从密码套件查找私钥，因此登记时从内存密钥库取出私钥存入钱包，加载身份时再放回内存密钥库
+type walletUserStore struct {
+	wallet wallet.Wallet
+	keys   *keyStore
+}
+
+func (s *walletUserStore) Store(user *msp.UserData) error {
+	ski, err := certSKI(user.EnrollmentCertificate)
+	if err != nil {
+		return err
+	}
+	key, err := s.keys.exportPEM(ski)
+	if err != nil {
+		return fmt.Errorf("读取%s的私钥失败: %s", user.ID, err)
+	}
+	return s.wallet.Put(user.ID, wallet.Identity{
+		MSPID:       user.MSPID,
+		Certificate: user.EnrollmentCertificate,
+		PrivateKey:  key,
+	})
+}
+
+func (s *walletUserStore) Load(id msp.IdentityIdentifier) (*msp.UserData, error) {
+	identity, err := s.wallet.Get(id.ID)
+	if err == wallet.ErrNotFound {
+		//组织管理员等不在钱包中的用户由SDK从cryptoPath加载
+		return nil, msp.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if identity.MSPID != id.MSPID {
+		return nil, msp.ErrUserNotFound
+	}
+	ski, err := certSKI(identity.Certificate)
+	if err != nil {
+		return nil, err
+	}
+	keySKI, err := s.keys.importPEM(identity.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("加载%s的私钥失败: %s", id.ID, err)
+	}
+	if !bytes.Equal(ski, keySKI) {
+		return nil, fmt.Errorf("%s的私钥与证书不匹配", id.ID)
+	}
+	return &msp.UserData{
+		ID:                    id.ID,
+		MSPID:                 identity.MSPID,
+		EnrollmentCertificate: identity.Certificate,
+	}, nil
+}
+
+// certSKI 计算证书公钥的SKI
+func certSKI(certPEM []byte) ([]byte, error) {
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return nil, errors.New("证书格式出错")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	pub, ok := cert.PublicKey.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, errors.New("只支持ECDSA证书")
+	}
+	return publicKeySKI(pub), nil
+}
+
+func randomSecret() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
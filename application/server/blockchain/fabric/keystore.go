@@ -0,0 +1,207 @@
+package fabric
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"sync"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/core"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fabsdk/factory/defcore"
+)
+
+// keyStore 平台账户的私钥只保存在内存中，持久化由钱包负责(加密钱包中为密文)
+// 登记时SDK通过walletCryptoSuite生成私钥，walletUserStore.Store取出写入钱包；加载身份时再从钱包放回，不在磁盘上留下明文私钥
+type keyStore struct {
+	mu   sync.RWMutex
+	keys map[string]*ecdsa.PrivateKey // SKI -> 私钥
+}
+
+func newKeyStore() *keyStore {
+	return &keyStore{keys: make(map[string]*ecdsa.PrivateKey)}
+}
+
+func (s *keyStore) put(key *ecdsa.PrivateKey) []byte {
+	ski := publicKeySKI(&key.PublicKey)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[string(ski)] = key
+	return ski
+}
+
+func (s *keyStore) get(ski []byte) (*ecdsa.PrivateKey, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	key, ok := s.keys[string(ski)]
+	return key, ok
+}
+
+// exportPEM 导出PKCS#8格式的私钥，与SDK文件密钥库中的格式一致
+func (s *keyStore) exportPEM(ski []byte) ([]byte, error) {
+	key, ok := s.get(ski)
+	if !ok {
+		return nil, errors.New("密钥库中没有该证书的私钥")
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// importPEM 导入钱包中的私钥，支持PKCS#8和SEC 1格式
+func (s *keyStore) importPEM(keyPEM []byte) ([]byte, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, errors.New("私钥格式出错")
+	}
+	var key *ecdsa.PrivateKey
+	if parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		var ok bool
+		if key, ok = parsed.(*ecdsa.PrivateKey); !ok {
+			return nil, errors.New("只支持ECDSA私钥")
+		}
+	} else if key, err = x509.ParseECPrivateKey(block.Bytes); err != nil {
+		return nil, errors.New("私钥格式出错")
+	}
+	return s.put(key), nil
+}
+
+// publicKeySKI 公钥的SKI，算法与SDK一致：sha256(未压缩的椭圆曲线点)
+func publicKeySKI(pub *ecdsa.PublicKey) []byte {
+	hash := sha256.Sum256(elliptic.Marshal(pub.Curve, pub.X, pub.Y))
+	return hash[:]
+}
+
+// coreProviderFactory 以walletCryptoSuite包装SDK默认的密码套件
+type coreProviderFactory struct {
+	*defcore.ProviderFactory
+	keys *keyStore
+}
+
+func (f *coreProviderFactory) CreateCryptoSuiteProvider(config core.CryptoSuiteConfig) (core.CryptoSuite, error) {
+	suite, err := f.ProviderFactory.CreateCryptoSuiteProvider(config)
+	if err != nil {
+		return nil, err
+	}
+	return &walletCryptoSuite{CryptoSuite: suite, keys: f.keys}, nil
+}
+
+// walletCryptoSuite 登记时生成的ECDSA私钥放在内存密钥库中，并用其签名
+// 组织管理员等从cryptoPath加载的身份、证书公钥的导入和验签仍由SDK默认的密码套件处理
+type walletCryptoSuite struct {
+	core.CryptoSuite
+	keys *keyStore
+}
+
+func (c *walletCryptoSuite) KeyGen(opts core.KeyGenOpts) (core.Key, error) {
+	if opts == nil || (opts.Algorithm() != "ECDSA" && opts.Algorithm() != "ECDSAP256") {
+		return c.CryptoSuite.KeyGen(opts)
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Ephemeral() {
+		return &privateKey{key: key, ski: publicKeySKI(&key.PublicKey)}, nil
+	}
+	return &privateKey{key: key, ski: c.keys.put(key)}, nil
+}
+
+func (c *walletCryptoSuite) GetKey(ski []byte) (core.Key, error) {
+	if key, ok := c.keys.get(ski); ok {
+		return &privateKey{key: key, ski: ski}, nil
+	}
+	return c.CryptoSuite.GetKey(ski)
+}
+
+func (c *walletCryptoSuite) Sign(k core.Key, digest []byte, opts core.SignerOpts) ([]byte, error) {
+	key, ok := k.(*privateKey)
+	if !ok {
+		return c.CryptoSuite.Sign(k, digest, opts)
+	}
+	r, s, err := ecdsa.Sign(rand.Reader, key.key, digest)
+	if err != nil {
+		return nil, err
+	}
+	//Fabric只接受low-S签名
+	if halfOrder := new(big.Int).Rsh(key.key.Params().N, 1); s.Cmp(halfOrder) > 0 {
+		s.Sub(key.key.Params().N, s)
+	}
+	return asn1.Marshal(struct{ R, S *big.Int }{r, s})
+}
+
+func (c *walletCryptoSuite) Verify(k core.Key, signature, digest []byte, opts core.SignerOpts) (bool, error) {
+	var pub *ecdsa.PublicKey
+	switch key := k.(type) {
+	case *privateKey:
+		pub = &key.key.PublicKey
+	case *publicKey:
+		pub = key.key
+	default:
+		return c.CryptoSuite.Verify(k, signature, digest, opts)
+	}
+	var sig struct{ R, S *big.Int }
+	if _, err := asn1.Unmarshal(signature, &sig); err != nil {
+		return false, err
+	}
+	return ecdsa.Verify(pub, digest, sig.R, sig.S), nil
+}
+
+// privateKey 内存密钥库中的私钥，不允许导出
+type privateKey struct {
+	key *ecdsa.PrivateKey
+	ski []byte
+}
+
+func (k *privateKey) Bytes() ([]byte, error) {
+	return nil, errors.New("不允许导出私钥")
+}
+
+func (k *privateKey) SKI() []byte {
+	return k.ski
+}
+
+func (k *privateKey) Symmetric() bool {
+	return false
+}
+
+func (k *privateKey) Private() bool {
+	return true
+}
+
+func (k *privateKey) PublicKey() (core.Key, error) {
+	return &publicKey{key: &k.key.PublicKey, ski: k.ski}, nil
+}
+
+// publicKey 私钥对应的公钥，Bytes为PKIX格式，供生成证书签名请求
+type publicKey struct {
+	key *ecdsa.PublicKey
+	ski []byte
+}
+
+func (k *publicKey) Bytes() ([]byte, error) {
+	return x509.MarshalPKIXPublicKey(k.key)
+}
+
+func (k *publicKey) SKI() []byte {
+	return k.ski
+}
+
+func (k *publicKey) Symmetric() bool {
+	return false
+}
+
+func (k *publicKey) Private() bool {
+	return false
+}
+
+func (k *publicKey) PublicKey() (core.Key, error) {
+	return k, nil
+}
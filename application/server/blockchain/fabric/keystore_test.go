@@ -0,0 +1,162 @@
+package fabric
+
+import (
+	"application/pkg/wallet"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/providers/msp"
+	"github.com/hyperledger/fabric-sdk-go/pkg/core/cryptosuite"
+	"github.com/hyperledger/fabric-sdk-go/pkg/core/cryptosuite/bccsp/sw"
+)
+
+func newTestSuite(t *testing.T, keys *keyStore) *walletCryptoSuite {
+	suite, err := sw.GetSuiteWithDefaultEphemeral()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &walletCryptoSuite{CryptoSuite: suite, keys: keys}
+}
+
+// selfSignedCert 以私钥签发自签名证书，模拟CA签发的登记证书
+func selfSignedCert(t *testing.T, key *ecdsa.PrivateKey) []byte {
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "a1"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+// 测试登记和加载身份：私钥经钱包在内存密钥库间传递，可用于签名，不写入磁盘
+func TestWalletKeyStore(t *testing.T) {
+	dir := t.TempDir()
+	w, err := wallet.NewFileSystemWallet(filepath.Join(dir, "wallet"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := newKeyStore()
+	suite := newTestSuite(t, keys)
+	key, err := suite.KeyGen(cryptosuite.GetECDSAP256KeyGenOpts(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !key.Private() {
+		t.Fatal("KeyGen应返回私钥")
+	}
+	if _, err := key.Bytes(); err == nil {
+		t.Fatal("私钥不允许导出")
+	}
+	ecKey, ok := keys.get(key.SKI())
+	if !ok {
+		t.Fatal("生成的私钥应保存在内存密钥库中")
+	}
+	cert := selfSignedCert(t, ecKey)
+	certKey, err := certSKI(cert)
+	if err != nil || string(certKey) != string(key.SKI()) {
+		t.Fatalf("证书的SKI应与私钥一致: %v", err)
+	}
+
+	//登记成功后SDK调用Store
+	store := &walletUserStore{wallet: w, keys: keys}
+	if err := store.Store(&msp.UserData{ID: "a1", MSPID: "Org1MSP", EnrollmentCertificate: cert}); err != nil {
+		t.Fatal(err)
+	}
+	other := selfSignedCert(t, mustGenerateKey(t))
+	if err := store.Store(&msp.UserData{ID: "a2", MSPID: "Org1MSP", EnrollmentCertificate: other}); err == nil {
+		t.Fatal("密钥库中没有证书对应的私钥时应登记失败")
+	}
+
+	//重启后密钥库为空，从钱包加载
+	keys = newKeyStore()
+	suite = newTestSuite(t, keys)
+	store = &walletUserStore{wallet: w, keys: keys}
+	if _, err := store.Load(msp.IdentityIdentifier{ID: "a1", MSPID: "Org2MSP"}); err != msp.ErrUserNotFound {
+		t.Fatalf("MSP ID不一致时应返回ErrUserNotFound: %v", err)
+	}
+	if _, err := store.Load(msp.IdentityIdentifier{ID: "a3", MSPID: "Org1MSP"}); err != msp.ErrUserNotFound {
+		t.Fatalf("钱包中没有的用户应返回ErrUserNotFound: %v", err)
+	}
+	user, err := store.Load(msp.IdentityIdentifier{ID: "a1", MSPID: "Org1MSP"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(user.EnrollmentCertificate) != string(cert) {
+		t.Fatal("加载的证书与登记的不一致")
+	}
+	loaded, err := suite.GetKey(certKey)
+	if err != nil || !loaded.Private() {
+		t.Fatalf("加载身份后应可按SKI取得私钥: %v", err)
+	}
+	digest := sha256.Sum256([]byte("proposal"))
+	for i := 0; i < 16; i++ {
+		signature, err := suite.Sign(loaded, digest[:], nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var sig struct{ R, S *big.Int }
+		if _, err := asn1.Unmarshal(signature, &sig); err != nil {
+			t.Fatal(err)
+		}
+		if !ecdsa.Verify(&ecKey.PublicKey, digest[:], sig.R, sig.S) {
+			t.Fatal("签名校验失败")
+		}
+		if sig.S.Cmp(new(big.Int).Rsh(elliptic.P256().Params().N, 1)) > 0 {
+			t.Fatal("签名应为low-S")
+		}
+	}
+
+	//钱包中私钥与证书不匹配
+	if err := w.Put("a4", wallet.Identity{MSPID: "Org1MSP", Certificate: other, PrivateKey: mustExport(t, ecKey)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Load(msp.IdentityIdentifier{ID: "a4", MSPID: "Org1MSP"}); err == nil {
+		t.Fatal("私钥与证书不匹配时应加载失败")
+	}
+
+	//除钱包外不应写入任何文件
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && filepath.Dir(path) != filepath.Join(dir, "wallet") {
+			t.Errorf("不应写入钱包以外的文件: %s", path)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func mustGenerateKey(t *testing.T) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func mustExport(t *testing.T, key *ecdsa.PrivateKey) []byte {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+}
@@ -0,0 +1,241 @@
+package fabric
+
+import (
+	"application/blockchain"
+	"log"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/status"
+)
+
+// channelClient 通道客户端，*channel.Client实现了该接口，基准测试中替换为桩实现
+type channelClient interface {
+	Query(request channel.Request, options ...channel.RequestOption) (channel.Response, error)
+	Execute(request channel.Request, options ...channel.RequestOption) (channel.Response, error)
+}
+
+type pooledClient struct {
+	once      sync.Once
+	client    channelClient
+	err       error
+	createdAt time.Time
+	lastUsed  int64 //UnixNano，原子读写
+	ready     int32 //创建成功，原子读写
+	broken    int32 //连接出错，下次使用时重建，原子读写
+	lastError string
+	checkedAt time.Time
+}
+
+// ClientPool 长期复用的通道客户端池，并发安全
+// channel.Client本身可以并发使用，池只负责按需创建、连接出错后重建、定期检查和回收长期不用的客户端
+type ClientPool struct {
+	newClient   func(key blockchain.ClientKey) (channelClient, error)
+	idleTimeout time.Duration
+
+	mu         sync.Mutex
+	clients    map[blockchain.ClientKey]*pooledClient
+	reconnects map[blockchain.ClientKey]int
+	lastErrors map[blockchain.ClientKey]string
+}
+
+// NewClientPool 创建客户端池，idleTimeout为0时不回收空闲客户端
+func NewClientPool(newClient func(key blockchain.ClientKey) (channelClient, error), idleTimeout time.Duration) *ClientPool {
+	return &ClientPool{
+		newClient:   newClient,
+		idleTimeout: idleTimeout,
+		clients:     make(map[blockchain.ClientKey]*pooledClient),
+		reconnects:  make(map[blockchain.ClientKey]int),
+		lastErrors:  make(map[blockchain.ClientKey]string),
+	}
+}
+
+// get 取出客户端，不存在或已损坏时创建，同一个key的并发创建只执行一次
+func (p *ClientPool) get(key blockchain.ClientKey) (*pooledClient, error) {
+	p.mu.Lock()
+	entry, ok := p.clients[key]
+	if !ok || atomic.LoadInt32(&entry.broken) == 1 {
+		if ok {
+			p.reconnects[key]++
+			log.Printf("通道客户端%v连接出错，重新创建", key)
+		}
+		entry = &pooledClient{}
+		p.clients[key] = entry
+	}
+	p.mu.Unlock()
+	entry.once.Do(func() {
+		entry.client, entry.err = p.newClient(key)
+		entry.createdAt = time.Now()
+		if entry.err == nil {
+			atomic.StoreInt32(&entry.ready, 1)
+		}
+	})
+	if entry.err != nil {
+		p.remove(key, entry)
+		return nil, entry.err
+	}
+	atomic.StoreInt64(&entry.lastUsed, time.Now().UnixNano())
+	return entry, nil
+}
+
+// markBroken 标记客户端连接出错，下次使用时重建
+func (p *ClientPool) markBroken(key blockchain.ClientKey, entry *pooledClient, err error) {
+	atomic.StoreInt32(&entry.broken, 1)
+	p.mu.Lock()
+	p.lastErrors[key] = err.Error()
+	p.mu.Unlock()
+}
+
+func (p *ClientPool) remove(key blockchain.ClientKey, entry *pooledClient) {
+	p.mu.Lock()
+	if p.clients[key] == entry {
+		delete(p.clients, key)
+	}
+	p.mu.Unlock()
+}
+
+// Query 查询，节点连接出错时重建客户端并重试一次
+func (p *ClientPool) Query(key blockchain.ClientKey, request channel.Request, options ...channel.RequestOption) (channel.Response, error) {
+	entry, err := p.get(key)
+	if err != nil {
+		return channel.Response{}, err
+	}
+	resp, err := entry.client.Query(request, options...)
+	if !isConnectionError(err) {
+		return resp, err
+	}
+	p.markBroken(key, entry, err)
+	if entry, err = p.get(key); err != nil {
+		return channel.Response{}, err
+	}
+	resp, err = entry.client.Query(request, options...)
+	if isConnectionError(err) {
+		p.markBroken(key, entry, err)
+	}
+	return resp, err
+}
+
+// Execute 提交交易，节点连接出错时重建客户端但不重试，交易可能已经提交到排序节点，由调用方决定是否重新提交
+func (p *ClientPool) Execute(key blockchain.ClientKey, request channel.Request, options ...channel.RequestOption) (channel.Response, error) {
+	entry, err := p.get(key)
+	if err != nil {
+		return channel.Response{}, err
+	}
+	resp, err := entry.client.Execute(request, options...)
+	if isConnectionError(err) {
+		p.markBroken(key, entry, err)
+	}
+	return resp, err
+}
+
+// CheckHealth 回收空闲的客户端，并用probe检查其余客户端，连接出错的标记为待重建
+func (p *ClientPool) CheckHealth(probe func(key blockchain.ClientKey, client channelClient) error) {
+	now := time.Now()
+	p.mu.Lock()
+	entries := make(map[blockchain.ClientKey]*pooledClient, len(p.clients))
+	for key, entry := range p.clients {
+		idle := now.Sub(time.Unix(0, atomic.LoadInt64(&entry.lastUsed)))
+		if p.idleTimeout > 0 && idle > p.idleTimeout {
+			delete(p.clients, key)
+			continue
+		}
+		entries[key] = entry
+	}
+	p.mu.Unlock()
+	for key, entry := range entries {
+		//创建中或已损坏的客户端跳过，由下次使用时处理
+		if atomic.LoadInt32(&entry.ready) == 0 || atomic.LoadInt32(&entry.broken) == 1 {
+			continue
+		}
+		err := probe(key, entry.client)
+		p.mu.Lock()
+		entry.checkedAt = time.Now()
+		entry.lastError = ""
+		if err != nil {
+			entry.lastError = err.Error()
+		}
+		p.mu.Unlock()
+		if isConnectionError(err) {
+			log.Printf("通道客户端%v健康检查失败: %s", key, err)
+			p.markBroken(key, entry, err)
+		}
+	}
+}
+
+// Run 每隔interval执行一次健康检查，阻塞运行
+func (p *ClientPool) Run(interval time.Duration, probe func(key blockchain.ClientKey, client channelClient) error) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		p.CheckHealth(probe)
+	}
+}
+
+// Health 池中客户端的状态，按key排序
+func (p *ClientPool) Health() []blockchain.ClientHealth {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	list := make([]blockchain.ClientHealth, 0, len(p.clients))
+	for key, entry := range p.clients {
+		if atomic.LoadInt32(&entry.ready) == 0 {
+			continue
+		}
+		broken := atomic.LoadInt32(&entry.broken) == 1
+		lastError := entry.lastError
+		if lastError == "" {
+			lastError = p.lastErrors[key]
+		}
+		list = append(list, blockchain.ClientHealth{
+			ClientKey:  key,
+			Healthy:    !broken && entry.lastError == "",
+			LastError:  lastError,
+			CreatedAt:  entry.createdAt,
+			LastUsed:   time.Unix(0, atomic.LoadInt64(&entry.lastUsed)),
+			CheckedAt:  entry.checkedAt,
+			Reconnects: p.reconnects[key],
+		})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i].ClientKey, list[j].ClientKey
+		if a.Channel != b.Channel {
+			return a.Channel < b.Channel
+		}
+		if a.Chaincode != b.Chaincode {
+			return a.Chaincode < b.Chaincode
+		}
+		return a.User < b.User
+	})
+	return list
+}
+
+// isConnectionError 是否为节点连接类错误(连接失败、超时、找不到节点)，链码返回的业务错误不算
+func isConnectionError(err error) bool {
+	if err == nil {
+		return false
+	}
+	s, ok := status.FromError(err)
+	if !ok {
+		return false
+	}
+	if status.Code(s.Code) == status.MultipleErrors {
+		for _, detail := range s.Details {
+			if e, ok := detail.(error); ok && isConnectionError(e) {
+				return true
+			}
+		}
+		return false
+	}
+	switch s.Group {
+	case status.GRPCTransportStatus:
+		return true
+	case status.EndorserClientStatus, status.OrdererClientStatus, status.ClientStatus, status.DiscoveryServerStatus:
+		switch status.Code(s.Code) {
+		case status.ConnectionFailed, status.Timeout, status.NoPeersFound:
+			return true
+		}
+	}
+	return false
+}
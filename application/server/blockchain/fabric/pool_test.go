@@ -0,0 +1,171 @@
+package fabric
+
+import (
+	"application/blockchain"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/status"
+)
+
+// stubClient 桩通道客户端，前failures次调用返回连接错误
+type stubClient struct {
+	failures int32
+	calls    int32
+}
+
+func (s *stubClient) Query(request channel.Request, options ...channel.RequestOption) (channel.Response, error) {
+	return s.call()
+}
+
+func (s *stubClient) Execute(request channel.Request, options ...channel.RequestOption) (channel.Response, error) {
+	return s.call()
+}
+
+func (s *stubClient) call() (channel.Response, error) {
+	atomic.AddInt32(&s.calls, 1)
+	if atomic.AddInt32(&s.failures, -1) >= 0 {
+		return channel.Response{}, status.New(status.EndorserClientStatus, status.ConnectionFailed.ToInt32(), "connection refused", nil)
+	}
+	return channel.Response{Payload: []byte("ok")}, nil
+}
+
+type stubSDK struct {
+	created  int32
+	failures int32         //新建的客户端前几次调用失败
+	setup    time.Duration //模拟创建客户端的耗时
+}
+
+func (s *stubSDK) newClient(key blockchain.ClientKey) (channelClient, error) {
+	atomic.AddInt32(&s.created, 1)
+	time.Sleep(s.setup)
+	return &stubClient{failures: atomic.SwapInt32(&s.failures, 0)}, nil
+}
+
+var benchKey = blockchain.ClientKey{Channel: "appchannel", User: "Admin", Chaincode: "fabric-realty"}
+
+// BenchmarkPool 复用客户端时连接池自身的开销(加锁、查找和状态更新)，桩客户端不包含SDK的耗时
+func BenchmarkPool(b *testing.B) {
+	sdk := new(stubSDK)
+	pool := NewClientPool(sdk.newClient, 0)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := pool.Query(benchKey, channel.Request{Fcn: "hello"}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// simulatedSetup 基准测试中模拟的客户端创建耗时，固定值，不代表真实SDK的耗时
+const simulatedSetup = 200 * time.Microsecond
+
+// BenchmarkClientReuse 在相同的模拟创建耗时下，比较每次请求新建客户端与通过连接池复用客户端
+func BenchmarkClientReuse(b *testing.B) {
+	b.Run("per-request", func(b *testing.B) {
+		sdk := &stubSDK{setup: simulatedSetup}
+		for i := 0; i < b.N; i++ {
+			client, err := sdk.newClient(benchKey)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := client.Query(channel.Request{Fcn: "hello"}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("pooled", func(b *testing.B) {
+		sdk := &stubSDK{setup: simulatedSetup}
+		pool := NewClientPool(sdk.newClient, 0)
+		for i := 0; i < b.N; i++ {
+			if _, err := pool.Query(benchKey, channel.Request{Fcn: "hello"}); err != nil {
+				b.Fatal(err)
+			}
+		}
+		if sdk.created != 1 {
+			b.Fatalf("复用时创建了%d个客户端", sdk.created)
+		}
+	})
+}
+
+// BenchmarkPoolParallel 并发使用同一个客户端时连接池的开销，并确认只创建一个客户端
+func BenchmarkPoolParallel(b *testing.B) {
+	sdk := new(stubSDK)
+	pool := NewClientPool(sdk.newClient, 0)
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, err := pool.Query(benchKey, channel.Request{Fcn: "hello"}); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	if sdk.created != 1 {
+		b.Fatalf("并发使用时创建了%d个客户端", sdk.created)
+	}
+}
+
+func TestPoolReconnect(t *testing.T) {
+	sdk := &stubSDK{failures: 1}
+	pool := NewClientPool(sdk.newClient, 0)
+	//查询遇到连接错误时重建客户端并重试
+	if _, err := pool.Query(benchKey, channel.Request{}); err != nil {
+		t.Fatal(err)
+	}
+	if sdk.created != 2 {
+		t.Fatalf("期望重建客户端，实际创建%d次", sdk.created)
+	}
+	health := pool.Health()
+	if len(health) != 1 || !health[0].Healthy || health[0].Reconnects != 1 {
+		t.Fatalf("状态不符 %+v", health)
+	}
+
+	//提交交易遇到连接错误时不重试，下次使用时重建
+	sdk = &stubSDK{failures: 1}
+	pool = NewClientPool(sdk.newClient, 0)
+	if _, err := pool.Execute(benchKey, channel.Request{}); err == nil {
+		t.Fatal("期望返回连接错误")
+	}
+	if health := pool.Health(); health[0].Healthy {
+		t.Fatal("连接出错的客户端应标记为不可用")
+	}
+	if _, err := pool.Execute(benchKey, channel.Request{}); err != nil {
+		t.Fatal(err)
+	}
+	if sdk.created != 2 {
+		t.Fatalf("期望重建客户端，实际创建%d次", sdk.created)
+	}
+}
+
+func TestPoolCheckHealth(t *testing.T) {
+	sdk := new(stubSDK)
+	pool := NewClientPool(sdk.newClient, time.Hour)
+	if _, err := pool.Query(benchKey, channel.Request{}); err != nil {
+		t.Fatal(err)
+	}
+	pool.CheckHealth(func(key blockchain.ClientKey, client channelClient) error {
+		return status.New(status.GRPCTransportStatus, 14, "unavailable", nil)
+	})
+	if health := pool.Health(); len(health) != 1 || health[0].Healthy {
+		t.Fatalf("健康检查失败后应标记为不可用 %+v", health)
+	}
+	//业务错误不影响连接状态
+	if _, err := pool.Query(benchKey, channel.Request{}); err != nil {
+		t.Fatal(err)
+	}
+	pool.CheckHealth(func(key blockchain.ClientKey, client channelClient) error {
+		return status.New(status.ChaincodeStatus, 500, "chaincode error", nil)
+	})
+	if health := pool.Health(); health[0].Reconnects != 1 || atomic.LoadInt32(&pool.clients[benchKey].broken) == 1 {
+		t.Fatalf("链码错误不应重建客户端 %+v", health)
+	}
+
+	//空闲超时的客户端被回收
+	pool.idleTimeout = time.Nanosecond
+	time.Sleep(time.Millisecond)
+	pool.CheckHealth(func(key blockchain.ClientKey, client channelClient) error { return nil })
+	if health := pool.Health(); len(health) != 0 {
+		t.Fatalf("空闲客户端未回收 %+v", health)
+	}
+}
@@ -0,0 +1,244 @@
+package inprocess
+
+import (
+	"application/blockchain"
+	"application/pkg/setting"
+	"chaincode/contract"
+	"chaincode/pkg/mockstub"
+	"container/list"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+)
+
+func init() {
+	blockchain.Register("inprocess", New)
+}
+
+// privateMSP 进程内账本的调用者所在组织，与network中的JD组织一致
+const privateMSP = "JDMSP"
+
+// block 进程内账本的区块，每笔成功提交的交易单独出块
+type block struct {
+	txID   string
+	events [][]byte //链码事件的Payload
+	hash   []byte   //上一区块的哈希、交易ID和事件的摘要，用于标识账本的位置
+}
+
+// Ledger 进程内账本，通过shimtest.MockStub直接运行房地产交易链码，不需要Fabric网络
+// 状态只保存在内存中，进程重启后恢复为链码初始化后的数据；不校验签名和背书策略，用于本地开发和测试
+// 调用者固定为可以读写私有数据的组织，私有数据与世界状态一样保存在MockStub中
+// 提交交易时调用者证书的realty.accountId为提交的用户，与Fabric CA为平台账户签发的证书一致
+type Ledger struct {
+	target    setting.Target
+	user      string
+	createdAt time.Time
+
+	mu       sync.Mutex
+	changed  *sync.Cond //出块时通知事件订阅
+	cc       *mockstub.Chaincode
+	stub     *shimtest.MockStub
+	blocks   []block
+	lastUsed time.Time
+}
+
+// New 创建进程内账本并初始化链码，链码初始化作为第0个区块
+func New(cfg setting.Fabric, logLevel string) (blockchain.LedgerClient, error) {
+	l := &Ledger{
+		target:    cfg.Default,
+		user:      cfg.User,
+		createdAt: time.Now(),
+		lastUsed:  time.Now(),
+		cc:        &mockstub.Chaincode{CC: new(contract.BlockChainRealEstate), MSPID: privateMSP},
+	}
+	l.stub = shimtest.NewMockStub(cfg.Default.Chaincode, l.cc)
+	l.changed = sync.NewCond(&l.mu)
+	l.stub.ChannelID = cfg.Default.Channel
+	args := [][]byte{[]byte("init")}
+	if cfg.Seed != "" {
+		seed, err := os.ReadFile(cfg.Seed)
+		if err != nil {
+			return nil, fmt.Errorf("读取种子数据出错: %s", err)
+		}
+		args = append(args, seed)
+	}
+	txID := newTxID()
+	if resp := l.stub.MockInit(txID, args); resp.Status >= shim.ERRORTHRESHOLD {
+		return nil, fmt.Errorf("链码初始化失败: %s", resp.Message)
+	}
+	l.appendBlock(txID, l.drainEvents())
+	return l, nil
+}
+
+// Execute 提交交易，链码返回错误时回滚本次写入，与Fabric中未通过背书的交易一样不出块
+func (l *Ledger) Execute(target setting.Target, user string, fcn string, args [][]byte, transient map[string][]byte) (blockchain.Response, error) {
+	if err := l.check(target); err != nil {
+		return blockchain.Response{}, err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.cc.Account, l.cc.Transient = user, transient
+	txID, payload, err := l.invoke(fcn, args, true)
+	return blockchain.Response{TxID: txID, Payload: payload}, err
+}
+
+// Query 以user身份查询，链码的写入一律回滚
+func (l *Ledger) Query(target setting.Target, user string, fcn string, args [][]byte) (blockchain.Response, error) {
+	if err := l.check(target); err != nil {
+		return blockchain.Response{}, err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.cc.Account, l.cc.Transient = user, nil
+	txID, payload, err := l.invoke(fcn, args, false)
+	return blockchain.Response{TxID: txID, Payload: payload}, err
+}
+
+// invoke 调用链码，commit为true且调用成功时保留写入并出块，调用方需持有锁
+func (l *Ledger) invoke(fcn string, args [][]byte, commit bool) (string, []byte, error) {
+	l.lastUsed = time.Now()
+	txID := newTxID()
+	state, keys := l.snapshot()
+	private := mockstub.CopyPrivate(l.stub)
+	resp := l.stub.MockInvoke(txID, append([][]byte{[]byte(fcn)}, args...))
+	events := l.drainEvents()
+	if resp.Status >= shim.ERRORTHRESHOLD {
+		l.restore(state, keys)
+		private.Restore(l.stub)
+		return txID, nil, fmt.Errorf("链码返回错误(%d): %s", resp.Status, resp.Message)
+	}
+	if !commit {
+		l.restore(state, keys)
+		private.Restore(l.stub)
+		return txID, resp.Payload, nil
+	}
+	l.appendBlock(txID, events)
+	l.changed.Broadcast()
+	return txID, resp.Payload, nil
+}
+
+// appendBlock 出块，调用方需持有锁
+func (l *Ledger) appendBlock(txID string, events [][]byte) {
+	h := sha256.New()
+	if len(l.blocks) > 0 {
+		h.Write(l.blocks[len(l.blocks)-1].hash)
+	}
+	h.Write([]byte(txID))
+	for _, e := range events {
+		h.Write(e)
+	}
+	l.blocks = append(l.blocks, block{txID: txID, events: events, hash: h.Sum(nil)})
+}
+
+// snapshot 复制世界状态，MockStub的写入直接生效，失败的调用需要据此回滚
+func (l *Ledger) snapshot() (map[string][]byte, []string) {
+	state := make(map[string][]byte, len(l.stub.State))
+	for k, v := range l.stub.State {
+		state[k] = v
+	}
+	keys := make([]string, 0, l.stub.Keys.Len())
+	for e := l.stub.Keys.Front(); e != nil; e = e.Next() {
+		keys = append(keys, e.Value.(string))
+	}
+	return state, keys
+}
+
+func (l *Ledger) restore(state map[string][]byte, keys []string) {
+	l.stub.State = state
+	l.stub.Keys = list.New()
+	for _, k := range keys {
+		l.stub.Keys.PushBack(k)
+	}
+}
+
+// drainEvents 取出本次调用写出的链码事件，MockStub的事件通道有容量限制，每次调用后都要取空
+func (l *Ledger) drainEvents() [][]byte {
+	var events [][]byte
+	for {
+		select {
+		case e := <-l.stub.ChaincodeEventsChannel:
+			events = append(events, e.Payload)
+		default:
+			return events
+		}
+	}
+}
+
+// EnsureIdentity 进程内账本不区分提交身份
+func (l *Ledger) EnsureIdentity(accountId string) error {
+	return nil
+}
+
+// Listen 从from区块开始按区块送达链码事件，追上最新区块后等待出块
+func (l *Ledger) Listen(from uint64, handler blockchain.EventHandler) error {
+	for {
+		l.mu.Lock()
+		for from >= uint64(len(l.blocks)) {
+			l.changed.Wait()
+		}
+		pending := l.blocks[from:]
+		l.mu.Unlock()
+		for _, b := range pending {
+			for _, payload := range b.events {
+				if err := handler.HandleEvent(from, b.txID, payload); err != nil {
+					return err
+				}
+			}
+			if err := handler.HandleBlock(from); err != nil {
+				return err
+			}
+			from++
+		}
+	}
+}
+
+// BlockHeight 当前区块高度
+func (l *Ledger) BlockHeight() (uint64, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return uint64(len(l.blocks)), nil
+}
+
+// ChainInfo 当前区块高度和最新区块的哈希
+func (l *Ledger) ChainInfo() (blockchain.ChainInfo, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return blockchain.ChainInfo{Height: uint64(len(l.blocks)), CurrentBlockHash: hex.EncodeToString(l.blocks[len(l.blocks)-1].hash)}, nil
+}
+
+// Health 进程内账本始终可用
+func (l *Ledger) Health() []blockchain.ClientHealth {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return []blockchain.ClientHealth{{
+		ClientKey: blockchain.ClientKey{Channel: l.target.Channel, User: l.user, Chaincode: l.target.Chaincode},
+		Healthy:   true,
+		CreatedAt: l.createdAt,
+		LastUsed:  l.lastUsed,
+	}}
+}
+
+// check 进程内只运行默认通道上的房地产交易链码
+func (l *Ledger) check(target setting.Target) error {
+	if target != l.target {
+		return errors.New(fmt.Sprintf("进程内账本只运行通道%s上的链码%s", l.target.Channel, l.target.Chaincode))
+	}
+	return nil
+}
+
+// newTxID 随机生成交易ID，格式与Fabric一致(64位十六进制)
+func newTxID() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
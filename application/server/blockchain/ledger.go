@@ -0,0 +1,151 @@
+package blockchain
+
+import (
+	"application/pkg/setting"
+	"fmt"
+	"time"
+)
+
+// Response 链码调用结果
+type Response struct {
+	TxID    string //交易ID
+	Payload []byte //链码返回的数据
+}
+
+// ChainInfo 默认通道的区块高度和最新区块的哈希
+type ChainInfo struct {
+	Height           uint64 `json:"height"`           //区块高度
+	CurrentBlockHash string `json:"currentBlockHash"` //最新区块的哈希(十六进制)
+}
+
+// ClientKey 通道客户端按(通道, 用户, 链码)复用
+type ClientKey struct {
+	Channel   string `json:"channel"`
+	User      string `json:"user"`
+	Chaincode string `json:"chaincode"`
+}
+
+// ClientHealth 通道客户端的状态
+type ClientHealth struct {
+	ClientKey
+	Healthy    bool      `json:"healthy"`    //最近一次检查或调用是否正常
+	LastError  string    `json:"lastError"`  //最近一次连接错误
+	CreatedAt  time.Time `json:"createdAt"`  //创建(重连)时间
+	LastUsed   time.Time `json:"lastUsed"`   //最近使用时间
+	CheckedAt  time.Time `json:"checkedAt"`  //最近检查时间
+	Reconnects int       `json:"reconnects"` //重连次数
+}
+
+// LedgerClient 账本客户端，REST服务只通过它与链码交互
+// fabric(blockchain/fabric)通过fabric-sdk-go连接区块链网络，inprocess(blockchain/inprocess)在进程内运行房地产交易链码
+type LedgerClient interface {
+	// Execute 以user身份向指定通道的链码提交交易，transient为临时数据，不写入交易
+	Execute(target setting.Target, user string, fcn string, args [][]byte, transient map[string][]byte) (Response, error)
+	// Query 以user身份查询指定通道的链码
+	Query(target setting.Target, user string, fcn string, args [][]byte) (Response, error)
+	// EnsureIdentity 确保平台账户有提交交易的身份
+	EnsureIdentity(accountId string) error
+	// Listen 从from区块开始订阅默认链码的事件，直到出错返回
+	Listen(from uint64, handler EventHandler) error
+	// BlockHeight 默认通道当前的区块高度
+	BlockHeight() (uint64, error)
+	// ChainInfo 默认通道当前的区块高度和最新区块的哈希
+	ChainInfo() (ChainInfo, error)
+	// Health 通道客户端的状态
+	Health() []ClientHealth
+}
+
+// Driver 按配置创建账本客户端
+type Driver func(cfg setting.Fabric, logLevel string) (LedgerClient, error)
+
+var (
+	drivers = make(map[string]Driver) // 已编译进程序的账本实现
+	ledger  LedgerClient              // 当前使用的账本客户端
+	conf    setting.Fabric            // 区块链配置
+)
+
+// Register 注册账本实现，由实现包在init中调用
+func Register(name string, driver Driver) {
+	if _, dup := drivers[name]; dup {
+		panic("账本实现重复注册: " + name)
+	}
+	drivers[name] = driver
+}
+
+// Init 按fabric.ledger选择账本实现并初始化
+func Init(cfg setting.Fabric, logLevel string) {
+	driver, ok := drivers[cfg.Ledger]
+	if !ok {
+		panic(fmt.Sprintf("账本实现%s未注册", cfg.Ledger))
+	}
+	client, err := driver(cfg, logLevel)
+	if err != nil {
+		panic(err)
+	}
+	conf = cfg
+	ledger = client
+}
+
+// Health 通道客户端的状态
+func Health() []ClientHealth {
+	return ledger.Health()
+}
+
+// ChannelExecute 区块链交互，以组织管理员身份向房地产交易链码提交
+func ChannelExecute(fcn string, args [][]byte) (Response, error) {
+	return Execute(conf.Default, conf.User, fcn, args)
+}
+
+// ChannelExecuteAs 以平台账户自己的身份提交交易，交易签名可追溯到实际操作人
+func ChannelExecuteAs(accountId string, fcn string, args [][]byte) (Response, error) {
+	if err := ledger.EnsureIdentity(accountId); err != nil {
+		return Response{}, err
+	}
+	return Execute(conf.Default, accountId, fcn, args)
+}
+
+// ChannelExecuteAsPrivate 以平台账户自己的身份提交交易，私有字段通过临时数据传入，不出现在交易参数中
+func ChannelExecuteAsPrivate(accountId string, fcn string, args [][]byte, transient map[string][]byte) (Response, error) {
+	if err := ledger.EnsureIdentity(accountId); err != nil {
+		return Response{}, err
+	}
+	return ledger.Execute(conf.Default, accountId, fcn, args, transient)
+}
+
+// ChannelQuery 区块链查询，查询房地产交易链码
+func ChannelQuery(fcn string, args [][]byte) (Response, error) {
+	return Query(conf.Default, fcn, args)
+}
+
+// ChannelQueryAs 以平台账户自己的身份查询，用于链码要求调用者是操作人本人的查询
+func ChannelQueryAs(accountId string, fcn string, args [][]byte) (Response, error) {
+	if err := ledger.EnsureIdentity(accountId); err != nil {
+		return Response{}, err
+	}
+	return ledger.Query(conf.Default, accountId, fcn, args)
+}
+
+// Execute 以user身份向指定通道的链码提交交易
+func Execute(target setting.Target, user string, fcn string, args [][]byte) (Response, error) {
+	return ledger.Execute(target, user, fcn, args, nil)
+}
+
+// Query 以组织管理员身份查询指定通道的链码
+func Query(target setting.Target, fcn string, args [][]byte) (Response, error) {
+	return ledger.Query(target, conf.User, fcn, args)
+}
+
+// Listen 从from区块开始订阅链码事件，直到出错返回
+func Listen(from uint64, handler EventHandler) error {
+	return ledger.Listen(from, handler)
+}
+
+// BlockHeight 查询当前区块高度
+func BlockHeight() (uint64, error) {
+	return ledger.BlockHeight()
+}
+
+// GetChainInfo 查询当前区块高度和最新区块的哈希
+func GetChainInfo() (ChainInfo, error) {
+	return ledger.ChainInfo()
+}
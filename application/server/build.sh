@@ -1,3 +1,4 @@
 #!/bin/bash
 
-docker build -t fabric-realty.server .
\ No newline at end of file
+# 依赖仓库根目录下的chaincode模块，以仓库根目录为构建上下文
+docker build -t fabric-realty.server -f Dockerfile ../..
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+// step 批量文件中的一行，account为空时使用配置中的账户
+type step struct {
+	Command  string                 `yaml:"command"`  //命令全名，如"selling create"
+	Account  string                 `yaml:"account"`  //以该账户登录执行
+	Password string                 `yaml:"password"` //该账户的密码，为空时使用配置中的密码
+	Args     map[string]interface{} `yaml:"args"`     //命令参数，与命令行参数同名
+}
+
+// result 一行的执行结果
+type result struct {
+	Row     int         `json:"row"`
+	Command string      `json:"command"`
+	Account string      `json:"account"`
+	OK      bool        `json:"ok"`
+	Data    interface{} `json:"data,omitempty"`
+	Error   string      `json:"error,omitempty"`
+}
+
+// reference 参数中引用前面某一行的结果，如{{1.realEstateId}}为第1行返回的房地产ID
+var reference = regexp.MustCompile(`\{\{(\d+)\.([\w.]+)\}\}`)
+
+// loadSteps 读取批量文件，按扩展名识别YAML或CSV
+func loadSteps(path string) ([]step, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		var steps []step
+		if err := yaml.NewDecoder(file).Decode(&steps); err != nil && err != io.EOF {
+			return nil, fmt.Errorf("解析%s失败: %s", path, err)
+		}
+		return steps, nil
+	case ".csv":
+		return readCSV(file)
+	default:
+		return nil, fmt.Errorf("不支持的批量文件%s，只支持.yaml、.yml和.csv", path)
+	}
+}
+
+// readCSV 第一行为列名，command列为命令全名，account、password列可选，其余列为命令参数，空单元格忽略
+func readCSV(r io.Reader) ([]step, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("解析CSV失败: %s", err)
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+	header := records[0]
+	var steps []step
+	for i, record := range records[1:] {
+		s := step{Args: make(map[string]interface{})}
+		for j, value := range record {
+			if j >= len(header) {
+				return nil, fmt.Errorf("CSV第%d行的列数多于列名", i+2)
+			}
+			if value == "" {
+				continue
+			}
+			switch name := strings.TrimSpace(header[j]); name {
+			case "command":
+				s.Command = value
+			case "account":
+				s.Account = value
+			case "password":
+				s.Password = value
+			default:
+				s.Args[name] = value
+			}
+		}
+		steps = append(steps, s)
+	}
+	return steps, nil
+}
+
+// runBatch 依次执行，keepGoing为false时遇到失败即停止
+func runBatch(client *Client, steps []step, keepGoing bool) []result {
+	var results []result
+	for i, s := range steps {
+		r := result{Row: i + 1, Command: s.Command, Account: s.Account}
+		data, err := runStep(client, s, results)
+		if err != nil {
+			r.Error = err.Error()
+		} else {
+			r.OK, r.Data = true, data
+		}
+		results = append(results, r)
+		if err != nil && !keepGoing {
+			break
+		}
+	}
+	return results
+}
+
+func runStep(client *Client, s step, results []result) (interface{}, error) {
+	fields := strings.Fields(s.Command)
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("命令%q应为<分组> <动作>", s.Command)
+	}
+	c := findCommand(fields[0], fields[1])
+	if c == nil {
+		return nil, fmt.Errorf("不支持的命令%q", s.Command)
+	}
+	values := make(map[string]string)
+	for name, v := range s.Args {
+		value, err := resolve(fmt.Sprint(v), results)
+		if err != nil {
+			return nil, err
+		}
+		values[name] = value
+	}
+	if s.Account != "" && s.Password != "" {
+		if _, err := client.Login(s.Account, s.Password); err != nil {
+			return nil, err
+		}
+	}
+	return c.run(client, s.Account, values)
+}
+
+// resolve 替换参数中对前面各行结果的引用
+func resolve(value string, results []result) (string, error) {
+	var err error
+	resolved := reference.ReplaceAllStringFunc(value, func(ref string) string {
+		match := reference.FindStringSubmatch(ref)
+		row, _ := strconv.Atoi(match[1])
+		if row < 1 || row > len(results) || !results[row-1].OK {
+			err = fmt.Errorf("引用%s无效，第%d行尚未成功执行", ref, row)
+			return ref
+		}
+		v := lookup(results[row-1].Data, match[2])
+		if v == nil {
+			err = fmt.Errorf("引用%s无效，第%d行的结果中没有%s", ref, row, match[2])
+			return ref
+		}
+		return fmt.Sprint(v)
+	})
+	return resolved, err
+}
+
+// renderResults 输出批量执行结果
+func renderResults(w io.Writer, format string, results []result) error {
+	if format == outputJSON {
+		return render(w, format, results, nil)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "#\tcommand\taccount\tresult")
+	for _, r := range results {
+		outcome := "成功"
+		if !r.OK {
+			outcome = "失败: " + r.Error
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", r.Row, r.Command, cell(r.Account), outcome)
+	}
+	return tw.Flush()
+}
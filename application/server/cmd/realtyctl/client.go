@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+)
+
+// response 与application/pkg/app.Response一致
+type response struct {
+	Code int             `json:"code"`
+	Msg  string          `json:"msg"`
+	Data json.RawMessage `json:"data"`
+}
+
+// errorBody 链码错误信封转换后的错误详情，与application/pkg/app.ErrorBody一致
+type errorBody struct {
+	Code   string `json:"code"`
+	Key    string `json:"key"`
+	Detail string `json:"detail"`
+}
+
+// APIError 接口返回的错误
+type APIError struct {
+	Status int    //HTTP状态码
+	Msg    string //提示信息
+	Code   string //链码错误码，非链码错误时为空
+	Key    string //链码消息键
+	Detail string //链码给出的原始描述或服务端的错误信息
+}
+
+func (e *APIError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d %s", e.Status, e.Msg)
+	if e.Code != "" {
+		fmt.Fprintf(&b, " [%s/%s]", e.Code, e.Key)
+	}
+	if e.Detail != "" && e.Detail != e.Msg {
+		fmt.Fprintf(&b, ": %s", e.Detail)
+	}
+	return b.String()
+}
+
+// Client REST接口客户端，按账户缓存访问令牌
+type Client struct {
+	profile Profile
+	http    *http.Client
+	tokens  map[string]string
+}
+
+// NewClient 创建客户端
+func NewClient(profile Profile) *Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if profile.Insecure {
+		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
+	}
+	return &Client{
+		profile: profile,
+		http:    &http.Client{Transport: transport, Timeout: profile.Timeout},
+		tokens:  make(map[string]string),
+	}
+}
+
+// Login 登录并缓存访问令牌，password为空时使用配置中的密码
+func (c *Client) Login(account string, password string) (string, error) {
+	if token, ok := c.tokens[account]; ok {
+		return token, nil
+	}
+	if password == "" {
+		password = c.profile.Password
+	}
+	var tokens struct {
+		AccessToken string `json:"accessToken"`
+	}
+	if err := c.do(http.MethodPost, "/auth/login", "", map[string]string{"accountId": account, "password": password}, &tokens); err != nil {
+		return "", fmt.Errorf("账户%s登录失败: %w", account, err)
+	}
+	c.tokens[account] = tokens.AccessToken
+	return tokens.AccessToken, nil
+}
+
+// Call 以account的身份调用接口，account为空时使用配置中的账户，结果写入out
+func (c *Client) Call(account string, method string, path string, body interface{}, out interface{}) error {
+	token, err := c.token(account)
+	if err != nil {
+		return err
+	}
+	return c.do(method, path, token, body, out)
+}
+
+// token account的访问令牌，account为空时使用配置中的账户
+func (c *Client) token(account string) (string, error) {
+	if account == "" {
+		account = c.profile.Account
+	}
+	return c.Login(account, "")
+}
+
+// Upload 以account的身份上传文件，表单字段为field，结果写入out
+func (c *Client) Upload(account string, path string, field string, fileName string, content []byte, out interface{}) error {
+	token, err := c.token(account)
+	if err != nil {
+		return err
+	}
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile(field, fileName)
+	if err != nil {
+		return err
+	}
+	if _, err := part.Write(content); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	status, raw, err := c.send(http.MethodPost, path, token, writer.FormDataContentType(), &body)
+	if err != nil {
+		return err
+	}
+	return decode(status, raw, out)
+}
+
+// Download 以account的身份下载非JSON的内容，如CSV报告
+func (c *Client) Download(account string, path string) ([]byte, error) {
+	token, err := c.token(account)
+	if err != nil {
+		return nil, err
+	}
+	status, raw, err := c.send(http.MethodGet, path, token, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, decode(status, raw, nil)
+	}
+	return raw, nil
+}
+
+func (c *Client) do(method string, path string, token string, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(raw)
+	}
+	status, raw, err := c.send(method, path, token, "application/json", reader)
+	if err != nil {
+		return err
+	}
+	return decode(status, raw, out)
+}
+
+// send 发送请求，返回HTTP状态码和响应内容
+func (c *Client) send(method string, path string, token string, contentType string, body io.Reader) (int, []byte, error) {
+	req, err := http.NewRequest(method, strings.TrimRight(c.profile.Server, "/")+"/api/v1"+path, body)
+	if err != nil {
+		return 0, nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	if c.profile.Language != "" {
+		req.Header.Set("Accept-Language", c.profile.Language)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	res, err := c.http.Do(req)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer res.Body.Close()
+	raw, err := io.ReadAll(res.Body)
+	return res.StatusCode, raw, err
+}
+
+// decode 解析{code, msg, data}响应，状态码不是200时返回APIError
+func decode(status int, raw []byte, out interface{}) error {
+	var resp response
+	if err := json.Unmarshal(raw, &resp); err != nil {
+		return &APIError{Status: status, Msg: http.StatusText(status), Detail: strings.TrimSpace(string(raw))}
+	}
+	if status != http.StatusOK {
+		e := &APIError{Status: status, Msg: resp.Msg}
+		var detail errorBody
+		if err := json.Unmarshal(resp.Data, &detail); err == nil && detail.Code != "" {
+			e.Code, e.Key, e.Detail = detail.Code, detail.Key, detail.Detail
+		} else {
+			_ = json.Unmarshal(resp.Data, &e.Detail)
+		}
+		return e
+	}
+	if out == nil || len(resp.Data) == 0 {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(resp.Data))
+	decoder.UseNumber()
+	return decoder.Decode(out)
+}
@@ -0,0 +1,297 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// 参数类型
+const (
+	kindString = iota
+	kindFloat
+	kindInt
+	kindBool     //可选的布尔值，不传则不限制
+	kindAccounts //逗号分隔的账户ID，转换为[{accountId}]
+)
+
+// param 命令参数，name同时是命令行参数名和批量文件的列名
+type param struct {
+	name     string
+	field    string //请求体中的字段
+	kind     int
+	usage    string
+	required bool
+	self     bool //不传时使用当前登录账户
+}
+
+// command 一个子命令，对应一个REST接口
+type command struct {
+	group   string
+	action  string
+	method  string
+	path    string
+	usage   string
+	params  []param
+	fixed   map[string]interface{} //固定的请求体字段，如更新的状态
+	columns []string               //列表的表格列，嵌套字段用.连接
+}
+
+// name 命令全名，如"selling create"
+func (c *command) name() string {
+	return c.group + " " + c.action
+}
+
+var (
+	sellingObject          = param{name: "object", field: "objectOfSale", usage: "房地产ID", required: true}
+	donatingObject         = param{name: "object", field: "objectOfDonating", usage: "房地产ID", required: true}
+	realEstateColumns      = []string{"realEstateId", "proprietor", "encumbrance", "totalArea", "livingSpace"}
+	sellingColumns         = []string{"objectOfSale", "seller", "buyer", "price", "salePeriod", "sellingStatus", "createTime"}
+	sellingBuyColumns      = []string{"buyer", "createTime", "selling.objectOfSale", "selling.seller", "selling.price", "selling.sellingStatus"}
+	donatingColumns        = []string{"objectOfDonating", "donor", "grantee", "donatingStatus", "createTime"}
+	donatingGranteeColumns = []string{"grantee", "createTime", "donating.objectOfDonating", "donating.donor", "donating.donatingStatus"}
+)
+
+// commands 全部子命令，覆盖链码对外提供的各个方法
+var commands = []*command{
+	{group: "account", action: "list", path: "/queryAccountList", usage: "查询账户列表",
+		params:  []param{{name: "ids", field: "args", kind: kindAccounts, usage: "账户ID，多个用逗号分隔，不传则查询全部"}},
+		columns: []string{"accountId", "userName", "balance"}},
+	{group: "account", action: "me", method: http.MethodGet, path: "/auth/me", usage: "查询当前登录账户"},
+
+	{group: "realestate", action: "create", path: "/createRealEstate", usage: "新建房地产(管理员)",
+		params: []param{
+			{name: "operator", field: "accountId", usage: "操作人，默认为当前登录账户", required: true, self: true},
+			{name: "proprietor", field: "proprietor", usage: "所有者账户ID", required: true},
+			{name: "total-area", field: "totalArea", kind: kindFloat, usage: "总面积", required: true},
+			{name: "living-space", field: "livingSpace", kind: kindFloat, usage: "生活空间", required: true},
+		}},
+	{group: "realestate", action: "list", path: "/queryRealEstateList", usage: "查询房地产列表",
+		params:  []param{{name: "proprietor", field: "proprietor", usage: "所有者账户ID，不传则查询全部"}},
+		columns: realEstateColumns},
+	{group: "realestate", action: "filter", path: "/queryRealEstatesByFilter", usage: "按条件筛选房地产",
+		params: []param{
+			{name: "proprietor", field: "proprietor", usage: "所有者账户ID"},
+			{name: "encumbrance", field: "encumbrance", kind: kindBool, usage: "是否作为担保"},
+			{name: "min-total-area", field: "minTotalArea", kind: kindFloat, usage: "最小总面积"},
+			{name: "max-total-area", field: "maxTotalArea", kind: kindFloat, usage: "最大总面积"},
+			{name: "min-living-space", field: "minLivingSpace", kind: kindFloat, usage: "最小生活空间"},
+			{name: "max-living-space", field: "maxLivingSpace", kind: kindFloat, usage: "最大生活空间"},
+		},
+		columns: realEstateColumns},
+
+	{group: "selling", action: "create", path: "/createSelling", usage: "发起销售",
+		params: []param{
+			sellingObject,
+			{name: "seller", field: "seller", usage: "卖家，默认为当前登录账户", required: true, self: true},
+			{name: "price", field: "price", kind: kindFloat, usage: "价格", required: true},
+			{name: "period", field: "salePeriod", kind: kindInt, usage: "有效期(天)", required: true},
+		}},
+	{group: "selling", action: "buy", path: "/createSellingByBuy", usage: "购买",
+		params: []param{
+			sellingObject,
+			{name: "seller", field: "seller", usage: "卖家", required: true},
+			{name: "buyer", field: "buyer", usage: "买家，默认为当前登录账户", required: true, self: true},
+		}},
+	{group: "selling", action: "confirm", path: "/updateSelling", usage: "卖家确认收款，房产过户给买家",
+		params: []param{
+			sellingObject,
+			{name: "seller", field: "seller", usage: "卖家，默认为当前登录账户", required: true, self: true},
+			{name: "buyer", field: "buyer", usage: "买家", required: true},
+		},
+		fixed: map[string]interface{}{"status": "done"}},
+	{group: "selling", action: "cancel", path: "/updateSelling", usage: "取消销售，交付中的销售退款给买家",
+		params: []param{
+			sellingObject,
+			{name: "seller", field: "seller", usage: "卖家", required: true},
+			{name: "buyer", field: "buyer", usage: "买家，销售中尚无买家时不传"},
+		},
+		fixed: map[string]interface{}{"status": "cancelled"}},
+	{group: "selling", action: "expire", path: "/updateSelling", usage: "将销售置为过期(管理员)",
+		params: []param{
+			sellingObject,
+			{name: "seller", field: "seller", usage: "卖家", required: true},
+			{name: "buyer", field: "buyer", usage: "买家，销售中尚无买家时不传"},
+		},
+		fixed: map[string]interface{}{"status": "expired"}},
+	{group: "selling", action: "list", path: "/querySellingList", usage: "查询发起的销售",
+		params:  []param{{name: "seller", field: "seller", usage: "卖家，不传则查询全部"}},
+		columns: sellingColumns},
+	{group: "selling", action: "bought", path: "/querySellingListByBuyer", usage: "查询参与的购买",
+		params:  []param{{name: "buyer", field: "buyer", usage: "买家，默认为当前登录账户", required: true, self: true}},
+		columns: sellingBuyColumns},
+	{group: "selling", action: "filter", path: "/querySellingsByFilter", usage: "按条件筛选销售",
+		params: []param{
+			{name: "seller", field: "seller", usage: "卖家"},
+			{name: "status", field: "sellingStatus", usage: "销售状态(saleStart/cancelled/expired/delivery/done)"},
+			{name: "min-price", field: "minPrice", kind: kindFloat, usage: "最低价格"},
+			{name: "max-price", field: "maxPrice", kind: kindFloat, usage: "最高价格"},
+			{name: "min-area", field: "minArea", kind: kindFloat, usage: "最小总面积"},
+			{name: "max-area", field: "maxArea", kind: kindFloat, usage: "最大总面积"},
+		},
+		columns: sellingColumns},
+
+	{group: "donating", action: "create", path: "/createDonating", usage: "发起捐赠",
+		params: []param{
+			donatingObject,
+			{name: "donor", field: "donor", usage: "捐赠人，默认为当前登录账户", required: true, self: true},
+			{name: "grantee", field: "grantee", usage: "受赠人", required: true},
+		}},
+	{group: "donating", action: "accept", path: "/updateDonating", usage: "受赠人接收捐赠，房产过户给受赠人",
+		params: []param{
+			donatingObject,
+			{name: "donor", field: "donor", usage: "捐赠人", required: true},
+			{name: "grantee", field: "grantee", usage: "受赠人，默认为当前登录账户", required: true, self: true},
+		},
+		fixed: map[string]interface{}{"status": "done"}},
+	{group: "donating", action: "cancel", path: "/updateDonating", usage: "取消捐赠",
+		params: []param{
+			donatingObject,
+			{name: "donor", field: "donor", usage: "捐赠人，默认为当前登录账户", required: true, self: true},
+			{name: "grantee", field: "grantee", usage: "受赠人", required: true},
+		},
+		fixed: map[string]interface{}{"status": "cancelled"}},
+	{group: "donating", action: "list", path: "/queryDonatingList", usage: "查询发起的捐赠",
+		params:  []param{{name: "donor", field: "donor", usage: "捐赠人，不传则查询全部"}},
+		columns: donatingColumns},
+	{group: "donating", action: "received", path: "/queryDonatingListByGrantee", usage: "查询收到的捐赠",
+		params:  []param{{name: "grantee", field: "grantee", usage: "受赠人，默认为当前登录账户", required: true, self: true}},
+		columns: donatingGranteeColumns},
+}
+
+// findCommand 根据分组和动作查找命令
+func findCommand(group string, action string) *command {
+	for _, c := range commands {
+		if c.group == group && c.action == action {
+			return c
+		}
+	}
+	return nil
+}
+
+// flagSet 命令的命令行参数，解析结果写入values
+func (c *command) flagSet(values map[string]string) *flag.FlagSet {
+	set := flag.NewFlagSet(c.name(), flag.ContinueOnError)
+	for _, p := range c.params {
+		set.Func(p.name, p.usage, func(name string) func(string) error {
+			return func(v string) error {
+				values[name] = v
+				return nil
+			}
+		}(p.name))
+	}
+	set.Usage = func() {
+		fmt.Fprintf(set.Output(), "realtyctl %s: %s\n", c.name(), c.usage)
+		set.PrintDefaults()
+	}
+	return set
+}
+
+// body 根据参数值生成请求体，self为当前登录账户，未知的参数名报错
+func (c *command) body(values map[string]string, self string) (map[string]interface{}, error) {
+	known := make(map[string]bool)
+	body := make(map[string]interface{})
+	for k, v := range c.fixed {
+		body[k] = v
+	}
+	for _, p := range c.params {
+		known[p.name] = true
+		v, ok := values[p.name]
+		if (!ok || v == "") && p.self {
+			v, ok = self, true
+		}
+		if !ok || v == "" {
+			if p.required {
+				return nil, fmt.Errorf("%s缺少参数%s(%s)", c.name(), p.name, p.usage)
+			}
+			continue
+		}
+		switch p.kind {
+		case kindFloat:
+			f, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				return nil, fmt.Errorf("参数%s必须为数字: %s", p.name, v)
+			}
+			body[p.field] = f
+		case kindInt:
+			i, err := strconv.Atoi(v)
+			if err != nil {
+				return nil, fmt.Errorf("参数%s必须为整数: %s", p.name, v)
+			}
+			body[p.field] = i
+		case kindBool:
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return nil, fmt.Errorf("参数%s必须为true或false: %s", p.name, v)
+			}
+			body[p.field] = b
+		case kindAccounts:
+			var ids []map[string]string
+			for _, id := range strings.Split(v, ",") {
+				if id = strings.TrimSpace(id); id != "" {
+					ids = append(ids, map[string]string{"accountId": id})
+				}
+			}
+			body[p.field] = ids
+		default:
+			body[p.field] = v
+		}
+	}
+	for name := range values {
+		if !known[name] {
+			return nil, fmt.Errorf("%s不支持参数%s", c.name(), name)
+		}
+	}
+	return body, nil
+}
+
+// run 以account的身份执行命令，account为空时使用配置中的账户
+func (c *command) run(client *Client, account string, values map[string]string) (interface{}, error) {
+	if account == "" {
+		account = client.profile.Account
+	}
+	method := c.method
+	if method == "" {
+		method = http.MethodPost
+	}
+	var body interface{}
+	if method != http.MethodGet {
+		b, err := c.body(values, account)
+		if err != nil {
+			return nil, err
+		}
+		body = b
+	}
+	var data interface{}
+	if err := client.Call(account, method, c.path, body, &data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// usage 全部命令的说明
+func usage() string {
+	var b strings.Builder
+	b.WriteString("用法: realtyctl [全局参数] <分组> <动作> [参数]\n      realtyctl [全局参数] batch -f <文件.yaml|文件.csv>\n\n命令:\n")
+	groups := make(map[string][]*command)
+	var names []string
+	for _, c := range commands {
+		if _, ok := groups[c.group]; !ok {
+			names = append(names, c.group)
+		}
+		groups[c.group] = append(groups[c.group], c)
+	}
+	sort.Strings(names)
+	for _, group := range names {
+		for _, c := range groups[group] {
+			fmt.Fprintf(&b, "  %-22s %s\n", c.name(), c.usage)
+		}
+	}
+	b.WriteString(importUsage())
+	b.WriteString(stateUsage())
+	b.WriteString("\n使用 realtyctl <分组> <动作> -h 查看命令参数\n")
+	return b.String()
+}
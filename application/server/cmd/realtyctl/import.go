@@ -0,0 +1,138 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// importColumns 导入任务列表的表格列
+var importColumns = []string{"id", "fileName", "status", "total", "next", "created", "existing", "failed", "createdAt"}
+
+// importActions import分组的动作，该分组上传文件或下载报告，不走commands的JSON请求
+var importActions = [][2]string{
+	{"upload", "上传CSV或JSON文件批量导入房地产(管理员)"},
+	{"list", "查询导入任务"},
+	{"status", "查询导入任务的进度"},
+	{"resume", "从中断的批次继续导入"},
+	{"errors", "下载导入任务的错误报告(CSV)"},
+}
+
+// importUsage import分组的说明，格式与usage一致
+func importUsage() string {
+	var b strings.Builder
+	for _, a := range importActions {
+		fmt.Fprintf(&b, "  %-22s %s\n", "import "+a[0], a[1])
+	}
+	return b.String()
+}
+
+// importCmd 批量导入房地产，-wait时等待任务结束，任务中断时退出码为1
+func importCmd(client *Client, args []string, output string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprintf(stderr, "缺少动作，例如: realtyctl import upload -f registry.csv\n\n%s", importUsage())
+		return 2
+	}
+	action := args[0]
+	set := flag.NewFlagSet("import "+action, flag.ContinueOnError)
+	set.SetOutput(stderr)
+	id := set.String("id", "", "导入任务ID")
+	var file, out *string
+	var wait *bool
+	var interval *time.Duration
+	switch action {
+	case "upload":
+		file = set.String("f", "", "导入文件，.csv或.json，列为ref(可选)、proprietor、totalArea、livingSpace")
+		fallthrough
+	case "resume":
+		wait = set.Bool("wait", false, "等待任务结束并输出结果")
+		interval = set.Duration("interval", time.Second, "-wait时查询进度的间隔")
+	case "errors":
+		out = set.String("out", "", "错误报告保存的文件，不传则输出到标准输出")
+	case "list", "status":
+	default:
+		fmt.Fprintf(stderr, "不支持的命令import %s\n\n%s", action, importUsage())
+		return 2
+	}
+	if err := set.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if action != "upload" && action != "list" && *id == "" {
+		fmt.Fprintf(stderr, "import %s必须用-id指定导入任务\n", action)
+		return 2
+	}
+
+	var job map[string]interface{}
+	var err error
+	switch action {
+	case "upload":
+		if *file == "" {
+			fmt.Fprintln(stderr, "必须用-f指定导入文件")
+			return 2
+		}
+		var content []byte
+		if content, err = os.ReadFile(*file); err == nil {
+			err = client.Upload("", "/imports", "file", filepath.Base(*file), content, &job)
+		}
+	case "resume":
+		err = client.Call("", http.MethodPost, "/imports/"+*id+"/resume", nil, &job)
+	case "status":
+		err = client.Call("", http.MethodGet, "/imports/"+*id, nil, &job)
+	case "list":
+		var jobs interface{}
+		if err = client.Call("", http.MethodGet, "/imports", nil, &jobs); err == nil {
+			err = render(stdout, output, jobs, importColumns)
+		}
+		return exitCode(err, stderr)
+	case "errors":
+		var report []byte
+		if report, err = client.Download("", "/imports/"+*id+"/errors"); err == nil {
+			if *out != "" {
+				err = os.WriteFile(*out, report, 0644)
+			} else {
+				_, err = stdout.Write(report)
+			}
+		}
+		return exitCode(err, stderr)
+	}
+	if err == nil && wait != nil && *wait {
+		job, err = waitImport(client, fmt.Sprint(job["id"]), *interval)
+	}
+	if err == nil {
+		err = render(stdout, output, job, nil)
+	}
+	if code := exitCode(err, stderr); code != 0 {
+		return code
+	}
+	if job["status"] == "failed" {
+		return 1
+	}
+	return 0
+}
+
+// waitImport 轮询任务进度直到不再执行
+func waitImport(client *Client, id string, interval time.Duration) (map[string]interface{}, error) {
+	for {
+		var job map[string]interface{}
+		if err := client.Call("", http.MethodGet, "/imports/"+id, nil, &job); err != nil {
+			return nil, err
+		}
+		if job["status"] != "running" {
+			return job, nil
+		}
+		time.Sleep(interval)
+	}
+}
+
+func exitCode(err error, stderr io.Writer) int {
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,134 @@
+// realtyctl 通过REST接口调用房地产交易链码的命令行客户端
+//
+//	realtyctl account list
+//	realtyctl -profile prod selling create -object 2bd1a8f3e0f6c4d1 -price 500000 -period 30
+//	realtyctl batch -f ops.yaml
+//	realtyctl import upload -f registry.csv -wait
+//	realtyctl state export -out state.json
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run 解析全局参数后执行子命令，返回进程退出码
+func run(args []string, stdout io.Writer, stderr io.Writer) int {
+	global := flag.NewFlagSet("realtyctl", flag.ContinueOnError)
+	global.SetOutput(stderr)
+	configPath := global.String("config", defaultConfigPath(), "配置文件路径，也可使用环境变量REALTYCTL_CONFIG")
+	profileName := global.String("profile", os.Getenv("REALTYCTL_PROFILE"), "使用的环境，默认为配置文件中的current，也可使用环境变量REALTYCTL_PROFILE")
+	server := global.String("server", "", "覆盖环境的REST服务地址")
+	account := global.String("account", "", "覆盖环境的登录账户")
+	password := global.String("password", "", "覆盖环境的登录密码")
+	output := global.String("o", outputTable, "输出格式: table或json")
+	global.Usage = func() {
+		fmt.Fprint(stderr, usage())
+		fmt.Fprintln(stderr, "\n全局参数:")
+		global.PrintDefaults()
+	}
+	if err := global.Parse(args); err != nil {
+		return 2
+	}
+	if *output != outputTable && *output != outputJSON {
+		fmt.Fprintf(stderr, "不支持的输出格式%s\n", *output)
+		return 2
+	}
+	rest := global.Args()
+	if len(rest) == 0 {
+		global.Usage()
+		return 2
+	}
+
+	profile, err := loadProfile(*configPath, *profileName)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	if *server != "" {
+		profile.Server = *server
+	}
+	if *account != "" {
+		profile.Account = *account
+	}
+	if *password != "" {
+		profile.Password = *password
+	}
+	client := NewClient(profile)
+
+	if rest[0] == "batch" {
+		return batch(client, rest[1:], *output, stdout, stderr)
+	}
+	if rest[0] == "import" {
+		return importCmd(client, rest[1:], *output, stdout, stderr)
+	}
+	if rest[0] == "state" {
+		return stateCmd(client, rest[1:], *output, stdout, stderr)
+	}
+	if len(rest) < 2 {
+		fmt.Fprintf(stderr, "缺少动作，例如: realtyctl %s list\n", rest[0])
+		return 2
+	}
+	c := findCommand(rest[0], rest[1])
+	if c == nil {
+		fmt.Fprintf(stderr, "不支持的命令%s %s\n\n%s", rest[0], rest[1], usage())
+		return 2
+	}
+	values := make(map[string]string)
+	set := c.flagSet(values)
+	set.SetOutput(stderr)
+	if err := set.Parse(rest[2:]); err != nil {
+		return 2
+	}
+	if set.NArg() > 0 {
+		fmt.Fprintf(stderr, "无法识别的参数: %v\n", set.Args())
+		return 2
+	}
+	data, err := c.run(client, "", values)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	if err := render(stdout, *output, data, c.columns); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// batch 执行批量文件，有任意一行失败时退出码为1
+func batch(client *Client, args []string, output string, stdout io.Writer, stderr io.Writer) int {
+	set := flag.NewFlagSet("batch", flag.ContinueOnError)
+	set.SetOutput(stderr)
+	file := set.String("f", "", "批量文件，.yaml/.yml或.csv")
+	keepGoing := set.Bool("keep-going", false, "某一行失败后继续执行后面的行")
+	if err := set.Parse(args); err != nil {
+		return 2
+	}
+	if *file == "" {
+		fmt.Fprintln(stderr, "必须用-f指定批量文件")
+		return 2
+	}
+	steps, err := loadSteps(*file)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	results := runBatch(client, steps, *keepGoing)
+	if err := renderResults(stdout, output, results); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	for _, r := range results {
+		if !r.OK {
+			return 1
+		}
+	}
+	return 0
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// 输出格式
+const (
+	outputTable = "table"
+	outputJSON  = "json"
+)
+
+// render 按格式输出接口返回的数据，列表按columns输出表格，单条记录输出字段和值
+func render(w io.Writer, format string, data interface{}, columns []string) error {
+	if format == outputJSON {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		encoder.SetEscapeHTML(false)
+		return encoder.Encode(data)
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	switch v := data.(type) {
+	case nil:
+		fmt.Fprintln(tw, "成功")
+	case []interface{}:
+		if len(v) == 0 {
+			fmt.Fprintln(tw, "(无记录)")
+			break
+		}
+		if len(columns) == 0 {
+			columns = keys(v[0])
+		}
+		fmt.Fprintln(tw, strings.Join(columns, "\t"))
+		for _, row := range v {
+			cells := make([]string, 0, len(columns))
+			for _, column := range columns {
+				cells = append(cells, cell(lookup(row, column)))
+			}
+			fmt.Fprintln(tw, strings.Join(cells, "\t"))
+		}
+	case map[string]interface{}:
+		for _, field := range flatten("", v) {
+			fmt.Fprintf(tw, "%s\t%s\n", field, cell(lookup(v, field)))
+		}
+	default:
+		fmt.Fprintln(tw, cell(v))
+	}
+	return tw.Flush()
+}
+
+// lookup 按.连接的路径取嵌套字段
+func lookup(v interface{}, path string) interface{} {
+	for _, key := range strings.Split(path, ".") {
+		m, ok := v.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[key]
+	}
+	return v
+}
+
+// keys 记录的字段名，按字母排序
+func keys(v interface{}) []string {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return []string{""}
+	}
+	var names []string
+	for k := range m {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// flatten 展开嵌套记录的全部字段路径
+func flatten(prefix string, m map[string]interface{}) []string {
+	var fields []string
+	for _, k := range keys(m) {
+		if nested, ok := m[k].(map[string]interface{}); ok {
+			fields = append(fields, flatten(prefix+k+".", nested)...)
+			continue
+		}
+		fields = append(fields, prefix+k)
+	}
+	return fields
+}
+
+// cell 单元格内容，缺失的字段显示为-
+func cell(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return "-"
+	case string:
+		if v == "" {
+			return "-"
+		}
+		return v
+	case map[string]interface{}, []interface{}:
+		raw, _ := json.Marshal(v)
+		return string(raw)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Profile 一套环境的连接信息
+type Profile struct {
+	Server   string        `yaml:"server"`   //REST服务地址，如http://127.0.0.1:8000
+	Account  string        `yaml:"account"`  //登录账户
+	Password string        `yaml:"password"` //登录密码，也可使用环境变量REALTYCTL_PASSWORD
+	Language string        `yaml:"language"` //错误提示语言，zh或en
+	Insecure bool          `yaml:"insecure"` //跳过HTTPS证书校验，仅用于自签名证书的测试环境
+	Timeout  time.Duration `yaml:"timeout"`  //单个请求的超时时间
+}
+
+// Profiles 配置文件，current为默认使用的环境
+type Profiles struct {
+	Current  string             `yaml:"current"`
+	Profiles map[string]Profile `yaml:"profiles"`
+}
+
+// defaultProfile 没有配置文件时连接本地开发环境的管理员账户，密码需通过-password或REALTYCTL_PASSWORD指定
+func defaultProfile() Profile {
+	return Profile{
+		Server:   "http://127.0.0.1:8000",
+		Account:  "5feceb66ffc8",
+		Language: "zh",
+		Timeout:  30 * time.Second,
+	}
+}
+
+// defaultConfigPath 配置文件默认为~/.realtyctl.yaml，可用环境变量REALTYCTL_CONFIG指定
+func defaultConfigPath() string {
+	if path := os.Getenv("REALTYCTL_CONFIG"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ".realtyctl.yaml"
+	}
+	return filepath.Join(home, ".realtyctl.yaml")
+}
+
+// loadProfile 读取配置文件中的环境，name为空时使用current，配置文件不存在且未指定环境时使用默认环境
+// 未配置的字段取默认值，环境变量REALTYCTL_PASSWORD覆盖密码
+func loadProfile(path string, name string) (Profile, error) {
+	profile := defaultProfile()
+	raw, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		var profiles Profiles
+		if err := yaml.Unmarshal(raw, &profiles); err != nil {
+			return profile, fmt.Errorf("解析配置文件%s失败: %s", path, err)
+		}
+		if name == "" {
+			name = profiles.Current
+		}
+		if name != "" {
+			p, ok := profiles.Profiles[name]
+			if !ok {
+				return profile, fmt.Errorf("配置文件%s中没有环境%s", path, name)
+			}
+			merge(&profile, p)
+		}
+	case os.IsNotExist(err) && name == "":
+	case os.IsNotExist(err):
+		return profile, fmt.Errorf("配置文件%s不存在，无法使用环境%s", path, name)
+	default:
+		return profile, fmt.Errorf("读取配置文件失败: %s", err)
+	}
+	if password := os.Getenv("REALTYCTL_PASSWORD"); password != "" {
+		profile.Password = password
+	}
+	return profile, nil
+}
+
+// merge 用p中已配置的字段覆盖profile
+func merge(profile *Profile, p Profile) {
+	if p.Server != "" {
+		profile.Server = p.Server
+	}
+	if p.Account != "" {
+		profile.Account = p.Account
+	}
+	if p.Password != "" {
+		profile.Password = p.Password
+	}
+	if p.Language != "" {
+		profile.Language = p.Language
+	}
+	if p.Timeout > 0 {
+		profile.Timeout = p.Timeout
+	}
+	profile.Insecure = p.Insecure
+}
@@ -0,0 +1,285 @@
+package main
+
+import (
+	"application/blockchain"
+	_ "application/blockchain/inprocess"
+	"application/pkg/auth"
+	"application/pkg/importer"
+	"application/pkg/setting"
+	"application/routers"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 链码初始化的账户
+const (
+	admin  = "5feceb66ffc8"
+	owner1 = "6b86b273ff34"
+	owner3 = "4e07408562be"
+)
+
+var dir string
+
+// password 测试中各账户的登录密码
+const password = "realtyctl"
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard
+	var err error
+	dir, err = os.MkdirTemp("", "realtyctl")
+	if err != nil {
+		log.Fatal(err)
+	}
+	cfg := setting.Default().Auth
+	cfg.DBPath = filepath.Join(dir, "auth.db")
+	cfg.JWTSecret = "realtyctl"
+	auth.Init(cfg)
+	for _, accountId := range []string{admin, owner1, owner3} {
+		if err := auth.Default().SetPassword(accountId, password); err != nil {
+			log.Fatal(err)
+		}
+	}
+	importCfg := setting.Default().Import
+	importCfg.DBPath = filepath.Join(dir, "imports.db")
+	importer.Init(importCfg)
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// cli 指向进程内账本上完整路由的命令行，每个测试使用新的账本
+type cli struct {
+	t      *testing.T
+	server string
+	config string
+}
+
+func newCLI(t *testing.T) *cli {
+	cfg := setting.Default().Fabric
+	cfg.Ledger = "inprocess"
+	blockchain.Init(cfg, "error")
+	ts := httptest.NewServer(routers.InitRouter())
+	t.Cleanup(ts.Close)
+	return &cli{t: t, server: ts.URL, config: filepath.Join(dir, "missing.yaml")}
+}
+
+// run 执行命令，返回退出码、标准输出和标准错误
+func (c *cli) run(args ...string) (int, string, string) {
+	var stdout, stderr bytes.Buffer
+	code := run(append([]string{"-config", c.config, "-server", c.server, "-password", password}, args...), &stdout, &stderr)
+	return code, stdout.String(), stderr.String()
+}
+
+// json 执行必须成功的命令，按JSON输出解析到v
+func (c *cli) json(v interface{}, args ...string) {
+	c.t.Helper()
+	code, stdout, stderr := c.run(append([]string{"-o", "json"}, args...)...)
+	if code != 0 {
+		c.t.Fatalf("%v 退出码%d: %s", args, code, stderr)
+	}
+	if err := json.Unmarshal([]byte(stdout), v); err != nil {
+		c.t.Fatalf("%v 输出不是JSON: %s", args, stdout)
+	}
+}
+
+func (c *cli) write(name string, content string) string {
+	path := filepath.Join(c.t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		c.t.Fatal(err)
+	}
+	return path
+}
+
+// 测试命令行完成一次销售，并以表格输出余额
+func TestSelling(t *testing.T) {
+	c := newCLI(t)
+	var realEstate struct {
+		RealEstateId string `json:"realEstateId"`
+	}
+	c.json(&realEstate, "realestate", "create", "-proprietor", owner1, "-total-area", "100", "-living-space", "80")
+	if realEstate.RealEstateId == "" {
+		t.Fatal("未返回房地产ID")
+	}
+	c.json(new(interface{}), "-account", owner1, "selling", "create", "-object", realEstate.RealEstateId, "-price", "500000", "-period", "30")
+	c.json(new(interface{}), "-account", owner3, "selling", "buy", "-object", realEstate.RealEstateId, "-seller", owner1)
+	c.json(new(interface{}), "-account", owner1, "selling", "confirm", "-object", realEstate.RealEstateId, "-buyer", owner3)
+
+	code, stdout, stderr := c.run("account", "list", "-ids", owner1+","+owner3)
+	if code != 0 {
+		t.Fatalf("查询账户失败: %s", stderr)
+	}
+	lines := strings.Split(strings.TrimSpace(stdout), "\n")
+	if len(lines) != 3 || strings.Fields(lines[0])[0] != "accountId" {
+		t.Fatalf("表格输出不符合预期:\n%s", stdout)
+	}
+	balances := make(map[string]string)
+	for _, line := range lines[1:] {
+		fields := strings.Fields(line)
+		balances[fields[0]] = fields[len(fields)-1]
+	}
+	if balances[owner1] != "5500000" || balances[owner3] != "4500000" {
+		t.Errorf("余额不符合预期: %v", balances)
+	}
+	var owned []map[string]interface{}
+	c.json(&owned, "realestate", "list", "-proprietor", owner3)
+	if len(owned) != 1 || owned[0]["realEstateId"] != realEstate.RealEstateId {
+		t.Errorf("房产应过户给买家: %v", owned)
+	}
+}
+
+// 测试参数校验和链码错误的输出
+func TestErrors(t *testing.T) {
+	c := newCLI(t)
+	if code, _, stderr := c.run("selling", "create", "-price", "1"); code != 1 || !strings.Contains(stderr, "缺少参数object") {
+		t.Errorf("缺少参数时应当失败: %d %s", code, stderr)
+	}
+	if code, _, _ := c.run("selling", "sell"); code != 2 {
+		t.Errorf("不支持的命令退出码应为2，实际%d", code)
+	}
+	code, _, stderr := c.run("-account", owner1, "realestate", "create", "-proprietor", owner3, "-total-area", "1", "-living-space", "1")
+	if code != 1 || !strings.Contains(stderr, "403") {
+		t.Errorf("非管理员新建房地产应当被拒绝: %d %s", code, stderr)
+	}
+	code, _, stderr = c.run("-account", owner1, "selling", "buy", "-object", "none", "-seller", owner3)
+	if code != 1 || !strings.Contains(stderr, "404") || !strings.Contains(stderr, "NOT_FOUND") {
+		t.Errorf("链码错误应当输出状态码和错误码: %d %s", code, stderr)
+	}
+	if code, _, stderr := c.run("-password", "wrong", "account", "me"); code != 1 || !strings.Contains(stderr, "登录失败") {
+		t.Errorf("密码错误应当登录失败: %d %s", code, stderr)
+	}
+}
+
+// 测试YAML批量文件，后面的行引用前面各行的结果
+func TestBatchYAML(t *testing.T) {
+	c := newCLI(t)
+	path := c.write("ops.yaml", `
+- command: realestate create
+  args: {proprietor: `+owner1+`, total-area: 100, living-space: 80}
+- command: donating create
+  account: `+owner1+`
+  args: {object: "{{1.realEstateId}}", grantee: `+owner3+`}
+- command: donating accept
+  account: `+owner3+`
+  args: {object: "{{1.realEstateId}}", donor: `+owner1+`}
+- command: realestate list
+  args: {proprietor: `+owner3+`}
+`)
+	var results []result
+	c.json(&results, "batch", "-f", path)
+	if len(results) != 4 {
+		t.Fatalf("期望执行4行，实际%d行: %+v", len(results), results)
+	}
+	owned, _ := results[3].Data.([]interface{})
+	if len(owned) != 1 || lookup(owned[0], "realEstateId") != lookup(results[0].Data, "realEstateId") {
+		t.Errorf("捐赠后房产应在受赠人名下: %+v", results[3].Data)
+	}
+}
+
+// 测试CSV批量文件，失败的行默认停止执行，-keep-going时继续
+func TestBatchCSV(t *testing.T) {
+	c := newCLI(t)
+	path := c.write("ops.csv", `command,account,proprietor,total-area,living-space,object,price,period
+realestate create,,`+owner1+`,100,80,,,
+selling create,`+owner1+`,,,,none,100,30
+realestate create,,`+owner3+`,60,40,,,
+`)
+	code, stdout, _ := c.run("batch", "-f", path)
+	if code != 1 || strings.Count(stdout, "\n") != 3 || !strings.Contains(stdout, "失败") {
+		t.Errorf("第2行失败后应停止执行: %d\n%s", code, stdout)
+	}
+	code, stdout, _ = c.run("batch", "-keep-going", "-f", path)
+	if code != 1 || strings.Count(stdout, "\n") != 4 {
+		t.Errorf("-keep-going时应执行全部3行: %d\n%s", code, stdout)
+	}
+	var all []interface{}
+	c.json(&all, "realestate", "list")
+	if len(all) != 3 {
+		t.Errorf("两次批量执行应共新建3个房地产，实际%d个", len(all))
+	}
+}
+
+// 测试上传文件批量导入，等待任务结束后下载错误报告
+func TestImport(t *testing.T) {
+	c := newCLI(t)
+	path := c.write("registry.json", `[
+  {"ref": "J-1", "proprietor": "`+owner1+`", "totalArea": 100, "livingSpace": 80},
+  {"ref": "J-2", "proprietor": "`+admin+`", "totalArea": 100, "livingSpace": 80}
+]`)
+	var job map[string]interface{}
+	c.json(&job, "import", "upload", "-f", path, "-wait", "-interval", "10ms")
+	if job["status"] != "done" || fmt.Sprint(job["created"]) != "1" || fmt.Sprint(job["failed"]) != "1" {
+		t.Fatalf("导入结果不符合预期: %v", job)
+	}
+	report := filepath.Join(t.TempDir(), "errors.csv")
+	if code, _, stderr := c.run("import", "errors", "-id", fmt.Sprint(job["id"]), "-out", report); code != 0 {
+		t.Fatalf("下载错误报告失败: %s", stderr)
+	}
+	content, _ := os.ReadFile(report)
+	if !strings.Contains(string(content), "2,J-2,"+admin+",100,80,VALIDATION,realEstate.sameAccount") {
+		t.Errorf("错误报告不符合预期:\n%s", content)
+	}
+	if code, _, stderr := c.run("-account", owner1, "import", "list"); code != 1 || !strings.Contains(stderr, "403") {
+		t.Errorf("非管理员不能查询导入任务: %d %s", code, stderr)
+	}
+	if code, _, _ := c.run("import", "status"); code != 2 {
+		t.Errorf("缺少-id时退出码应为2，实际%d", code)
+	}
+}
+
+// 测试导出账本状态后在新网络中导入，只能导入一次
+func TestState(t *testing.T) {
+	c := newCLI(t)
+	c.json(new(interface{}), "realestate", "create", "-proprietor", owner1, "-total-area", "100", "-living-space", "80")
+	path := filepath.Join(t.TempDir(), "state.json")
+	if code, _, stderr := c.run("state", "export", "-out", path); code != 0 {
+		t.Fatalf("导出失败: %s", stderr)
+	}
+	c = newCLI(t)
+	var imported map[string]interface{}
+	c.json(&imported, "state", "import", "-f", path)
+	if counts, _ := imported["counts"].(map[string]interface{}); fmt.Sprint(counts["realEstate"]) != "1" {
+		t.Fatalf("导入结果不符合预期: %v", imported)
+	}
+	if code, _, stderr := c.run("state", "import", "-f", path); code != 1 || !strings.Contains(stderr, "state.notFirstRun") {
+		t.Errorf("重复导入应失败: %d %s", code, stderr)
+	}
+}
+
+// 测试配置文件中的环境
+func TestProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "realtyctl.yaml")
+	if err := os.WriteFile(path, []byte(`
+current: local
+profiles:
+  local:
+    server: http://127.0.0.1:8000
+  prod:
+    server: https://realty.example.com
+    account: `+owner1+`
+    timeout: 5s
+`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	local, err := loadProfile(path, "")
+	if err != nil || local.Account != admin || local.Password != "" {
+		t.Errorf("未配置的字段应取默认值: %+v %v", local, err)
+	}
+	prod, err := loadProfile(path, "prod")
+	if err != nil || prod.Server != "https://realty.example.com" || prod.Account != owner1 || prod.Timeout.Seconds() != 5 {
+		t.Errorf("环境prod不符合预期: %+v %v", prod, err)
+	}
+	if _, err := loadProfile(path, "test"); err == nil {
+		t.Error("不存在的环境应当报错")
+	}
+}
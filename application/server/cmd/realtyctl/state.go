@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// stateActions state分组的动作，该分组下载或上传导出文件，不走commands的JSON请求
+var stateActions = [][2]string{
+	{"export", "导出链码的全部状态(管理员)"},
+	{"import", "用导出文件初始化新网络，只能执行一次(管理员)"},
+}
+
+// stateUsage state分组的说明，格式与usage一致
+func stateUsage() string {
+	var b strings.Builder
+	for _, a := range stateActions {
+		fmt.Fprintf(&b, "  %-22s %s\n", "state "+a[0], a[1])
+	}
+	return b.String()
+}
+
+// stateCmd 导出、导入账本状态，用于复现测试和演示环境
+func stateCmd(client *Client, args []string, output string, stdout io.Writer, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprintf(stderr, "缺少动作，例如: realtyctl state export -out state.json\n\n%s", stateUsage())
+		return 2
+	}
+	action := args[0]
+	set := flag.NewFlagSet("state "+action, flag.ContinueOnError)
+	set.SetOutput(stderr)
+	var file, out *string
+	switch action {
+	case "export":
+		out = set.String("out", "", "导出文件保存的路径，不传则输出到标准输出")
+	case "import":
+		file = set.String("f", "", "state export导出的文件")
+	default:
+		fmt.Fprintf(stderr, "不支持的命令state %s\n\n%s", action, stateUsage())
+		return 2
+	}
+	if err := set.Parse(args[1:]); err != nil {
+		return 2
+	}
+
+	var err error
+	switch action {
+	case "export":
+		var dump []byte
+		if dump, err = client.Download("", "/state"); err == nil {
+			if *out != "" {
+				err = os.WriteFile(*out, dump, 0644)
+			} else {
+				_, err = stdout.Write(dump)
+			}
+		}
+	case "import":
+		if *file == "" {
+			fmt.Fprintln(stderr, "必须用-f指定导出文件")
+			return 2
+		}
+		var content []byte
+		var imported map[string]interface{}
+		if content, err = os.ReadFile(*file); err == nil {
+			err = client.Upload("", "/state", "file", filepath.Base(*file), content, &imported)
+		}
+		if err == nil {
+			err = render(stdout, output, imported, nil)
+		}
+	}
+	return exitCode(err, stderr)
+}
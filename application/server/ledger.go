@@ -0,0 +1,7 @@
+package main
+
+// 编译两种账本实现，由配置项fabric.ledger选择：fabric连接区块链网络，inprocess在进程内运行房地产交易链码
+import (
+	_ "application/blockchain/fabric"
+	_ "application/blockchain/inprocess"
+)
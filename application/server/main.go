@@ -1,14 +1,21 @@
 package main
 
 import (
-	"fmt"
 	"log"
 	"net/http"
+	"os"
 	"time"
 
 	"application/blockchain"
+	"application/pkg/auth"
 	"application/pkg/cron"
+	"application/pkg/export"
+	"application/pkg/importer"
+	"application/pkg/readmodel"
+	"application/pkg/setting"
 	"application/routers"
+
+	"github.com/gin-gonic/gin"
 )
 
 func main() {
@@ -18,16 +25,37 @@ func main() {
 	}
 	time.Local = timeLocal
 
-	blockchain.Init()
-	go cron.Init()
+	cfg, err := setting.Load(os.Args[1:])
+	if err != nil {
+		log.Fatalf("加载配置失败 %s", err)
+	}
+	if cfg.Log.Level == "debug" {
+		gin.SetMode(gin.DebugMode)
+	} else {
+		gin.SetMode(gin.ReleaseMode)
+	}
+
+	blockchain.Init(cfg.Fabric, cfg.Log.Level)
+	auth.Init(cfg.Auth)
+	readmodel.Init(cfg.ReadModel)
+	go readmodel.Run()
+	importer.Init(cfg.Import)
+	export.Init(cfg.Export)
+	if cfg.Cron.Enabled {
+		go cron.Init(cfg.Cron.Spec)
+	}
 
-	endPoint := fmt.Sprintf("0.0.0.0:%d", 8888)
 	server := &http.Server{
-		Addr:    endPoint,
+		Addr:    cfg.Server.Listen,
 		Handler: routers.InitRouter(),
 	}
-	log.Printf("[info] start http server listening %s", endPoint)
-	if err := server.ListenAndServe(); err != nil {
+	log.Printf("[info] start http server listening %s", cfg.Server.Listen)
+	if cfg.Server.TLS.Enabled {
+		err = server.ListenAndServeTLS(cfg.Server.TLS.CertFile, cfg.Server.TLS.KeyFile)
+	} else {
+		err = server.ListenAndServe()
+	}
+	if err != nil {
 		log.Printf("start http server failed %s", err)
 	}
 }
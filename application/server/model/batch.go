@@ -0,0 +1,40 @@
+package model
+
+// RealEstateRow 批量新建房地产的一行，与链码batchCreateRealEstate的参数一致
+type RealEstateRow struct {
+	Ref         string  `json:"ref"`         //外部编号，同一编号只导入一次
+	Proprietor  string  `json:"proprietor"`  //所有者(业主AccountId)
+	TotalArea   float64 `json:"totalArea"`   //总面积
+	LivingSpace float64 `json:"livingSpace"` //生活空间
+}
+
+// 批量新建每一行的结果
+const (
+	RowCreated = "created" //新建成功
+	RowExists  = "exists"  //外部编号已导入过
+	RowFailed  = "failed"  //校验失败，未写入账本
+)
+
+// RowError 某一行校验失败的原因
+type RowError struct {
+	Code    string `json:"code"`
+	Key     string `json:"key"`
+	Message string `json:"message"`
+}
+
+// RowResult 批量新建某一行的结果，Index为该行在本批中的下标
+type RowResult struct {
+	Index      int         `json:"index"`
+	Ref        string      `json:"ref,omitempty"`
+	Status     string      `json:"status"`
+	RealEstate *RealEstate `json:"realEstate,omitempty"`
+	Error      *RowError   `json:"error,omitempty"`
+}
+
+// BatchResult 链码batchCreateRealEstate的返回结果
+type BatchResult struct {
+	Created  int         `json:"created"`
+	Existing int         `json:"existing"`
+	Failed   int         `json:"failed"`
+	Results  []RowResult `json:"results"`
+}
@@ -0,0 +1,86 @@
+package model
+
+import "encoding/json"
+
+// EventName 链码事件名，链码每笔交易只写出一个事件，交易内的所有状态变化合并为一个EventBatch
+const EventName = "realty"
+
+// EventVersion 事件结构版本，结构发生不兼容变化时递增
+// 与链码chaincode/model/event.go保持一致，两边需同步修改
+const EventVersion = 2
+
+// EventType 事件类型
+type EventType string
+
+const (
+	AccountCreated        EventType = "AccountCreated"        //账户创建，Payload为Account的公开部分
+	BalanceChanged        EventType = "BalanceChanged"        //余额变化，Payload为BalanceChangedPayload
+	RealEstateCreated     EventType = "RealEstateCreated"     //房地产创建，Payload为RealEstate
+	RealEstateTransferred EventType = "RealEstateTransferred" //房地产过户，Payload为RealEstateTransferredPayload
+	SellingCreated        EventType = "SellingCreated"        //发起销售，Payload为Selling的公开部分
+	SellingPurchased      EventType = "SellingPurchased"      //买家购买(进入交付中)，Payload为SellingBuy的公开部分
+	SellingCompleted      EventType = "SellingCompleted"      //卖家确认收款，Payload为SellingBuy的公开部分
+	SellingCancelled      EventType = "SellingCancelled"      //销售取消，Payload为SellingClosedPayload
+	SellingExpired        EventType = "SellingExpired"        //销售过期，Payload为SellingClosedPayload
+	DonationCreated       EventType = "DonationCreated"       //发起捐赠，Payload为Donating
+	DonationAccepted      EventType = "DonationAccepted"      //受赠人确认受赠，Payload为Donating
+	DonationCancelled     EventType = "DonationCancelled"     //捐赠取消，Payload为Donating
+	StateImported         EventType = "StateImported"         //导入账本状态，Payload为StateDump，记录只有公开部分
+	RecordsMigrated       EventType = "RecordsMigrated"       //记录结构迁移，Payload为MigrationBatch，记录只有公开部分
+)
+
+// EventBatch 一笔交易产生的全部事件
+type EventBatch struct {
+	Version   int     `json:"version"`   //事件结构版本
+	TxID      string  `json:"txId"`      //交易ID
+	Timestamp string  `json:"timestamp"` //交易时间
+	Events    []Event `json:"events"`    //按发生顺序排列的事件
+}
+
+// Event 单个事件
+// Accounts为受该事件影响的账户，便于应用层按账户推送
+// 事件中没有账号名、余额和价格，读模型通过链码的私有数据查询补齐
+type Event struct {
+	Type     EventType       `json:"type"`     //事件类型
+	Accounts []string        `json:"accounts"` //相关账户AccountId
+	Payload  json.RawMessage `json:"payload"`  //事件内容，结构由Type决定
+}
+
+// BalanceChangedPayload 余额变化，变化后的余额通过链码Account:QueryAccountPrivate读取
+type BalanceChangedPayload struct {
+	AccountId   string `json:"accountId"`   //账号ID
+	PrivateHash string `json:"privateHash"` //变化后私有部分的哈希
+	Reason      string `json:"reason"`      //变化原因(purchase/income/refund)
+}
+
+// RealEstateTransferredPayload 房地产过户
+type RealEstateTransferredPayload struct {
+	RealEstateID string `json:"realEstateId"` //房地产ID
+	From         string `json:"from"`         //原所有者AccountId
+	To           string `json:"to"`           //新所有者AccountId
+	Reason       string `json:"reason"`       //过户原因(selling/donating)
+}
+
+// SellingClosedPayload 销售取消或过期，交付中关闭时Buyer为获得退款的买家，退款金额即销售价格
+type SellingClosedPayload struct {
+	Selling Selling `json:"selling"` //销售的公开部分
+	Buyer   string  `json:"buyer"`   //退款的买家AccountId，销售中关闭时为空
+}
+
+// 余额变化原因
+const (
+	BalanceReasonPurchase = "purchase" //购买扣款
+	BalanceReasonIncome   = "income"   //卖家收款
+	BalanceReasonRefund   = "refund"   //取消退款
+)
+
+// 过户原因
+const (
+	TransferReasonSelling  = "selling"
+	TransferReasonDonating = "donating"
+)
+
+// Decode 按事件类型将Payload反序列化到v
+func (e Event) Decode(v interface{}) error {
+	return json.Unmarshal(e.Payload, v)
+}
@@ -1,17 +1,35 @@
 package model
 
+// Account 账户，虚拟管理员和若干业主账号
+type Account struct {
+	AccountId string  `json:"accountId"`      //账号ID
+	UserName  string  `json:"userName"`       //账号名
+	Balance   float64 `json:"balance"`        //余额
+	Role      string  `json:"role,omitempty"` //角色，admin或owner，旧记录为空
+}
+
+// RealEstate 房地产作为担保出售、捐赠或质押时Encumbrance为true，默认状态false。
+type RealEstate struct {
+	RealEstateID string  `json:"realEstateId"` //房地产ID
+	Proprietor   string  `json:"proprietor"`   //所有者(业主)(业主AccountId)
+	Encumbrance  bool    `json:"encumbrance"`  //是否作为担保
+	TotalArea    float64 `json:"totalArea"`    //总面积
+	LivingSpace  float64 `json:"livingSpace"`  //生活空间
+}
+
 // Selling 销售要约
 // 需要确定ObjectOfSale是否属于Seller
 // 买家初始为空
 // Seller和ObjectOfSale一起作为复合键,保证可以通过seller查询到名下所有发起的销售
 type Selling struct {
-	ObjectOfSale  string  `json:"objectOfSale"`  //销售对象(正在出售的房地产RealEstateID)
-	Seller        string  `json:"seller"`        //发起销售人、卖家(卖家AccountId)
-	Buyer         string  `json:"buyer"`         //参与销售人、买家(买家AccountId)
-	Price         float64 `json:"price"`         //价格
-	CreateTime    string  `json:"createTime"`    //创建时间
-	SalePeriod    int     `json:"salePeriod"`    //智能合约的有效期(单位为天)
-	SellingStatus string  `json:"sellingStatus"` //销售状态
+	ObjectOfSale  string  `json:"objectOfSale"`       //销售对象(正在出售的房地产RealEstateID)
+	Seller        string  `json:"seller"`             //发起销售人、卖家(卖家AccountId)
+	Buyer         string  `json:"buyer"`              //参与销售人、买家(买家AccountId)
+	Price         float64 `json:"price"`              //价格
+	CreateTime    string  `json:"createTime"`         //创建时间
+	SalePeriod    int     `json:"salePeriod"`         //智能合约的有效期(单位为天)
+	SellingStatus string  `json:"sellingStatus"`      //销售状态
+	DoneTime      string  `json:"doneTime,omitempty"` //卖家确认收款的时间，旧记录为空
 }
 
 // SellingStatusConstant 销售状态
@@ -25,15 +43,24 @@ var SellingStatusConstant = func() map[string]string {
 	}
 }
 
+// SellingBuy 买家参与销售
+type SellingBuy struct {
+	Buyer      string  `json:"buyer"`          //参与销售人、买家(买家AccountId)
+	CreateTime string  `json:"createTime"`     //创建时间
+	TxID       string  `json:"txId,omitempty"` //购买时的交易ID，旧记录为空
+	Selling    Selling `json:"selling"`        //销售对象
+}
+
 // Donating 捐赠要约
 // 需要确定ObjectOfDonating是否属于Donor
 // 需要指定受赠人Grantee，并等待受赠人同意接收
 type Donating struct {
-	ObjectOfDonating string `json:"objectOfDonating"` //捐赠对象(正在捐赠的房地产RealEstateID)
-	Donor            string `json:"donor"`            //捐赠人(捐赠人AccountId)
-	Grantee          string `json:"grantee"`          //受赠人(受赠人AccountId)
-	CreateTime       string `json:"createTime"`       //创建时间
-	DonatingStatus   string `json:"donatingStatus"`   //捐赠状态
+	ObjectOfDonating string `json:"objectOfDonating"`   //捐赠对象(正在捐赠的房地产RealEstateID)
+	Donor            string `json:"donor"`              //捐赠人(捐赠人AccountId)
+	Grantee          string `json:"grantee"`            //受赠人(受赠人AccountId)
+	CreateTime       string `json:"createTime"`         //创建时间
+	DonatingStatus   string `json:"donatingStatus"`     //捐赠状态
+	DoneTime         string `json:"doneTime,omitempty"` //受赠人确认受赠的时间，旧记录为空
 }
 
 // DonatingStatusConstant 捐赠状态
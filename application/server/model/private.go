@@ -0,0 +1,25 @@
+package model
+
+// TransientOffer 发起销售时价格所在的临时数据键，与链码chaincode/model/private.go保持一致
+const TransientOffer = "offer"
+
+// OfferInput 通过临时数据传入的销售价格
+type OfferInput struct {
+	Price float64 `json:"price"` //价格
+}
+
+// AccountPrivate 账户的私有部分(账号名和余额)，由链码Account:QueryAccountPrivate返回
+type AccountPrivate struct {
+	AccountId   string  `json:"accountId"`   //账号ID
+	UserName    string  `json:"userName"`    //账号名
+	Balance     float64 `json:"balance"`     //余额
+	PrivateHash string  `json:"privateHash"` //公开记录中的哈希
+}
+
+// OfferPrivate 销售的私有部分(价格)，由链码Selling:QueryOfferPrivate返回
+type OfferPrivate struct {
+	ObjectOfSale string  `json:"objectOfSale"` //销售对象
+	Seller       string  `json:"seller"`       //卖家
+	Price        float64 `json:"price"`        //价格
+	PrivateHash  string  `json:"privateHash"`  //公开记录中的哈希
+}
@@ -0,0 +1,10 @@
+package model
+
+// SeedRecord 链码Init写入种子数据的记录，由Ledger:QuerySeed查询，与链码chaincode/model/seed.go保持一致
+type SeedRecord struct {
+	Digest      string  `json:"digest"`
+	TxID        string  `json:"txId"`
+	Accounts    int     `json:"accounts"`
+	RealEstates int     `json:"realEstates"`
+	MoneySupply float64 `json:"moneySupply"` //货币总量，即种子数据中各账户的初始余额之和
+}
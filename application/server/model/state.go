@@ -0,0 +1,46 @@
+package model
+
+import "encoding/json"
+
+// StateDumpVersion 账本导出文件的格式版本
+// 与链码chaincode/model/state.go保持一致，两边需同步修改
+const StateDumpVersion = 1
+
+// 导出文件中的复合主键前缀，与链码chaincode/model/model.go保持一致
+const (
+	AccountKey         = "account-key"
+	RealEstateKey      = "real-estate-key"
+	SellingKey         = "selling-key"
+	SellingBuyKey      = "selling-buy-key"
+	DonatingKey        = "donating-key"
+	DonatingGranteeKey = "donating-grantee-key"
+	ImportRefKey       = "import-ref-key"
+)
+
+// StateDump 链码的全部状态，由链码exportState导出，importState在新网络中恢复
+type StateDump struct {
+	Version       int          `json:"version"`       //导出文件的格式版本
+	SchemaVersion int          `json:"schemaVersion"` //导出时链码的记录结构版本
+	Entries       []StateEntry `json:"entries"`
+}
+
+// StateEntry 账本中的一条记录，Value为账本中的原始json
+type StateEntry struct {
+	ObjectType string          `json:"objectType"` //复合主键前缀
+	Keys       []string        `json:"keys"`       //复合主键的各部分
+	Value      json.RawMessage `json:"value"`
+}
+
+// StateImport importState的执行记录
+type StateImport struct {
+	Operator string         `json:"operator"` //执行导入的管理员
+	TxID     string         `json:"txId"`     //导入时的交易ID
+	Counts   map[string]int `json:"counts"`   //各记录类型导入的条数
+}
+
+// MigrationBatch 链码migrate一批迁移后的记录，与链码chaincode/model/migration.go保持一致
+type MigrationBatch struct {
+	From    int          `json:"from"`
+	To      int          `json:"to"`
+	Entries []StateEntry `json:"entries"`
+}
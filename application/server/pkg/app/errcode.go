@@ -0,0 +1,139 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// ChaincodeError 链码返回的错误信封，与chaincode/pkg/errcode保持一致
+type ChaincodeError struct {
+	Code    string                 `json:"code"`
+	Key     string                 `json:"key"`
+	Message string                 `json:"message"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// ErrorBody 返回给前端的错误详情
+type ErrorBody struct {
+	Code    string                 `json:"code"`    //错误码
+	Key     string                 `json:"key"`     //消息键
+	Message Messages               `json:"message"` //中英文提示
+	Detail  string                 `json:"detail"`  //链码给出的原始描述
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+// Messages 中英文提示
+type Messages struct {
+	Zh string `json:"zh"`
+	En string `json:"en"`
+}
+
+// codeStatus 错误码对应的HTTP状态码
+var codeStatus = map[string]int{
+	"VALIDATION":         http.StatusBadRequest,
+	"UNAUTHENTICATED":    http.StatusUnauthorized,
+	"FORBIDDEN":          http.StatusForbidden,
+	"NOT_FOUND":          http.StatusNotFound,
+	"CONFLICT":           http.StatusConflict,
+	"INSUFFICIENT_FUNDS": http.StatusUnprocessableEntity,
+	"INTERNAL":           http.StatusInternalServerError,
+}
+
+// codeMessages 消息键不在目录中时，按错误码给出的默认提示
+var codeMessages = map[string]Messages{
+	"VALIDATION":         {Zh: "参数校验失败", En: "Invalid request parameters"},
+	"UNAUTHENTICATED":    {Zh: "操作人身份验证失败", En: "Operator could not be authenticated"},
+	"FORBIDDEN":          {Zh: "操作人权限不足", En: "Operation not permitted"},
+	"NOT_FOUND":          {Zh: "记录不存在", En: "Record not found"},
+	"CONFLICT":           {Zh: "记录当前状态不允许该操作", En: "Record state does not allow this operation"},
+	"INSUFFICIENT_FUNDS": {Zh: "余额不足", En: "Insufficient balance"},
+	"INTERNAL":           {Zh: "链码内部错误", En: "Internal chaincode error"},
+}
+
+// keyMessages 消息目录
+var keyMessages = map[string]Messages{
+	"function.unknown":         {Zh: "调用的链码方法不存在", En: "Unknown chaincode function"},
+	"args.count":               {Zh: "参数个数不满足", En: "Wrong number of arguments"},
+	"args.empty":               {Zh: "参数存在空值", En: "Arguments must not be empty"},
+	"args.format":              {Zh: "参数格式转换出错", En: "Malformed argument"},
+	"args.required":            {Zh: "缺少必填参数", En: "Missing required argument"},
+	"filter.invalid":           {Zh: "筛选条件不合法", En: "Invalid filter"},
+	"status.unsupported":       {Zh: "状态不支持", En: "Unsupported status"},
+	"auth.operatorNotFound":    {Zh: "操作人不存在", En: "Operator account not found"},
+	"auth.notAdmin":            {Zh: "该操作仅限管理员", En: "Only the administrator may perform this operation"},
+	"auth.noAccountAttr":       {Zh: "调用者证书中没有平台账户，不能执行管理员操作", En: "Caller certificate carries no platform account"},
+	"auth.operatorMismatch":    {Zh: "操作人与调用者证书中的账户不一致", En: "Operator does not match the caller certificate"},
+	"account.notFound":         {Zh: "账户不存在", En: "Account not found"},
+	"realEstate.notFound":      {Zh: "房地产不存在", En: "Real estate not found"},
+	"realEstate.encumbered":    {Zh: "房地产已作为担保，无法操作", En: "Real estate is encumbered"},
+	"realEstate.sameAccount":   {Zh: "操作人不能是业主本人", En: "Operator must not be the proprietor"},
+	"realEstate.area":          {Zh: "总面积和生活空间必须大于0，且生活空间不大于总面积", En: "Total area and living space must be positive, and living space must not exceed total area"},
+	"realEstate.batchSize":     {Zh: "批量新建的条数超出范围", En: "Batch size out of range"},
+	"importRef.duplicate":      {Zh: "外部编号在本批中重复", En: "Duplicate reference in batch"},
+	"selling.notFound":         {Zh: "销售不存在", En: "Selling not found"},
+	"selling.notOnSale":        {Zh: "此交易不属于销售中状态", En: "Selling is not on sale"},
+	"selling.notInDelivery":    {Zh: "此交易不属于交付中状态", En: "Selling is not in delivery"},
+	"selling.closed":           {Zh: "此交易已结束", En: "Selling is already closed"},
+	"selling.adminBuy":         {Zh: "管理员不能购买", En: "The administrator cannot buy"},
+	"selling.sameAccount":      {Zh: "买家和卖家不能同一人", En: "Buyer and seller must differ"},
+	"sellingBuy.notFound":      {Zh: "购买记录不存在", En: "Purchase not found"},
+	"donating.notFound":        {Zh: "捐赠不存在", En: "Donation not found"},
+	"donating.notStarted":      {Zh: "此捐赠不属于捐赠中状态", En: "Donation is not in progress"},
+	"donating.toAdmin":         {Zh: "不能捐赠给管理员", En: "Cannot donate to the administrator"},
+	"donating.sameAccount":     {Zh: "捐赠人和受赠人不能同一人", En: "Donor and grantee must differ"},
+	"donatingGrantee.notFound": {Zh: "受赠记录不存在", En: "Donation receipt not found"},
+	"balance.insufficient":     {Zh: "余额不足，购买失败", En: "Insufficient balance for this purchase"},
+	"state.notFirstRun":        {Zh: "只能在新网络中导入一次账本状态", En: "Ledger state can only be imported once into a fresh network"},
+	"state.version":            {Zh: "导出文件的版本与链码不兼容", En: "State dump version is not supported by the chaincode"},
+	"state.invalid":            {Zh: "导出文件中的记录不合法", En: "Invalid record in state dump"},
+	"state.noAdmin":            {Zh: "导出文件中没有当前管理员账户", En: "State dump does not contain the operating administrator"},
+	"seed.invalid":             {Zh: "种子数据不合法", En: "Invalid seed data"},
+	"seed.moneySupply":         {Zh: "货币总量与初始余额之和不一致", En: "Money supply does not match the sum of initial balances"},
+	"seed.notFound":            {Zh: "账本中没有种子记录", En: "Seed record not found"},
+	"seed.dataExists":          {Zh: "账本中已有数据，不能写入种子数据", En: "Ledger already has data, seed data refused"},
+	"migrate.batchSize":        {Zh: "每批迁移的条数超出范围", En: "Migration batch size out of range"},
+	"migrate.cursor":           {Zh: "只有预览时可以指定迁移位置", En: "A cursor can only be given for a dry run"},
+	"migrate.newerSchema":      {Zh: "账本的记录结构版本高于链码支持的版本", En: "Ledger schema is newer than the chaincode supports"},
+	"private.forbidden":        {Zh: "所在组织不能读写私有数据", En: "Organization may not access private data"},
+	"transient.missing":        {Zh: "临时数据中缺少必填项", En: "Missing required transient data"},
+}
+
+// ParseChaincodeError 从SDK返回的错误描述中解析链码错误信封，不存在时返回nil
+func ParseChaincodeError(msg string) *ChaincodeError {
+	idx := strings.Index(msg, `{"code":"`)
+	if idx < 0 {
+		return nil
+	}
+	//SDK会在信封前后拼接上下文，只解码第一个json对象
+	decoder := json.NewDecoder(strings.NewReader(msg[idx:]))
+	var e ChaincodeError
+	if err := decoder.Decode(&e); err != nil || e.Code == "" {
+		return nil
+	}
+	return &e
+}
+
+// Status 错误码对应的HTTP状态码，未知错误码按500处理
+func (e *ChaincodeError) Status() int {
+	if status, ok := codeStatus[e.Code]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+// Messages 根据消息键给出中英文提示
+func (e *ChaincodeError) Messages() Messages {
+	if m, ok := keyMessages[e.Key]; ok {
+		return m
+	}
+	if m, ok := codeMessages[e.Code]; ok {
+		return m
+	}
+	return codeMessages["INTERNAL"]
+}
+
+// Body 转换为返回给前端的错误详情
+func (e *ChaincodeError) Body() ErrorBody {
+	return ErrorBody{Code: e.Code, Key: e.Key, Message: e.Messages(), Detail: e.Message, Details: e.Details}
+}
@@ -1,6 +1,8 @@
 package app
 
 import (
+	"net/http"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -14,7 +16,16 @@ type Response struct {
 	Data interface{} `json:"data"`
 }
 
+// Response 返回结果，链码错误信封会按错误码转换为对应的HTTP状态码和中英文提示
 func (g *Gin) Response(httpCode int, errMsg string, data interface{}) {
+	if httpCode == http.StatusInternalServerError {
+		if msg, ok := data.(string); ok {
+			if e := ParseChaincodeError(msg); e != nil {
+				g.ChaincodeError(e)
+				return
+			}
+		}
+	}
 	g.C.JSON(httpCode, Response{
 		Code: httpCode,
 		Msg:  errMsg,
@@ -22,3 +33,17 @@ func (g *Gin) Response(httpCode int, errMsg string, data interface{}) {
 	})
 	return
 }
+
+// ChaincodeError 返回链码错误，Msg按Accept-Language选择中文或英文提示
+func (g *Gin) ChaincodeError(e *ChaincodeError) {
+	messages := e.Messages()
+	msg := messages.Zh
+	if lang := g.C.GetHeader("Accept-Language"); len(lang) >= 2 && lang[:2] == "en" {
+		msg = messages.En
+	}
+	g.C.JSON(e.Status(), Response{
+		Code: e.Status(),
+		Msg:  msg,
+		Data: e.Body(),
+	})
+}
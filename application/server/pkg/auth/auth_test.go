@@ -0,0 +1,235 @@
+package auth
+
+import (
+	"application/pkg/setting"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	dir, err := os.MkdirTemp("", "realty-auth")
+	if err != nil {
+		panic(err)
+	}
+	cfg := setting.Default().Auth
+	cfg.DBPath = filepath.Join(dir, "auth.db")
+	cfg.JWTSecret = "auth"
+	cfg.BootstrapAccount = "bootstrap"
+	Init(cfg)
+	code := m.Run()
+	store.Close()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// 测试登录凭证：没有凭证不能登录，一次性密码只能用于设置新密码且只能使用一次，过期后失效
+func TestCredential(t *testing.T) {
+	s := Default()
+	if err := s.Verify("a1", ""); err != ErrInvalidCredential {
+		t.Fatalf("没有凭证的账户不能登录: %v", err)
+	}
+	if found, err := s.HasCredential("bootstrap"); err != nil || !found {
+		t.Fatalf("启动时应为bootstrapAccount生成一次性密码: %v %v", found, err)
+	}
+
+	secret, expiresAt, err := s.Provision("a1", time.Hour)
+	if err != nil || secret == "" || time.Until(expiresAt) <= 0 {
+		t.Fatalf("生成一次性密码失败: %q %v %v", secret, expiresAt, err)
+	}
+	if err := s.Verify("a1", secret); err != ErrPasswordChangeRequired {
+		t.Fatalf("一次性密码登录时应要求设置新密码: %v", err)
+	}
+	if err := s.Activate("a1", "wrong", "new-password"); err != ErrInvalidCredential {
+		t.Fatalf("错误的一次性密码不能设置新密码: %v", err)
+	}
+	if err := s.Activate("a1", secret, "new-password"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Verify("a1", "new-password"); err != nil {
+		t.Fatalf("设置的新密码应可以登录: %v", err)
+	}
+	if err := s.Verify("a1", secret); err != ErrInvalidCredential {
+		t.Fatalf("一次性密码使用后应失效: %v", err)
+	}
+	if err := s.Activate("a1", "new-password", "other"); err != ErrInvalidCredential {
+		t.Fatalf("已设置密码的账户不能再走一次性密码流程: %v", err)
+	}
+
+	expired, _, err := s.Provision("a2", -time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Verify("a2", expired); err != ErrInvalidCredential {
+		t.Fatalf("过期的一次性密码不能登录: %v", err)
+	}
+	if err := s.Activate("a2", expired, "new-password"); err != ErrInvalidCredential {
+		t.Fatalf("过期的一次性密码不能设置新密码: %v", err)
+	}
+
+	//重新生成一次性密码覆盖原有密码
+	if _, _, err := s.Provision("a1", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Verify("a1", "new-password"); err != ErrInvalidCredential {
+		t.Fatalf("重置后原密码应失效: %v", err)
+	}
+}
+
+// 测试刷新令牌：每个刷新令牌只能使用一次，换取的新令牌可以继续刷新，注销后全部失效
+func TestRefresh(t *testing.T) {
+	tokens, err := Issue("r1", "①号业主", "owner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := Parse(tokens.AccessToken, AccessTokenType)
+	if err != nil || claims.AccountId != "r1" || claims.IsAdmin() {
+		t.Fatalf("访问令牌内容不符合预期: %+v %v", claims, err)
+	}
+	if _, err := Parse(tokens.AccessToken, RefreshTokenType); err == nil {
+		t.Fatal("访问令牌不能作为刷新令牌")
+	}
+	if _, err := Refresh(tokens.AccessToken); err == nil {
+		t.Fatal("不能用访问令牌刷新")
+	}
+
+	rotated, err := Refresh(tokens.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotated.RefreshToken == tokens.RefreshToken {
+		t.Fatal("刷新后应签发新的刷新令牌")
+	}
+	if _, err := Refresh(tokens.RefreshToken); err == nil {
+		t.Fatal("刷新令牌只能使用一次")
+	}
+	again, err := Refresh(rotated.RefreshToken)
+	if err != nil {
+		t.Fatalf("新的刷新令牌应可以使用: %v", err)
+	}
+
+	if err := Default().RevokeRefreshTokens("r1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Refresh(again.RefreshToken); err == nil {
+		t.Fatal("注销后刷新令牌应失效")
+	}
+}
+
+// 测试令牌过期和签名校验
+func TestExpiry(t *testing.T) {
+	defer func(access, refresh time.Duration) {
+		accessTokenTTL, refreshTokenTTL = access, refresh
+	}(accessTokenTTL, refreshTokenTTL)
+	accessTokenTTL, refreshTokenTTL = -time.Second, -time.Second
+	tokens, err := Issue("e1", "②号业主", "owner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Parse(tokens.AccessToken, AccessTokenType); err == nil {
+		t.Fatal("过期的访问令牌应校验失败")
+	}
+	if _, err := Refresh(tokens.RefreshToken); err == nil {
+		t.Fatal("过期的刷新令牌不能使用")
+	}
+
+	accessTokenTTL = time.Minute
+	tokens, err = Issue("e1", "②号业主", "owner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func(s []byte) { secret = s }(secret)
+	secret = []byte("other")
+	if _, err := Parse(tokens.AccessToken, AccessTokenType); err == nil {
+		t.Fatal("其它密钥签名的令牌应校验失败")
+	}
+}
+
+// 测试中间件：令牌只从请求头读取(access_token查询参数只在QueryToken之后有效)，Body中的账户字段必须为登录账户
+// updateBody 模拟handler解析Body使用的结构体
+type updateBody struct {
+	Seller string `json:"seller"`
+	Buyer  string `json:"buyer"`
+}
+
+func TestMiddleware(t *testing.T) {
+	r := gin.New()
+	echo := func(c *gin.Context) {
+		data, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, "%s:%s", AccountId(c), data)
+	}
+	r.POST("/update", Required(), BodyAccount(updateBody{}, "seller", "buyer"), echo)
+	r.GET("/me", Required(), echo)
+	r.GET("/stream", QueryToken(), Required(), echo)
+
+	owner, err := Issue("m1", "③号业主", "owner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	admin, err := Issue("m0", "管理员", "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	accessTokenTTL = -time.Second
+	expired, err := Issue("m1", "③号业主", "owner")
+	accessTokenTTL = setting.Default().Auth.AccessTokenTTL
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := func(v map[string]string) []byte {
+		b, _ := json.Marshal(v)
+		return b
+	}
+	cases := []struct {
+		name     string
+		method   string
+		path     string
+		token    string
+		body     []byte
+		wantCode int
+		wantBody string
+	}{
+		{"没有令牌", http.MethodGet, "/me", "", nil, http.StatusUnauthorized, ""},
+		{"请求头中的令牌", http.MethodGet, "/me", owner.AccessToken, nil, http.StatusOK, "m1:"},
+		{"刷新令牌不能访问", http.MethodGet, "/me", owner.RefreshToken, nil, http.StatusUnauthorized, ""},
+		{"过期的令牌", http.MethodGet, "/me", expired.AccessToken, nil, http.StatusUnauthorized, ""},
+		{"其它接口不接受查询参数", http.MethodGet, "/me?access_token=" + owner.AccessToken, "", nil, http.StatusUnauthorized, ""},
+		{"stream接受查询参数", http.MethodGet, "/stream?access_token=" + owner.AccessToken, "", nil, http.StatusOK, "m1:"},
+		{"Body账户为卖家", http.MethodPost, "/update", owner.AccessToken, body(map[string]string{"seller": "m1", "buyer": "m2"}), http.StatusOK, `m1:{"buyer":"m2","seller":"m1"}`},
+		{"Body账户为买家", http.MethodPost, "/update", owner.AccessToken, body(map[string]string{"seller": "m2", "buyer": "m1"}), http.StatusOK, ""},
+		{"Body账户不是登录账户", http.MethodPost, "/update", owner.AccessToken, body(map[string]string{"seller": "m2", "buyer": "m3"}), http.StatusForbidden, ""},
+		{"大小写不同的重复字段以handler解析的值为准", http.MethodPost, "/update", owner.AccessToken, []byte(`{"seller":"m1","Seller":"m2","buyer":"m3"}`), http.StatusForbidden, ""},
+		{"大小写不同的字段", http.MethodPost, "/update", owner.AccessToken, []byte(`{"Seller":"m1","buyer":"m3"}`), http.StatusOK, ""},
+		{"Body账户字段缺失", http.MethodPost, "/update", owner.AccessToken, body(map[string]string{}), http.StatusForbidden, ""},
+		{"Body不是json", http.MethodPost, "/update", owner.AccessToken, []byte("{"), http.StatusBadRequest, ""},
+		{"管理员不受限制", http.MethodPost, "/update", admin.AccessToken, body(map[string]string{"seller": "m2"}), http.StatusOK, ""},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest(c.method, c.path, bytes.NewReader(c.body))
+			if c.body != nil {
+				req.Header.Set("Content-Type", "application/json")
+			}
+			if c.token != "" {
+				req.Header.Set("Authorization", "Bearer "+c.token)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != c.wantCode {
+				t.Fatalf("期望%d，实际%d: %s", c.wantCode, w.Code, w.Body.String())
+			}
+			if c.wantBody != "" && w.Body.String() != c.wantBody {
+				t.Fatalf("handler收到的内容不符合预期: %s", w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package auth
+
+import (
+	"application/pkg/app"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const claimsKey = "auth.claims"
+
+// Required 校验访问令牌，并把登录账户注入上下文，令牌放在Authorization: Bearer中
+func Required() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		appG := app.Gin{C: c}
+		tokenString := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if tokenString == "" {
+			appG.Response(http.StatusUnauthorized, "失败", "请先登录")
+			c.Abort()
+			return
+		}
+		claims, err := Parse(tokenString, AccessTokenType)
+		if err != nil {
+			appG.Response(http.StatusUnauthorized, "失败", fmt.Sprintf("登录已失效%s", err.Error()))
+			c.Abort()
+			return
+		}
+		c.Set(claimsKey, claims)
+		c.Next()
+	}
+}
+
+// QueryToken 把access_token查询参数作为Authorization请求头，放在Required之前
+// 只用于EventSource等无法设置请求头的接口，查询参数会出现在访问日志和浏览器历史中，其它接口不接受
+func QueryToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token := c.Query("access_token"); token != "" && c.GetHeader("Authorization") == "" {
+			c.Request.Header.Set("Authorization", "Bearer "+token)
+		}
+		c.Next()
+	}
+}
+
+// Current 当前登录账户，未经过Required时返回nil
+func Current(c *gin.Context) *Claims {
+	if v, ok := c.Get(claimsKey); ok {
+		return v.(*Claims)
+	}
+	return nil
+}
+
+// AccountId 当前登录账户的AccountId
+func AccountId(c *gin.Context) string {
+	if claims := Current(c); claims != nil {
+		return claims.AccountId
+	}
+	return ""
+}
+
+// BodyAccount 校验请求Body中的账户字段，fields中至少有一个等于登录账户，管理员不受限制
+// body为handler解析Body使用的结构体，以与handler相同的ShouldBind解析，校验的就是handler取得的值，fields为其中账户字段的json标签
+// 例如updateSelling只允许卖家或买家本人操作: BodyAccount(v1.UpdateSellingRequestBody{}, "seller", "buyer")
+func BodyAccount(body interface{}, fields ...string) gin.HandlerFunc {
+	bodyType := reflect.TypeOf(body)
+	index := make([]int, len(fields))
+	for i, field := range fields {
+		index[i] = accountField(bodyType, field)
+	}
+	return func(c *gin.Context) {
+		appG := app.Gin{C: c}
+		claims := Current(c)
+		if claims == nil {
+			appG.Response(http.StatusUnauthorized, "失败", "请先登录")
+			c.Abort()
+			return
+		}
+		if claims.IsAdmin() {
+			c.Next()
+			return
+		}
+		data, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(data))
+		v := reflect.New(bodyType)
+		err = c.ShouldBind(v.Interface())
+		//放回Body，供后续handler解析
+		c.Request.Body = io.NopCloser(bytes.NewReader(data))
+		if err != nil {
+			appG.Response(http.StatusBadRequest, "失败", fmt.Sprintf("参数出错%s", err.Error()))
+			c.Abort()
+			return
+		}
+		for _, i := range index {
+			if v.Elem().Field(i).String() == claims.AccountId {
+				c.Next()
+				return
+			}
+		}
+		appG.Response(http.StatusForbidden, "失败", fmt.Sprintf("%s必须为当前登录账户", strings.Join(fields, "或")))
+		c.Abort()
+	}
+}
+
+// accountField 按json标签查找结构体中的账户字段，返回字段序号，找不到或不是字符串时panic(路由配置错误)
+func accountField(bodyType reflect.Type, field string) int {
+	if bodyType.Kind() == reflect.Struct {
+		for i := 0; i < bodyType.NumField(); i++ {
+			f := bodyType.Field(i)
+			if strings.Split(f.Tag.Get("json"), ",")[0] == field && f.Type.Kind() == reflect.String {
+				return i
+			}
+		}
+	}
+	panic(fmt.Sprintf("%v中没有json标签为%s的字符串字段", bodyType, field))
+}
@@ -0,0 +1,234 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/json"
+	"errors"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var (
+	credentialBucket   = []byte("credentials")   //accountId -> Credential
+	refreshTokenBucket = []byte("refreshTokens") //刷新令牌ID -> RefreshToken，注销或刷新后删除
+)
+
+var (
+	// ErrInvalidCredential 账号或密码错误，一次性密码已使用或已过期时同样返回该错误
+	ErrInvalidCredential = errors.New("账号或密码错误")
+	// ErrPasswordChangeRequired 使用一次性密码登录，需要同时设置新密码
+	ErrPasswordChangeRequired = errors.New("使用一次性密码登录时需要设置新密码")
+)
+
+// Credential 账户登录凭证
+// 账户没有凭证时不能登录，由管理员为其生成一次性密码(见Provision)，首次登录时换成账户自己设置的密码
+type Credential struct {
+	AccountId    string `json:"accountId"`           //账号ID
+	PasswordHash []byte `json:"passwordHash"`        //bcrypt哈希后的密码
+	OneTime      bool   `json:"oneTime,omitempty"`   //是否为管理员生成的一次性密码
+	ExpiresAt    int64  `json:"expiresAt,omitempty"` //一次性密码的过期时间
+	UpdatedAt    int64  `json:"updatedAt"`           //修改时间
+}
+
+// RefreshToken 已签发且未使用的刷新令牌
+type RefreshToken struct {
+	AccountId string `json:"accountId"` //账号ID
+	ExpiresAt int64  `json:"expiresAt"` //过期时间
+}
+
+// Store 凭证与刷新令牌存储
+type Store struct {
+	db *bolt.DB
+}
+
+// Open 打开凭证数据库，不存在时创建
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{credentialBucket, refreshTokenBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Store{db: db}, nil
+}
+
+// Close 关闭数据库
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Verify 校验密码，密码正确但为一次性密码时返回ErrPasswordChangeRequired，需通过Activate设置新密码后才能登录
+func (s *Store) Verify(accountId string, password string) error {
+	var err error
+	viewErr := s.db.View(func(tx *bolt.Tx) error {
+		_, err = check(tx, accountId, password)
+		return nil
+	})
+	if viewErr != nil {
+		return viewErr
+	}
+	return err
+}
+
+// Activate 用一次性密码设置账户自己的密码，一次性密码随即失效
+func (s *Store) Activate(accountId string, secret string, password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		credential, err := check(tx, accountId, secret)
+		if err != ErrPasswordChangeRequired {
+			if err == nil {
+				//已经设置过密码，不能再用一次性密码的流程修改
+				return ErrInvalidCredential
+			}
+			return err
+		}
+		return putCredential(tx, &Credential{AccountId: credential.AccountId, PasswordHash: hash, UpdatedAt: time.Now().Unix()})
+	})
+}
+
+// Provision 为账户生成一次性密码，覆盖账户原有的密码，ttl后过期
+// 生成的密码只返回这一次，账户使用它登录时需要同时设置新密码
+func (s *Store) Provision(accountId string, ttl time.Duration) (string, time.Time, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", time.Time{}, err
+	}
+	secret := base32.StdEncoding.EncodeToString(b)
+	hash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	now := time.Now()
+	expiresAt := now.Add(ttl)
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		return putCredential(tx, &Credential{AccountId: accountId, PasswordHash: hash, OneTime: true, ExpiresAt: expiresAt.Unix(), UpdatedAt: now.Unix()})
+	})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return secret, expiresAt, nil
+}
+
+// HasCredential 账户是否已有凭证(包括未使用的一次性密码)
+func (s *Store) HasCredential(accountId string) (bool, error) {
+	found := false
+	err := s.db.View(func(tx *bolt.Tx) error {
+		found = tx.Bucket(credentialBucket).Get([]byte(accountId)) != nil
+		return nil
+	})
+	return found, err
+}
+
+// SetPassword 设置密码
+func (s *Store) SetPassword(accountId string, password string) error {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putCredential(tx, &Credential{AccountId: accountId, PasswordHash: hash, UpdatedAt: time.Now().Unix()})
+	})
+}
+
+// check 在事务中校验密码，返回账户的凭证
+func check(tx *bolt.Tx, accountId string, password string) (*Credential, error) {
+	val := tx.Bucket(credentialBucket).Get([]byte(accountId))
+	if val == nil {
+		return nil, ErrInvalidCredential
+	}
+	credential := new(Credential)
+	if err := json.Unmarshal(val, credential); err != nil {
+		return nil, err
+	}
+	if credential.OneTime && credential.ExpiresAt < time.Now().Unix() {
+		return nil, ErrInvalidCredential
+	}
+	if bcrypt.CompareHashAndPassword(credential.PasswordHash, []byte(password)) != nil {
+		return nil, ErrInvalidCredential
+	}
+	if credential.OneTime {
+		return credential, ErrPasswordChangeRequired
+	}
+	return credential, nil
+}
+
+func putCredential(tx *bolt.Tx, credential *Credential) error {
+	val, err := json.Marshal(credential)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(credentialBucket).Put([]byte(credential.AccountId), val)
+}
+
+// SaveRefreshToken 记录签发的刷新令牌
+func (s *Store) SaveRefreshToken(id string, token RefreshToken) error {
+	val, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(refreshTokenBucket).Put([]byte(id), val)
+	})
+}
+
+// ConsumeRefreshToken 使用刷新令牌，每个刷新令牌只能使用一次
+func (s *Store) ConsumeRefreshToken(id string) (*RefreshToken, error) {
+	var token *RefreshToken
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(refreshTokenBucket)
+		val := bucket.Get([]byte(id))
+		if val == nil {
+			return nil
+		}
+		token = new(RefreshToken)
+		if err := json.Unmarshal(val, token); err != nil {
+			return err
+		}
+		return bucket.Delete([]byte(id))
+	})
+	return token, err
+}
+
+// RevokeRefreshTokens 注销账户签发的全部刷新令牌，同时清理已过期的令牌
+func (s *Store) RevokeRefreshTokens(accountId string) error {
+	now := time.Now().Unix()
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(refreshTokenBucket)
+		var expired [][]byte
+		err := bucket.ForEach(func(k, v []byte) error {
+			var token RefreshToken
+			if err := json.Unmarshal(v, &token); err != nil {
+				return err
+			}
+			if token.AccountId == accountId || token.ExpiresAt < now {
+				expired = append(expired, append([]byte(nil), k...))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for _, k := range expired {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,200 @@
+package auth
+
+import (
+	"application/pkg/setting"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
+)
+
+// issuer 令牌签发者
+const issuer = "fabric-realty"
+
+var (
+	accessTokenTTL  time.Duration // 访问令牌有效期
+	refreshTokenTTL time.Duration // 刷新令牌有效期
+	secretTTL       time.Duration // 管理员生成的一次性密码的有效期
+	secret          []byte        // 签名密钥，未配置时随机生成(重启后已签发的令牌失效)
+	store           *Store
+)
+
+// 令牌类型
+const (
+	AccessTokenType  = "access"
+	RefreshTokenType = "refresh"
+)
+
+// 与链码中的管理员判断保持一致：有角色时按角色，旧账户没有角色时按账号名
+const (
+	adminRole    = "admin"
+	adminAccount = "管理员"
+)
+
+// Claims 令牌内容
+type Claims struct {
+	AccountId string `json:"accountId"` //账号ID
+	UserName  string `json:"userName"`  //账号名
+	Role      string `json:"role"`      //角色
+	TokenType string `json:"tokenType"` //令牌类型
+	jwt.RegisteredClaims
+}
+
+// IsAdmin 是否管理员
+func (c *Claims) IsAdmin() bool {
+	if c.Role != "" {
+		return c.Role == adminRole
+	}
+	return c.UserName == adminAccount
+}
+
+// TokenPair 登录或刷新后签发的令牌
+type TokenPair struct {
+	AccessToken  string `json:"accessToken"`  //访问令牌，放在Authorization: Bearer中
+	RefreshToken string `json:"refreshToken"` //刷新令牌，用于换取新的令牌
+	ExpiresIn    int64  `json:"expiresIn"`    //访问令牌有效期(秒)
+}
+
+// Init 打开凭证数据库并准备签名密钥
+// 配置了auth.bootstrapAccount且该账户还没有凭证时，为其生成一次性密码并打印到日志，用于部署后第一个管理员登录
+func Init(cfg setting.Auth) {
+	accessTokenTTL = cfg.AccessTokenTTL
+	refreshTokenTTL = cfg.RefreshTokenTTL
+	secretTTL = cfg.SecretTTL
+	var err error
+	store, err = Open(cfg.DBPath)
+	if err != nil {
+		panic(err)
+	}
+	if cfg.BootstrapAccount != "" {
+		if err := bootstrap(cfg.BootstrapAccount); err != nil {
+			panic(err)
+		}
+	}
+	if cfg.JWTSecret != "" {
+		secret = []byte(cfg.JWTSecret)
+		return
+	}
+	secret = make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		panic(err)
+	}
+	log.Printf("未设置auth.jwtSecret(JWT_SECRET)，使用随机生成的签名密钥，重启后需要重新登录")
+}
+
+// Default 默认的凭证存储
+func Default() *Store {
+	return store
+}
+
+// SecretTTL 一次性密码的有效期
+func SecretTTL() time.Duration {
+	return secretTTL
+}
+
+func bootstrap(accountId string) error {
+	found, err := store.HasCredential(accountId)
+	if err != nil || found {
+		return err
+	}
+	secret, expiresAt, err := store.Provision(accountId, secretTTL)
+	if err != nil {
+		return err
+	}
+	log.Printf("[warn] 账户%s的一次性密码为%s，%s前有效，首次登录时需要设置新密码", accountId, secret, expiresAt.Format("2006-01-02 15:04:05"))
+	return nil
+}
+
+// Issue 签发访问令牌和刷新令牌
+func Issue(accountId string, userName string, role string) (*TokenPair, error) {
+	now := time.Now()
+	access, err := sign(Claims{
+		AccountId: accountId,
+		UserName:  userName,
+		Role:      role,
+		TokenType: AccessTokenType,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    issuer,
+			Subject:   accountId,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(accessTokenTTL)),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	id, err := randomId()
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := sign(Claims{
+		AccountId: accountId,
+		UserName:  userName,
+		Role:      role,
+		TokenType: RefreshTokenType,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        id,
+			Issuer:    issuer,
+			Subject:   accountId,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(refreshTokenTTL)),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := store.SaveRefreshToken(id, RefreshToken{AccountId: accountId, ExpiresAt: now.Add(refreshTokenTTL).Unix()}); err != nil {
+		return nil, err
+	}
+	return &TokenPair{AccessToken: access, RefreshToken: refresh, ExpiresIn: int64(accessTokenTTL / time.Second)}, nil
+}
+
+// Refresh 使用刷新令牌换取新的令牌，旧的刷新令牌随即失效
+func Refresh(refreshToken string) (*TokenPair, error) {
+	claims, err := Parse(refreshToken, RefreshTokenType)
+	if err != nil {
+		return nil, err
+	}
+	token, err := store.ConsumeRefreshToken(claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if token == nil || token.AccountId != claims.AccountId {
+		return nil, errors.New("刷新令牌已失效")
+	}
+	return Issue(claims.AccountId, claims.UserName, claims.Role)
+}
+
+// Parse 校验令牌签名、有效期和类型
+func Parse(tokenString string, tokenType string) (*Claims, error) {
+	claims := new(Claims)
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, errors.New(fmt.Sprintf("不支持的签名算法%v", token.Header["alg"]))
+		}
+		return secret, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if claims.TokenType != tokenType {
+		return nil, errors.New("令牌类型不符")
+	}
+	return claims, nil
+}
+
+func sign(claims Claims) (string, error) {
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+}
+
+func randomId() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -13,10 +13,8 @@ import (
 	"github.com/robfig/cron/v3"
 )
 
-const spec = "0 0 0 * * ?" // 每天0点执行
-//const spec = "*/10 * * * * ?" //10秒执行一次，用于测试
-
-func Init() {
+// Init 按spec定时关闭过期的销售，spec由配置cron.spec指定，默认每天0点执行
+func Init(spec string) {
 	c := cron.New(cron.WithSeconds()) //支持到秒级别
 	_, err := c.AddFunc(spec, GoRun)
 	if err != nil {
@@ -58,8 +56,8 @@ func GoRun() {
 				bodyBytes = append(bodyBytes, []byte(v.Seller))
 				bodyBytes = append(bodyBytes, []byte(v.Buyer))
 				bodyBytes = append(bodyBytes, []byte("expired"))
-				//调用智能合约
-				resp, err := bc.ChannelExecute("updateSelling", bodyBytes)
+				//链码要求调用者是销售的当事人，以卖家身份调用智能合约
+				resp, err := bc.ChannelExecuteAs(v.Seller, "updateSelling", bodyBytes)
 				if err != nil {
 					return
 				}
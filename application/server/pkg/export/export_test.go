@@ -0,0 +1,125 @@
+package export
+
+import (
+	bc "application/blockchain"
+	"application/model"
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+)
+
+func snapshot() *Snapshot {
+	done, delivery := model.SellingStatusConstant()["done"], model.SellingStatusConstant()["delivery"]
+	return &Snapshot{
+		Chain: bc.ChainInfo{Height: 7, CurrentBlockHash: "abcd"},
+		Accounts: []model.Account{
+			{AccountId: "b", UserName: "②号业主", Balance: 4000},
+			{AccountId: "a", UserName: "①号业主", Balance: 5500.5},
+		},
+		Sellings: []model.Selling{
+			{ObjectOfSale: "r1", Seller: "a", Buyer: "b", Price: 500.5, CreateTime: "2024-01-30 10:00:00", DoneTime: "2024-02-01 09:00:00", SellingStatus: done},
+			{ObjectOfSale: "r2", Seller: "a", Buyer: "b", Price: 300, CreateTime: "2023-12-31 10:00:00", SellingStatus: done},
+			{ObjectOfSale: "r3", Seller: "b", Buyer: "a", Price: 500, CreateTime: "2024-02-01 10:00:00", SellingStatus: delivery},
+		},
+		Seed: &model.SeedRecord{Accounts: 2, MoneySupply: 10000.5},
+	}
+}
+
+// 测试销售报表按完成时间筛选(旧记录以创建时间代替)，CSV去掉首行后的摘要与报表一致
+func TestSellingsReport(t *testing.T) {
+	r, err := Build(ReportSellings, snapshot(), "2024-01-01", "2024-02-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Rows) != 1 || r.Rows[0][0] != "r1" || r.Rows[0][5] != "2024-02-01 09:00:00" {
+		t.Fatalf("销售报表不符合预期: %+v", r.Rows)
+	}
+	if r, _ := Build(ReportSellings, snapshot(), "", "2023-12-31"); len(r.Rows) != 1 || r.Rows[0][0] != "r2" {
+		t.Fatalf("没有完成时间的旧记录应按创建时间筛选: %+v", r.Rows)
+	}
+	if _, err := Build(ReportSellings, snapshot(), "2024-02-02", "2024-02-01"); err == nil {
+		t.Fatal("起始日期晚于结束日期应返回错误")
+	}
+
+	var buf bytes.Buffer
+	if err := WriteCSV(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+	content := buf.String()
+	header := content[:strings.Index(content, "\n")+1]
+	if !strings.Contains(header, "blockHeight=7 blockHash=abcd digest="+r.Digest) {
+		t.Fatalf("CSV首行应包含区块和摘要: %s", header)
+	}
+	sum := sha256.Sum256([]byte(content[len(header):]))
+	if hex.EncodeToString(sum[:]) != r.Digest {
+		t.Fatal("CSV内容与报表摘要不一致")
+	}
+}
+
+// 测试JSON和XLSX输出，XLSX中数值列写为数字单元格
+func TestFormats(t *testing.T) {
+	r, err := Build(ReportAccounts, snapshot(), "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+	var body struct {
+		BlockHeight uint64              `json:"blockHeight"`
+		Rows        []map[string]string `json:"rows"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &body); err != nil || body.BlockHeight != 7 || body.Rows[0]["accountId"] != "a" || body.Rows[0]["balance"] != "5500.5" {
+		t.Fatalf("JSON报表不符合预期: %s", buf.String())
+	}
+
+	buf.Reset()
+	if err := WriteXLSX(&buf, r); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sheets := make(map[string]string)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		sheets[f.Name] = string(data)
+	}
+	if !strings.Contains(sheets["xl/worksheets/sheet1.xml"], `<c r="C2"><v>5500.5</v></c>`) ||
+		!strings.Contains(sheets["xl/worksheets/sheet1.xml"], `<t xml:space="preserve">①号业主</t>`) ||
+		!strings.Contains(sheets["xl/worksheets/sheet2.xml"], r.Digest) {
+		t.Fatalf("XLSX工作表不符合预期: %v", sheets)
+	}
+	if cellRef(26, 3) != "AA3" {
+		t.Fatalf("第26列应为AA，实际%s", cellRef(26, 3))
+	}
+}
+
+// 测试对账：余额加托管资金等于种子记录中的货币总量时一致，否则给出差额和负余额的账户，没有种子记录时不能对账
+func TestReconcile(t *testing.T) {
+	snap := snapshot()
+	if rec := Reconcile(snap); !rec.OK || rec.Escrow != 500 || rec.Balances != 9500.5 || rec.MoneySupply != 10000.5 {
+		t.Fatalf("对账应一致: %+v", rec)
+	}
+	snap.Accounts = append(snap.Accounts, model.Account{AccountId: "c", Balance: -100})
+	rec := Reconcile(snap)
+	if rec.OK || len(rec.Findings) != 2 || rec.Findings[0].Kind != FindingNegativeBalance || rec.Findings[1].Kind != FindingSupply || rec.Difference != -100 {
+		t.Fatalf("对账应发现负余额和差额: %+v", rec)
+	}
+	snap.Seed = nil
+	if rec := Reconcile(snap); rec.OK || rec.Findings[len(rec.Findings)-1].Kind != FindingNoSeed {
+		t.Fatalf("没有种子记录时应报告无法对账: %+v", rec)
+	}
+}
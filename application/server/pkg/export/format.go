@@ -0,0 +1,75 @@
+package export
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// Format 报表的输出格式
+type Format struct {
+	Name        string
+	ContentType string
+	Write       func(w io.Writer, r *Report) error
+}
+
+// Formats 支持的输出格式
+var Formats = map[string]Format{
+	"csv":  {Name: "csv", ContentType: "text/csv; charset=utf-8", Write: WriteCSV},
+	"json": {Name: "json", ContentType: "application/json; charset=utf-8", Write: WriteJSON},
+	"xlsx": {Name: "xlsx", ContentType: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet", Write: WriteXLSX},
+}
+
+// LookupFormat 按名称查找输出格式，为空时为csv
+func LookupFormat(name string) (Format, error) {
+	if name == "" {
+		name = "csv"
+	}
+	f, ok := Formats[name]
+	if !ok {
+		return Format{}, errors.New(fmt.Sprintf("不支持的格式%s，可选csv、json、xlsx", name))
+	}
+	return f, nil
+}
+
+// FileName 报表的下载文件名
+func FileName(r *Report, f Format) string {
+	return fmt.Sprintf("%s-%d.%s", r.Name, r.BlockHeight, f.Name)
+}
+
+// WriteCSV 首行为#开头的报表信息，其后为列名和各行，去掉首行后的SHA-256即Digest
+func WriteCSV(w io.Writer, r *Report) error {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# report=%s blockHeight=%d blockHash=%s digest=%s generatedAt=%s", r.Name, r.BlockHeight, r.BlockHash, r.Digest, r.GeneratedAt.Format(time.RFC3339))
+	if r.From != "" || r.To != "" {
+		fmt.Fprintf(&buf, " from=%s to=%s", r.From, r.To)
+	}
+	buf.WriteByte('\n')
+	if err := writeRecords(&buf, r); err != nil {
+		return err
+	}
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// WriteJSON 报表信息和各行，行为列名到值的对象
+func WriteJSON(w io.Writer, r *Report) error {
+	rows := make([]map[string]string, 0, len(r.Rows))
+	for _, row := range r.Rows {
+		obj := make(map[string]string, len(row))
+		for i, v := range row {
+			obj[r.Columns[i]] = v
+		}
+		rows = append(rows, obj)
+	}
+	body := struct {
+		*Report
+		Rows []map[string]string `json:"rows"`
+	}{Report: r, Rows: rows}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(body)
+}
@@ -0,0 +1,138 @@
+package export
+
+import (
+	"application/model"
+	"application/pkg/setting"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// tolerance 金额比较的误差，余额为浮点数，累加时会有舍入误差
+const tolerance = 0.005
+
+// 对账的触发方式
+const (
+	TriggerManual   = "manual"   //管理员手动对账
+	TriggerSchedule = "schedule" //定时对账
+)
+
+// 对账发现的问题
+const (
+	FindingSupply          = "supplyMismatch"  //余额与托管资金之和不等于货币总量
+	FindingNegativeBalance = "negativeBalance" //账户余额为负
+	FindingNoSeed          = "noSeed"          //账本中没有种子记录，无法确定货币总量
+)
+
+// Finding 对账发现的问题
+type Finding struct {
+	Kind      string  `json:"kind"`
+	AccountId string  `json:"accountId,omitempty"`
+	Amount    float64 `json:"amount"`
+	Message   string  `json:"message"`
+}
+
+// Reconciliation 对账结果，全部账户余额加上交付中销售托管的资金应等于种子记录中的货币总量
+type Reconciliation struct {
+	ID          uint64    `json:"id"`
+	Trigger     string    `json:"trigger"`
+	BlockHeight uint64    `json:"blockHeight"`
+	BlockHash   string    `json:"blockHash"`
+	MoneySupply float64   `json:"moneySupply"` //种子记录中的货币总量
+	Balances    float64   `json:"balances"`    //全部账户余额之和
+	Escrow      float64   `json:"escrow"`      //买家已付款、卖家尚未确认收款的资金
+	Difference  float64   `json:"difference"`  //Balances+Escrow-MoneySupply
+	OK          bool      `json:"ok"`
+	Findings    []Finding `json:"findings"`
+	CreatedAt   time.Time `json:"createdAt"`
+}
+
+var (
+	store *Store
+	mu    sync.Mutex //同一时间只执行一次对账
+)
+
+// Init 打开对账结果数据库，按export.reconcileSpec定时对账
+func Init(cfg setting.Export) {
+	var err error
+	store, err = Open(cfg.DBPath)
+	if err != nil {
+		panic(err)
+	}
+	if cfg.ReconcileSpec == "" {
+		return
+	}
+	c := cron.New(cron.WithSeconds())
+	if _, err := c.AddFunc(cfg.ReconcileSpec, func() {
+		if _, err := Run(TriggerSchedule); err != nil {
+			log.Printf("定时对账失败 %s", err)
+		}
+	}); err != nil {
+		panic(err)
+	}
+	c.Start()
+}
+
+// Default 默认的对账结果存储
+func Default() *Store {
+	return store
+}
+
+// Run 在账本的一致快照上对账并保存结果，发现问题时记录日志
+func Run(trigger string) (*Reconciliation, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	snap, err := Take()
+	if err != nil {
+		return nil, err
+	}
+	rec := Reconcile(snap)
+	rec.Trigger = trigger
+	if err := store.Save(rec); err != nil {
+		return nil, err
+	}
+	if !rec.OK {
+		log.Printf("[warn] 对账发现%d个问题(区块高度%d): %+v", len(rec.Findings), rec.BlockHeight, rec.Findings)
+	}
+	return rec, nil
+}
+
+// Reconcile 由快照计算对账结果，货币总量取自同一区块高度的种子记录
+func Reconcile(snap *Snapshot) *Reconciliation {
+	rec := &Reconciliation{
+		BlockHeight: snap.Chain.Height,
+		BlockHash:   snap.Chain.CurrentBlockHash,
+		Findings:    make([]Finding, 0),
+		CreatedAt:   time.Now(),
+	}
+	for _, v := range snap.Accounts {
+		rec.Balances += v.Balance
+		if v.Balance < -tolerance {
+			rec.Findings = append(rec.Findings, Finding{Kind: FindingNegativeBalance, AccountId: v.AccountId, Amount: v.Balance,
+				Message: fmt.Sprintf("账户%s余额为%s", v.AccountId, formatFloat(v.Balance))})
+		}
+	}
+	//交付中的销售，买家的付款已扣除但尚未转给卖家
+	for _, v := range snap.Sellings {
+		if v.SellingStatus == model.SellingStatusConstant()["delivery"] {
+			rec.Escrow += v.Price
+		}
+	}
+	if snap.Seed == nil {
+		rec.Findings = append(rec.Findings, Finding{Kind: FindingNoSeed, Message: "账本中没有种子记录(导入状态或早于种子数据的账本)，无法核对货币总量"})
+		rec.OK = false
+		return rec
+	}
+	rec.MoneySupply = snap.Seed.MoneySupply
+	rec.Difference = rec.Balances + rec.Escrow - rec.MoneySupply
+	if math.Abs(rec.Difference) > tolerance {
+		rec.Findings = append(rec.Findings, Finding{Kind: FindingSupply, Amount: rec.Difference,
+			Message: fmt.Sprintf("余额之和%s加托管资金%s与货币总量%s相差%s", formatFloat(rec.Balances), formatFloat(rec.Escrow), formatFloat(rec.MoneySupply), formatFloat(rec.Difference))})
+	}
+	rec.OK = len(rec.Findings) == 0
+	return rec
+}
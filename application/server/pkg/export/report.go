@@ -0,0 +1,189 @@
+package export
+
+import (
+	"application/model"
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// 报表名称
+const (
+	ReportAccounts    = "accounts"    //账户及余额
+	ReportRealEstates = "realEstates" //房地产登记簿
+	ReportSellings    = "sellings"    //时间段内完成的销售
+	ReportDonatings   = "donatings"   //捐赠
+)
+
+// Reports 支持的全部报表
+var Reports = []string{ReportAccounts, ReportRealEstates, ReportSellings, ReportDonatings}
+
+// dateLayout 时间段参数的格式，链码中的时间为"2006-01-02 15:04:05"，前10位即日期
+const dateLayout = "2006-01-02"
+
+// Report 报表，记录数据所在的区块高度和哈希，Digest为列名和各行按CSV编码后的SHA-256，可据此核对导出的文件
+type Report struct {
+	Name        string     `json:"name"`
+	BlockHeight uint64     `json:"blockHeight"`
+	BlockHash   string     `json:"blockHash"`
+	Digest      string     `json:"digest"`
+	GeneratedAt time.Time  `json:"generatedAt"`
+	From        string     `json:"from,omitempty"` //时间段起始日期(含)
+	To          string     `json:"to,omitempty"`   //时间段结束日期(含)
+	Columns     []string   `json:"columns"`
+	Rows        [][]string `json:"rows"`
+	numeric     []bool     //各列是否为数值，XLSX中写为数字单元格
+}
+
+// Generate 在账本的一致快照上生成报表，from、to为YYYY-MM-DD格式的日期，可为空
+func Generate(name string, from string, to string) (*Report, error) {
+	if err := CheckRange(from, to); err != nil {
+		return nil, err
+	}
+	if !ValidReport(name) {
+		return nil, errors.New(fmt.Sprintf("不支持的报表%s", name))
+	}
+	snap, err := Take()
+	if err != nil {
+		return nil, err
+	}
+	return Build(name, snap, from, to)
+}
+
+// Build 由快照生成报表，各行按主键排序，相同的账本数据得到相同的摘要
+func Build(name string, snap *Snapshot, from string, to string) (*Report, error) {
+	if err := CheckRange(from, to); err != nil {
+		return nil, err
+	}
+	r := &Report{Name: name, BlockHeight: snap.Chain.Height, BlockHash: snap.Chain.CurrentBlockHash, GeneratedAt: time.Now(), From: from, To: to, Rows: make([][]string, 0)}
+	switch name {
+	case ReportAccounts:
+		r.setColumns("accountId", "userName", "#balance")
+		for _, v := range snap.Accounts {
+			r.Rows = append(r.Rows, []string{v.AccountId, v.UserName, formatFloat(v.Balance)})
+		}
+	case ReportRealEstates:
+		r.setColumns("realEstateId", "proprietor", "encumbrance", "#totalArea", "#livingSpace")
+		for _, v := range snap.RealEstates {
+			r.Rows = append(r.Rows, []string{v.RealEstateID, v.Proprietor, strconv.FormatBool(v.Encumbrance), formatFloat(v.TotalArea), formatFloat(v.LivingSpace)})
+		}
+	case ReportSellings:
+		r.setColumns("objectOfSale", "seller", "buyer", "#price", "createTime", "doneTime")
+		for _, v := range snap.Sellings {
+			if v.SellingStatus != model.SellingStatusConstant()["done"] {
+				continue
+			}
+			//旧记录没有完成时间，以创建时间代替
+			doneTime := v.DoneTime
+			if doneTime == "" {
+				doneTime = v.CreateTime
+			}
+			if !inRange(doneTime, from, to) {
+				continue
+			}
+			r.Rows = append(r.Rows, []string{v.ObjectOfSale, v.Seller, v.Buyer, formatFloat(v.Price), v.CreateTime, doneTime})
+		}
+	case ReportDonatings:
+		r.setColumns("objectOfDonating", "donor", "grantee", "donatingStatus", "createTime", "doneTime")
+		for _, v := range snap.Donatings {
+			if !inRange(v.CreateTime, from, to) {
+				continue
+			}
+			r.Rows = append(r.Rows, []string{v.ObjectOfDonating, v.Donor, v.Grantee, v.DonatingStatus, v.CreateTime, v.DoneTime})
+		}
+	default:
+		return nil, errors.New(fmt.Sprintf("不支持的报表%s", name))
+	}
+	sort.Slice(r.Rows, func(i, j int) bool {
+		a, b := r.Rows[i], r.Rows[j]
+		for k := range a {
+			if a[k] != b[k] {
+				return a[k] < b[k]
+			}
+		}
+		return false
+	})
+	digest, err := r.digest()
+	if err != nil {
+		return nil, err
+	}
+	r.Digest = digest
+	return r, nil
+}
+
+// setColumns 设置列名，以#开头的为数值列
+func (r *Report) setColumns(columns ...string) {
+	for _, c := range columns {
+		numeric := c[0] == '#'
+		if numeric {
+			c = c[1:]
+		}
+		r.Columns = append(r.Columns, c)
+		r.numeric = append(r.numeric, numeric)
+	}
+}
+
+// digest 列名和各行按CSV编码后的SHA-256，即CSV报表去掉首行注释后的内容
+func (r *Report) digest() (string, error) {
+	var buf bytes.Buffer
+	if err := writeRecords(&buf, r); err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func writeRecords(buf *bytes.Buffer, r *Report) error {
+	w := csv.NewWriter(buf)
+	if err := w.Write(r.Columns); err != nil {
+		return err
+	}
+	if err := w.WriteAll(r.Rows); err != nil {
+		return err
+	}
+	return w.Error()
+}
+
+// ValidReport 是否为支持的报表
+func ValidReport(name string) bool {
+	for _, v := range Reports {
+		if v == name {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckRange 校验时间段，from、to为YYYY-MM-DD格式的日期，均可为空
+func CheckRange(from string, to string) error {
+	for _, v := range []string{from, to} {
+		if v == "" {
+			continue
+		}
+		if _, err := time.Parse(dateLayout, v); err != nil {
+			return errors.New(fmt.Sprintf("日期%s格式出错，应为YYYY-MM-DD", v))
+		}
+	}
+	if from != "" && to != "" && from > to {
+		return errors.New(fmt.Sprintf("起始日期%s晚于结束日期%s", from, to))
+	}
+	return nil
+}
+
+// inRange 链码时间的日期是否在[from, to]内
+func inRange(t string, from string, to string) bool {
+	if len(t) > len(dateLayout) {
+		t = t[:len(dateLayout)]
+	}
+	return (from == "" || t >= from) && (to == "" || t <= to)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
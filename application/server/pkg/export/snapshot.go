@@ -0,0 +1,81 @@
+package export
+
+import (
+	bc "application/blockchain"
+	"application/model"
+	"application/pkg/app"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// snapshotAttempts 查询期间有新区块时重新查询的次数
+const snapshotAttempts = 3
+
+// Snapshot 在同一区块高度查询到的账本数据
+type Snapshot struct {
+	Chain       bc.ChainInfo
+	Accounts    []model.Account
+	RealEstates []model.RealEstate
+	Sellings    []model.Selling
+	Donatings   []model.Donating
+	Seed        *model.SeedRecord //链码Init写入的种子记录，导入状态或早于种子数据的账本为nil
+}
+
+// Take 查询账户、房地产、销售、捐赠和种子记录，查询前后的区块高度和哈希不一致时重新查询，保证数据取自同一区块
+func Take() (*Snapshot, error) {
+	for i := 0; i < snapshotAttempts; i++ {
+		before, err := bc.GetChainInfo()
+		if err != nil {
+			return nil, err
+		}
+		snap := &Snapshot{Chain: before}
+		if err := query("queryAccountList", &snap.Accounts); err != nil {
+			return nil, err
+		}
+		if err := query("queryRealEstateList", &snap.RealEstates); err != nil {
+			return nil, err
+		}
+		if err := query("querySellingList", &snap.Sellings); err != nil {
+			return nil, err
+		}
+		if err := query("queryDonatingList", &snap.Donatings); err != nil {
+			return nil, err
+		}
+		if snap.Seed, err = querySeed(); err != nil {
+			return nil, err
+		}
+		after, err := bc.GetChainInfo()
+		if err != nil {
+			return nil, err
+		}
+		if after == before {
+			return snap, nil
+		}
+	}
+	return nil, errors.New(fmt.Sprintf("查询期间账本持续有新区块，%d次均未取得一致的快照", snapshotAttempts))
+}
+
+// query 不带参数查询链码中的全部记录
+func query(fcn string, out interface{}) error {
+	resp, err := bc.ChannelQuery(fcn, [][]byte{})
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(resp.Payload, out); err != nil {
+		return errors.New(fmt.Sprintf("%s返回的数据格式出错: %s", fcn, err))
+	}
+	return nil
+}
+
+// querySeed 查询种子记录，账本中没有种子记录时返回nil
+func querySeed() (*model.SeedRecord, error) {
+	seed := new(model.SeedRecord)
+	if err := query("Ledger:QuerySeed", seed); err != nil {
+		if e := app.ParseChaincodeError(err.Error()); e != nil && e.Code == "NOT_FOUND" {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return seed, nil
+}
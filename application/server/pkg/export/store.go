@@ -0,0 +1,79 @@
+package export
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// reconcileBucket 对账结果，键为大端序的自增ID
+var reconcileBucket = []byte("reconciliations")
+
+// Store 基于BoltDB的对账结果存储
+type Store struct {
+	db *bolt.DB
+}
+
+// Open 打开对账结果数据库，不存在时创建
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(reconcileBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Store{db: db}, nil
+}
+
+// Close 关闭数据库
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Save 分配ID并保存对账结果
+func (s *Store) Save(rec *Reconciliation) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(reconcileBucket)
+		id, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		rec.ID = id
+		val, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		return bucket.Put(idKey(id), val)
+	})
+}
+
+// List 最近的至多limit次对账结果，新的在前
+func (s *Store) List(limit int) ([]Reconciliation, error) {
+	list := make([]Reconciliation, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(reconcileBucket).Cursor()
+		for k, v := c.Last(); k != nil && len(list) < limit; k, v = c.Prev() {
+			var rec Reconciliation
+			if err := json.Unmarshal(v, &rec); err != nil {
+				return err
+			}
+			list = append(list, rec)
+		}
+		return nil
+	})
+	return list, err
+}
+
+func idKey(id uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, id)
+	return key
+}
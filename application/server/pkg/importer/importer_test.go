@@ -0,0 +1,126 @@
+package importer
+
+import (
+	"application/model"
+	"application/pkg/setting"
+	"bytes"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeLedger 模拟链码batchCreateRealEstate，按外部编号去重，failAt次调用返回错误
+type fakeLedger struct {
+	calls  int
+	failAt int
+	refs   map[string]string
+}
+
+func (f *fakeLedger) execute(operator string, rows []model.RealEstateRow) (*model.BatchResult, error) {
+	f.calls++
+	if f.calls == f.failAt {
+		return nil, errors.New("连接节点超时")
+	}
+	result := new(model.BatchResult)
+	for i, row := range rows {
+		r := model.RowResult{Index: i, Ref: row.Ref}
+		id, exists := f.refs[row.Ref]
+		switch {
+		case row.Proprietor == "":
+			r.Status, r.Error = model.RowFailed, &model.RowError{Code: "VALIDATION", Key: "args.empty", Message: "proprietor不能为空"}
+			result.Failed++
+		case exists:
+			r.Status, r.RealEstate = model.RowExists, &model.RealEstate{RealEstateID: id}
+			result.Existing++
+		default:
+			id = fmt.Sprintf("id-%d", len(f.refs))
+			f.refs[row.Ref] = id
+			r.Status, r.RealEstate = model.RowCreated, &model.RealEstate{RealEstateID: id}
+			result.Created++
+		}
+		result.Results = append(result.Results, r)
+	}
+	return result, nil
+}
+
+// 测试分批提交中断后继续导入，已提交的批次不重复提交，错误报告包含格式错误和链码校验失败的行
+func TestResume(t *testing.T) {
+	Init(setting.Import{DBPath: filepath.Join(t.TempDir(), "imports.db"), ChunkSize: 2})
+	defer store.Close()
+	ledger := &fakeLedger{failAt: 2, refs: make(map[string]string)}
+	execute = ledger.execute
+	defer func() { execute = batchCreate }()
+
+	rows, err := Parse("registry.csv", []byte("\xef\xbb\xbfRef,Proprietor,TotalArea,LivingSpace\n"+
+		"A-1,owner1,100,80\n"+
+		"A-2,owner1,abc,80\n"+
+		"A-3,,100,80\n"+
+		",owner2,60,40\n"+
+		"A-5,owner2,60,40\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows[1].Status != model.RowFailed || rows[3].Ref == "" {
+		t.Fatalf("解析结果不符合预期: %+v", rows)
+	}
+	job, err := Submit("admin", "registry.csv", rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Wait()
+	job, _ = store.Job(job.ID)
+	if job.Status != StatusFailed || job.Next != 2 || job.Chunks != 1 || !strings.Contains(job.Error, "超时") {
+		t.Fatalf("第2批提交失败后任务应中断在第2行: %+v", job)
+	}
+
+	if _, err := Resume(job.ID); err != nil {
+		t.Fatal(err)
+	}
+	Wait()
+	job, _ = store.Job(job.ID)
+	if job.Status != StatusDone || job.Next != 5 || job.Created != 3 || job.Existing != 0 || job.Failed != 2 {
+		t.Fatalf("继续导入后任务应完成: %+v", job)
+	}
+	if ledger.calls != 4 || len(ledger.refs) != 3 {
+		t.Errorf("已提交的批次不应重复提交: 调用%d次，新建%d条", ledger.calls, len(ledger.refs))
+	}
+	if _, err := Resume(job.ID); err != ErrDone {
+		t.Errorf("已完成的任务不能继续导入: %v", err)
+	}
+
+	failures, err := store.Failures(job.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report bytes.Buffer
+	if err := WriteReport(&report, failures); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(report.String()), "\n")
+	if len(lines) != 3 || lines[0] != strings.Join(reportColumns, ",") ||
+		!strings.HasPrefix(lines[1], "3,A-2,owner1,abc,80,VALIDATION,args.format,") ||
+		!strings.HasPrefix(lines[2], "4,A-3,,100,80,VALIDATION,args.empty,") {
+		t.Errorf("错误报告不符合预期:\n%s", report.String())
+	}
+}
+
+// 测试文件格式错误
+func TestParseErrors(t *testing.T) {
+	cases := map[string]string{
+		"rows.txt":  "ref,proprietor,totalArea,livingSpace\n",
+		"rows.csv":  "ref,owner,totalArea\nA-1,owner1,100\n",
+		"rows.json": `{"proprietor":"owner1"}`,
+		"none.csv":  "proprietor,totalArea,livingSpace\n",
+	}
+	for name, content := range cases {
+		if _, err := Parse(name, []byte(content)); err == nil {
+			t.Errorf("%s应当解析失败", name)
+		}
+	}
+	rows, err := Parse("rows.json", []byte(`[{"proprietor":"owner1","totalArea":100,"livingSpace":80}]`))
+	if err != nil || len(rows) != 1 || rows[0].Line != 1 || rows[0].TotalArea != "100" || rows[0].Status != "" {
+		t.Errorf("JSON解析结果不符合预期: %+v %v", rows, err)
+	}
+}
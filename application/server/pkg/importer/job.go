@@ -0,0 +1,253 @@
+package importer
+
+import (
+	bc "application/blockchain"
+	"application/model"
+	"application/pkg/setting"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+)
+
+// 导入任务状态
+const (
+	StatusRunning = "running" //正在分批提交
+	StatusFailed  = "failed"  //某一批提交出错后中断，可继续导入
+	StatusDone    = "done"    //全部行已提交
+)
+
+// Job 导入任务，Next之前的行均已提交并保存了结果
+type Job struct {
+	ID        string    `json:"id"`
+	Operator  string    `json:"operator"` //提交导入的管理员
+	FileName  string    `json:"fileName"`
+	Status    string    `json:"status"`
+	Total     int       `json:"total"`           //总行数
+	Next      int       `json:"next"`            //下一批的起始行下标
+	Chunks    int       `json:"chunks"`          //已提交的批数
+	Created   int       `json:"created"`         //新建的行数
+	Existing  int       `json:"existing"`        //外部编号已导入过的行数
+	Failed    int       `json:"failed"`          //失败的行数，含文件中格式错误的行
+	Error     string    `json:"error,omitempty"` //中断的原因
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// Row 文件中的一行及其导入结果，数值保留文件中的原文用于错误报告
+type Row struct {
+	Index        int             `json:"index"` //在任务中的下标
+	Line         int             `json:"line"`  //文件中的行号
+	Ref          string          `json:"ref"`
+	Proprietor   string          `json:"proprietor"`
+	TotalArea    string          `json:"totalArea"`
+	LivingSpace  string          `json:"livingSpace"`
+	Status       string          `json:"status,omitempty"` //为空表示尚未提交
+	RealEstateID string          `json:"realEstateId,omitempty"`
+	Error        *model.RowError `json:"error,omitempty"`
+}
+
+var (
+	ErrNotFound = errors.New("导入任务不存在")
+	ErrRunning  = errors.New("导入任务正在执行")
+	ErrDone     = errors.New("导入任务已完成")
+)
+
+var (
+	store     *Store
+	chunkSize int
+	mu        sync.Mutex
+	running   = make(map[string]bool) //正在执行的任务
+	wg        sync.WaitGroup
+	// execute 提交一批行，测试时替换以模拟链码调用失败
+	execute = batchCreate
+)
+
+// Init 打开导入任务数据库，上次退出时仍在执行的任务标记为中断，可继续导入
+func Init(cfg setting.Import) {
+	chunkSize = cfg.ChunkSize
+	var err error
+	store, err = Open(cfg.DBPath)
+	if err != nil {
+		panic(err)
+	}
+	jobs, err := store.Jobs()
+	if err != nil {
+		panic(err)
+	}
+	for _, job := range jobs {
+		if job.Status == StatusRunning {
+			job.Status, job.Error = StatusFailed, "服务重启时任务中断"
+			if err := store.Save(&job, nil); err != nil {
+				panic(err)
+			}
+		}
+	}
+}
+
+// Default 默认的导入任务存储
+func Default() *Store {
+	return store
+}
+
+// Submit 新建导入任务并在后台分批提交
+func Submit(operator string, fileName string, rows []Row) (*Job, error) {
+	id, err := randomId()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	job := &Job{ID: id, Operator: operator, FileName: fileName, Status: StatusRunning, Total: len(rows), CreatedAt: now, UpdatedAt: now}
+	for _, row := range rows {
+		if row.Status == model.RowFailed {
+			job.Failed++
+		}
+	}
+	if err := store.Create(job, rows); err != nil {
+		return nil, err
+	}
+	start(*job)
+	return job, nil
+}
+
+// Resume 从中断的批次继续执行，已提交的批次不再重复提交
+func Resume(id string) (*Job, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	job, err := store.Job(id)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case job == nil:
+		return nil, ErrNotFound
+	case running[id]:
+		return nil, ErrRunning
+	case job.Status == StatusDone:
+		return nil, ErrDone
+	}
+	job.Status, job.Error, job.UpdatedAt = StatusRunning, "", time.Now()
+	if err := store.Save(job, nil); err != nil {
+		return nil, err
+	}
+	running[id] = true
+	wg.Add(1)
+	go run(*job)
+	return job, nil
+}
+
+// Wait 等待后台执行的任务全部结束
+func Wait() {
+	wg.Wait()
+}
+
+func start(job Job) {
+	mu.Lock()
+	defer mu.Unlock()
+	running[job.ID] = true
+	wg.Add(1)
+	go run(job)
+}
+
+// run 从job.Next开始每次提交chunkSize行，每批的结果与进度一起保存
+func run(job Job) {
+	defer func() {
+		mu.Lock()
+		delete(running, job.ID)
+		mu.Unlock()
+		wg.Done()
+	}()
+	for job.Next < job.Total {
+		rows, err := store.Rows(job.ID, job.Next, chunkSize)
+		if err == nil && len(rows) == 0 {
+			err = errors.New(fmt.Sprintf("第%d行不存在", job.Next))
+		}
+		if err == nil {
+			err = submitChunk(&job, rows)
+		}
+		if err != nil {
+			job.Status, job.Error, job.UpdatedAt = StatusFailed, err.Error(), time.Now()
+			if err := store.Save(&job, nil); err != nil {
+				log.Printf("导入任务%s保存失败: %s", job.ID, err)
+			}
+			log.Printf("导入任务%s在第%d行中断: %s", job.ID, job.Next, job.Error)
+			return
+		}
+	}
+	job.Status, job.UpdatedAt = StatusDone, time.Now()
+	if err := store.Save(&job, nil); err != nil {
+		log.Printf("导入任务%s保存失败: %s", job.ID, err)
+	}
+}
+
+// submitChunk 提交一批中尚未提交的行，更新行的结果和任务进度后保存
+func submitChunk(job *Job, rows []Row) error {
+	var pending []int
+	var values []model.RealEstateRow
+	for i, row := range rows {
+		if row.Status == "" {
+			pending = append(pending, i)
+			values = append(values, row.value())
+		}
+	}
+	if len(values) > 0 {
+		result, err := execute(job.Operator, values)
+		if err != nil {
+			return err
+		}
+		if len(result.Results) != len(values) {
+			return errors.New(fmt.Sprintf("链码返回%d行结果，提交了%d行", len(result.Results), len(values)))
+		}
+		for _, r := range result.Results {
+			if r.Index < 0 || r.Index >= len(pending) {
+				return errors.New(fmt.Sprintf("链码返回的行下标%d超出范围", r.Index))
+			}
+			row := &rows[pending[r.Index]]
+			row.Status, row.Error = r.Status, r.Error
+			if r.RealEstate != nil {
+				row.RealEstateID = r.RealEstate.RealEstateID
+			}
+			switch r.Status {
+			case model.RowCreated:
+				job.Created++
+			case model.RowExists:
+				job.Existing++
+			default:
+				job.Failed++
+			}
+		}
+	}
+	job.Next = rows[len(rows)-1].Index + 1
+	job.Chunks++
+	job.UpdatedAt = time.Now()
+	return store.Save(job, rows)
+}
+
+// batchCreate 以管理员本人的身份调用链码batchCreateRealEstate
+func batchCreate(operator string, rows []model.RealEstateRow) (*model.BatchResult, error) {
+	rowsByte, err := json.Marshal(rows)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := bc.ChannelExecuteAs(operator, "batchCreateRealEstate", [][]byte{[]byte(operator), rowsByte})
+	if err != nil {
+		return nil, err
+	}
+	result := new(model.BatchResult)
+	if err := json.Unmarshal(resp.Payload, result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func randomId() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
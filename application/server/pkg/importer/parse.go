@@ -0,0 +1,129 @@
+package importer
+
+import (
+	"application/model"
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// columns CSV文件的列名，ref可选，不区分大小写
+var columns = []string{"ref", "proprietor", "totalArea", "livingSpace"}
+
+// Parse 按扩展名解析.csv或.json文件，文件格式错误时返回错误
+// 数值无法解析的行直接记为失败，不提交到链码；没有外部编号的行以文件摘要和行号作为外部编号，重新上传同一文件不会重复导入
+func Parse(fileName string, data []byte) ([]Row, error) {
+	var rows []Row
+	var err error
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".csv":
+		rows, err = parseCSV(data)
+	case ".json":
+		rows, err = parseJSON(data)
+	default:
+		return nil, errors.New(fmt.Sprintf("不支持的文件%s，只支持.csv和.json", fileName))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("文件中没有要导入的行")
+	}
+	sum := sha256.Sum256(data)
+	digest := hex.EncodeToString(sum[:])[:16]
+	for i := range rows {
+		rows[i].Index = i
+		if rows[i].Ref == "" {
+			rows[i].Ref = fmt.Sprintf("%s:%d", digest, rows[i].Line)
+		}
+	}
+	return rows, nil
+}
+
+// parseCSV 第一行为列名，proprietor、totalArea、livingSpace必须有
+func parseCSV(data []byte) ([]Row, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\xef\xbb\xbf")))) //Excel导出的UTF-8 BOM
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("读取CSV列名失败: %s", err))
+	}
+	index := make(map[string]int)
+	for i, name := range header {
+		for _, column := range columns {
+			if strings.EqualFold(strings.TrimSpace(name), column) {
+				index[column] = i
+			}
+		}
+	}
+	for _, column := range columns[1:] {
+		if _, ok := index[column]; !ok {
+			return nil, errors.New(fmt.Sprintf("CSV缺少列%s，列名应为%s", column, strings.Join(columns, ",")))
+		}
+	}
+	var rows []Row
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("解析CSV失败: %s", err))
+		}
+		line, _ := reader.FieldPos(0)
+		value := func(column string) string {
+			if i, ok := index[column]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		row := Row{Line: line, Ref: value("ref"), Proprietor: value("proprietor"),
+			TotalArea: value("totalArea"), LivingSpace: value("livingSpace")}
+		row.check()
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// parseJSON 文件为对象数组，字段与CSV列名相同，行号为数组下标加1
+func parseJSON(data []byte) ([]Row, error) {
+	var values []model.RealEstateRow
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, errors.New(fmt.Sprintf("解析JSON失败，文件应为[{ref, proprietor, totalArea, livingSpace}]数组: %s", err))
+	}
+	rows := make([]Row, 0, len(values))
+	for i, v := range values {
+		rows = append(rows, Row{Line: i + 1, Ref: v.Ref, Proprietor: v.Proprietor,
+			TotalArea:   strconv.FormatFloat(v.TotalArea, 'f', -1, 64),
+			LivingSpace: strconv.FormatFloat(v.LivingSpace, 'f', -1, 64)})
+	}
+	return rows, nil
+}
+
+// check 校验数值格式，不合法时将该行记为失败
+func (r *Row) check() {
+	for _, v := range []struct{ name, value string }{{"totalArea", r.TotalArea}, {"livingSpace", r.LivingSpace}} {
+		if f, err := strconv.ParseFloat(v.value, 64); err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			r.Status = model.RowFailed
+			r.Error = &model.RowError{Code: "VALIDATION", Key: "args.format", Message: fmt.Sprintf("%s参数格式转换出错: %q", v.name, v.value)}
+			return
+		}
+	}
+}
+
+// value 转换为链码的参数，调用前已通过check
+func (r *Row) value() model.RealEstateRow {
+	totalArea, _ := strconv.ParseFloat(r.TotalArea, 64)
+	livingSpace, _ := strconv.ParseFloat(r.LivingSpace, 64)
+	return model.RealEstateRow{Ref: r.Ref, Proprietor: r.Proprietor, TotalArea: totalArea, LivingSpace: livingSpace}
+}
@@ -0,0 +1,45 @@
+package importer
+
+import (
+	"application/model"
+	"encoding/csv"
+	"io"
+	"strconv"
+)
+
+// reportColumns 错误报告的列，前几列与导入文件一致，修正后可直接重新导入
+var reportColumns = []string{"line", "ref", "proprietor", "totalArea", "livingSpace", "code", "key", "message"}
+
+// Failures 任务中失败的行
+func (s *Store) Failures(id string) ([]Row, error) {
+	rows, err := s.Rows(id, 0, -1)
+	if err != nil {
+		return nil, err
+	}
+	failures := make([]Row, 0)
+	for _, row := range rows {
+		if row.Status == model.RowFailed {
+			failures = append(failures, row)
+		}
+	}
+	return failures, nil
+}
+
+// WriteReport 以CSV输出失败的行和原因
+func WriteReport(w io.Writer, rows []Row) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write(reportColumns); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		record := []string{strconv.Itoa(row.Line), row.Ref, row.Proprietor, row.TotalArea, row.LivingSpace, "", "", ""}
+		if row.Error != nil {
+			record[5], record[6], record[7] = row.Error.Code, row.Error.Key, row.Error.Message
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
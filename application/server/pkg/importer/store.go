@@ -0,0 +1,141 @@
+package importer
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// 导入任务数据库中的bucket
+var (
+	jobBucket  = []byte("jobs") //jobID -> Job
+	rowBucket  = []byte("rows") //jobID/行下标 -> Row
+	allBuckets = [][]byte{jobBucket, rowBucket}
+)
+
+// Store 基于BoltDB的导入任务存储，任务的进度和每一行的结果在每批提交后保存
+type Store struct {
+	db *bolt.DB
+}
+
+// Open 打开导入任务数据库，不存在时创建
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range allBuckets {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Store{db: db}, nil
+}
+
+// Close 关闭数据库
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Create 保存新建的任务及其全部行
+func (s *Store) Create(job *Job, rows []Row) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := putJSON(tx.Bucket(jobBucket), []byte(job.ID), job); err != nil {
+			return err
+		}
+		for _, row := range rows {
+			if err := putJSON(tx.Bucket(rowBucket), rowKey(job.ID, row.Index), row); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Save 保存任务和本批各行的结果，两者在同一事务中写入，中断后按任务的Next继续
+func (s *Store) Save(job *Job, rows []Row) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, row := range rows {
+			if err := putJSON(tx.Bucket(rowBucket), rowKey(job.ID, row.Index), row); err != nil {
+				return err
+			}
+		}
+		return putJSON(tx.Bucket(jobBucket), []byte(job.ID), job)
+	})
+}
+
+// Job 根据ID获取任务，不存在时返回nil
+func (s *Store) Job(id string) (*Job, error) {
+	var job *Job
+	err := s.db.View(func(tx *bolt.Tx) error {
+		val := tx.Bucket(jobBucket).Get([]byte(id))
+		if val == nil {
+			return nil
+		}
+		job = new(Job)
+		return json.Unmarshal(val, job)
+	})
+	return job, err
+}
+
+// Jobs 全部任务，新建的在前
+func (s *Store) Jobs() ([]Job, error) {
+	jobs := make([]Job, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(jobBucket).ForEach(func(k, v []byte) error {
+			var job Job
+			if err := json.Unmarshal(v, &job); err != nil {
+				return err
+			}
+			jobs = append(jobs, job)
+			return nil
+		})
+	})
+	sort.SliceStable(jobs, func(i, j int) bool { return jobs[i].CreatedAt.After(jobs[j].CreatedAt) })
+	return jobs, err
+}
+
+// Rows 任务从下标from开始的至多n行，n小于0时返回之后的全部行
+func (s *Store) Rows(id string, from int, n int) ([]Row, error) {
+	var rows []Row
+	err := s.db.View(func(tx *bolt.Tx) error {
+		prefix := rowKey(id, 0)[:len(id)+1]
+		c := tx.Bucket(rowBucket).Cursor()
+		for k, v := c.Seek(rowKey(id, from)); k != nil && bytes.HasPrefix(k, prefix) && (n < 0 || len(rows) < n); k, v = c.Next() {
+			var row Row
+			if err := json.Unmarshal(v, &row); err != nil {
+				return err
+			}
+			rows = append(rows, row)
+		}
+		return nil
+	})
+	return rows, err
+}
+
+// rowKey 行的键为jobID、分隔符和大端序的行下标，同一任务的行按下标有序
+func rowKey(id string, index int) []byte {
+	key := make([]byte, len(id)+1+8)
+	copy(key, id)
+	binary.BigEndian.PutUint64(key[len(id)+1:], uint64(index))
+	return key
+}
+
+func putJSON(bucket *bolt.Bucket, key []byte, v interface{}) error {
+	val, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return bucket.Put(key, val)
+}
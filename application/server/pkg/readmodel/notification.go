@@ -0,0 +1,109 @@
+package readmodel
+
+import (
+	"application/model"
+	"fmt"
+)
+
+// Notification 推送给某个账户的通知
+type Notification struct {
+	Seq       uint64          `json:"seq"`       //事件序号，作为SSE的事件ID
+	Type      model.EventType `json:"type"`      //事件类型
+	TxID      string          `json:"txId"`      //交易ID
+	Timestamp string          `json:"timestamp"` //交易时间
+	Message   string          `json:"message"`   //通知内容
+	Payload   interface{}     `json:"payload"`   //事件内容
+	Amount    float64         `json:"amount"`    //余额变化后的余额或退款金额，见EventRecord.Amount
+}
+
+// NotificationFor 将事件转换为对accountId的通知，与该账户无关或无需通知的事件返回false
+func NotificationFor(record EventRecord, accountId string) (Notification, bool) {
+	if !contains(record.Accounts, accountId) {
+		return Notification{}, false
+	}
+	event := model.Event{Type: record.Type, Accounts: record.Accounts, Payload: record.Payload}
+	var message string
+	var payload interface{}
+	switch record.Type {
+	case model.BalanceChanged:
+		var v model.BalanceChangedPayload
+		if event.Decode(&v) != nil {
+			return Notification{}, false
+		}
+		message, payload = fmt.Sprintf("您的余额变为%.2f", record.Amount), v
+	case model.RealEstateCreated:
+		var v model.RealEstate
+		if event.Decode(&v) != nil {
+			return Notification{}, false
+		}
+		message, payload = fmt.Sprintf("管理员为您创建了房产%s", v.RealEstateID), v
+	case model.SellingCreated:
+		var v model.Selling
+		if event.Decode(&v) != nil {
+			return Notification{}, false
+		}
+		message, payload = fmt.Sprintf("您已发起房产%s的销售", v.ObjectOfSale), v
+	case model.SellingPurchased:
+		var v model.SellingBuy
+		if event.Decode(&v) != nil {
+			return Notification{}, false
+		}
+		if accountId == v.Selling.Seller {
+			message = fmt.Sprintf("您发起销售的房产%s已被买家%s购买，请确认收款", v.Selling.ObjectOfSale, v.Buyer)
+		} else {
+			message = fmt.Sprintf("您已购买房产%s，等待卖家确认收款", v.Selling.ObjectOfSale)
+		}
+		payload = v
+	case model.SellingCompleted:
+		var v model.SellingBuy
+		if event.Decode(&v) != nil {
+			return Notification{}, false
+		}
+		if accountId == v.Selling.Seller {
+			message = fmt.Sprintf("您已确认收款，房产%s的销售完成", v.Selling.ObjectOfSale)
+		} else {
+			message = fmt.Sprintf("卖家已确认收款，房产%s已过户给您", v.Selling.ObjectOfSale)
+		}
+		payload = v
+	case model.SellingCancelled, model.SellingExpired:
+		var v model.SellingClosedPayload
+		if event.Decode(&v) != nil {
+			return Notification{}, false
+		}
+		message = fmt.Sprintf("房产%s的销售%s", v.Selling.ObjectOfSale, v.Selling.SellingStatus)
+		if accountId == v.Buyer {
+			message += fmt.Sprintf("，%.2f已退回您的账户", record.Amount)
+		}
+		payload = v
+	case model.DonationCreated, model.DonationAccepted, model.DonationCancelled:
+		var v model.Donating
+		if event.Decode(&v) != nil {
+			return Notification{}, false
+		}
+		switch {
+		case record.Type == model.DonationCreated && accountId == v.Grantee:
+			message = fmt.Sprintf("%s向您捐赠房产%s，请确认受赠", v.Donor, v.ObjectOfDonating)
+		case record.Type == model.DonationCreated:
+			message = fmt.Sprintf("您已向%s发起房产%s的捐赠", v.Grantee, v.ObjectOfDonating)
+		case record.Type == model.DonationAccepted && accountId == v.Donor:
+			message = fmt.Sprintf("您捐赠的房产%s已被%s接收", v.ObjectOfDonating, v.Grantee)
+		case record.Type == model.DonationAccepted:
+			message = fmt.Sprintf("您已接收房产%s", v.ObjectOfDonating)
+		default:
+			message = fmt.Sprintf("房产%s的捐赠已取消", v.ObjectOfDonating)
+		}
+		payload = v
+	default:
+		//账户创建、房产过户已包含在其他通知中
+		return Notification{}, false
+	}
+	return Notification{
+		Seq:       record.Seq,
+		Type:      record.Type,
+		TxID:      record.TxID,
+		Timestamp: record.Timestamp,
+		Message:   message,
+		Payload:   payload,
+		Amount:    record.Amount,
+	}, true
+}
@@ -0,0 +1,161 @@
+package readmodel
+
+import (
+	"application/model"
+	"application/pkg/app"
+	"encoding/json"
+)
+
+// PrivateSource 读取事件中没有的私有字段，记录不存在时返回nil
+type PrivateSource interface {
+	AccountPrivate(accountId string) (*model.AccountPrivate, error)
+	OfferPrivate(seller, objectOfSale string) (*model.OfferPrivate, error)
+}
+
+// chaincodePrivate 通过链码的私有数据查询读取，服务端所在组织需为私有数据集合的成员
+type chaincodePrivate struct{}
+
+func (chaincodePrivate) AccountPrivate(accountId string) (*model.AccountPrivate, error) {
+	v := new(model.AccountPrivate)
+	if err := queryPrivate("Account:QueryAccountPrivate", [][]byte{[]byte(accountId)}, v); err != nil || v.AccountId == "" {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (chaincodePrivate) OfferPrivate(seller, objectOfSale string) (*model.OfferPrivate, error) {
+	v := new(model.OfferPrivate)
+	if err := queryPrivate("Selling:QueryOfferPrivate", [][]byte{[]byte(seller), []byte(objectOfSale)}, v); err != nil || v.Seller == "" {
+		return nil, err
+	}
+	return v, nil
+}
+
+// queryPrivate 记录已被删除(如导入账本状态时删除的账户)时不报错，v保持为空
+func queryPrivate(fcn string, args [][]byte, v interface{}) error {
+	err := queryList(fcn, args, v)
+	if err != nil {
+		if e := app.ParseChaincodeError(err.Error()); e != nil && e.Code == "NOT_FOUND" {
+			return nil
+		}
+	}
+	return err
+}
+
+// privateSet 一笔交易的事件涉及的私有字段，在写入读模型之前查询，避免在BoltDB的写事务中调用链码
+// 查询到的是账本的当前值，重新应用较早的事件时以当前值为准，与bootstrap一致
+type privateSet struct {
+	accounts map[string]*model.AccountPrivate
+	offers   map[string]*model.OfferPrivate
+}
+
+// fetchPrivate 查询事件涉及的账户余额和销售价格
+func fetchPrivate(source PrivateSource, events []model.Event) (*privateSet, error) {
+	set := &privateSet{accounts: make(map[string]*model.AccountPrivate), offers: make(map[string]*model.OfferPrivate)}
+	for _, e := range events {
+		accounts, sellings, err := privateKeys(e)
+		if err != nil {
+			return nil, err
+		}
+		for _, accountId := range accounts {
+			if _, ok := set.accounts[accountId]; ok {
+				continue
+			}
+			if set.accounts[accountId], err = source.AccountPrivate(accountId); err != nil {
+				return nil, err
+			}
+		}
+		for _, s := range sellings {
+			key := string(joinKey(s.Seller, s.ObjectOfSale))
+			if _, ok := set.offers[key]; ok {
+				continue
+			}
+			if set.offers[key], err = source.OfferPrivate(s.Seller, s.ObjectOfSale); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return set, nil
+}
+
+// privateKeys 事件中带私有字段的账户和销售
+func privateKeys(e model.Event) ([]string, []model.Selling, error) {
+	switch e.Type {
+	case model.AccountCreated:
+		var v model.Account
+		err := e.Decode(&v)
+		return []string{v.AccountId}, nil, err
+	case model.BalanceChanged:
+		var v model.BalanceChangedPayload
+		err := e.Decode(&v)
+		return []string{v.AccountId}, nil, err
+	case model.SellingCreated:
+		var v model.Selling
+		err := e.Decode(&v)
+		return nil, []model.Selling{v}, err
+	case model.SellingPurchased, model.SellingCompleted:
+		var v model.SellingBuy
+		err := e.Decode(&v)
+		return nil, []model.Selling{v.Selling}, err
+	case model.SellingCancelled, model.SellingExpired:
+		var v model.SellingClosedPayload
+		err := e.Decode(&v)
+		return nil, []model.Selling{v.Selling}, err
+	case model.StateImported:
+		var v model.StateDump
+		if err := e.Decode(&v); err != nil {
+			return nil, nil, err
+		}
+		return entryKeys(v.Entries)
+	case model.RecordsMigrated:
+		var v model.MigrationBatch
+		if err := e.Decode(&v); err != nil {
+			return nil, nil, err
+		}
+		return entryKeys(v.Entries)
+	}
+	return nil, nil, nil
+}
+
+func entryKeys(entries []model.StateEntry) ([]string, []model.Selling, error) {
+	var accounts []string
+	var sellings []model.Selling
+	for _, entry := range entries {
+		switch entry.ObjectType {
+		case model.AccountKey:
+			var v model.Account
+			if err := json.Unmarshal(entry.Value, &v); err != nil {
+				return nil, nil, err
+			}
+			accounts = append(accounts, v.AccountId)
+		case model.SellingKey:
+			var v model.Selling
+			if err := json.Unmarshal(entry.Value, &v); err != nil {
+				return nil, nil, err
+			}
+			sellings = append(sellings, v)
+		case model.SellingBuyKey:
+			var v model.SellingBuy
+			if err := json.Unmarshal(entry.Value, &v); err != nil {
+				return nil, nil, err
+			}
+			sellings = append(sellings, v.Selling)
+		}
+	}
+	return accounts, sellings, nil
+}
+
+// fillAccount 补齐账号名和余额
+func (p *privateSet) fillAccount(account *model.Account) {
+	if v := p.accounts[account.AccountId]; v != nil {
+		account.UserName = v.UserName
+		account.Balance = v.Balance
+	}
+}
+
+// fillSelling 补齐销售价格
+func (p *privateSet) fillSelling(selling *model.Selling) {
+	if v := p.offers[string(joinKey(selling.Seller, selling.ObjectOfSale))]; v != nil {
+		selling.Price = v.Price
+	}
+}
@@ -0,0 +1,209 @@
+package readmodel
+
+import (
+	"application/model"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Query 读模型查询条件
+type Query struct {
+	Keyword   string `json:"keyword"`   //全文检索关键字，多个关键字用空格分隔，需全部命中
+	AccountId string `json:"accountId"` //只查询与该账户相关的记录
+	SortBy    string `json:"sortBy"`    //排序字段
+	Desc      bool   `json:"desc"`      //是否倒序
+	Offset    int    `json:"offset"`    //分页偏移
+	Limit     int    `json:"limit"`     //分页大小，0表示不分页
+}
+
+// Page 分页结果
+type Page[T any] struct {
+	Total int `json:"total"` //满足条件的总数
+	Items []T `json:"items"` //当前页
+}
+
+// index 某类实体的检索方式
+type index[T any] struct {
+	bucket  []byte
+	text    func(v T) []string           //参与全文检索的字段
+	owners  func(v T) []string           //相关账户
+	sorters map[string]func(a, b T) bool //可排序字段
+}
+
+var realEstateIndex = index[model.RealEstate]{
+	bucket: realEstateBucket,
+	text: func(v model.RealEstate) []string {
+		return []string{v.RealEstateID, v.Proprietor}
+	},
+	owners: func(v model.RealEstate) []string {
+		return []string{v.Proprietor}
+	},
+	sorters: map[string]func(a, b model.RealEstate) bool{
+		"realEstateId": func(a, b model.RealEstate) bool { return a.RealEstateID < b.RealEstateID },
+		"totalArea":    func(a, b model.RealEstate) bool { return a.TotalArea < b.TotalArea },
+		"livingSpace":  func(a, b model.RealEstate) bool { return a.LivingSpace < b.LivingSpace },
+	},
+}
+
+var sellingIndex = index[model.Selling]{
+	bucket: sellingBucket,
+	text: func(v model.Selling) []string {
+		return []string{v.ObjectOfSale, v.Seller, v.Buyer, v.SellingStatus, v.CreateTime}
+	},
+	owners: func(v model.Selling) []string {
+		return []string{v.Seller, v.Buyer}
+	},
+	sorters: map[string]func(a, b model.Selling) bool{
+		"price":      func(a, b model.Selling) bool { return a.Price < b.Price },
+		"createTime": func(a, b model.Selling) bool { return a.CreateTime < b.CreateTime },
+		"salePeriod": func(a, b model.Selling) bool { return a.SalePeriod < b.SalePeriod },
+	},
+}
+
+var donatingIndex = index[model.Donating]{
+	bucket: donatingBucket,
+	text: func(v model.Donating) []string {
+		return []string{v.ObjectOfDonating, v.Donor, v.Grantee, v.DonatingStatus, v.CreateTime}
+	},
+	owners: func(v model.Donating) []string {
+		return []string{v.Donor, v.Grantee}
+	},
+	sorters: map[string]func(a, b model.Donating) bool{
+		"createTime": func(a, b model.Donating) bool { return a.CreateTime < b.CreateTime },
+	},
+}
+
+var accountIndex = index[model.Account]{
+	bucket: accountBucket,
+	text: func(v model.Account) []string {
+		return []string{v.AccountId, v.UserName}
+	},
+	owners: func(v model.Account) []string {
+		return []string{v.AccountId}
+	},
+	sorters: map[string]func(a, b model.Account) bool{
+		"accountId": func(a, b model.Account) bool { return a.AccountId < b.AccountId },
+		"balance":   func(a, b model.Account) bool { return a.Balance < b.Balance },
+	},
+}
+
+// SearchRealEstates 检索房地产，可按realEstateId/totalArea/livingSpace排序
+func (s *Store) SearchRealEstates(q Query) (Page[model.RealEstate], error) {
+	return search(s, realEstateIndex, q)
+}
+
+// SearchSellings 检索销售，可按price/createTime/salePeriod排序
+func (s *Store) SearchSellings(q Query) (Page[model.Selling], error) {
+	return search(s, sellingIndex, q)
+}
+
+// SearchDonatings 检索捐赠，可按createTime排序
+func (s *Store) SearchDonatings(q Query) (Page[model.Donating], error) {
+	return search(s, donatingIndex, q)
+}
+
+// SearchAccounts 检索账户，可按accountId/balance排序
+func (s *Store) SearchAccounts(q Query) (Page[model.Account], error) {
+	return search(s, accountIndex, q)
+}
+
+// Events 查询序号大于afterSeq的事件，accountId不为空时只返回与该账户相关的事件
+func (s *Store) Events(accountId string, afterSeq uint64, limit int) ([]EventRecord, error) {
+	records := make([]EventRecord, 0)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(eventBucket).Cursor()
+		for k, v := c.Seek(seqKey(afterSeq + 1)); k != nil; k, v = c.Next() {
+			var record EventRecord
+			if err := json.Unmarshal(v, &record); err != nil {
+				return err
+			}
+			if accountId != "" && !contains(record.Accounts, accountId) {
+				continue
+			}
+			records = append(records, record)
+			if limit > 0 && len(records) >= limit {
+				return nil
+			}
+		}
+		return nil
+	})
+	return records, err
+}
+
+func search[T any](s *Store, idx index[T], q Query) (Page[T], error) {
+	page := Page[T]{Items: make([]T, 0)}
+	var less func(a, b T) bool
+	if q.SortBy != "" {
+		var ok bool
+		if less, ok = idx.sorters[q.SortBy]; !ok {
+			return page, errors.New(fmt.Sprintf("不支持按%s排序", q.SortBy))
+		}
+	}
+	if q.Offset < 0 || q.Limit < 0 {
+		return page, errors.New("offset和limit不能为负数")
+	}
+	terms := strings.Fields(strings.ToLower(q.Keyword))
+	var items []T
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(idx.bucket).ForEach(func(k, v []byte) error {
+			var item T
+			if err := json.Unmarshal(v, &item); err != nil {
+				return err
+			}
+			if q.AccountId != "" && !contains(idx.owners(item), q.AccountId) {
+				return nil
+			}
+			if !matchTerms(idx.text(item), terms) {
+				return nil
+			}
+			items = append(items, item)
+			return nil
+		})
+	})
+	if err != nil {
+		return page, err
+	}
+	if less != nil {
+		sort.SliceStable(items, func(i, j int) bool {
+			if q.Desc {
+				return less(items[j], items[i])
+			}
+			return less(items[i], items[j])
+		})
+	}
+	page.Total = len(items)
+	if q.Offset >= len(items) {
+		return page, nil
+	}
+	items = items[q.Offset:]
+	if q.Limit > 0 && q.Limit < len(items) {
+		items = items[:q.Limit]
+	}
+	page.Items = items
+	return page, nil
+}
+
+// matchTerms 每个关键字都需要出现在某个字段中(不区分大小写)
+func matchTerms(fields []string, terms []string) bool {
+	text := strings.ToLower(strings.Join(fields, "\n"))
+	for _, term := range terms {
+		if !strings.Contains(text, term) {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(list []string, val string) bool {
+	for _, v := range list {
+		if v == val {
+			return true
+		}
+	}
+	return false
+}
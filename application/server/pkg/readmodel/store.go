@@ -0,0 +1,461 @@
+package readmodel
+
+import (
+	"application/model"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// 读模型中的bucket
+var (
+	metaBucket       = []byte("meta")        //检查点等元数据
+	txBucket         = []byte("txs")         //已处理的交易ID -> 区块号，用于重复送达时去重
+	eventBucket      = []byte("events")      //事件序号 -> EventRecord
+	accountBucket    = []byte("accounts")    //accountId -> Account
+	realEstateBucket = []byte("realEstates") //realEstateId -> RealEstate
+	sellingBucket    = []byte("sellings")    //seller/objectOfSale -> Selling
+	sellingBuyBucket = []byte("sellingBuys") //buyer/createTime -> SellingBuy
+	donatingBucket   = []byte("donatings")   //donor/objectOfDonating/grantee -> Donating
+	checkpointKey    = []byte("checkpoint")
+	allBuckets       = [][]byte{metaBucket, txBucket, eventBucket, accountBucket, realEstateBucket, sellingBucket, sellingBuyBucket, donatingBucket}
+)
+
+// Checkpoint 已处理到的位置，重启后从BlockNumber所在区块重新订阅(已处理的交易会被跳过)
+type Checkpoint struct {
+	BlockNumber uint64 `json:"blockNumber"` //区块号
+	TxID        string `json:"txId"`        //最后处理的交易ID，仅区块事件推进时为空
+}
+
+// EventRecord 持久化的事件，Seq在读模型内单调递增
+type EventRecord struct {
+	Seq         uint64          `json:"seq"`         //事件序号
+	BlockNumber uint64          `json:"blockNumber"` //区块号
+	TxID        string          `json:"txId"`        //交易ID
+	Timestamp   string          `json:"timestamp"`   //交易时间
+	Type        model.EventType `json:"type"`        //事件类型
+	Accounts    []string        `json:"accounts"`    //相关账户AccountId
+	Payload     json.RawMessage `json:"payload"`     //事件内容，不含私有字段
+	Amount      float64         `json:"amount"`      //读模型补齐的金额：余额变化后的余额，或交付中关闭时退还的价格
+}
+
+// Store 基于BoltDB的链下读模型
+type Store struct {
+	db          *bolt.DB
+	private     PrivateSource //读取事件中没有的私有字段
+	mu          sync.Mutex
+	subscribers map[chan struct{}]struct{} //有新事件时通知，订阅方再按序号读取
+}
+
+// Open 打开读模型数据库，不存在时创建
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range allBuckets {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Store{db: db, private: chaincodePrivate{}, subscribers: make(map[chan struct{}]struct{})}, nil
+}
+
+// Close 关闭数据库
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Checkpoint 读取检查点，从未同步过时返回nil
+func (s *Store) Checkpoint() (*Checkpoint, error) {
+	var checkpoint *Checkpoint
+	err := s.db.View(func(tx *bolt.Tx) error {
+		val := tx.Bucket(metaBucket).Get(checkpointKey)
+		if val == nil {
+			return nil
+		}
+		checkpoint = new(Checkpoint)
+		return json.Unmarshal(val, checkpoint)
+	})
+	return checkpoint, err
+}
+
+// HandleEvent 应用一笔交易的事件批次，更新实体并推进检查点，实现blockchain.EventHandler
+func (s *Store) HandleEvent(blockNumber uint64, txID string, payload []byte) error {
+	var batch model.EventBatch
+	if err := json.Unmarshal(payload, &batch); err != nil {
+		return errors.New(fmt.Sprintf("交易%s的事件反序列化出错: %s", txID, err))
+	}
+	if batch.Version > model.EventVersion {
+		return errors.New(fmt.Sprintf("交易%s的事件版本%d高于支持的版本%d，请升级应用", txID, batch.Version, model.EventVersion))
+	}
+	if processed, err := s.processed(txID); err != nil || processed {
+		return err
+	}
+	privates, err := fetchPrivate(s.private, batch.Events)
+	if err != nil {
+		return errors.New(fmt.Sprintf("交易%s的私有数据查询出错: %s", txID, err))
+	}
+	applied := false
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(txBucket).Get([]byte(txID)) != nil {
+			return nil
+		}
+		applied = len(batch.Events) > 0
+		for _, e := range batch.Events {
+			amount, err := applyEvent(tx, e, privates)
+			if err != nil {
+				return errors.New(fmt.Sprintf("交易%s的%s事件处理出错: %s", txID, e.Type, err))
+			}
+			seq, err := tx.Bucket(eventBucket).NextSequence()
+			if err != nil {
+				return err
+			}
+			record := EventRecord{Seq: seq, BlockNumber: blockNumber, TxID: txID, Timestamp: batch.Timestamp,
+				Type: e.Type, Accounts: e.Accounts, Payload: e.Payload, Amount: amount}
+			if err := putJSON(tx.Bucket(eventBucket), seqKey(seq), record); err != nil {
+				return err
+			}
+		}
+		if err := tx.Bucket(txBucket).Put([]byte(txID), seqKey(blockNumber)); err != nil {
+			return err
+		}
+		return putCheckpoint(tx, Checkpoint{BlockNumber: blockNumber, TxID: txID})
+	})
+	if err == nil && applied {
+		s.notify()
+	}
+	return err
+}
+
+// processed 交易是否已处理，重复送达的交易不再查询私有数据
+func (s *Store) processed(txID string) (bool, error) {
+	var processed bool
+	err := s.db.View(func(tx *bolt.Tx) error {
+		processed = tx.Bucket(txBucket).Get([]byte(txID)) != nil
+		return nil
+	})
+	return processed, err
+}
+
+// LastSeq 最新的事件序号
+func (s *Store) LastSeq() (uint64, error) {
+	var seq uint64
+	err := s.db.View(func(tx *bolt.Tx) error {
+		seq = tx.Bucket(eventBucket).Sequence()
+		return nil
+	})
+	return seq, err
+}
+
+// Subscribe 订阅新事件通知，返回的函数用于取消订阅
+// 通知只表示有新事件，多次通知可能合并为一次，订阅方应按序号读取全部新事件
+func (s *Store) Subscribe() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	s.mu.Lock()
+	s.subscribers[ch] = struct{}{}
+	s.mu.Unlock()
+	return ch, func() {
+		s.mu.Lock()
+		delete(s.subscribers, ch)
+		s.mu.Unlock()
+	}
+}
+
+func (s *Store) notify() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for ch := range s.subscribers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// HandleBlock 区块已处理完，检查点推进到下一个区块，实现blockchain.EventHandler
+func (s *Store) HandleBlock(blockNumber uint64) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return putCheckpoint(tx, Checkpoint{BlockNumber: blockNumber + 1})
+	})
+}
+
+// putCheckpoint 检查点只前进不后退
+func putCheckpoint(tx *bolt.Tx, checkpoint Checkpoint) error {
+	bucket := tx.Bucket(metaBucket)
+	if val := bucket.Get(checkpointKey); val != nil {
+		var current Checkpoint
+		if err := json.Unmarshal(val, &current); err != nil {
+			return err
+		}
+		if current.BlockNumber > checkpoint.BlockNumber {
+			return nil
+		}
+	}
+	return putJSON(bucket, checkpointKey, checkpoint)
+}
+
+// applyEvent 根据事件更新实体，事件中没有的私有字段从privates补齐
+// 返回通知用的金额：余额变化后的余额，交付中关闭时退还给买家的价格
+func applyEvent(tx *bolt.Tx, e model.Event, privates *privateSet) (float64, error) {
+	switch e.Type {
+	case model.AccountCreated:
+		var account model.Account
+		if err := e.Decode(&account); err != nil {
+			return 0, err
+		}
+		privates.fillAccount(&account)
+		return 0, putJSON(tx.Bucket(accountBucket), []byte(account.AccountId), account)
+	case model.BalanceChanged:
+		var payload model.BalanceChangedPayload
+		if err := e.Decode(&payload); err != nil {
+			return 0, err
+		}
+		account := model.Account{AccountId: payload.AccountId}
+		if _, err := getJSON(tx.Bucket(accountBucket), []byte(payload.AccountId), &account); err != nil {
+			return 0, err
+		}
+		privates.fillAccount(&account)
+		return account.Balance, putJSON(tx.Bucket(accountBucket), []byte(account.AccountId), account)
+	case model.RealEstateCreated:
+		var realEstate model.RealEstate
+		if err := e.Decode(&realEstate); err != nil {
+			return 0, err
+		}
+		return 0, putJSON(tx.Bucket(realEstateBucket), []byte(realEstate.RealEstateID), realEstate)
+	case model.RealEstateTransferred:
+		var payload model.RealEstateTransferredPayload
+		if err := e.Decode(&payload); err != nil {
+			return 0, err
+		}
+		return 0, updateRealEstate(tx, payload.RealEstateID, func(realEstate *model.RealEstate) {
+			realEstate.Proprietor = payload.To
+			realEstate.Encumbrance = false
+		})
+	case model.SellingCreated:
+		var selling model.Selling
+		if err := e.Decode(&selling); err != nil {
+			return 0, err
+		}
+		privates.fillSelling(&selling)
+		if err := putSelling(tx, selling); err != nil {
+			return 0, err
+		}
+		return 0, setEncumbrance(tx, selling.ObjectOfSale, true)
+	case model.SellingPurchased, model.SellingCompleted:
+		var sellingBuy model.SellingBuy
+		if err := e.Decode(&sellingBuy); err != nil {
+			return 0, err
+		}
+		privates.fillSelling(&sellingBuy.Selling)
+		if err := putJSON(tx.Bucket(sellingBuyBucket), sellingBuyKey(sellingBuy), sellingBuy); err != nil {
+			return 0, err
+		}
+		return 0, putSelling(tx, sellingBuy.Selling)
+	case model.SellingCancelled, model.SellingExpired:
+		var payload model.SellingClosedPayload
+		if err := e.Decode(&payload); err != nil {
+			return 0, err
+		}
+		privates.fillSelling(&payload.Selling)
+		if err := putSelling(tx, payload.Selling); err != nil {
+			return 0, err
+		}
+		var refund float64
+		if payload.Buyer != "" {
+			refund = payload.Selling.Price
+			if err := closeSellingBuy(tx, payload.Buyer, payload.Selling); err != nil {
+				return 0, err
+			}
+		}
+		return refund, setEncumbrance(tx, payload.Selling.ObjectOfSale, false)
+	case model.DonationCreated, model.DonationAccepted, model.DonationCancelled:
+		var donating model.Donating
+		if err := e.Decode(&donating); err != nil {
+			return 0, err
+		}
+		if err := putJSON(tx.Bucket(donatingBucket), joinKey(donating.Donor, donating.ObjectOfDonating, donating.Grantee), donating); err != nil {
+			return 0, err
+		}
+		switch e.Type {
+		case model.DonationCreated:
+			return 0, setEncumbrance(tx, donating.ObjectOfDonating, true)
+		case model.DonationCancelled:
+			return 0, setEncumbrance(tx, donating.ObjectOfDonating, false)
+		}
+		return 0, nil
+	case model.StateImported:
+		var dump model.StateDump
+		if err := e.Decode(&dump); err != nil {
+			return 0, err
+		}
+		return 0, importState(tx, dump, privates)
+	case model.RecordsMigrated:
+		var batch model.MigrationBatch
+		if err := e.Decode(&batch); err != nil {
+			return 0, err
+		}
+		for _, entry := range batch.Entries {
+			if err := putEntry(tx, entry, privates); err != nil {
+				return 0, err
+			}
+		}
+		return 0, nil
+	default:
+		//同一版本内新增的事件类型，只记录事件不更新实体
+		return 0, nil
+	}
+}
+
+func putSelling(tx *bolt.Tx, selling model.Selling) error {
+	return putJSON(tx.Bucket(sellingBucket), joinKey(selling.Seller, selling.ObjectOfSale), selling)
+}
+
+// closeSellingBuy 同步更新买家交付中的购买记录
+func closeSellingBuy(tx *bolt.Tx, buyer string, selling model.Selling) error {
+	bucket := tx.Bucket(sellingBuyBucket)
+	prefix := joinKey(buyer)
+	c := bucket.Cursor()
+	for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+		var sellingBuy model.SellingBuy
+		if err := json.Unmarshal(v, &sellingBuy); err != nil {
+			return err
+		}
+		if sellingBuy.Selling.Seller == selling.Seller && sellingBuy.Selling.ObjectOfSale == selling.ObjectOfSale &&
+			sellingBuy.Selling.SellingStatus == model.SellingStatusConstant()["delivery"] {
+			sellingBuy.Selling = selling
+			return putJSON(bucket, k, sellingBuy)
+		}
+	}
+	return nil
+}
+
+// entityBuckets 读模型中的实体，导入账本状态时清空后重建
+var entityBuckets = [][]byte{accountBucket, realEstateBucket, sellingBucket, sellingBuyBucket, donatingBucket}
+
+// importState 链码导入了账本状态，实体按导出文件重建，受赠记录和外部编号不在读模型中
+func importState(tx *bolt.Tx, dump model.StateDump, privates *privateSet) error {
+	for _, name := range entityBuckets {
+		if err := tx.DeleteBucket(name); err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucket(name); err != nil {
+			return err
+		}
+	}
+	for _, entry := range dump.Entries {
+		if err := putEntry(tx, entry, privates); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// putEntry 按账本中记录的公开部分写入实体，私有字段从privates补齐，受赠记录和外部编号不在读模型中
+func putEntry(tx *bolt.Tx, entry model.StateEntry, privates *privateSet) error {
+	var err error
+	switch entry.ObjectType {
+	case model.AccountKey:
+		var v model.Account
+		if err = json.Unmarshal(entry.Value, &v); err == nil {
+			privates.fillAccount(&v)
+			err = putJSON(tx.Bucket(accountBucket), []byte(v.AccountId), v)
+		}
+	case model.RealEstateKey:
+		var v model.RealEstate
+		if err = json.Unmarshal(entry.Value, &v); err == nil {
+			err = putJSON(tx.Bucket(realEstateBucket), []byte(v.RealEstateID), v)
+		}
+	case model.SellingKey:
+		var v model.Selling
+		if err = json.Unmarshal(entry.Value, &v); err == nil {
+			privates.fillSelling(&v)
+			err = putSelling(tx, v)
+		}
+	case model.SellingBuyKey:
+		var v model.SellingBuy
+		if err = json.Unmarshal(entry.Value, &v); err == nil {
+			privates.fillSelling(&v.Selling)
+			err = putJSON(tx.Bucket(sellingBuyBucket), sellingBuyKey(v), v)
+		}
+	case model.DonatingKey:
+		var v model.Donating
+		if err = json.Unmarshal(entry.Value, &v); err == nil {
+			err = putJSON(tx.Bucket(donatingBucket), joinKey(v.Donor, v.ObjectOfDonating, v.Grantee), v)
+		}
+	}
+	if err != nil {
+		return errors.New(fmt.Sprintf("%s%v: %s", entry.ObjectType, entry.Keys, err))
+	}
+	return nil
+}
+
+func setEncumbrance(tx *bolt.Tx, realEstateID string, encumbrance bool) error {
+	return updateRealEstate(tx, realEstateID, func(realEstate *model.RealEstate) {
+		realEstate.Encumbrance = encumbrance
+	})
+}
+
+// updateRealEstate 更新房地产，读模型中不存在时忽略(例如早于事件机制创建的数据)
+func updateRealEstate(tx *bolt.Tx, realEstateID string, update func(realEstate *model.RealEstate)) error {
+	bucket := tx.Bucket(realEstateBucket)
+	var realEstate model.RealEstate
+	found, err := getJSON(bucket, []byte(realEstateID), &realEstate)
+	if err != nil || !found {
+		return err
+	}
+	update(&realEstate)
+	return putJSON(bucket, []byte(realEstateID), realEstate)
+}
+
+func putJSON(bucket *bolt.Bucket, key []byte, v interface{}) error {
+	val, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return bucket.Put(key, val)
+}
+
+func getJSON(bucket *bolt.Bucket, key []byte, v interface{}) (bool, error) {
+	val := bucket.Get(key)
+	if val == nil {
+		return false, nil
+	}
+	return true, json.Unmarshal(val, v)
+}
+
+// sellingBuyKey 与链码一致，同一买家同一秒内的多次购买由TxID区分，旧记录没有TxID
+func sellingBuyKey(v model.SellingBuy) []byte {
+	if v.TxID == "" {
+		return joinKey(v.Buyer, v.CreateTime)
+	}
+	return joinKey(v.Buyer, v.CreateTime, v.TxID)
+}
+
+// joinKey 与链码复合主键一致，用\x00分隔各部分，保证前缀查询不会串号
+func joinKey(parts ...string) []byte {
+	var key []byte
+	for _, part := range parts {
+		key = append(key, part...)
+		key = append(key, 0)
+	}
+	return key
+}
+
+func seqKey(seq uint64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, seq)
+	return key
+}
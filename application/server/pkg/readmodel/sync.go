@@ -0,0 +1,132 @@
+package readmodel
+
+import (
+	bc "application/blockchain"
+	"application/model"
+	"application/pkg/setting"
+	"encoding/json"
+	"log"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	retryInterval time.Duration // 订阅中断后重新订阅的间隔
+	store         *Store
+)
+
+// Init 打开读模型数据库
+func Init(cfg setting.ReadModel) {
+	retryInterval = cfg.RetryInterval
+	var err error
+	store, err = Open(cfg.DBPath)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// Default 默认的读模型
+func Default() *Store {
+	return store
+}
+
+// Run 同步链上数据到读模型，订阅中断后从检查点重新订阅
+func Run() {
+	for {
+		if err := syncOnce(); err != nil {
+			log.Printf("读模型同步中断，%s后重试: %s", retryInterval, err)
+		}
+		time.Sleep(retryInterval)
+	}
+}
+
+func syncOnce() error {
+	checkpoint, err := store.Checkpoint()
+	if err != nil {
+		return err
+	}
+	//从未同步过，先导入链上快照，再从快照时的区块高度开始订阅
+	if checkpoint == nil {
+		if checkpoint, err = store.bootstrap(); err != nil {
+			return err
+		}
+	}
+	log.Printf("读模型从区块%d开始同步", checkpoint.BlockNumber)
+	return bc.Listen(checkpoint.BlockNumber, store)
+}
+
+// bootstrap 导入链上快照
+// 先取区块高度再查询，快照之后提交的交易会被重新应用，事件内容均为最终状态，重复应用不影响结果
+func (s *Store) bootstrap() (*Checkpoint, error) {
+	height, err := bc.BlockHeight()
+	if err != nil {
+		return nil, err
+	}
+	var accounts []model.Account
+	if err := queryList("queryAccountList", nil, &accounts); err != nil {
+		return nil, err
+	}
+	var realEstates []model.RealEstate
+	if err := queryList("queryRealEstateList", nil, &realEstates); err != nil {
+		return nil, err
+	}
+	var sellings []model.Selling
+	if err := queryList("querySellingList", nil, &sellings); err != nil {
+		return nil, err
+	}
+	var donatings []model.Donating
+	if err := queryList("queryDonatingList", nil, &donatings); err != nil {
+		return nil, err
+	}
+	var sellingBuys []model.SellingBuy
+	for _, account := range accounts {
+		var list []model.SellingBuy
+		if err := queryList("querySellingListByBuyer", [][]byte{[]byte(account.AccountId)}, &list); err != nil {
+			return nil, err
+		}
+		sellingBuys = append(sellingBuys, list...)
+	}
+	checkpoint := &Checkpoint{BlockNumber: height}
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		for _, v := range accounts {
+			if err := putJSON(tx.Bucket(accountBucket), []byte(v.AccountId), v); err != nil {
+				return err
+			}
+		}
+		for _, v := range realEstates {
+			if err := putJSON(tx.Bucket(realEstateBucket), []byte(v.RealEstateID), v); err != nil {
+				return err
+			}
+		}
+		for _, v := range sellings {
+			if err := putSelling(tx, v); err != nil {
+				return err
+			}
+		}
+		for _, v := range sellingBuys {
+			if err := putJSON(tx.Bucket(sellingBuyBucket), sellingBuyKey(v), v); err != nil {
+				return err
+			}
+		}
+		for _, v := range donatings {
+			if err := putJSON(tx.Bucket(donatingBucket), joinKey(v.Donor, v.ObjectOfDonating, v.Grantee), v); err != nil {
+				return err
+			}
+		}
+		return putCheckpoint(tx, *checkpoint)
+	})
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("读模型已导入区块高度%d时的快照", height)
+	return checkpoint, nil
+}
+
+func queryList(fcn string, args [][]byte, v interface{}) error {
+	resp, err := bc.ChannelQuery(fcn, args)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(resp.Payload, v)
+}
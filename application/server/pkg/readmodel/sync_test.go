@@ -0,0 +1,234 @@
+package readmodel
+
+import (
+	bc "application/blockchain"
+	_ "application/blockchain/inprocess"
+	"application/model"
+	"application/pkg/setting"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+// 链码初始化的账户
+const (
+	admin  = "5feceb66ffc8"
+	owner1 = "6b86b273ff34"
+)
+
+// errCaughtUp 同步到当前区块高度后结束订阅
+var errCaughtUp = errors.New("caught up")
+
+// untilHeight 处理完height之前的区块后返回errCaughtUp，使bc.Listen返回
+type untilHeight struct {
+	*Store
+	height uint64
+}
+
+func (h untilHeight) HandleBlock(blockNumber uint64) error {
+	if err := h.Store.HandleBlock(blockNumber); err != nil {
+		return err
+	}
+	if blockNumber+1 >= h.height {
+		return errCaughtUp
+	}
+	return nil
+}
+
+// newLedger 每个测试使用新的进程内账本
+func newLedger(t *testing.T) {
+	cfg := setting.Default().Fabric
+	cfg.Ledger = "inprocess"
+	bc.Init(cfg, "error")
+}
+
+// catchUp 从检查点同步到当前区块高度，从未同步过时先导入快照，与syncOnce一致
+func catchUp(t *testing.T, s *Store) {
+	t.Helper()
+	checkpoint, err := s.Checkpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checkpoint == nil {
+		if checkpoint, err = s.bootstrap(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	replay(t, s, checkpoint.BlockNumber)
+}
+
+// replay 从from区块重新送达事件直到当前区块高度
+func replay(t *testing.T, s *Store, from uint64) {
+	t.Helper()
+	height, err := bc.BlockHeight()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if from >= height {
+		return
+	}
+	if err := bc.Listen(from, untilHeight{Store: s, height: height}); err != errCaughtUp {
+		t.Fatal(err)
+	}
+	checkpoint, err := s.Checkpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if checkpoint.BlockNumber < height {
+		t.Fatalf("同步后检查点应推进到区块高度%d，实际%d", height, checkpoint.BlockNumber)
+	}
+}
+
+func openStore(t *testing.T, path string) *Store {
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+// createRealEstate 管理员为owner1创建房产，返回房产ID
+func createRealEstate(t *testing.T) string {
+	t.Helper()
+	resp, err := bc.ChannelExecuteAs(admin, "createRealEstate", [][]byte{
+		[]byte(admin), []byte(owner1), []byte(strconv.FormatFloat(100, 'E', -1, 64)), []byte(strconv.FormatFloat(80, 'E', -1, 64)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var realEstate model.RealEstate
+	if err := json.Unmarshal(resp.Payload, &realEstate); err != nil {
+		t.Fatal(err)
+	}
+	return realEstate.RealEstateID
+}
+
+func realEstateTotal(t *testing.T, s *Store) int {
+	t.Helper()
+	page, err := s.SearchRealEstates(Query{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return page.Total
+}
+
+// 测试从空的读模型启动：导入链上快照，检查点为快照时的区块高度，之后的交易从检查点开始同步
+func TestBootstrap(t *testing.T) {
+	newLedger(t)
+	createRealEstate(t)
+	s := openStore(t, filepath.Join(t.TempDir(), "readmodel.db"))
+	defer s.Close()
+	if checkpoint, err := s.Checkpoint(); err != nil || checkpoint != nil {
+		t.Fatalf("空的读模型不应有检查点: %+v %v", checkpoint, err)
+	}
+
+	catchUp(t, s)
+	height, _ := bc.BlockHeight()
+	checkpoint, err := s.Checkpoint()
+	if err != nil || checkpoint.BlockNumber != height {
+		t.Fatalf("导入快照后检查点应为区块高度%d: %+v %v", height, checkpoint, err)
+	}
+	accounts, err := s.SearchAccounts(Query{})
+	if err != nil || accounts.Total != 6 {
+		t.Fatalf("应导入链码初始化的6个账户: %+v %v", accounts, err)
+	}
+	if total := realEstateTotal(t, s); total != 1 {
+		t.Fatalf("应导入快照中的1个房产，实际%d个", total)
+	}
+	if seq, _ := s.LastSeq(); seq != 0 {
+		t.Fatalf("快照中的数据不产生事件，实际事件序号%d", seq)
+	}
+
+	id := createRealEstate(t)
+	catchUp(t, s)
+	if total := realEstateTotal(t, s); total != 2 {
+		t.Fatalf("快照之后创建的房产应同步到读模型，实际%d个", total)
+	}
+	records, err := s.Events(owner1, 0, 0)
+	if err != nil || len(records) != 1 || records[0].Type != model.RealEstateCreated || records[0].BlockNumber != height {
+		t.Fatalf("应只记录快照之后的房产%s创建事件: %+v %v", id, records, err)
+	}
+}
+
+// 测试检查点续传：重新打开读模型后从检查点继续同步，已处理的事件不重复记录
+func TestCheckpointResume(t *testing.T) {
+	newLedger(t)
+	path := filepath.Join(t.TempDir(), "readmodel.db")
+	s := openStore(t, path)
+	catchUp(t, s)
+	createRealEstate(t)
+	catchUp(t, s)
+	seq, err := s.LastSeq()
+	if err != nil || seq == 0 {
+		t.Fatalf("创建房产应记录事件: %d %v", seq, err)
+	}
+	checkpoint, _ := s.Checkpoint()
+	s.Close()
+
+	//停机期间提交的交易
+	id := createRealEstate(t)
+	s = openStore(t, path)
+	defer s.Close()
+	if resumed, err := s.Checkpoint(); err != nil || *resumed != *checkpoint {
+		t.Fatalf("重新打开后检查点应保持不变: %+v %+v %v", checkpoint, resumed, err)
+	}
+	catchUp(t, s)
+	if total := realEstateTotal(t, s); total != 2 {
+		t.Fatalf("停机期间创建的房产应同步到读模型，实际%d个", total)
+	}
+	records, err := s.Events("", seq, 0)
+	if err != nil || len(records) == 0 || records[0].Seq != seq+1 {
+		t.Fatalf("事件序号应在原有基础上连续递增: %+v %v", records, err)
+	}
+	for _, record := range records {
+		if record.BlockNumber < checkpoint.BlockNumber {
+			t.Fatalf("检查点之前的区块不应重新记录事件: %+v", record)
+		}
+	}
+	var realEstate model.RealEstate
+	if err := json.Unmarshal(records[0].Payload, &realEstate); err != nil || realEstate.RealEstateID != id {
+		t.Fatalf("续传的第一条事件应为停机期间创建的房产%s: %s %v", id, records[0].Payload, err)
+	}
+}
+
+// 测试交易去重：从头重放全部区块或重复送达同一交易时，不重复记录事件、不改变实体
+func TestDedup(t *testing.T) {
+	newLedger(t)
+	s := openStore(t, filepath.Join(t.TempDir(), "readmodel.db"))
+	defer s.Close()
+	catchUp(t, s)
+	createRealEstate(t)
+	createRealEstate(t)
+	catchUp(t, s)
+	seq, _ := s.LastSeq()
+	checkpoint, _ := s.Checkpoint()
+
+	replay(t, s, checkpoint.BlockNumber-2)
+	if again, _ := s.LastSeq(); again != seq {
+		t.Fatalf("重放已处理的区块不应重复记录事件: %d -> %d", seq, again)
+	}
+	if total := realEstateTotal(t, s); total != 2 {
+		t.Fatalf("重放后房产数量应不变，实际%d个", total)
+	}
+	if resumed, _ := s.Checkpoint(); resumed.BlockNumber != checkpoint.BlockNumber {
+		t.Fatalf("重放后检查点应不变: %+v %+v", checkpoint, resumed)
+	}
+
+	records, err := s.Events("", 0, 1)
+	if err != nil || len(records) != 1 {
+		t.Fatal(err)
+	}
+	batch, err := json.Marshal(model.EventBatch{Version: model.EventVersion, Timestamp: records[0].Timestamp,
+		Events: []model.Event{{Type: records[0].Type, Accounts: records[0].Accounts, Payload: records[0].Payload}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.HandleEvent(records[0].BlockNumber, records[0].TxID, batch); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := s.LastSeq(); again != seq {
+		t.Fatalf("重复送达的交易不应重复记录事件: %d -> %d", seq, again)
+	}
+}
@@ -0,0 +1,179 @@
+package setting
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// envPrefix 环境变量前缀，配置项路径转为大写并以下划线连接，如REALTY_SERVER_LISTEN、REALTY_FABRIC_POOL_IDLETIMEOUT
+const envPrefix = "REALTY_"
+
+// legacyEnv 早先版本使用的环境变量，继续支持
+var legacyEnv = map[string]string{
+	"JWT_SECRET":        "auth.jwtSecret",
+	"WALLET_PASSPHRASE": "fabric.wallet.passphrase",
+}
+
+// field 可以通过环境变量和命令行参数覆盖的配置项，只包含字符串、布尔、整数、时长和字符串列表
+type field struct {
+	path  string //配置文件中的路径，如server.tls.enabled
+	index []int
+	kind  reflect.Type
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// fields 遍历配置项
+func fields(t reflect.Type, prefix string, index []int) []field {
+	var list []field
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name := strings.Split(f.Tag.Get("yaml"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		path := name
+		if prefix != "" {
+			path = prefix + "." + name
+		}
+		idx := append(append([]int(nil), index...), i)
+		switch {
+		case f.Type.Kind() == reflect.Struct:
+			list = append(list, fields(f.Type, path, idx)...)
+		case f.Type.Kind() == reflect.Slice && f.Type.Elem().Kind() != reflect.String:
+			//通道等结构体列表只能在配置文件中设置
+		default:
+			list = append(list, field{path: path, index: idx, kind: f.Type})
+		}
+	}
+	return list
+}
+
+// set 将字符串形式的值写入配置项
+func (f field) set(cfg *Config, value string) error {
+	v := reflect.ValueOf(cfg).Elem().FieldByIndex(f.index)
+	switch {
+	case f.kind == durationType:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%s格式出错: %s", f.path, err)
+		}
+		v.SetInt(int64(d))
+	case f.kind.Kind() == reflect.String:
+		v.SetString(value)
+	case f.kind.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%s格式出错: %s", f.path, err)
+		}
+		v.SetBool(b)
+	case f.kind.Kind() == reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%s格式出错: %s", f.path, err)
+		}
+		v.SetInt(int64(n))
+	case f.kind.Kind() == reflect.Float64:
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("%s格式出错: %s", f.path, err)
+		}
+		v.SetFloat(n)
+	case f.kind.Kind() == reflect.Slice:
+		var list []string
+		for _, s := range strings.Split(value, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				list = append(list, s)
+			}
+		}
+		v.Set(reflect.ValueOf(list))
+	default:
+		return fmt.Errorf("%s不支持覆盖", f.path)
+	}
+	return nil
+}
+
+func envName(path string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(path, ".", "_"))
+}
+
+// applyEnv 使用环境变量覆盖配置
+func applyEnv(cfg *Config) error {
+	list := fields(reflect.TypeOf(*cfg), "", nil)
+	byPath := make(map[string]field, len(list))
+	for _, f := range list {
+		byPath[f.path] = f
+	}
+	for env, path := range legacyEnv {
+		if value, ok := os.LookupEnv(env); ok && value != "" {
+			if err := byPath[path].set(cfg, value); err != nil {
+				return err
+			}
+		}
+	}
+	for _, f := range list {
+		if value, ok := os.LookupEnv(envName(f.path)); ok {
+			if err := f.set(cfg, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// flagValue 记录命令行参数是否被设置，未设置的不覆盖配置文件和环境变量
+type flagValue struct {
+	value string
+	set   bool
+}
+
+func (v *flagValue) String() string {
+	return v.value
+}
+
+func (v *flagValue) Set(s string) error {
+	v.value, v.set = s, true
+	return nil
+}
+
+type flagSet map[*flagValue]field
+
+func (fs flagSet) apply(cfg *Config) error {
+	for v, f := range fs {
+		if !v.set {
+			continue
+		}
+		if err := f.set(cfg, v.value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseFlags 解析命令行参数，每个配置项对应一个同名参数，如-server.listen、-fabric.sdkConfig
+func parseFlags(defaults *Config, args []string) (flagSet, *flagValue, error) {
+	set := flag.NewFlagSet("server", flag.ContinueOnError)
+	configPath := &flagValue{value: "app.yaml"}
+	set.Var(configPath, "config", "配置文件路径")
+	flags := make(flagSet)
+	for _, f := range fields(reflect.TypeOf(*defaults), "", nil) {
+		v := &flagValue{value: fmt.Sprint(reflect.ValueOf(defaults).Elem().FieldByIndex(f.index).Interface())}
+		if f.kind.Kind() == reflect.Slice {
+			v.value = strings.Join(reflect.ValueOf(defaults).Elem().FieldByIndex(f.index).Interface().([]string), ",")
+		}
+		set.Var(v, f.path, fmt.Sprintf("覆盖配置项%s，也可使用环境变量%s", f.path, envName(f.path)))
+		flags[v] = f
+	}
+	if err := set.Parse(args); err != nil {
+		return nil, nil, err
+	}
+	if set.NArg() > 0 {
+		return nil, nil, fmt.Errorf("无法识别的参数: %s", strings.Join(set.Args(), " "))
+	}
+	return flags, configPath, nil
+}
@@ -0,0 +1,287 @@
+package setting
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"gopkg.in/yaml.v3"
+)
+
+// Config 应用配置，优先级：命令行参数 > 环境变量 > 配置文件 > 默认值
+type Config struct {
+	Server    Server    `yaml:"server"`
+	Log       Log       `yaml:"log"`
+	Fabric    Fabric    `yaml:"fabric"`
+	Cron      Cron      `yaml:"cron"`
+	Auth      Auth      `yaml:"auth"`
+	ReadModel ReadModel `yaml:"readModel"`
+	Import    Import    `yaml:"import"`
+	Export    Export    `yaml:"export"`
+}
+
+// Server HTTP服务
+type Server struct {
+	Listen string `yaml:"listen"` //监听地址
+	TLS    TLS    `yaml:"tls"`
+}
+
+// TLS 开启后使用HTTPS监听
+type TLS struct {
+	Enabled  bool   `yaml:"enabled"`
+	CertFile string `yaml:"certFile"` //证书文件
+	KeyFile  string `yaml:"keyFile"`  //私钥文件
+}
+
+// Log 日志
+type Log struct {
+	Level string `yaml:"level"` //debug、info、warn、error
+}
+
+// Fabric 区块链网络
+type Fabric struct {
+	Ledger    string    `yaml:"ledger"`    //fabric 或 inprocess(进程内运行链码，不连接区块链网络)
+	SDKConfig string    `yaml:"sdkConfig"` //fabric-sdk-go的网络配置文件
+	User      string    `yaml:"user"`      //组织管理员，用于查询、事件订阅和定时任务
+	Default   Target    `yaml:"default"`   //房地产交易链码所在的通道和链码
+	Channels  []Channel `yaml:"channels"`
+	Wallet    Wallet    `yaml:"wallet"`
+	Pool      Pool      `yaml:"pool"`
+	Seed      string    `yaml:"seed"` //inprocess初始化链码的种子数据文件，为空时使用链码的默认种子数据
+}
+
+// Target 通道与链码
+type Target struct {
+	Channel   string `yaml:"channel"`
+	Chaincode string `yaml:"chaincode"`
+}
+
+// Channel 通道
+type Channel struct {
+	Name       string   `yaml:"name"`
+	Endpoints  []string `yaml:"endpoints"`  //要发送交易的节点，为空时由SDK根据背书策略选择
+	Chaincodes []string `yaml:"chaincodes"` //通道上部署的链码
+}
+
+// Wallet 平台账户的Fabric身份钱包
+type Wallet struct {
+	Type       string `yaml:"type"`       //fs 或 encrypted
+	Path       string `yaml:"path"`       //fs为目录，encrypted为数据库文件
+	Passphrase string `yaml:"passphrase"` //encrypted的口令，建议通过环境变量WALLET_PASSPHRASE设置
+}
+
+// Pool 通道客户端池
+type Pool struct {
+	HealthCheck  time.Duration `yaml:"healthCheck"`  //健康检查间隔
+	IdleTimeout  time.Duration `yaml:"idleTimeout"`  //超过该时间未使用的客户端会被回收
+	ProbeTimeout time.Duration `yaml:"probeTimeout"` //健康检查的查询超时
+}
+
+// Cron 定时关闭过期的销售
+type Cron struct {
+	Enabled bool   `yaml:"enabled"`
+	Spec    string `yaml:"spec"` //支持秒级的cron表达式
+}
+
+// Auth 登录认证
+type Auth struct {
+	DBPath           string        `yaml:"dbPath"`           //凭证数据库文件路径
+	AccessTokenTTL   time.Duration `yaml:"accessTokenTTL"`   //访问令牌有效期
+	RefreshTokenTTL  time.Duration `yaml:"refreshTokenTTL"`  //刷新令牌有效期
+	SecretTTL        time.Duration `yaml:"secretTTL"`        //管理员生成的一次性密码的有效期
+	BootstrapAccount string        `yaml:"bootstrapAccount"` //还没有凭证时启动后生成一次性密码并打印到日志的账户，通常为第一个管理员
+	JWTSecret        string        `yaml:"jwtSecret"`        //签名密钥，为空时随机生成(重启后已签发的令牌失效)
+}
+
+// ReadModel 链下读模型
+type ReadModel struct {
+	DBPath        string        `yaml:"dbPath"`        //读模型数据库文件路径
+	RetryInterval time.Duration `yaml:"retryInterval"` //订阅中断后重新订阅的间隔
+}
+
+// Import 批量导入房地产
+type Import struct {
+	DBPath    string `yaml:"dbPath"`    //导入任务数据库文件路径
+	ChunkSize int    `yaml:"chunkSize"` //每笔交易提交的行数，不能超过链码的单次上限
+}
+
+// Export 导出报表和对账
+type Export struct {
+	DBPath        string `yaml:"dbPath"`        //对账结果数据库文件路径
+	ReconcileSpec string `yaml:"reconcileSpec"` //定时对账的cron表达式(支持秒)，为空则只能手动对账
+}
+
+// MaxImportChunkSize 链码batchCreateRealEstate单次最多新建的房地产条数
+const MaxImportChunkSize = 200
+
+// Default 默认配置，与原先代码中的取值一致
+func Default() Config {
+	return Config{
+		Server: Server{Listen: "0.0.0.0:8888"},
+		Log:    Log{Level: "info"},
+		Fabric: Fabric{
+			Ledger:    "fabric",
+			SDKConfig: "config.yaml",
+			User:      "Admin",
+			Default:   Target{Channel: "appchannel", Chaincode: "fabric-realty"},
+			Channels: []Channel{{
+				Name:       "appchannel",
+				Endpoints:  []string{"peer0.jd.com", "peer0.taobao.com"},
+				Chaincodes: []string{"fabric-realty"},
+			}},
+			Wallet: Wallet{Type: "fs", Path: "wallet"},
+			Pool:   Pool{HealthCheck: 30 * time.Second, IdleTimeout: 10 * time.Minute, ProbeTimeout: 5 * time.Second},
+		},
+		Cron: Cron{Enabled: true, Spec: "0 0 0 * * ?"},
+		Auth: Auth{
+			DBPath:          "auth.db",
+			AccessTokenTTL:  15 * time.Minute,
+			RefreshTokenTTL: 7 * 24 * time.Hour,
+			SecretTTL:       72 * time.Hour,
+		},
+		ReadModel: ReadModel{DBPath: "readmodel.db", RetryInterval: 5 * time.Second},
+		Import:    Import{DBPath: "imports.db", ChunkSize: 100},
+		Export:    Export{DBPath: "exports.db", ReconcileSpec: "0 0 1 * * ?"},
+	}
+}
+
+// Load 依次应用默认值、配置文件、环境变量和命令行参数，并校验
+// args为命令行参数(不含程序名)，-config指定配置文件，默认为app.yaml，不存在时只使用默认值
+func Load(args []string) (*Config, error) {
+	cfg := Default()
+	flags, configPath, err := parseFlags(&cfg, args)
+	if err != nil {
+		return nil, err
+	}
+	raw, err := os.ReadFile(configPath.value)
+	switch {
+	case err == nil:
+		//channels等列表以配置文件为准，不与默认值合并
+		if err := yaml.Unmarshal(raw, &cfg); err != nil {
+			return nil, fmt.Errorf("解析配置文件%s失败: %s", configPath.value, err)
+		}
+	case os.IsNotExist(err) && !configPath.set:
+	default:
+		return nil, fmt.Errorf("读取配置文件失败: %s", err)
+	}
+	if err := applyEnv(&cfg); err != nil {
+		return nil, err
+	}
+	if err := flags.apply(&cfg); err != nil {
+		return nil, err
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// Validate 校验配置
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			errs = append(errs, fmt.Errorf(format, args...))
+		}
+	}
+	if _, _, err := net.SplitHostPort(c.Server.Listen); err != nil {
+		errs = append(errs, fmt.Errorf("server.listen格式出错: %s", err))
+	}
+	if c.Server.TLS.Enabled {
+		check(fileExists(c.Server.TLS.CertFile), "server.tls.certFile文件不存在: %q", c.Server.TLS.CertFile)
+		check(fileExists(c.Server.TLS.KeyFile), "server.tls.keyFile文件不存在: %q", c.Server.TLS.KeyFile)
+	}
+	check(logLevels[c.Log.Level], "log.level只能是debug、info、warn、error: %q", c.Log.Level)
+
+	check(c.Fabric.Ledger == "fabric" || c.Fabric.Ledger == "inprocess", "fabric.ledger只能是fabric或inprocess: %q", c.Fabric.Ledger)
+	check(c.Fabric.User != "", "fabric.user不能为空")
+	check(len(c.Fabric.Channels) > 0, "fabric.channels至少配置一个通道")
+	channels := make(map[string]Channel)
+	for i, ch := range c.Fabric.Channels {
+		check(ch.Name != "", "fabric.channels[%d].name不能为空", i)
+		_, dup := channels[ch.Name]
+		check(!dup, "fabric.channels中通道%s重复", ch.Name)
+		check(len(ch.Chaincodes) > 0, "通道%s至少配置一个链码", ch.Name)
+		channels[ch.Name] = ch
+	}
+	if ch, ok := channels[c.Fabric.Default.Channel]; !ok {
+		errs = append(errs, fmt.Errorf("fabric.default.channel不在fabric.channels中: %q", c.Fabric.Default.Channel))
+	} else {
+		check(contains(ch.Chaincodes, c.Fabric.Default.Chaincode), "fabric.default.chaincode不在通道%s的链码中: %q", ch.Name, c.Fabric.Default.Chaincode)
+	}
+	//进程内账本不使用SDK配置、钱包和客户端池
+	if c.Fabric.Ledger == "fabric" {
+		check(fileExists(c.Fabric.SDKConfig), "fabric.sdkConfig文件不存在: %q", c.Fabric.SDKConfig)
+		check(c.Fabric.Wallet.Type == "fs" || c.Fabric.Wallet.Type == "encrypted", "fabric.wallet.type只能是fs或encrypted: %q", c.Fabric.Wallet.Type)
+		check(c.Fabric.Wallet.Path != "", "fabric.wallet.path不能为空")
+		check(c.Fabric.Wallet.Type != "encrypted" || c.Fabric.Wallet.Passphrase != "", "加密钱包需要设置fabric.wallet.passphrase")
+		check(c.Fabric.Pool.HealthCheck > 0, "fabric.pool.healthCheck必须大于0")
+		check(c.Fabric.Pool.ProbeTimeout > 0, "fabric.pool.probeTimeout必须大于0")
+		check(c.Fabric.Pool.IdleTimeout >= 0, "fabric.pool.idleTimeout不能小于0")
+	}
+
+	if c.Cron.Enabled {
+		if _, err := cronParser.Parse(c.Cron.Spec); err != nil {
+			errs = append(errs, fmt.Errorf("cron.spec格式出错: %s", err))
+		}
+	}
+
+	check(c.Auth.DBPath != "", "auth.dbPath不能为空")
+	check(c.Auth.AccessTokenTTL > 0, "auth.accessTokenTTL必须大于0")
+	check(c.Auth.RefreshTokenTTL > c.Auth.AccessTokenTTL, "auth.refreshTokenTTL必须大于auth.accessTokenTTL")
+	check(c.Auth.SecretTTL > 0, "auth.secretTTL必须大于0")
+	check(c.ReadModel.DBPath != "", "readModel.dbPath不能为空")
+	check(c.ReadModel.RetryInterval > 0, "readModel.retryInterval必须大于0")
+	check(c.Import.DBPath != "", "import.dbPath不能为空")
+	check(c.Export.DBPath != "", "export.dbPath不能为空")
+	if c.Export.ReconcileSpec != "" {
+		if _, err := cronParser.Parse(c.Export.ReconcileSpec); err != nil {
+			errs = append(errs, fmt.Errorf("export.reconcileSpec格式出错: %s", err))
+		}
+	}
+	check(c.Import.ChunkSize > 0 && c.Import.ChunkSize <= MaxImportChunkSize, "import.chunkSize必须在1到%d之间: %d", MaxImportChunkSize, c.Import.ChunkSize)
+	if len(errs) > 0 {
+		msgs := make([]string, 0, len(errs))
+		for _, err := range errs {
+			msgs = append(msgs, err.Error())
+		}
+		return errors.New("配置校验失败:\n  " + strings.Join(msgs, "\n  "))
+	}
+	return nil
+}
+
+// Endpoints 通道的目标节点
+func (f *Fabric) Endpoints(channel string) []string {
+	for _, ch := range f.Channels {
+		if ch.Name == channel {
+			return ch.Endpoints
+		}
+	}
+	return nil
+}
+
+// cronParser 与定时任务一致，支持秒级的cron表达式
+var cronParser = cron.NewParser(cron.Second | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+var logLevels = map[string]bool{"debug": true, "info": true, "warn": true, "error": true}
+
+func fileExists(path string) bool {
+	if path == "" {
+		return false
+	}
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,173 @@
+package setting
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, name string, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// 测试配置来源的优先级：命令行参数 > 环境变量 > 配置文件 > 默认值
+func TestLoadPrecedence(t *testing.T) {
+	path := writeFile(t, "app.yaml", `
+server:
+  listen: 127.0.0.1:1001
+log:
+  level: debug
+fabric:
+  ledger: inprocess
+  default:
+    channel: ch1
+    chaincode: cc1
+  channels:
+    - name: ch1
+      chaincodes: [cc1]
+  pool:
+    idleTimeout: 1m
+auth:
+  jwtSecret: yaml
+import:
+  chunkSize: 10
+`)
+	t.Setenv("REALTY_SERVER_LISTEN", "127.0.0.1:1002")
+	t.Setenv("REALTY_LOG_LEVEL", "warn")
+	t.Setenv("REALTY_IMPORT_CHUNKSIZE", "20")
+	t.Setenv("REALTY_FABRIC_POOL_HEALTHCHECK", "45s")
+	t.Setenv("JWT_SECRET", "legacy")
+	t.Setenv("REALTY_AUTH_JWTSECRET", "env")
+	t.Setenv("WALLET_PASSPHRASE", "legacy-passphrase")
+	cfg, err := Load([]string{"-config", path, "-server.listen", "127.0.0.1:1003", "-cron.enabled=false"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name string
+		got  interface{}
+		want interface{}
+	}{
+		{"命令行参数覆盖环境变量和配置文件", cfg.Server.Listen, "127.0.0.1:1003"},
+		{"命令行参数覆盖默认值", cfg.Cron.Enabled, false},
+		{"环境变量覆盖配置文件", cfg.Log.Level, "warn"},
+		{"环境变量覆盖整数", cfg.Import.ChunkSize, 20},
+		{"环境变量覆盖时长", cfg.Fabric.Pool.HealthCheck, 45 * time.Second},
+		{"REALTY_前缀的环境变量优先于早先的环境变量", cfg.Auth.JWTSecret, "env"},
+		{"早先的环境变量继续支持", cfg.Fabric.Wallet.Passphrase, "legacy-passphrase"},
+		{"配置文件覆盖默认值", cfg.Fabric.Pool.IdleTimeout, time.Minute},
+		{"配置文件未设置时保留默认值", cfg.Auth.SecretTTL, 72 * time.Hour},
+		{"同一结构中未设置的配置项保留默认值", cfg.Fabric.Pool.ProbeTimeout, 5 * time.Second},
+		{"通道列表以配置文件为准", len(cfg.Fabric.Channels), 1},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if c.got != c.want {
+				t.Fatalf("期望%v，实际%v", c.want, c.got)
+			}
+		})
+	}
+}
+
+// 测试加载出错的情况
+func TestLoadErrors(t *testing.T) {
+	valid := writeFile(t, "app.yaml", "fabric:\n  ledger: inprocess\n")
+	cases := []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		wantErr string
+	}{
+		{"指定的配置文件不存在", []string{"-config", filepath.Join(t.TempDir(), "missing.yaml")}, nil, "读取配置文件失败"},
+		{"配置文件格式出错", []string{"-config", writeFile(t, "bad.yaml", "server: [")}, nil, "解析配置文件"},
+		{"环境变量格式出错", []string{"-config", valid}, map[string]string{"REALTY_AUTH_ACCESSTOKENTTL": "soon"}, "auth.accessTokenTTL格式出错"},
+		{"命令行参数格式出错", []string{"-config", valid, "-import.chunkSize", "many"}, nil, "import.chunkSize格式出错"},
+		{"未知的命令行参数", []string{"-config", valid, "-unknown", "1"}, nil, "unknown"},
+		{"多余的参数", []string{"-config", valid, "extra"}, nil, "无法识别的参数: extra"},
+		{"加载后校验", []string{"-config", valid, "-log.level", "trace"}, nil, "log.level只能是"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			for k, v := range c.env {
+				t.Setenv(k, v)
+			}
+			if _, err := Load(c.args); err == nil || !strings.Contains(err.Error(), c.wantErr) {
+				t.Fatalf("期望包含%q的错误，实际%v", c.wantErr, err)
+			}
+		})
+	}
+
+	//未指定配置文件且默认的app.yaml不存在时只使用默认值
+	cfg, err := Load([]string{"-fabric.ledger", "inprocess"})
+	if err != nil || cfg.Server.Listen != Default().Server.Listen {
+		t.Fatalf("应使用默认值: %+v %v", cfg, err)
+	}
+}
+
+// 测试配置校验，每个用例在合法配置的基础上修改一项
+func TestValidate(t *testing.T) {
+	sdkConfig := writeFile(t, "config.yaml", "")
+	valid := func() Config {
+		cfg := Default()
+		cfg.Fabric.SDKConfig = sdkConfig
+		return cfg
+	}
+	cases := []struct {
+		name    string
+		modify  func(c *Config)
+		wantErr string //为空表示校验通过
+	}{
+		{"默认配置", func(c *Config) {}, ""},
+		{"进程内账本不需要SDK配置和钱包", func(c *Config) {
+			c.Fabric.Ledger, c.Fabric.SDKConfig, c.Fabric.Wallet.Type = "inprocess", "", ""
+		}, ""},
+		{"监听地址", func(c *Config) { c.Server.Listen = "8888" }, "server.listen格式出错"},
+		{"TLS证书", func(c *Config) { c.Server.TLS.Enabled = true }, "server.tls.certFile文件不存在"},
+		{"日志级别", func(c *Config) { c.Log.Level = "trace" }, "log.level只能是"},
+		{"账本实现", func(c *Config) { c.Fabric.Ledger = "memory" }, "fabric.ledger只能是"},
+		{"SDK配置文件", func(c *Config) { c.Fabric.SDKConfig = "missing.yaml" }, "fabric.sdkConfig文件不存在"},
+		{"通道重复", func(c *Config) { c.Fabric.Channels = append(c.Fabric.Channels, c.Fabric.Channels[0]) }, "通道appchannel重复"},
+		{"默认通道", func(c *Config) { c.Fabric.Default.Channel = "other" }, "fabric.default.channel不在fabric.channels中"},
+		{"默认链码", func(c *Config) { c.Fabric.Default.Chaincode = "other" }, "fabric.default.chaincode不在通道appchannel的链码中"},
+		{"钱包类型", func(c *Config) { c.Fabric.Wallet.Type = "vault" }, "fabric.wallet.type只能是"},
+		{"加密钱包口令", func(c *Config) { c.Fabric.Wallet.Type = "encrypted" }, "加密钱包需要设置fabric.wallet.passphrase"},
+		{"健康检查间隔", func(c *Config) { c.Fabric.Pool.HealthCheck = 0 }, "fabric.pool.healthCheck必须大于0"},
+		{"cron表达式", func(c *Config) { c.Cron.Spec = "every day" }, "cron.spec格式出错"},
+		{"关闭定时任务时不校验cron表达式", func(c *Config) { c.Cron.Enabled, c.Cron.Spec = false, "every day" }, ""},
+		{"刷新令牌有效期", func(c *Config) { c.Auth.RefreshTokenTTL = c.Auth.AccessTokenTTL }, "auth.refreshTokenTTL必须大于auth.accessTokenTTL"},
+		{"一次性密码有效期", func(c *Config) { c.Auth.SecretTTL = 0 }, "auth.secretTTL必须大于0"},
+		{"读模型重试间隔", func(c *Config) { c.ReadModel.RetryInterval = 0 }, "readModel.retryInterval必须大于0"},
+		{"对账cron表达式", func(c *Config) { c.Export.ReconcileSpec = "every day" }, "export.reconcileSpec格式出错"},
+		{"导入分块大小", func(c *Config) { c.Import.ChunkSize = MaxImportChunkSize + 1 }, "import.chunkSize必须在1到"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cfg := valid()
+			c.modify(&cfg)
+			err := cfg.Validate()
+			if c.wantErr == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), c.wantErr) {
+				t.Fatalf("期望包含%q的错误，实际%v", c.wantErr, err)
+			}
+		})
+	}
+
+	//多个错误一次返回
+	cfg := valid()
+	cfg.Log.Level, cfg.Auth.DBPath = "trace", ""
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "log.level") || !strings.Contains(err.Error(), "auth.dbPath不能为空") {
+		t.Fatalf("应返回全部校验错误: %v", err)
+	}
+}
@@ -0,0 +1,167 @@
+package wallet
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+
+	bolt "go.etcd.io/bbolt"
+	"golang.org/x/crypto/scrypt"
+)
+
+var (
+	metaBucket     = []byte("meta")       //saltKey -> 派生密钥使用的盐
+	identityBucket = []byte("identities") //label -> nonce + 密文
+	saltKey        = []byte("salt")
+	checkKey       = []byte("check") //用口令加密的固定内容，打开时校验口令是否正确
+	checkPlaintext = []byte("fabric-realty wallet")
+)
+
+// ErrPassphrase 口令错误
+var ErrPassphrase = errors.New("钱包口令错误")
+
+// EncryptedWallet 加密钱包，身份使用由口令派生(scrypt)的密钥以AES-256-GCM加密后保存在BoltDB中
+type EncryptedWallet struct {
+	db   *bolt.DB
+	aead cipher.AEAD
+}
+
+// NewEncryptedWallet 打开加密钱包，不存在时创建
+func NewEncryptedWallet(path string, passphrase string) (*EncryptedWallet, error) {
+	if passphrase == "" {
+		return nil, errors.New("加密钱包需要设置口令")
+	}
+	db, err := bolt.Open(path, 0600, nil)
+	if err != nil {
+		return nil, err
+	}
+	w := &EncryptedWallet{db: db}
+	if err := w.init(passphrase); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return w, nil
+}
+
+func (w *EncryptedWallet) init(passphrase string) error {
+	return w.db.Update(func(tx *bolt.Tx) error {
+		meta, err := tx.CreateBucketIfNotExists(metaBucket)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.CreateBucketIfNotExists(identityBucket); err != nil {
+			return err
+		}
+		salt := meta.Get(saltKey)
+		if salt == nil {
+			salt = make([]byte, 16)
+			if _, err := rand.Read(salt); err != nil {
+				return err
+			}
+			if err := meta.Put(saltKey, salt); err != nil {
+				return err
+			}
+		}
+		key, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+		if err != nil {
+			return err
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return err
+		}
+		if w.aead, err = cipher.NewGCM(block); err != nil {
+			return err
+		}
+		check := meta.Get(checkKey)
+		if check == nil {
+			sealed, err := w.seal(checkPlaintext)
+			if err != nil {
+				return err
+			}
+			return meta.Put(checkKey, sealed)
+		}
+		if _, err := w.open(check); err != nil {
+			return ErrPassphrase
+		}
+		return nil
+	})
+}
+
+// Close 关闭数据库
+func (w *EncryptedWallet) Close() error {
+	return w.db.Close()
+}
+
+func (w *EncryptedWallet) Put(label string, identity Identity) error {
+	val, err := json.Marshal(identity)
+	if err != nil {
+		return err
+	}
+	sealed, err := w.seal(val)
+	if err != nil {
+		return err
+	}
+	return w.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(identityBucket).Put([]byte(label), sealed)
+	})
+}
+
+func (w *EncryptedWallet) Get(label string) (*Identity, error) {
+	var sealed []byte
+	err := w.db.View(func(tx *bolt.Tx) error {
+		sealed = append(sealed, tx.Bucket(identityBucket).Get([]byte(label))...)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) == 0 {
+		return nil, ErrNotFound
+	}
+	val, err := w.open(sealed)
+	if err != nil {
+		return nil, err
+	}
+	identity := new(Identity)
+	if err := json.Unmarshal(val, identity); err != nil {
+		return nil, err
+	}
+	return identity, nil
+}
+
+func (w *EncryptedWallet) List() ([]string, error) {
+	var labels []string
+	err := w.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(identityBucket).ForEach(func(k, v []byte) error {
+			labels = append(labels, string(k))
+			return nil
+		})
+	})
+	return labels, err
+}
+
+func (w *EncryptedWallet) Remove(label string) error {
+	return w.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(identityBucket).Delete([]byte(label))
+	})
+}
+
+// seal 加密，结果为nonce + 密文
+func (w *EncryptedWallet) seal(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, w.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return w.aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func (w *EncryptedWallet) open(sealed []byte) ([]byte, error) {
+	size := w.aead.NonceSize()
+	if len(sealed) < size {
+		return nil, errors.New("密文格式出错")
+	}
+	return w.aead.Open(nil, sealed[:size], sealed[size:], nil)
+}
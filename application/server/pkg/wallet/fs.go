@@ -0,0 +1,84 @@
+package wallet
+
+import (
+	"encoding/json"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const idExt = ".id"
+
+// FileSystemWallet 文件钱包，每个身份以JSON保存为<label>.id，仅所有者可读写
+type FileSystemWallet struct {
+	dir string
+}
+
+// NewFileSystemWallet 创建文件钱包，目录不存在时创建
+func NewFileSystemWallet(dir string) (*FileSystemWallet, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FileSystemWallet{dir: dir}, nil
+}
+
+func (w *FileSystemWallet) Put(label string, identity Identity) error {
+	val, err := json.Marshal(identity)
+	if err != nil {
+		return err
+	}
+	//先写临时文件再改名，避免写到一半时进程退出留下损坏的身份
+	tmp := w.path(label) + ".tmp"
+	if err := os.WriteFile(tmp, val, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, w.path(label))
+}
+
+func (w *FileSystemWallet) Get(label string) (*Identity, error) {
+	val, err := os.ReadFile(w.path(label))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	identity := new(Identity)
+	if err := json.Unmarshal(val, identity); err != nil {
+		return nil, err
+	}
+	return identity, nil
+}
+
+func (w *FileSystemWallet) List() ([]string, error) {
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return nil, err
+	}
+	var labels []string
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), idExt) {
+			continue
+		}
+		label, err := url.PathUnescape(strings.TrimSuffix(entry.Name(), idExt))
+		if err != nil {
+			continue
+		}
+		labels = append(labels, label)
+	}
+	return labels, nil
+}
+
+func (w *FileSystemWallet) Remove(label string) error {
+	err := os.Remove(w.path(label))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// path label可能包含中文或特殊字符(如管理员)，转义后作为文件名
+func (w *FileSystemWallet) path(label string) string {
+	return filepath.Join(w.dir, url.PathEscape(label)+idExt)
+}
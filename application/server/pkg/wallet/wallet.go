@@ -0,0 +1,41 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrNotFound 钱包中没有该身份
+var ErrNotFound = errors.New("身份不存在")
+
+// Identity 保存在钱包中的Fabric身份
+type Identity struct {
+	MSPID       string `json:"mspId"`       //所属组织的MSP ID
+	Certificate []byte `json:"certificate"` //PEM格式的登记证书
+	PrivateKey  []byte `json:"privateKey"`  //PEM格式的私钥
+}
+
+// Wallet 身份钱包，label一般为平台账户ID
+type Wallet interface {
+	Put(label string, identity Identity) error
+	Get(label string) (*Identity, error) // 不存在时返回ErrNotFound
+	List() ([]string, error)
+	Remove(label string) error
+}
+
+// 钱包类型
+const (
+	FileSystem = "fs"        // 每个身份一个文件
+	Encrypted  = "encrypted" // BoltDB中使用AES-GCM加密保存
+)
+
+// New 根据类型创建钱包，加密钱包需要提供口令
+func New(kind string, path string, passphrase string) (Wallet, error) {
+	switch kind {
+	case FileSystem:
+		return NewFileSystemWallet(path)
+	case Encrypted:
+		return NewEncryptedWallet(path, passphrase)
+	}
+	return nil, fmt.Errorf("不支持的钱包类型%s", kind)
+}
@@ -0,0 +1,569 @@
+package routers
+
+import (
+	"application/blockchain"
+	_ "application/blockchain/inprocess"
+	"application/model"
+	"application/pkg/auth"
+	"application/pkg/export"
+	"application/pkg/importer"
+	"application/pkg/setting"
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 链码初始化的账户
+const (
+	admin  = "5feceb66ffc8"
+	owner1 = "6b86b273ff34"
+	owner2 = "d4735e3a265e"
+	owner3 = "4e07408562be"
+	owner4 = "4b227777d4dd"
+	owner5 = "ef2d127de37b"
+)
+
+const initialBalance = 5000000
+
+func TestMain(m *testing.M) {
+	gin.SetMode(gin.TestMode)
+	gin.DefaultWriter = io.Discard
+	dir, err := os.MkdirTemp("", "realty-e2e")
+	if err != nil {
+		log.Fatal(err)
+	}
+	cfg := setting.Default().Auth
+	cfg.DBPath = filepath.Join(dir, "auth.db")
+	cfg.JWTSecret = "e2e"
+	auth.Init(cfg)
+	//账户没有默认密码，测试前为种子账户设置密码
+	for _, accountId := range []string{admin, owner1, owner2, owner3, owner4, owner5} {
+		if err := auth.Default().SetPassword(accountId, password(accountId)); err != nil {
+			log.Fatal(err)
+		}
+	}
+	importCfg := setting.Default().Import
+	importCfg.DBPath = filepath.Join(dir, "imports.db")
+	importCfg.ChunkSize = 2
+	importer.Init(importCfg)
+	exportCfg := setting.Default().Export
+	exportCfg.DBPath = filepath.Join(dir, "exports.db")
+	exportCfg.ReconcileSpec = ""
+	export.Init(exportCfg)
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+// server 在进程内账本上启动的完整路由，每个场景使用新的账本
+type server struct {
+	t      *testing.T
+	router *gin.Engine
+	tokens map[string]string
+}
+
+func newServer(t *testing.T) *server {
+	cfg := setting.Default().Fabric
+	cfg.Ledger = "inprocess"
+	blockchain.Init(cfg, "error")
+	return &server{t: t, router: InitRouter(), tokens: make(map[string]string)}
+}
+
+// do 发送请求并检查HTTP状态码，返回响应中的data
+func (s *server) do(method string, path string, token string, body interface{}, wantCode int) json.RawMessage {
+	s.t.Helper()
+	var reader io.Reader
+	if body != nil {
+		raw, err := json.Marshal(body)
+		if err != nil {
+			s.t.Fatal(err)
+		}
+		reader = bytes.NewReader(raw)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	var resp struct {
+		Code int             `json:"code"`
+		Msg  string          `json:"msg"`
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		s.t.Fatalf("%s %s 响应不是json: %s", method, path, w.Body.String())
+	}
+	if w.Code != wantCode || resp.Code != wantCode {
+		s.t.Fatalf("%s %s 期望%d，实际%d: %s", method, path, wantCode, w.Code, w.Body.String())
+	}
+	return resp.Data
+}
+
+// post 以accountId登录后调用接口
+func (s *server) post(accountId string, path string, body interface{}, wantCode int) json.RawMessage {
+	s.t.Helper()
+	return s.do(http.MethodPost, "/api/v1"+path, s.login(accountId), body, wantCode)
+}
+
+// password 测试中种子账户的密码
+func password(accountId string) string {
+	return "e2e-" + accountId
+}
+
+// login 登录，令牌按账户缓存
+func (s *server) login(accountId string) string {
+	s.t.Helper()
+	if token, ok := s.tokens[accountId]; ok {
+		return token
+	}
+	var tokens auth.TokenPair
+	data := s.do(http.MethodPost, "/api/v1/auth/login", "",
+		map[string]string{"accountId": accountId, "password": password(accountId)}, http.StatusOK)
+	decode(s.t, data, &tokens)
+	s.tokens[accountId] = tokens.AccessToken
+	return tokens.AccessToken
+}
+
+// assertBalances 检查全部账户余额，未列出的账户应为初始余额
+func (s *server) assertBalances(want map[string]float64) {
+	s.t.Helper()
+	var accounts []model.Account
+	decode(s.t, s.post(admin, "/queryAccountList", map[string]interface{}{}, http.StatusOK), &accounts)
+	if len(accounts) != 6 {
+		s.t.Fatalf("期望6个账户，实际%d个", len(accounts))
+	}
+	for _, a := range accounts {
+		expected, ok := want[a.AccountId]
+		if !ok {
+			expected = initialBalance
+			if a.AccountId == admin {
+				expected = 0
+			}
+		}
+		if a.Balance != expected {
+			s.t.Fatalf("账户%s余额期望%.2f，实际%.2f", a.AccountId, expected, a.Balance)
+		}
+	}
+}
+
+// assertOwner 检查房产的所有者和担保状态，且不出现在其他账户名下
+func (s *server) assertOwner(realEstateId string, owner string, encumbrance bool) {
+	s.t.Helper()
+	var all []model.RealEstate
+	decode(s.t, s.post(admin, "/queryRealEstateList", map[string]string{}, http.StatusOK), &all)
+	found := 0
+	for _, r := range all {
+		if r.RealEstateID != realEstateId {
+			continue
+		}
+		found++
+		if r.Proprietor != owner || r.Encumbrance != encumbrance {
+			s.t.Fatalf("房产%s期望属于%s(担保%v)，实际属于%s(担保%v)", realEstateId, owner, encumbrance, r.Proprietor, r.Encumbrance)
+		}
+	}
+	if found != 1 {
+		s.t.Fatalf("房产%s期望出现1次，实际%d次", realEstateId, found)
+	}
+	var owned []model.RealEstate
+	decode(s.t, s.post(owner, "/queryRealEstateList", map[string]string{"proprietor": owner}, http.StatusOK), &owned)
+	for _, r := range owned {
+		if r.RealEstateID == realEstateId {
+			return
+		}
+	}
+	s.t.Fatalf("房产%s不在%s名下", realEstateId, owner)
+}
+
+// assertSelling 检查销售状态
+func (s *server) assertSelling(seller string, realEstateId string, status string) {
+	s.t.Helper()
+	var sellings []model.Selling
+	decode(s.t, s.post(seller, "/querySellingList", map[string]string{"seller": seller}, http.StatusOK), &sellings)
+	for _, v := range sellings {
+		if v.ObjectOfSale == realEstateId {
+			if v.SellingStatus != model.SellingStatusConstant()[status] {
+				s.t.Fatalf("销售%s期望%s，实际%s", realEstateId, model.SellingStatusConstant()[status], v.SellingStatus)
+			}
+			return
+		}
+	}
+	s.t.Fatalf("卖家%s没有房产%s的销售", seller, realEstateId)
+}
+
+// createRealEstate 管理员为proprietor创建房产
+func (s *server) createRealEstate(proprietor string) string {
+	s.t.Helper()
+	var realEstate model.RealEstate
+	decode(s.t, s.post(admin, "/createRealEstate", map[string]interface{}{
+		"accountId": admin, "proprietor": proprietor, "totalArea": 120, "livingSpace": 100,
+	}, http.StatusOK), &realEstate)
+	if realEstate.RealEstateID == "" {
+		s.t.Fatal("创建房产未返回realEstateId")
+	}
+	return realEstate.RealEstateID
+}
+
+func (s *server) createSelling(seller string, realEstateId string, price float64) {
+	s.t.Helper()
+	s.post(seller, "/createSelling", map[string]interface{}{
+		"objectOfSale": realEstateId, "seller": seller, "price": price, "salePeriod": 30,
+	}, http.StatusOK)
+}
+
+func (s *server) buy(buyer string, seller string, realEstateId string, wantCode int) {
+	s.t.Helper()
+	s.post(buyer, "/createSellingByBuy", map[string]string{
+		"objectOfSale": realEstateId, "seller": seller, "buyer": buyer,
+	}, wantCode)
+}
+
+func (s *server) updateSelling(operator string, seller string, buyer string, realEstateId string, status string, wantCode int) {
+	s.t.Helper()
+	s.post(operator, "/updateSelling", map[string]string{
+		"objectOfSale": realEstateId, "seller": seller, "buyer": buyer, "status": status,
+	}, wantCode)
+}
+
+func decode(t *testing.T, data json.RawMessage, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal(data, v); err != nil {
+		t.Fatalf("解析data出错: %s: %s", err, data)
+	}
+}
+
+func TestAuthRequired(t *testing.T) {
+	s := newServer(t)
+	s.do(http.MethodPost, "/api/v1/queryAccountList", "", map[string]string{}, http.StatusUnauthorized)
+	s.do(http.MethodGet, "/api/v1/auth/accounts", "", nil, http.StatusUnauthorized)
+	s.do(http.MethodPost, "/api/v1/auth/login", "", map[string]string{"accountId": owner1, "password": "wrong"}, http.StatusUnauthorized)
+	//access_token查询参数只用于/stream
+	s.do(http.MethodGet, "/api/v1/auth/me?access_token="+s.login(owner1), "", nil, http.StatusUnauthorized)
+	s.do(http.MethodGet, "/api/v1/auth/accounts", s.login(owner1), nil, http.StatusOK)
+	//非管理员不能以他人身份操作
+	s.post(owner1, "/createRealEstate", map[string]interface{}{
+		"accountId": admin, "proprietor": owner1, "totalArea": 120, "livingSpace": 100,
+	}, http.StatusForbidden)
+	//非管理员只能看到自己账户的余额
+	var accounts []model.Account
+	decode(t, s.post(owner1, "/queryAccountList", map[string]interface{}{}, http.StatusOK), &accounts)
+	for _, a := range accounts {
+		if (a.AccountId == owner1) != (a.Balance == initialBalance) || a.UserName == "" {
+			t.Fatalf("账户%s的余额不应返回给%s: %+v", a.AccountId, owner1, a)
+		}
+	}
+}
+
+// 测试管理员生成一次性密码：只能使用一次且必须同时设置新密码
+func TestProvision(t *testing.T) {
+	s := newServer(t)
+	s.post(owner1, "/auth/provision", map[string]string{"accountId": owner2}, http.StatusForbidden)
+	s.post(admin, "/auth/provision", map[string]string{"accountId": "none"}, http.StatusNotFound)
+	var provisioned struct {
+		Secret string `json:"secret"`
+	}
+	decode(t, s.post(admin, "/auth/provision", map[string]string{"accountId": owner2}, http.StatusOK), &provisioned)
+	login := func(password string, newPassword string, wantCode int) {
+		t.Helper()
+		s.do(http.MethodPost, "/api/v1/auth/login", "", map[string]string{"accountId": owner2, "password": password, "newPassword": newPassword}, wantCode)
+	}
+	login(provisioned.Secret, "", http.StatusForbidden)
+	login(provisioned.Secret, "12345", http.StatusForbidden)
+	login(provisioned.Secret, "owner2-password", http.StatusOK)
+	login(provisioned.Secret, "owner2-password", http.StatusUnauthorized)
+	login("owner2-password", "", http.StatusOK)
+	if err := auth.Default().SetPassword(owner2, password(owner2)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSellingDone(t *testing.T) {
+	s := newServer(t)
+	id := s.createRealEstate(owner1)
+	s.assertOwner(id, owner1, false)
+	s.assertBalances(nil)
+
+	s.createSelling(owner1, id, 800000)
+	s.assertSelling(owner1, id, "saleStart")
+	s.assertOwner(id, owner1, true)
+	s.assertBalances(nil)
+
+	//卖家不能购买自己的房产，管理员不能购买
+	s.buy(owner1, owner1, id, http.StatusBadRequest)
+	s.buy(admin, owner1, id, http.StatusForbidden)
+	s.buy(owner2, owner1, id, http.StatusOK)
+	s.assertSelling(owner1, id, "delivery")
+	s.assertOwner(id, owner1, true)
+	s.assertBalances(map[string]float64{owner2: initialBalance - 800000})
+
+	//交付中不能再被购买
+	s.buy(owner3, owner1, id, http.StatusConflict)
+	s.assertBalances(map[string]float64{owner2: initialBalance - 800000})
+
+	s.updateSelling(owner1, owner1, owner2, id, "done", http.StatusOK)
+	s.assertSelling(owner1, id, "done")
+	s.assertOwner(id, owner2, false)
+	s.assertBalances(map[string]float64{owner1: initialBalance + 800000, owner2: initialBalance - 800000})
+
+	//已完成的销售房产已过户，不能再更新
+	s.updateSelling(owner1, owner1, owner2, id, "cancelled", http.StatusNotFound)
+	s.assertBalances(map[string]float64{owner1: initialBalance + 800000, owner2: initialBalance - 800000})
+}
+
+func TestSellingCancel(t *testing.T) {
+	s := newServer(t)
+	id := s.createRealEstate(owner1)
+
+	//销售中取消，不涉及资金
+	s.createSelling(owner1, id, 600000)
+	s.updateSelling(owner1, owner1, "", id, "cancelled", http.StatusOK)
+	s.assertSelling(owner1, id, "cancelled")
+	s.assertOwner(id, owner1, false)
+	s.assertBalances(nil)
+
+	//交付中买家取消，退款给买家
+	s.createSelling(owner1, id, 600000)
+	s.buy(owner3, owner1, id, http.StatusOK)
+	s.assertBalances(map[string]float64{owner3: initialBalance - 600000})
+	s.updateSelling(owner3, owner1, owner3, id, "cancelled", http.StatusOK)
+	s.assertSelling(owner1, id, "cancelled")
+	s.assertOwner(id, owner1, false)
+	s.assertBalances(nil)
+
+	//余额不足不能购买
+	s.createSelling(owner1, id, initialBalance+1)
+	s.buy(owner4, owner1, id, http.StatusUnprocessableEntity)
+	s.assertSelling(owner1, id, "saleStart")
+	s.assertBalances(nil)
+}
+
+func TestSellingExpire(t *testing.T) {
+	s := newServer(t)
+	id := s.createRealEstate(owner1)
+	s.createSelling(owner1, id, 300000)
+	s.buy(owner5, owner1, id, http.StatusOK)
+	s.assertBalances(map[string]float64{owner5: initialBalance - 300000})
+
+	//与定时任务一样由管理员将过期的销售关闭，交付中的退款给买家
+	s.updateSelling(admin, owner1, owner5, id, "expired", http.StatusOK)
+	s.assertSelling(owner1, id, "expired")
+	s.assertOwner(id, owner1, false)
+	s.assertBalances(nil)
+
+	s.buy(owner2, owner1, id, http.StatusConflict)
+	s.assertBalances(nil)
+}
+
+func TestDonating(t *testing.T) {
+	s := newServer(t)
+	id := s.createRealEstate(owner1)
+	donating := map[string]string{"objectOfDonating": id, "donor": owner1, "grantee": owner2}
+
+	//不能捐赠给管理员
+	s.post(owner1, "/createDonating", map[string]string{"objectOfDonating": id, "donor": owner1, "grantee": admin}, http.StatusForbidden)
+
+	//捐赠中取消
+	s.post(owner1, "/createDonating", donating, http.StatusOK)
+	s.assertOwner(id, owner1, true)
+	s.post(owner1, "/updateDonating", map[string]string{"objectOfDonating": id, "donor": owner1, "grantee": owner2, "status": "cancelled"}, http.StatusOK)
+	s.assertOwner(id, owner1, false)
+	s.assertBalances(nil)
+
+	//受赠人接收，房产过户且不涉及资金
+	s.post(owner1, "/createDonating", donating, http.StatusOK)
+	//担保中的房产不能发起销售
+	s.post(owner1, "/createSelling", map[string]interface{}{"objectOfSale": id, "seller": owner1, "price": 1, "salePeriod": 1}, http.StatusConflict)
+	s.post(owner2, "/updateDonating", map[string]string{"objectOfDonating": id, "donor": owner1, "grantee": owner2, "status": "done"}, http.StatusOK)
+	s.assertOwner(id, owner2, false)
+	s.assertBalances(nil)
+
+	var received []struct {
+		Donating model.Donating `json:"donating"`
+	}
+	decode(t, s.post(owner2, "/queryDonatingListByGrantee", map[string]string{"grantee": owner2}, http.StatusOK), &received)
+	done := 0
+	for _, v := range received {
+		if v.Donating.DonatingStatus == model.DonatingStatusConstant()["done"] {
+			done++
+		}
+	}
+	if done != 1 {
+		t.Fatalf("受赠人期望1条已完成的受赠记录，实际%d条", done)
+	}
+
+	//新所有者可以继续出售
+	s.createSelling(owner2, id, 100000)
+	s.buy(owner1, owner2, id, http.StatusOK)
+	s.updateSelling(owner2, owner2, owner1, id, "done", http.StatusOK)
+	s.assertOwner(id, owner1, false)
+	s.assertBalances(map[string]float64{owner1: initialBalance - 100000, owner2: initialBalance + 100000})
+}
+
+// upload 以accountId登录后以表单字段file上传文件
+func (s *server) upload(accountId string, path string, fileName string, content string, wantCode int) json.RawMessage {
+	s.t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", fileName)
+	if err != nil {
+		s.t.Fatal(err)
+	}
+	part.Write([]byte(content))
+	writer.Close()
+	req := httptest.NewRequest(http.MethodPost, "/api/v1"+path, &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+s.login(accountId))
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	var resp struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if w.Code != wantCode || json.Unmarshal(w.Body.Bytes(), &resp) != nil {
+		s.t.Fatalf("上传%s 期望%d，实际%d: %s", fileName, wantCode, w.Code, w.Body.String())
+	}
+	return resp.Data
+}
+
+// 场景：管理员上传CSV批量导入房产，逐行给出结果，重新上传同一文件不会重复新建
+func TestImport(t *testing.T) {
+	s := newServer(t)
+	content := "ref,proprietor,totalArea,livingSpace\n" +
+		"R-1," + owner1 + ",100,80\n" +
+		"R-2," + owner2 + ",abc,80\n" +
+		"R-3,unknown,100,80\n" +
+		"," + owner3 + ",60,40\n" +
+		"R-1," + owner2 + ",60,40\n"
+	s.upload(owner1, "/imports", "registry.csv", content, http.StatusForbidden)
+	s.upload(admin, "/imports", "registry.txt", content, http.StatusBadRequest)
+
+	var job importer.Job
+	decode(t, s.upload(admin, "/imports", "registry.csv", content, http.StatusOK), &job)
+	importer.Wait()
+	decode(t, s.do(http.MethodGet, "/api/v1/imports/"+job.ID, s.login(admin), nil, http.StatusOK), &job)
+	if job.Status != importer.StatusDone || job.Total != 5 || job.Created != 2 || job.Failed != 2 || job.Existing != 1 || job.Chunks != 3 {
+		t.Fatalf("导入任务结果不符合预期: %+v", job)
+	}
+	var owned []model.RealEstate
+	decode(t, s.post(owner3, "/queryRealEstateList", map[string]string{"proprietor": owner3}, http.StatusOK), &owned)
+	if len(owned) != 1 || owned[0].TotalArea != 60 {
+		t.Fatalf("没有外部编号的行应导入到%s名下: %+v", owner3, owned)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/imports/"+job.ID+"/errors", nil)
+	req.Header.Set("Authorization", "Bearer "+s.login(admin))
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	report := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if w.Code != http.StatusOK || len(report) != 3 || !strings.Contains(report[1], "args.format") || !strings.Contains(report[2], "account.notFound") {
+		t.Fatalf("错误报告不符合预期: %d\n%s", w.Code, w.Body.String())
+	}
+	s.post(admin, "/imports/"+job.ID+"/resume", nil, http.StatusConflict)
+
+	var again importer.Job
+	decode(t, s.upload(admin, "/imports", "registry.csv", content, http.StatusOK), &again)
+	importer.Wait()
+	decode(t, s.do(http.MethodGet, "/api/v1/imports/"+again.ID, s.login(admin), nil, http.StatusOK), &again)
+	if again.Created != 0 || again.Existing != 3 {
+		t.Fatalf("重新上传同一文件不应重复新建: %+v", again)
+	}
+	var all []model.RealEstate
+	decode(t, s.post(admin, "/queryRealEstateList", map[string]string{}, http.StatusOK), &all)
+	if len(all) != 2 {
+		t.Fatalf("期望共2个房产，实际%d个", len(all))
+	}
+}
+
+// download 以管理员身份下载报表，返回响应
+func (s *server) download(path string, wantCode int) *httptest.ResponseRecorder {
+	s.t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1"+path, nil)
+	req.Header.Set("Authorization", "Bearer "+s.login(admin))
+	w := httptest.NewRecorder()
+	s.router.ServeHTTP(w, req)
+	if w.Code != wantCode {
+		s.t.Fatalf("GET %s 期望%d，实际%d: %s", path, wantCode, w.Code, w.Body.String())
+	}
+	return w
+}
+
+// 场景：交付中的销售计入托管资金，对账一致；完成的销售出现在报表中，报表带有区块高度和摘要
+func TestExport(t *testing.T) {
+	s := newServer(t)
+	id := s.createRealEstate(owner1)
+	s.createSelling(owner1, id, 800000)
+	s.buy(owner2, owner1, id, http.StatusOK)
+
+	s.post(owner1, "/reconciliations", nil, http.StatusForbidden)
+	var rec export.Reconciliation
+	decode(t, s.post(admin, "/reconciliations", nil, http.StatusOK), &rec)
+	if !rec.OK || rec.Escrow != 800000 || rec.Balances != 5*initialBalance-800000 || rec.BlockHeight == 0 {
+		t.Fatalf("交付中的销售应计入托管资金: %+v", rec)
+	}
+
+	s.updateSelling(owner1, owner1, owner2, id, "done", http.StatusOK)
+	w := s.download("/reports/sellings?from=2000-01-01", http.StatusOK)
+	lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "# report=sellings blockHeight="+w.Header().Get("X-Block-Height")) ||
+		!strings.HasPrefix(lines[2], id+","+owner1+","+owner2+",800000,") {
+		t.Fatalf("销售报表不符合预期:\n%s", w.Body.String())
+	}
+	if w.Header().Get("X-Block-Hash") == "" || !strings.Contains(lines[0], "digest="+w.Header().Get("X-Report-Digest")) {
+		t.Fatalf("报表缺少区块哈希或摘要: %v", w.Header())
+	}
+	if w := s.download("/reports/sellings?to=2000-01-01", http.StatusOK); strings.Count(w.Body.String(), "\n") != 2 {
+		t.Fatalf("时间段外的销售不应出现在报表中:\n%s", w.Body.String())
+	}
+	if w := s.download("/reports/accounts?format=xlsx", http.StatusOK); !strings.HasPrefix(w.Body.String(), "PK") {
+		t.Fatalf("xlsx报表应为zip格式: %s", w.Header())
+	}
+	s.download("/reports/unknown", http.StatusNotFound)
+	s.download("/reports/accounts?format=pdf", http.StatusBadRequest)
+	s.download("/reports/sellings?from=2024-02-01&to=2024-01-01", http.StatusBadRequest)
+
+	var list []export.Reconciliation
+	decode(t, s.do(http.MethodGet, "/api/v1/reconciliations?limit=1", s.login(admin), nil, http.StatusOK), &list)
+	if len(list) != 1 || list[0].ID != rec.ID {
+		t.Fatalf("应返回最近一次对账结果: %+v", list)
+	}
+}
+
+// 场景：导出账本状态，在新网络中导入后账户、房产和进行中的销售与原账本一致，只能导入一次
+func TestState(t *testing.T) {
+	s := newServer(t)
+	id := s.createRealEstate(owner1)
+	s.createSelling(owner1, id, 800000)
+	s.buy(owner2, owner1, id, http.StatusOK)
+	s.do(http.MethodGet, "/api/v1/state", s.login(owner1), nil, http.StatusForbidden)
+	dump := s.download("/state", http.StatusOK).Body.String()
+	if again := s.download("/state", http.StatusOK).Body.String(); again != dump {
+		t.Fatal("相同的账本状态应导出相同的内容")
+	}
+
+	restored := newServer(t)
+	restored.upload(owner1, "/state", "state.json", dump, http.StatusForbidden)
+	var imported model.StateImport
+	decode(t, restored.upload(admin, "/state", "state.json", dump, http.StatusOK), &imported)
+	if imported.Counts["realEstate"] != 1 || imported.Counts["sellingBuy"] != 1 {
+		t.Fatalf("导入的条数不符合预期: %+v", imported)
+	}
+	if got := restored.download("/state", http.StatusOK).Body.String(); got != dump {
+		t.Fatalf("导入后导出的内容与原账本不一致:\n%s\n%s", dump, got)
+	}
+	restored.assertBalances(map[string]float64{owner2: initialBalance - 800000})
+	restored.assertSelling(owner1, id, "delivery")
+	restored.updateSelling(owner1, owner1, owner2, id, "done", http.StatusOK)
+	restored.assertOwner(id, owner2, false)
+	restored.upload(admin, "/state", "state.json", dump, http.StatusConflict)
+	restored.upload(admin, "/state", "state.json", "{", http.StatusBadRequest)
+}
@@ -2,6 +2,8 @@ package routers
 
 import (
 	v1 "application/api/v1"
+	"application/pkg/auth"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -12,18 +14,49 @@ func InitRouter() *gin.Engine {
 	apiV1 := r.Group("/api/v1")
 	{
 		apiV1.GET("/hello", v1.Hello)
-		apiV1.POST("/queryAccountList", v1.QueryAccountList)
-		apiV1.POST("/createRealEstate", v1.CreateRealEstate)
-		apiV1.POST("/queryRealEstateList", v1.QueryRealEstateList)
-		apiV1.POST("/createSelling", v1.CreateSelling)
-		apiV1.POST("/createSellingByBuy", v1.CreateSellingByBuy)
-		apiV1.POST("/querySellingList", v1.QuerySellingList)
-		apiV1.POST("/querySellingListByBuyer", v1.QuerySellingListByBuyer)
-		apiV1.POST("/updateSelling", v1.UpdateSelling)
-		apiV1.POST("/createDonating", v1.CreateDonating)
-		apiV1.POST("/queryDonatingList", v1.QueryDonatingList)
-		apiV1.POST("/queryDonatingListByGrantee", v1.QueryDonatingListByGrantee)
-		apiV1.POST("/updateDonating", v1.UpdateDonating)
+		apiV1.GET("/health", v1.Health)
+		apiV1.POST("/auth/login", v1.Login)
+		apiV1.POST("/auth/refresh", v1.Refresh)
+		//EventSource无法设置请求头，只有该接口可以使用access_token查询参数
+		apiV1.GET("/stream", auth.QueryToken(), auth.Required(), v1.Stream)
+	}
+	// 以下接口需要登录，Body中的账户字段必须为当前登录账户(管理员除外)
+	authV1 := apiV1.Group("", auth.Required())
+	{
+		authV1.GET("/auth/me", v1.Me)
+		authV1.GET("/auth/accounts", v1.LoginAccounts)
+		authV1.POST("/auth/provision", v1.Provision)
+		authV1.POST("/auth/logout", v1.Logout)
+		authV1.POST("/auth/password", v1.ChangePassword)
+		authV1.POST("/queryAccountList", v1.QueryAccountList)
+		authV1.POST("/createRealEstate", auth.BodyAccount(v1.RealEstateRequestBody{}, "accountId"), v1.CreateRealEstate)
+		authV1.POST("/queryRealEstateList", v1.QueryRealEstateList)
+		authV1.POST("/queryRealEstatesByFilter", v1.QueryRealEstatesByFilter)
+		authV1.POST("/createSelling", auth.BodyAccount(v1.SellingRequestBody{}, "seller"), v1.CreateSelling)
+		authV1.POST("/createSellingByBuy", auth.BodyAccount(v1.SellingByBuyRequestBody{}, "buyer"), v1.CreateSellingByBuy)
+		authV1.POST("/querySellingList", v1.QuerySellingList)
+		authV1.POST("/querySellingListByBuyer", auth.BodyAccount(v1.SellingListQueryByBuyRequestBody{}, "buyer"), v1.QuerySellingListByBuyer)
+		authV1.POST("/querySellingsByFilter", v1.QuerySellingsByFilter)
+		authV1.POST("/updateSelling", auth.BodyAccount(v1.UpdateSellingRequestBody{}, "seller", "buyer"), v1.UpdateSelling)
+		authV1.POST("/createDonating", auth.BodyAccount(v1.DonatingRequestBody{}, "donor"), v1.CreateDonating)
+		authV1.POST("/queryDonatingList", v1.QueryDonatingList)
+		authV1.POST("/queryDonatingListByGrantee", auth.BodyAccount(v1.DonatingListQueryByGranteeRequestBody{}, "grantee"), v1.QueryDonatingListByGrantee)
+		authV1.POST("/updateDonating", auth.BodyAccount(v1.UpdateDonatingRequestBody{}, "donor", "grantee"), v1.UpdateDonating)
+		authV1.POST("/searchAccounts", v1.SearchAccounts)
+		authV1.POST("/searchRealEstates", v1.SearchRealEstates)
+		authV1.POST("/searchSellings", v1.SearchSellings)
+		authV1.POST("/searchDonatings", v1.SearchDonatings)
+		authV1.POST("/queryEvents", auth.BodyAccount(v1.EventQueryRequestBody{}, "accountId"), v1.QueryEvents)
+		authV1.POST("/imports", v1.CreateImport)
+		authV1.GET("/imports", v1.QueryImportList)
+		authV1.GET("/imports/:id", v1.QueryImport)
+		authV1.POST("/imports/:id/resume", v1.ResumeImport)
+		authV1.GET("/imports/:id/errors", v1.QueryImportErrors)
+		authV1.GET("/reports/:name", v1.QueryReport)
+		authV1.GET("/reconciliations", v1.QueryReconciliationList)
+		authV1.POST("/reconciliations", v1.CreateReconciliation)
+		authV1.GET("/state", v1.ExportState)
+		authV1.POST("/state", v1.ImportState)
 	}
 	return r
 }
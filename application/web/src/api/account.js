@@ -1,6 +1,14 @@
 import request from '@/utils/request'
 
-// 获取登录界面角色选择列表
+// 获取账户选择列表(不含余额)，需要登录
+export function loginAccounts() {
+  return request({
+    url: '/auth/accounts',
+    method: 'get'
+  })
+}
+
+// 获取账户列表
 export function queryAccountList() {
   return request({
     url: '/queryAccountList',
@@ -11,8 +19,33 @@ export function queryAccountList() {
 // 登录
 export function login(data) {
   return request({
-    url: '/queryAccountList',
+    url: '/auth/login',
+    method: 'post',
+    data
+  })
+}
+
+// 使用刷新令牌换取新的令牌
+export function refresh(data) {
+  return request({
+    url: '/auth/refresh',
     method: 'post',
     data
   })
 }
+
+// 当前登录账户信息
+export function me() {
+  return request({
+    url: '/auth/me',
+    method: 'get'
+  })
+}
+
+// 注销
+export function logout() {
+  return request({
+    url: '/auth/logout',
+    method: 'post'
+  })
+}
@@ -1,14 +1,21 @@
 import {
-  login
+  login,
+  me,
+  logout
 } from '@/api/account'
 import {
   getToken,
   setToken,
+  setRefreshToken,
   removeToken
 } from '@/utils/auth'
 import {
   resetRouter
 } from '@/router'
+import {
+  openStream,
+  closeStream
+} from '@/utils/stream'
 
 const getDefaultState = () => {
   return {
@@ -46,15 +53,20 @@ const mutations = {
 const actions = {
   login({
     commit
-  }, accountId) {
+  }, {
+    accountId,
+    password,
+    newPassword
+  }) {
     return new Promise((resolve, reject) => {
       login({
-        args: [{
-          accountId: accountId
-        }]
+        accountId: accountId,
+        password: password,
+        newPassword: newPassword
       }).then(response => {
-        commit('SET_TOKEN', response[0].accountId)
-        setToken(response[0].accountId)
+        commit('SET_TOKEN', response.accessToken)
+        setToken(response.accessToken)
+        setRefreshToken(response.refreshToken)
         resolve()
       }).catch(error => {
         reject(error)
@@ -67,21 +79,22 @@ const actions = {
     state
   }) {
     return new Promise((resolve, reject) => {
-      login({
-        args: [{
-          accountId: state.token
-        }]
-      }).then(response => {
+      me().then(response => {
         var roles
-        if (response[0].userName === '管理员') {
+        if (response.userName === '管理员') {
           roles = ['admin']
         } else {
           roles = ['editor']
         }
         commit('SET_ROLES', roles)
-        commit('SET_ACCOUNTID', response[0].accountId)
-        commit('SET_USERNAME', response[0].userName)
-        commit('SET_BALANCE', response[0].balance)
+        commit('SET_ACCOUNTID', response.accountId)
+        commit('SET_USERNAME', response.userName)
+        commit('SET_BALANCE', response.balance)
+        openStream(data => {
+          if (data.type === 'BalanceChanged') {
+            commit('SET_BALANCE', data.amount)
+          }
+        })
         resolve(roles)
       }).catch(error => {
         reject(error)
@@ -92,10 +105,14 @@ const actions = {
     commit
   }) {
     return new Promise(resolve => {
-      removeToken()
-      resetRouter()
-      commit('RESET_STATE')
-      resolve()
+      closeStream()
+      // 注销失败(如令牌已失效)不影响本地退出
+      logout().catch(() => {}).finally(() => {
+        removeToken()
+        resetRouter()
+        commit('RESET_STATE')
+        resolve()
+      })
     })
   },
 
@@ -103,6 +120,7 @@ const actions = {
     commit
   }) {
     return new Promise(resolve => {
+      closeStream()
       removeToken()
       commit('RESET_STATE')
       resolve()
@@ -1,6 +1,7 @@
 import Cookies from 'js-cookie'
 
-const TokenKey = 'account_id_token'
+const TokenKey = 'access_token'
+const RefreshTokenKey = 'refresh_token'
 
 export function getToken() {
   return Cookies.get(TokenKey)
@@ -11,5 +12,14 @@ export function setToken(token) {
 }
 
 export function removeToken() {
+  Cookies.remove(RefreshTokenKey)
   return Cookies.remove(TokenKey)
 }
+
+export function getRefreshToken() {
+  return Cookies.get(RefreshTokenKey)
+}
+
+export function setRefreshToken(token) {
+  return Cookies.set(RefreshTokenKey, token)
+}
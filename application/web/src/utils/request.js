@@ -3,12 +3,45 @@ import {
   MessageBox,
   Message
 } from 'element-ui'
+import {
+  getToken,
+  setToken,
+  getRefreshToken,
+  setRefreshToken,
+  removeToken
+} from '@/utils/auth'
 
 const service = axios.create({
   baseURL: process.env.VUE_APP_BASE_API,
   timeout: 5000
 })
 
+service.interceptors.request.use(config => {
+  const token = getToken()
+  if (token) {
+    config.headers['Authorization'] = 'Bearer ' + token
+  }
+  return config
+})
+
+let refreshing = null
+
+// 访问令牌过期时使用刷新令牌换取新的令牌，并发请求共用同一次刷新
+export function refreshToken() {
+  if (!refreshing) {
+    refreshing = axios.post(process.env.VUE_APP_BASE_API + '/auth/refresh', {
+      refreshToken: getRefreshToken()
+    }).then(response => {
+      setToken(response.data.data.accessToken)
+      setRefreshToken(response.data.data.refreshToken)
+      return response.data.data.accessToken
+    }).finally(() => {
+      refreshing = null
+    })
+  }
+  return refreshing
+}
+
 service.interceptors.response.use(
   response => {
     const res = response.data
@@ -23,6 +56,15 @@ service.interceptors.response.use(
     }
   },
   error => {
+    const config = error.config
+    if (error.response && error.response.status === 401 && getRefreshToken() && !config._retried) {
+      config._retried = true
+      return refreshToken().then(() => service(config)).catch(() => {
+        removeToken()
+        location.reload()
+        return Promise.reject(error)
+      })
+    }
     if (error.response === undefined) {
       Message({
         message: '请求失败 ' + error.message,
@@ -31,8 +73,10 @@ service.interceptors.response.use(
       })
       return Promise.reject(error)
     } else {
+      // 链码错误的data为错误详情，提示信息在msg中
+      const data = error.response.data
       Message({
-        message: '失败 ' + error.response.data.data,
+        message: '失败 ' + (typeof data.data === 'object' && data.data !== null ? data.msg : data.data),
         type: 'error',
         duration: 5 * 1000
       })
@@ -0,0 +1,47 @@
+import { Notification } from 'element-ui'
+import { getToken } from '@/utils/auth'
+import { refreshToken } from '@/utils/request'
+
+let source = null
+let lastEventId = ''
+
+// 订阅后端推送的当前账户通知
+// 网络断开时浏览器会自动重连并带上Last-Event-ID；令牌过期导致连接被拒绝时，刷新令牌后从最后收到的事件继续订阅
+export function openStream(onNotification) {
+  closeStream()
+  let url = `${process.env.VUE_APP_BASE_API}/stream?access_token=${encodeURIComponent(getToken())}`
+  if (lastEventId) {
+    url += `&lastEventId=${lastEventId}`
+  }
+  const current = new EventSource(url)
+  source = current
+  current.addEventListener('notification', event => {
+    lastEventId = event.lastEventId
+    const data = JSON.parse(event.data)
+    Notification({
+      title: '交易通知',
+      message: data.message,
+      type: 'info'
+    })
+    if (onNotification) {
+      onNotification(data)
+    }
+  })
+  current.onerror = () => {
+    if (current.readyState !== EventSource.CLOSED || source !== current) {
+      return
+    }
+    refreshToken().then(() => {
+      if (source === current) {
+        openStream(onNotification)
+      }
+    }).catch(() => {})
+  }
+}
+
+export function closeStream() {
+  if (source) {
+    source.close()
+    source = null
+  }
+}
@@ -2,34 +2,113 @@ package api
 
 import (
 	"chaincode/model"
+	"chaincode/pkg/errcode"
 	"chaincode/pkg/utils"
-	"encoding/json"
 	"fmt"
+	"strings"
 
-	"github.com/hyperledger/fabric/core/chaincode/shim"
-	pb "github.com/hyperledger/fabric/protos/peer"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-contract-api-go/metadata"
 )
 
-// QueryAccountList 查询账户列表
-func QueryAccountList(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+// AccountContract 账户合约
+type AccountContract struct {
+	Contract
+}
+
+// NewAccountContract 账户合约
+func NewAccountContract() *AccountContract {
+	c := &AccountContract{}
+	c.Name = "Account"
+	c.Info = metadata.InfoMetadata{Title: "账户", Description: "查询账户及余额"}
+	c.Params = map[string][]string{
+		"QueryAccountList":    {"accountIds"},
+		"QueryAccountPrivate": {"accountId"},
+	}
+	c.Evaluate = []string{"QueryAccountList", "QueryAccountPrivate"}
+	c.BeforeTransaction = guard{contract: &c.Contract}.before
+	return c
+}
+
+// QueryAccountList 查询账户列表，accountIds为空数组时返回所有账户，不存在的账户忽略
+// 账号名和余额在私有数据集合中，调用者所在组织不能读取私有数据时为空
+func (c *AccountContract) QueryAccountList(ctx contractapi.TransactionContextInterface, accountIds []string) (_ []model.Account, err error) {
+	defer errcode.Envelope(&err)
+	accounts := utils.Accounts(ctx.GetStub())
+	if len(accountIds) == 0 {
+		// 不传accountId则查找并返回所有数据
+		return accounts.List()
+	}
 	var accountList []model.Account
-	results, err := utils.GetStateByPartialCompositeKeys(stub, model.AccountKey, args)
-	if err != nil {
-		return shim.Error(fmt.Sprintf("%s", err))
-	}
-	for _, v := range results {
-		if v != nil {
-			var account model.Account
-			err := json.Unmarshal(v, &account)
-			if err != nil {
-				return shim.Error(fmt.Sprintf("QueryAccountList-反序列化出错: %s", err))
-			}
-			accountList = append(accountList, account)
+	for _, accountId := range accountIds {
+		account, err := accounts.Get(accountId)
+		if utils.IsNotFound(err) {
+			continue
 		}
+		if err != nil {
+			return nil, err
+		}
+		accountList = append(accountList, *account)
+	}
+	return accountList, nil
+}
+
+// checkAdmin 验证操作人是否为管理员
+// 操作人必须是交易提案的创建者，即证书中realty.accountId属性对应的账户，参数中的accountId只用于核对
+func checkAdmin(stub shim.ChaincodeStubInterface, accountId string) error {
+	caller, err := callerAccount(stub, "不能执行管理员操作")
+	if err != nil {
+		return err
+	}
+	if caller != accountId {
+		return errcode.New(errcode.Forbidden, "auth.operatorMismatch", fmt.Sprintf("操作人%s与调用者证书中的账户%s不一致", accountId, caller)).
+			With("accountId", accountId).With("caller", caller)
+	}
+	account, err := utils.Accounts(stub).Get(accountId)
+	if utils.IsNotFound(err) {
+		return errcode.New(errcode.Unauthenticated, "auth.operatorNotFound", fmt.Sprintf("操作人权限验证失败: %s", err)).With("accountId", accountId)
 	}
-	accountListByte, err := json.Marshal(accountList)
 	if err != nil {
-		return shim.Error(fmt.Sprintf("QueryAccountList-序列化出错: %s", err))
+		return errcode.Wrap(err, "操作人权限验证失败")
+	}
+	if !account.IsAdmin() {
+		return errcode.New(errcode.Forbidden, "auth.notAdmin", "操作人权限不足").With("accountId", accountId)
+	}
+	return nil
+}
+
+// checkParty 验证调用者是交易的当事人之一，即证书中realty.accountId属性对应的账户在accounts中，管理员可以代当事人操作
+func checkParty(stub shim.ChaincodeStubInterface, accounts []string) error {
+	caller, err := callerAccount(stub, "不能以当事人身份操作")
+	if err != nil {
+		return err
+	}
+	for _, account := range accounts {
+		if account == caller {
+			return nil
+		}
+	}
+	account, err := utils.Accounts(stub).Get(caller)
+	if err != nil && !utils.IsNotFound(err) {
+		return errcode.Wrap(err, "调用者权限验证失败")
+	}
+	if err == nil && account.IsAdmin() {
+		return nil
+	}
+	return errcode.New(errcode.Forbidden, "auth.notParty", fmt.Sprintf("调用者证书中的账户%s不是%s", caller, strings.Join(accounts, "或"))).
+		With("accounts", accounts).With("caller", caller)
+}
+
+// callerAccount 调用者证书中的realty.accountId，没有该属性时返回auth.noAccountAttr，action为错误消息中不能执行的操作
+func callerAccount(stub shim.ChaincodeStubInterface, action string) (string, error) {
+	caller, found, err := utils.CallerAccountId(stub)
+	if err != nil || !found {
+		message := fmt.Sprintf("调用者证书中没有%s属性，%s", model.AccountIdAttr, action)
+		if err != nil {
+			message = err.Error()
+		}
+		return "", errcode.New(errcode.Unauthenticated, "auth.noAccountAttr", message).With("attr", model.AccountIdAttr)
 	}
-	return shim.Success(accountListByte)
+	return caller, nil
 }
@@ -0,0 +1,165 @@
+package api
+
+import (
+	"chaincode/pkg/errcode"
+	"chaincode/pkg/utils"
+	"strings"
+	"unicode"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// Contracts 链码的全部合约，按合约名分为账户、房地产、销售、捐赠和账本维护，第一个为未指定合约名时的默认合约
+func Contracts() []contractapi.ContractInterface {
+	return []contractapi.ContractInterface{
+		NewAccountContract(),
+		NewRealEstateContract(),
+		NewSellingContract(),
+		NewDonatingContract(),
+		NewLedgerContract(),
+	}
+}
+
+// Contract 合约的公共部分，在contractapi.Contract上声明各交易函数的参数名和只读的交易函数
+type Contract struct {
+	contractapi.Contract
+	Params   map[string][]string //各交易函数的参数名，每个交易函数都需要声明，用于参数个数校验和错误提示
+	Evaluate []string            //只读的交易函数，元数据中标记为evaluate，其余为submit
+	Parties  map[string][]string //提交类交易函数中代表当事人的账户参数名，调用者必须是其中之一(管理员除外)
+}
+
+// GetEvaluateTransactions 只读的交易函数
+func (c *Contract) GetEvaluateTransactions() []string {
+	return c.Evaluate
+}
+
+// GetIgnoredFunctions 不作为交易函数的导出方法
+func (c *Contract) GetIgnoredFunctions() []string {
+	return []string{"ParamNames", "PartyArgs"}
+}
+
+// ParamNames 交易函数的参数名，交易函数不存在时返回false
+func (c *Contract) ParamNames(function string) ([]string, bool) {
+	names, ok := c.Params[function]
+	return names, ok
+}
+
+// PartyArgs 交易函数当事人参数的值，为空的参数(如销售中取消时的买家)不计入，没有声明当事人时返回nil
+func (c *Contract) PartyArgs(function string, args []string) []string {
+	names := c.Params[function]
+	var accounts []string
+	for _, party := range c.Parties[function] {
+		for i, name := range names {
+			if name == party && i < len(args) && args[i] != "" {
+				accounts = append(accounts, args[i])
+			}
+		}
+	}
+	return accounts
+}
+
+// Parties 调用的当事人账户，function可以是旧函数名或"合约名:交易函数名"，用于测试中以当事人身份调用
+func Parties(function string, args []string) []string {
+	if target, adapt, ok := Legacy(function); ok {
+		function = target
+		if adapt != nil {
+			adapted, err := adapt(args)
+			if err != nil {
+				return nil
+			}
+			args = adapted
+		}
+	}
+	name := FunctionName(function)
+	for _, c := range Contracts() {
+		if contract, ok := c.(interface {
+			PartyArgs(function string, args []string) []string
+		}); ok {
+			if accounts := contract.PartyArgs(name, args); accounts != nil {
+				return accounts
+			}
+		}
+	}
+	return nil
+}
+
+// Transaction 本次调用的交易函数名和参数，旧函数名调用时为转换后的函数名和参数
+func Transaction(stub shim.ChaincodeStubInterface) (string, []string) {
+	function, args := stub.GetFunctionAndParameters()
+	return FunctionName(function), args
+}
+
+// FunctionName "合约名:交易函数名"中的交易函数名，与contractapi一致，首字母转为大写
+func FunctionName(function string) string {
+	if i := strings.LastIndex(function, ":"); i >= 0 {
+		function = function[i+1:]
+	}
+	if function == "" {
+		return ""
+	}
+	name := []rune(function)
+	name[0] = unicode.ToUpper(name[0])
+	return string(name)
+}
+
+// guard 交易函数调用前的校验，作为合约的BeforeTransaction，在参数转换之前执行
+// 参数个数必须与声明的参数名一致，字符串参数不能为空(optional中声明的参数除外)，adminOnly中的交易函数第一个参数为操作人，必须是调用者本人且为管理员
+// 声明了当事人的交易函数，调用者必须是其中之一或管理员
+// private不为空时合约的提交类交易函数会读写该类型记录的私有字段，调用者所在组织必须可以读写私有数据
+type guard struct {
+	contract  *Contract
+	optional  map[string][]string
+	adminOnly []string
+	private   string
+}
+
+func (g guard) before(ctx contractapi.TransactionContextInterface) (err error) {
+	defer errcode.Envelope(&err)
+	function, args := Transaction(ctx.GetStub())
+	names, ok := g.contract.ParamNames(function)
+	if !ok {
+		// 不存在的交易函数由contractapi返回错误
+		return nil
+	}
+	if len(args) != len(names) {
+		return errcode.ArgCount()
+	}
+	for i, arg := range args {
+		if arg != "" || contains(g.optional[function], names[i]) {
+			continue
+		}
+		return errcode.ArgEmpty().With("arg", names[i])
+	}
+	if g.private != "" && !contains(g.contract.Evaluate, function) {
+		if err := utils.RequirePrivate(ctx.GetStub(), g.private); err != nil {
+			return err
+		}
+	}
+	if contains(g.adminOnly, function) {
+		return checkAdmin(ctx.GetStub(), args[0])
+	}
+	if _, ok := g.contract.Parties[function]; ok {
+		return checkParty(ctx.GetStub(), g.contract.PartyArgs(function, args))
+	}
+	return nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// listKeys 查询列表时的复合主键前缀，取到第一个空值为止
+func listKeys(keys ...string) []string {
+	for i, key := range keys {
+		if key == "" {
+			return keys[:i]
+		}
+	}
+	return keys
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"chaincode/model"
+	"chaincode/pkg/errcode"
+	"chaincode/pkg/events"
+	"chaincode/pkg/utils"
+	"encoding/json"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+)
+
+// 事件随区块分发给通道上的所有组织，私有字段(账号名、余额、价格)不写入事件
+// 记录只保留公开部分及privateHash，应用层通过QueryAccountPrivate/QueryOfferPrivate读取私有字段
+
+// emitRecord 记录以记录为内容的事件，objectType为记录的复合主键前缀
+func emitRecord(stub shim.ChaincodeStubInterface, eventType model.EventType, accounts []string, objectType string, record interface{}) error {
+	public, err := publicRecord(objectType, record)
+	if err != nil {
+		return err
+	}
+	return events.Emit(stub, eventType, accounts, public)
+}
+
+// publicRecord 记录的公开部分
+func publicRecord(objectType string, record interface{}) (json.RawMessage, error) {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return nil, errcode.Wrap(err, objectType+"-序列化事件出错")
+	}
+	public, _, err := utils.SplitPrivate(objectType, value)
+	if err != nil {
+		return nil, err
+	}
+	return public, nil
+}
+
+// publicEntries 导入或迁移的记录只保留公开部分
+func publicEntries(entries []model.StateEntry) ([]model.StateEntry, error) {
+	public := make([]model.StateEntry, len(entries))
+	for i, entry := range entries {
+		value, _, err := utils.SplitPrivate(entry.ObjectType, entry.Value)
+		if err != nil {
+			return nil, err
+		}
+		public[i] = model.StateEntry{ObjectType: entry.ObjectType, Keys: entry.Keys, Value: value}
+	}
+	return public, nil
+}
+
+// emitBalanceChanged 记录余额变化事件，account必须已写入账本(privateHash为变化后私有部分的哈希)
+func emitBalanceChanged(stub shim.ChaincodeStubInterface, account *model.Account, reason string) error {
+	return events.Emit(stub, model.BalanceChanged, []string{account.AccountId}, model.BalanceChangedPayload{
+		AccountId:   account.AccountId,
+		PrivateHash: account.PrivateHash,
+		Reason:      reason,
+	})
+}
+
+// emitTransferred 记录房地产过户事件
+func emitTransferred(stub shim.ChaincodeStubInterface, realEstateID string, from string, to string, reason string) error {
+	return events.Emit(stub, model.RealEstateTransferred, []string{from, to}, model.RealEstateTransferredPayload{
+		RealEstateID: realEstateID,
+		From:         from,
+		To:           to,
+		Reason:       reason,
+	})
+}
@@ -1,17 +1,46 @@
 package api
 
 import (
+	"chaincode/pkg/errcode"
 	"chaincode/pkg/utils"
 
-	"github.com/hyperledger/fabric/core/chaincode/shim"
-	pb "github.com/hyperledger/fabric/protos/peer"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-contract-api-go/metadata"
 )
 
+// LedgerContract 账本维护合约，记录结构升级、迁移和状态导出导入
+type LedgerContract struct {
+	Contract
+}
+
+// NewLedgerContract 账本维护合约，除Hello和QuerySeed外需要管理员
+func NewLedgerContract() *LedgerContract {
+	c := &LedgerContract{}
+	c.Name = "Ledger"
+	c.Info = metadata.InfoMetadata{Title: "账本维护", Description: "记录结构升级、分批迁移和账本状态的导出导入"}
+	c.Params = map[string][]string{
+		"Hello":          {},
+		"ExportState":    {"operator"},
+		"QuerySeed":      {},
+		"UpgradeDocType": {"accountId"},
+		"Migrate":        {"accountId", "batchSize", "dryRun", "cursor"},
+		"ImportState":    {"operator", "state"},
+	}
+	c.Evaluate = []string{"ExportState", "QuerySeed"}
+	c.BeforeTransaction = guard{
+		contract:  &c.Contract,
+		optional:  map[string][]string{"Migrate": {"cursor"}},
+		adminOnly: []string{"ExportState", "UpgradeDocType", "Migrate", "ImportState"},
+	}.before
+	return c
+}
+
 // Hello 测试
-func Hello(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	err := utils.WriteLedger(map[string]interface{}{"msg": "hello"}, stub, "hello", []string{})
+func (c *LedgerContract) Hello(ctx contractapi.TransactionContextInterface) (_ string, err error) {
+	defer errcode.Envelope(&err)
+	err = utils.WriteLedger(map[string]interface{}{"msg": "hello"}, ctx.GetStub(), "hello", []string{})
 	if err != nil {
-		return shim.Error(err.Error())
+		return "", err
 	}
-	return shim.Success([]byte("hello world"))
+	return "hello world", nil
 }
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"bytes"
+	"chaincode/model"
+	"chaincode/pkg/errcode"
+	"encoding/json"
+	"fmt"
+)
+
+// legacyFunctions 改为合约之前的函数名，保持原有的位置参数和错误，已有的客户端不需要修改
+var legacyFunctions = []struct {
+	name   string
+	target string
+	adapt  Adapter
+}{
+	{"hello", "Ledger:Hello", func(args []string) ([]string, error) { return nil, nil }},
+	{"queryAccountList", "Account:QueryAccountList", jsonArray},
+	{"createRealEstate", "RealEstate:CreateRealEstate", nil},
+	{"batchCreateRealEstate", "RealEstate:BatchCreateRealEstate", nil},
+	{"queryRealEstateList", "RealEstate:QueryRealEstateList", optionalArgs(0, 2)},
+	{"queryRealEstatesByFilter", "RealEstate:QueryRealEstatesByFilter", filterArg(func() interface{} { return new(model.RealEstateFilter) })},
+	{"createSelling", "Selling:CreateSelling", nil},
+	{"createSellingByBuy", "Selling:CreateSellingByBuy", nil},
+	{"querySellingList", "Selling:QuerySellingList", optionalArgs(0, 2)},
+	{"querySellingListByBuyer", "Selling:QuerySellingListByBuyer", requiredArg("必须指定买家AccountId查询")},
+	{"querySellingsByFilter", "Selling:QuerySellingsByFilter", filterArg(func() interface{} { return new(model.SellingFilter) })},
+	{"updateSelling", "Selling:UpdateSelling", nil},
+	{"createDonating", "Donating:CreateDonating", nil},
+	{"queryDonatingList", "Donating:QueryDonatingList", optionalArgs(0, 3)},
+	{"queryDonatingListByGrantee", "Donating:QueryDonatingListByGrantee", requiredArg("必须指定受赠人AccountId查询")},
+	{"updateDonating", "Donating:UpdateDonating", nil},
+	{"upgradeDocType", "Ledger:UpgradeDocType", nil},
+	{"migrate", "Ledger:Migrate", optionalArgs(3, 4)},
+	{"exportState", "Ledger:ExportState", nil},
+	{"importState", "Ledger:ImportState", nil},
+}
+
+// Adapter 把旧函数的位置参数转换为交易函数的参数，返回的错误直接作为交易错误
+type Adapter func(args []string) ([]string, error)
+
+// Legacy 旧函数名对应的交易函数("合约名:交易函数名")和参数转换，adapt为nil时参数原样传递，不是旧函数名时返回false
+func Legacy(name string) (target string, adapt Adapter, ok bool) {
+	for _, f := range legacyFunctions {
+		if f.name == name {
+			return f.target, f.adapt, true
+		}
+	}
+	return "", nil, false
+}
+
+// jsonArray 旧函数逐个传入的参数合并为json数组
+func jsonArray(args []string) ([]string, error) {
+	if args == nil {
+		args = []string{}
+	}
+	argsByte, err := json.Marshal(args)
+	if err != nil {
+		return nil, errcode.Wrap(err, "合并参数出错")
+	}
+	return []string{string(argsByte)}, nil
+}
+
+// optionalArgs 旧函数可省略末尾的参数，省略的参数补为空值
+func optionalArgs(min, max int) Adapter {
+	return func(args []string) ([]string, error) {
+		if len(args) < min || len(args) > max {
+			return nil, errcode.ArgCount()
+		}
+		return append(args, make([]string, max-len(args))...), nil
+	}
+}
+
+// requiredArg 旧函数必须且只能传一个参数
+func requiredArg(message string) Adapter {
+	return func(args []string) ([]string, error) {
+		if len(args) != 1 {
+			return nil, errcode.New(errcode.Validation, "args.required", message)
+		}
+		return args, nil
+	}
+}
+
+// filterArg 旧函数的筛选条件可省略，格式出错时为filter.invalid，不允许出现未知字段
+func filterArg(newFilter func() interface{}) Adapter {
+	return func(args []string) ([]string, error) {
+		if len(args) > 1 {
+			return nil, errcode.ArgCount()
+		}
+		if len(args) == 0 || args[0] == "" {
+			return []string{""}, nil
+		}
+		decoder := json.NewDecoder(bytes.NewReader([]byte(args[0])))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(newFilter()); err != nil {
+			return nil, errcode.New(errcode.Validation, "filter.invalid", fmt.Sprintf("筛选条件格式出错: %s", err))
+		}
+		return args, nil
+	}
+}
@@ -0,0 +1,311 @@
+package api
+
+import (
+	"chaincode/model"
+	"chaincode/pkg/errcode"
+	"chaincode/pkg/events"
+	"chaincode/pkg/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// migrationStep 把记录从Version-1升级到Version的一步，ObjectType为空时适用于全部类型
+// Apply修改按字段解析的旧记录，为nil时只更新版本；docType和version由重写时统一设置
+type migrationStep struct {
+	Version     int
+	ObjectType  string
+	Description string
+	Apply       func(record map[string]json.RawMessage) error
+}
+
+// migrations 按版本排列的迁移步骤，修改model中的记录结构时在此追加一步并递增model.SchemaVersion
+var migrations = []migrationStep{
+	{Version: 1, Description: "补齐docType和version"},
+	{Version: 2, ObjectType: model.AccountKey, Description: "账户补齐角色", Apply: backfillAccountRole},
+	{Version: 3, ObjectType: model.AccountKey, Description: "账号名和余额移入私有数据集合"},
+	{Version: 3, ObjectType: model.SellingKey, Description: "价格移入私有数据集合"},
+	{Version: 3, ObjectType: model.SellingBuyKey, Description: "价格移入私有数据集合"},
+}
+
+// nestedDocs 记录中嵌套的记录，迁移时按嵌套记录自身的类型和版本执行迁移步骤
+var nestedDocs = map[string]struct{ field, objectType string }{
+	model.SellingBuyKey:      {field: "selling", objectType: model.SellingKey},
+	model.DonatingGranteeKey: {field: "donating", objectType: model.DonatingKey},
+}
+
+// backfillAccountRole 旧账户没有角色，账号名为管理员的补为管理员，其余为业主
+func backfillAccountRole(record map[string]json.RawMessage) error {
+	var role, userName string
+	if raw, ok := record["role"]; ok {
+		if err := json.Unmarshal(raw, &role); err != nil {
+			return err
+		}
+	}
+	if role != "" {
+		return nil
+	}
+	if raw, ok := record["userName"]; ok {
+		if err := json.Unmarshal(raw, &userName); err != nil {
+			return err
+		}
+	}
+	role = model.RoleOwner
+	if userName == model.AdminUserName {
+		role = model.RoleAdmin
+	}
+	record["role"], _ = json.Marshal(role)
+	return nil
+}
+
+// applyMigrations 对版本为version的记录执行之后的迁移步骤，返回执行的步骤说明
+func applyMigrations(objectType string, record map[string]json.RawMessage, version int) ([]string, error) {
+	var applied []string
+	for _, step := range migrations {
+		if step.Version <= version || (step.ObjectType != "" && step.ObjectType != objectType) {
+			continue
+		}
+		if step.Apply != nil {
+			if err := step.Apply(record); err != nil {
+				return nil, errors.New(fmt.Sprintf("%s-迁移到版本%d出错: %s", objectType, step.Version, err))
+			}
+		}
+		applied = append(applied, fmt.Sprintf("v%d %s", step.Version, step.Description))
+	}
+	if nested, ok := nestedDocs[objectType]; ok && record[nested.field] != nil {
+		var doc model.Doc
+		var nestedRecord map[string]json.RawMessage
+		if err := json.Unmarshal(record[nested.field], &doc); err != nil {
+			return nil, errors.New(fmt.Sprintf("%s-读取嵌套记录版本出错: %s", objectType, err))
+		}
+		if err := json.Unmarshal(record[nested.field], &nestedRecord); err != nil {
+			return nil, errors.New(fmt.Sprintf("%s-反序列化嵌套记录出错: %s", objectType, err))
+		}
+		if _, err := applyMigrations(nested.objectType, nestedRecord, doc.Version); err != nil {
+			return nil, err
+		}
+		record[nested.field], _ = json.Marshal(nestedRecord)
+	}
+	return applied, nil
+}
+
+// upgradeDoc 把账本中的一条记录升级为当前结构，返回升级后的记录、原版本和执行的步骤说明
+func upgradeDoc(objectType string, value []byte) (model.Document, int, []string, error) {
+	factory, ok := findDocFactory(objectType)
+	if !ok {
+		return nil, 0, nil, errors.New(fmt.Sprintf("%s-不支持迁移", objectType))
+	}
+	if err := utils.CheckDoc(objectType, value); err != nil {
+		return nil, 0, nil, err
+	}
+	var doc model.Doc
+	var record map[string]json.RawMessage
+	if err := json.Unmarshal(value, &doc); err != nil {
+		return nil, 0, nil, errors.New(fmt.Sprintf("%s-读取记录版本出错: %s", objectType, err))
+	}
+	if err := json.Unmarshal(value, &record); err != nil {
+		return nil, 0, nil, errors.New(fmt.Sprintf("%s-反序列化出错: %s", objectType, err))
+	}
+	steps, err := applyMigrations(objectType, record, doc.Version)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	migrated, _ := json.Marshal(record)
+	upgraded := factory.newDoc()
+	if err := json.Unmarshal(migrated, upgraded); err != nil {
+		return nil, 0, nil, errors.New(fmt.Sprintf("%s-反序列化迁移后的记录出错: %s", objectType, err))
+	}
+	if factory.nested != nil {
+		factory.nested(upgraded)
+	}
+	return upgraded, doc.Version, steps, nil
+}
+
+// readSchemaState 读取账本的记录结构版本，没有记录时为版本0
+func readSchemaState(stub shim.ChaincodeStubInterface) (*model.SchemaState, error) {
+	state := new(model.SchemaState)
+	stateByte, err := stub.GetState(model.SchemaKey)
+	if err != nil {
+		return nil, errcode.Wrap(err, "读取记录结构版本出错")
+	}
+	if stateByte == nil {
+		return state, nil
+	}
+	if err := json.Unmarshal(stateByte, state); err != nil {
+		return nil, errcode.Wrap(err, "反序列化记录结构版本出错")
+	}
+	return state, nil
+}
+
+// writeSchemaState 写入账本的记录结构版本
+func writeSchemaState(stub shim.ChaincodeStubInterface, state *model.SchemaState) error {
+	stateByte, err := json.Marshal(state)
+	if err != nil {
+		return errcode.Wrap(err, "序列化记录结构版本出错")
+	}
+	if err := stub.PutState(model.SchemaKey, stateByte); err != nil {
+		return errcode.Wrap(err, "写入记录结构版本出错")
+	}
+	return nil
+}
+
+// Migrate 把账本中的记录迁移到当前结构版本(管理员)
+// 每次最多扫描batchSize条记录，进度保存在账本中，重复调用直到返回done为true
+// dryRun为true时只报告本批需要重写的记录而不写入，可传入上次报告的cursor继续预览
+func (c *LedgerContract) Migrate(ctx contractapi.TransactionContextInterface, accountId string, batchSize int, dryRun bool,
+	cursor *model.MigrationCursor) (_ *model.MigrationReport, err error) {
+	defer errcode.Envelope(&err)
+	stub := ctx.GetStub()
+	if batchSize < 1 || batchSize > model.MaxMigrationBatch {
+		return nil, errcode.New(errcode.Validation, "migrate.batchSize",
+			fmt.Sprintf("每批迁移的条数应为1到%d，实际%d", model.MaxMigrationBatch, batchSize)).
+			With("max", model.MaxMigrationBatch).With("size", batchSize)
+	}
+	if cursor != nil && !dryRun {
+		return nil, errcode.New(errcode.Validation, "migrate.cursor", "只有dryRun可以指定cursor，迁移从账本中保存的进度继续")
+	}
+	state, err := readSchemaState(stub)
+	if err != nil {
+		return nil, err
+	}
+	if state.Version > model.SchemaVersion {
+		return nil, errcode.New(errcode.Conflict, "migrate.newerSchema",
+			fmt.Sprintf("账本的记录结构版本%d高于当前链码支持的版本%d", state.Version, model.SchemaVersion))
+	}
+	//上次迁移的目标版本不同时从头开始，已迁移的记录版本仍低于当前版本
+	from := cursor
+	if state.Migration != nil && state.Migration.To == model.SchemaVersion {
+		if from == nil {
+			from = &state.Migration.Cursor
+		}
+	} else {
+		state.Migration = nil
+	}
+	report := &model.MigrationReport{From: state.Version, To: model.SchemaVersion, DryRun: dryRun, Changes: make([]model.MigrationChange, 0)}
+	if state.Version == model.SchemaVersion && state.Migration == nil && cursor == nil {
+		report.Done = true
+		return report, nil
+	}
+	batch := model.MigrationBatch{From: state.Version, To: model.SchemaVersion}
+	next, err := migrateBatch(stub, from, batchSize, report, &batch)
+	if err != nil {
+		return nil, err
+	}
+	report.Done = next == nil
+	report.Cursor = next
+	if dryRun {
+		return report, nil
+	}
+	if report.Done {
+		state = &model.SchemaState{Version: model.SchemaVersion}
+	} else {
+		if state.Migration == nil {
+			state.Migration = &model.MigrationProgress{To: model.SchemaVersion}
+		}
+		state.Migration.Cursor = *next
+		state.Migration.Scanned += report.Scanned
+		state.Migration.Migrated += report.Migrated
+	}
+	if err := writeSchemaState(stub, state); err != nil {
+		return nil, err
+	}
+	if len(batch.Entries) != 0 {
+		if batch.Entries, err = publicEntries(batch.Entries); err != nil {
+			return nil, err
+		}
+		if err := events.Emit(stub, model.RecordsMigrated, []string{}, batch); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+// migrateBatch 从cursor之后扫描最多batchSize条记录，重写版本低于当前版本的记录(DryRun时只报告)
+// 返回最后处理的记录，全部处理完时返回nil
+func migrateBatch(stub shim.ChaincodeStubInterface, cursor *model.MigrationCursor, batchSize int,
+	report *model.MigrationReport, batch *model.MigrationBatch) (*model.MigrationCursor, error) {
+	var last *model.MigrationCursor
+	for _, objectType := range stateObjectTypes() {
+		if cursor != nil && objectType < cursor.ObjectType {
+			continue
+		}
+		var after string
+		if cursor != nil && objectType == cursor.ObjectType {
+			key, err := stub.CreateCompositeKey(objectType, cursor.Keys)
+			if err != nil {
+				return nil, errcode.ArgFormat("cursor", err)
+			}
+			after = key
+		}
+		full, err := migrateObjectType(stub, objectType, after, batchSize, &last, report, batch)
+		if err != nil {
+			return nil, err
+		}
+		if full {
+			return last, nil
+		}
+	}
+	return nil, nil
+}
+
+// migrateObjectType 迁移某一复合主键前缀下key之后的记录，本批已满且还有未处理的记录时返回true
+func migrateObjectType(stub shim.ChaincodeStubInterface, objectType string, after string, batchSize int,
+	last **model.MigrationCursor, report *model.MigrationReport, batch *model.MigrationBatch) (bool, error) {
+	resultIterator, err := stub.GetStateByPartialCompositeKey(objectType, []string{})
+	if err != nil {
+		return false, errors.New(fmt.Sprintf("%s-获取全部数据出错: %s", objectType, err))
+	}
+	defer resultIterator.Close()
+
+	for resultIterator.HasNext() {
+		val, err := resultIterator.Next()
+		if err != nil {
+			return false, errors.New(fmt.Sprintf("%s-返回的数据出错: %s", objectType, err))
+		}
+		if after != "" && val.GetKey() <= after {
+			continue
+		}
+		if report.Scanned == batchSize {
+			return true, nil
+		}
+		report.Scanned++
+		_, keys, err := stub.SplitCompositeKey(val.GetKey())
+		if err != nil {
+			return false, errors.New(fmt.Sprintf("%s-拆分复合主键出错: %s", objectType, err))
+		}
+		*last = &model.MigrationCursor{ObjectType: objectType, Keys: keys}
+		var doc model.Doc
+		if err := json.Unmarshal(val.GetValue(), &doc); err != nil {
+			return false, errors.New(fmt.Sprintf("%s-读取记录版本出错: %s", objectType, err))
+		}
+		if doc.Version >= model.SchemaVersion {
+			continue
+		}
+		//私有字段在私有数据集合中，合并后再迁移，重写时由WriteLedger重新拆分
+		value, err := utils.ReadPrivate(stub, objectType, val.GetKey(), val.GetValue())
+		if err != nil {
+			return false, err
+		}
+		upgraded, fromVersion, steps, err := upgradeDoc(objectType, value)
+		if err != nil {
+			return false, err
+		}
+		report.Migrated++
+		report.Changes = append(report.Changes, model.MigrationChange{ObjectType: objectType, Keys: keys, FromVersion: fromVersion, Steps: steps})
+		if report.DryRun {
+			continue
+		}
+		if err := utils.WriteLedger(upgraded, stub, objectType, keys); err != nil {
+			return false, err
+		}
+		value, err = json.Marshal(upgraded)
+		if err != nil {
+			return false, errors.New(fmt.Sprintf("%s-序列化出错: %s", objectType, err))
+		}
+		batch.Entries = append(batch.Entries, model.StateEntry{ObjectType: objectType, Keys: keys, Value: value})
+	}
+	return false, nil
+}
@@ -0,0 +1,51 @@
+package api
+
+import (
+	"chaincode/model"
+	"chaincode/pkg/errcode"
+	"chaincode/pkg/utils"
+	"fmt"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// transientArg 从临时数据中读取name对应的json，临时数据不写入区块，用于传入私有字段
+func transientArg(stub shim.ChaincodeStubInterface, name string, v interface{}) error {
+	found, err := utils.GetTransient(stub, name, v)
+	if err != nil {
+		return errcode.ArgFormat(name, err)
+	}
+	if !found {
+		return errcode.New(errcode.Validation, "transient.missing", fmt.Sprintf("临时数据中缺少%s", name)).With("key", name)
+	}
+	return nil
+}
+
+// QueryAccountPrivate 查询账户的私有部分(账号名和余额)，只有可以读取私有数据的组织能够调用
+func (c *AccountContract) QueryAccountPrivate(ctx contractapi.TransactionContextInterface, accountId string) (_ *model.AccountPrivate, err error) {
+	defer errcode.Envelope(&err)
+	stub := ctx.GetStub()
+	if err := utils.RequirePrivate(stub, model.AccountKey); err != nil {
+		return nil, err
+	}
+	account, err := utils.Accounts(stub).Get(accountId)
+	if err != nil {
+		return nil, err
+	}
+	return &model.AccountPrivate{AccountId: account.AccountId, UserName: account.UserName, Balance: account.Balance, PrivateHash: account.PrivateHash}, nil
+}
+
+// QueryOfferPrivate 查询销售的私有部分(价格)，只有可以读取私有数据的组织能够调用
+func (c *SellingContract) QueryOfferPrivate(ctx contractapi.TransactionContextInterface, seller string, objectOfSale string) (_ *model.OfferPrivate, err error) {
+	defer errcode.Envelope(&err)
+	stub := ctx.GetStub()
+	if err := utils.RequirePrivate(stub, model.SellingKey); err != nil {
+		return nil, err
+	}
+	selling, err := utils.Sellings(stub).Get(seller, objectOfSale)
+	if err != nil {
+		return nil, err
+	}
+	return &model.OfferPrivate{ObjectOfSale: selling.ObjectOfSale, Seller: selling.Seller, Price: selling.Price, PrivateHash: selling.PrivateHash}, nil
+}
@@ -0,0 +1,241 @@
+package api
+
+import (
+	"bytes"
+	"chaincode/model"
+	"chaincode/pkg/errcode"
+	"chaincode/pkg/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// QuerySellingsByFilter 根据筛选条件查询销售(价格区间、状态、面积区间、卖家)，不传筛选条件则查询所有
+func (c *SellingContract) QuerySellingsByFilter(ctx contractapi.TransactionContextInterface, filter *model.SellingFilter) (_ []model.Selling, err error) {
+	defer errcode.Envelope(&err)
+	if filter == nil {
+		filter = new(model.SellingFilter)
+	}
+	if err := validateSellingFilter(*filter); err != nil {
+		return nil, err
+	}
+	return querySellings(ctx.GetStub(), *filter)
+}
+
+// QueryRealEstatesByFilter 根据筛选条件查询房地产(所有者、担保状态、面积区间)，不传筛选条件则查询所有
+func (c *RealEstateContract) QueryRealEstatesByFilter(ctx contractapi.TransactionContextInterface, filter *model.RealEstateFilter) (_ []model.RealEstate, err error) {
+	defer errcode.Envelope(&err)
+	if filter == nil {
+		filter = new(model.RealEstateFilter)
+	}
+	if err := validateRealEstateFilter(*filter); err != nil {
+		return nil, err
+	}
+	return queryRealEstates(ctx.GetStub(), *filter)
+}
+
+// validateRange 校验区间，max为0表示不限制上限
+func validateRange(name string, min, max float64) error {
+	if min < 0 || max < 0 {
+		return errcode.New(errcode.Validation, "filter.invalid", fmt.Sprintf("%s不能为负数", name)).With("field", name)
+	}
+	if max > 0 && min > max {
+		return errcode.New(errcode.Validation, "filter.invalid", fmt.Sprintf("%s下限不能大于上限", name)).With("field", name)
+	}
+	return nil
+}
+
+func validateSellingFilter(filter model.SellingFilter) error {
+	if filter.SellingStatus != "" {
+		if _, ok := model.SellingStatusConstant()[filter.SellingStatus]; !ok {
+			return errcode.StatusUnsupported(filter.SellingStatus)
+		}
+	}
+	if err := validateRange("price价格", filter.MinPrice, filter.MaxPrice); err != nil {
+		return err
+	}
+	return validateRange("area面积", filter.MinArea, filter.MaxArea)
+}
+
+func validateRealEstateFilter(filter model.RealEstateFilter) error {
+	if _, ok := filter.Encumbrance.(bool); filter.Encumbrance != nil && !ok {
+		return errcode.New(errcode.Validation, "filter.invalid", "encumbrance担保状态只能为布尔值").With("field", "encumbrance")
+	}
+	if err := validateRange("totalArea总面积", filter.MinTotalArea, filter.MaxTotalArea); err != nil {
+		return err
+	}
+	return validateRange("livingSpace生活空间", filter.MinLivingSpace, filter.MaxLivingSpace)
+}
+
+// rangeSelector 构建Mango区间条件，没有限制时返回nil
+func rangeSelector(min, max float64) map[string]interface{} {
+	if min <= 0 && max <= 0 {
+		return nil
+	}
+	selector := map[string]interface{}{}
+	if min > 0 {
+		selector["$gte"] = min
+	}
+	if max > 0 {
+		selector["$lte"] = max
+	}
+	return selector
+}
+
+// inRange 与rangeSelector的语义保持一致，用于降级遍历时的筛选
+func inRange(val, min, max float64) bool {
+	return (min <= 0 || val >= min) && (max <= 0 || val <= max)
+}
+
+// errRichQueryUnsupported 状态数据库不支持富查询，由调用方降级为遍历复合主键
+var errRichQueryUnsupported = errors.New("状态数据库不支持富查询")
+
+// unsupportedMessages 不支持富查询时的错误消息(小写)，分别来自LevelDB和shimtest.MockStub
+var unsupportedMessages = []string{"executequery not supported for leveldb", "not implemented"}
+
+// richQuery 使用CouchDB富查询，LevelDB或MockStub不支持时返回errRichQueryUnsupported，其它错误(如CouchDB超时、语句出错)直接返回
+// selector都带有docType条件，没有docType的旧记录查询不到，升级链码后需先调用upgradeDocType补齐
+func richQuery(stub shim.ChaincodeStubInterface, selector map[string]interface{}, v interface{}) error {
+	query, err := json.Marshal(map[string]interface{}{"selector": selector})
+	if err != nil {
+		return errors.New(fmt.Sprintf("构建富查询语句出错: %s", err))
+	}
+	results, err := utils.GetQueryResult(stub, string(query))
+	if err != nil {
+		for _, message := range unsupportedMessages {
+			if strings.Contains(strings.ToLower(err.Error()), message) {
+				return errRichQueryUnsupported
+			}
+		}
+		return err
+	}
+	//拼接为json数组一次性反序列化
+	if err := json.Unmarshal(append(append([]byte("["), bytes.Join(results, []byte(","))...), ']'), v); err != nil {
+		return errors.New(fmt.Sprintf("富查询-反序列化出错: %s", err))
+	}
+	return nil
+}
+
+func queryRealEstates(stub shim.ChaincodeStubInterface, filter model.RealEstateFilter) ([]model.RealEstate, error) {
+	selector := map[string]interface{}{"docType": model.RealEstateDocType}
+	var keys []string
+	if filter.Proprietor != "" {
+		selector["proprietor"] = filter.Proprietor
+		keys = []string{filter.Proprietor}
+	}
+	if filter.Encumbrance != nil {
+		selector["encumbrance"] = filter.Encumbrance
+	}
+	if r := rangeSelector(filter.MinTotalArea, filter.MaxTotalArea); r != nil {
+		selector["totalArea"] = r
+	}
+	if r := rangeSelector(filter.MinLivingSpace, filter.MaxLivingSpace); r != nil {
+		selector["livingSpace"] = r
+	}
+	var realEstateList []model.RealEstate
+	if err := richQuery(stub, selector, &realEstateList); err == nil {
+		return realEstateList, nil
+	} else if err != errRichQueryUnsupported {
+		return nil, err
+	}
+	all, err := utils.RealEstates(stub).List(keys...)
+	if err != nil {
+		return nil, err
+	}
+	for _, realEstate := range all {
+		if matchRealEstate(filter, realEstate) {
+			realEstateList = append(realEstateList, realEstate)
+		}
+	}
+	return realEstateList, nil
+}
+
+func matchRealEstate(filter model.RealEstateFilter, realEstate model.RealEstate) bool {
+	if filter.Proprietor != "" && realEstate.Proprietor != filter.Proprietor {
+		return false
+	}
+	if filter.Encumbrance != nil && realEstate.Encumbrance != filter.Encumbrance.(bool) {
+		return false
+	}
+	return inRange(realEstate.TotalArea, filter.MinTotalArea, filter.MaxTotalArea) &&
+		inRange(realEstate.LivingSpace, filter.MinLivingSpace, filter.MaxLivingSpace)
+}
+
+func querySellings(stub shim.ChaincodeStubInterface, filter model.SellingFilter) ([]model.Selling, error) {
+	selector := map[string]interface{}{"docType": model.SellingDocType}
+	var keys []string
+	if filter.Seller != "" {
+		selector["seller"] = filter.Seller
+		keys = []string{filter.Seller}
+	}
+	if filter.SellingStatus != "" {
+		selector["sellingStatus"] = model.SellingStatusConstant()[filter.SellingStatus]
+	}
+	//价格在私有数据集合中，公开记录没有价格，不能作为富查询条件
+	//面积属于房地产，先筛选出满足面积条件的房地产，再限定销售对象
+	var objectOfSales map[string]bool
+	if filter.MinArea > 0 || filter.MaxArea > 0 {
+		realEstateList, err := queryRealEstates(stub, model.RealEstateFilter{MinTotalArea: filter.MinArea, MaxTotalArea: filter.MaxArea})
+		if err != nil {
+			return nil, err
+		}
+		if len(realEstateList) == 0 {
+			return nil, nil
+		}
+		objectOfSales = make(map[string]bool)
+		var ids []string
+		for _, v := range realEstateList {
+			objectOfSales[v.RealEstateID] = true
+			ids = append(ids, v.RealEstateID)
+		}
+		selector["objectOfSale"] = map[string]interface{}{"$in": ids}
+	}
+	var sellingList []model.Selling
+	if err := richQuery(stub, selector, &sellingList); err == nil {
+		return filterSellingPrice(stub, sellingList, filter)
+	} else if err != errRichQueryUnsupported {
+		return nil, err
+	}
+	all, err := utils.Sellings(stub).List(keys...)
+	if err != nil {
+		return nil, err
+	}
+	for _, selling := range all {
+		if matchSelling(filter, objectOfSales, selling) {
+			sellingList = append(sellingList, selling)
+		}
+	}
+	return sellingList, nil
+}
+
+func matchSelling(filter model.SellingFilter, objectOfSales map[string]bool, selling model.Selling) bool {
+	if filter.Seller != "" && selling.Seller != filter.Seller {
+		return false
+	}
+	if filter.SellingStatus != "" && selling.SellingStatus != model.SellingStatusConstant()[filter.SellingStatus] {
+		return false
+	}
+	if objectOfSales != nil && !objectOfSales[selling.ObjectOfSale] {
+		return false
+	}
+	return inRange(selling.Price, filter.MinPrice, filter.MaxPrice)
+}
+
+// filterSellingPrice 富查询返回的是公开记录，逐条合并私有数据后按价格筛选
+func filterSellingPrice(stub shim.ChaincodeStubInterface, sellingList []model.Selling, filter model.SellingFilter) ([]model.Selling, error) {
+	var filtered []model.Selling
+	for _, s := range sellingList {
+		selling, err := utils.Sellings(stub).Get(s.Seller, s.ObjectOfSale)
+		if err != nil {
+			return nil, err
+		}
+		if inRange(selling.Price, filter.MinPrice, filter.MaxPrice) {
+			filtered = append(filtered, *selling)
+		}
+	}
+	return filtered, nil
+}
@@ -2,101 +2,184 @@ package api
 
 import (
 	"chaincode/model"
+	"chaincode/pkg/errcode"
+	"chaincode/pkg/events"
 	"chaincode/pkg/utils"
-	"encoding/json"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
-	"strconv"
 
-	"github.com/hyperledger/fabric/core/chaincode/shim"
-	pb "github.com/hyperledger/fabric/protos/peer"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-contract-api-go/metadata"
 )
 
-// CreateRealEstate 新建房地产(管理员)
-func CreateRealEstate(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	// 验证参数
-	if len(args) != 4 {
-		return shim.Error("参数个数不满足")
-	}
-	accountId := args[0] //accountId用于验证是否为管理员
-	proprietor := args[1]
-	totalArea := args[2]
-	livingSpace := args[3]
-	if accountId == "" || proprietor == "" || totalArea == "" || livingSpace == "" {
-		return shim.Error("参数存在空值")
+// RealEstateContract 房地产合约
+type RealEstateContract struct {
+	Contract
+}
+
+// NewRealEstateContract 房地产合约，新建房地产需要管理员
+func NewRealEstateContract() *RealEstateContract {
+	c := &RealEstateContract{}
+	c.Name = "RealEstate"
+	c.Info = metadata.InfoMetadata{Title: "房地产", Description: "新建和查询房地产"}
+	c.Params = map[string][]string{
+		"CreateRealEstate":         {"accountId", "proprietor", "totalArea", "livingSpace"},
+		"BatchCreateRealEstate":    {"accountId", "rows"},
+		"QueryRealEstateList":      {"proprietor", "realEstateId"},
+		"QueryRealEstatesByFilter": {"filter"},
 	}
+	c.Evaluate = []string{"QueryRealEstateList", "QueryRealEstatesByFilter"}
+	c.BeforeTransaction = guard{
+		contract: &c.Contract,
+		optional: map[string][]string{
+			"QueryRealEstateList":      {"proprietor", "realEstateId"},
+			"QueryRealEstatesByFilter": {"filter"},
+		},
+		adminOnly: []string{"CreateRealEstate", "BatchCreateRealEstate"},
+	}.before
+	return c
+}
+
+// CreateRealEstate 新建房地产(管理员)，accountId用于验证是否为管理员
+func (c *RealEstateContract) CreateRealEstate(ctx contractapi.TransactionContextInterface, accountId string, proprietor string,
+	totalArea float64, livingSpace float64) (_ *model.RealEstate, err error) {
+	defer errcode.Envelope(&err)
+	stub := ctx.GetStub()
 	if accountId == proprietor {
-		return shim.Error("操作人应为管理员且与所有人不能相同")
-	}
-	// 参数数据格式转换
-	var formattedTotalArea float64
-	if val, err := strconv.ParseFloat(totalArea, 64); err != nil {
-		return shim.Error(fmt.Sprintf("totalArea参数格式转换出错: %s", err))
-	} else {
-		formattedTotalArea = val
-	}
-	var formattedLivingSpace float64
-	if val, err := strconv.ParseFloat(livingSpace, 64); err != nil {
-		return shim.Error(fmt.Sprintf("livingSpace参数格式转换出错: %s", err))
-	} else {
-		formattedLivingSpace = val
-	}
-	//判断是否管理员操作
-	resultsAccount, err := utils.GetStateByPartialCompositeKeys(stub, model.AccountKey, []string{accountId})
-	if err != nil || len(resultsAccount) != 1 {
-		return shim.Error(fmt.Sprintf("操作人权限验证失败%s", err))
-	}
-	var account model.Account
-	if err = json.Unmarshal(resultsAccount[0], &account); err != nil {
-		return shim.Error(fmt.Sprintf("查询操作人信息-反序列化出错: %s", err))
-	}
-	if account.UserName != "管理员" {
-		return shim.Error(fmt.Sprintf("操作人权限不足%s", err))
+		return nil, errcode.New(errcode.Validation, "realEstate.sameAccount", "操作人应为管理员且与所有人不能相同")
 	}
 	//判断业主是否存在
-	resultsProprietor, err := utils.GetStateByPartialCompositeKeys(stub, model.AccountKey, []string{proprietor})
-	if err != nil || len(resultsProprietor) != 1 {
-		return shim.Error(fmt.Sprintf("业主proprietor信息验证失败%s", err))
+	if _, err := utils.Accounts(stub).Get(proprietor); err != nil {
+		return nil, errcode.Wrap(err, "业主proprietor信息验证失败")
 	}
 	realEstate := &model.RealEstate{
 		RealEstateID: stub.GetTxID()[:16],
 		Proprietor:   proprietor,
 		Encumbrance:  false,
-		TotalArea:    formattedTotalArea,
-		LivingSpace:  formattedLivingSpace,
+		TotalArea:    totalArea,
+		LivingSpace:  livingSpace,
 	}
 	// 写入账本
-	if err := utils.WriteLedger(realEstate, stub, model.RealEstateKey, []string{realEstate.Proprietor, realEstate.RealEstateID}); err != nil {
-		return shim.Error(fmt.Sprintf("%s", err))
+	if err := utils.RealEstates(stub).Put(realEstate); err != nil {
+		return nil, err
 	}
-	//将成功创建的信息返回
-	realEstateByte, err := json.Marshal(realEstate)
-	if err != nil {
-		return shim.Error(fmt.Sprintf("序列化成功创建的信息出错: %s", err))
+	if err := events.Emit(stub, model.RealEstateCreated, []string{proprietor}, realEstate); err != nil {
+		return nil, err
 	}
-	// 成功返回
-	return shim.Success(realEstateByte)
+	return realEstate, nil
 }
 
-// QueryRealEstateList 查询房地产(可查询所有，也可根据所有人查询名下房产)
-func QueryRealEstateList(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	var realEstateList []model.RealEstate
-	results, err := utils.GetStateByPartialCompositeKeys2(stub, model.RealEstateKey, args)
-	if err != nil {
-		return shim.Error(fmt.Sprintf("%s", err))
-	}
-	for _, v := range results {
-		if v != nil {
-			var realEstate model.RealEstate
-			err := json.Unmarshal(v, &realEstate)
+// BatchCreateRealEstate 批量新建房地产(管理员)，最多MaxBatchRealEstates行
+// 各行单独校验，校验失败的行不影响其它行写入，逐行返回结果；读写账本出错时整个交易失败
+func (c *RealEstateContract) BatchCreateRealEstate(ctx contractapi.TransactionContextInterface, accountId string, rows []model.RealEstateRow) (_ *model.BatchResult, err error) {
+	defer errcode.Envelope(&err)
+	stub := ctx.GetStub()
+	if len(rows) == 0 || len(rows) > model.MaxBatchRealEstates {
+		return nil, errcode.New(errcode.Validation, "realEstate.batchSize",
+			fmt.Sprintf("批量新建房地产的条数应为1到%d，实际%d", model.MaxBatchRealEstates, len(rows))).
+			With("max", model.MaxBatchRealEstates).With("size", len(rows))
+	}
+	result := &model.BatchResult{Results: make([]model.RowResult, 0, len(rows))}
+	proprietors := make(map[string]error) //同一业主只查询一次
+	refs := make(map[string]int)          //交易内读不到本交易的写入，批内重复的外部编号在此判断
+	for i, row := range rows {
+		rowResult := model.RowResult{Index: i, Ref: row.Ref}
+		realEstate, existing, err := batchCreateRow(stub, accountId, i, row, proprietors, refs)
+		switch {
+		case err != nil:
+			e := errcode.From(err)
+			if e.Code == errcode.Internal {
+				return nil, errcode.Wrap(err, fmt.Sprintf("批量新建第%d行出错", i))
+			}
+			rowResult.Status = model.RowFailed
+			rowResult.Error = &model.RowError{Code: string(e.Code), Key: e.Key, Message: e.Message}
+			result.Failed++
+		case existing:
+			rowResult.Status = model.RowExists
+			rowResult.RealEstate = realEstate
+			result.Existing++
+		default:
+			rowResult.Status = model.RowCreated
+			rowResult.RealEstate = realEstate
+			result.Created++
+		}
+		result.Results = append(result.Results, rowResult)
+	}
+	return result, nil
+}
+
+// batchCreateRow 校验并写入一行，外部编号已导入过时返回已有的房地产且existing为true
+func batchCreateRow(stub shim.ChaincodeStubInterface, accountId string, index int, row model.RealEstateRow,
+	proprietors map[string]error, refs map[string]int) (*model.RealEstate, bool, error) {
+	if row.Proprietor == "" {
+		return nil, false, errcode.New(errcode.Validation, "args.empty", "proprietor不能为空").With("arg", "proprietor")
+	}
+	if row.Proprietor == accountId {
+		return nil, false, errcode.New(errcode.Validation, "realEstate.sameAccount", "操作人应为管理员且与所有人不能相同")
+	}
+	if row.TotalArea <= 0 || row.LivingSpace <= 0 || row.LivingSpace > row.TotalArea {
+		return nil, false, errcode.New(errcode.Validation, "realEstate.area", "总面积和生活空间必须大于0，且生活空间不大于总面积")
+	}
+	if row.Ref != "" {
+		if first, ok := refs[row.Ref]; ok {
+			return nil, false, errcode.New(errcode.Conflict, "importRef.duplicate",
+				fmt.Sprintf("外部编号%s与第%d行重复", row.Ref, first)).With("ref", row.Ref).With("index", first)
+		}
+		importRef, err := utils.ImportRefs(stub).Get(row.Ref)
+		if err == nil {
+			refs[row.Ref] = index
+			realEstate, err := utils.RealEstates(stub).Get(importRef.Proprietor, importRef.RealEstateID)
+			if utils.IsNotFound(err) {
+				//已导入的房地产过户后不在原所有者名下，只返回ID
+				return &model.RealEstate{RealEstateID: importRef.RealEstateID}, true, nil
+			}
 			if err != nil {
-				return shim.Error(fmt.Sprintf("QueryRealEstateList-反序列化出错: %s", err))
+				return nil, false, err
 			}
-			realEstateList = append(realEstateList, realEstate)
+			return realEstate, true, nil
+		}
+		if !utils.IsNotFound(err) {
+			return nil, false, err
+		}
+	}
+	//判断业主是否存在
+	checked, ok := proprietors[row.Proprietor]
+	if !ok {
+		_, checked = utils.Accounts(stub).Get(row.Proprietor)
+		proprietors[row.Proprietor] = checked
+	}
+	if checked != nil {
+		return nil, false, errcode.Wrap(checked, "业主proprietor信息验证失败")
+	}
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s:%d", stub.GetTxID(), index)))
+	realEstate := &model.RealEstate{
+		RealEstateID: hex.EncodeToString(sum[:])[:16],
+		Proprietor:   row.Proprietor,
+		Encumbrance:  false,
+		TotalArea:    row.TotalArea,
+		LivingSpace:  row.LivingSpace,
+	}
+	// 写入账本
+	if err := utils.RealEstates(stub).Put(realEstate); err != nil {
+		return nil, false, err
+	}
+	if row.Ref != "" {
+		refs[row.Ref] = index
+		importRef := &model.ImportRef{Ref: row.Ref, RealEstateID: realEstate.RealEstateID, Proprietor: row.Proprietor, TxID: stub.GetTxID()}
+		if err := utils.ImportRefs(stub).Put(importRef); err != nil {
+			return nil, false, err
 		}
 	}
-	realEstateListByte, err := json.Marshal(realEstateList)
-	if err != nil {
-		return shim.Error(fmt.Sprintf("QueryRealEstateList-序列化出错: %s", err))
+	if err := events.Emit(stub, model.RealEstateCreated, []string{row.Proprietor}, realEstate); err != nil {
+		return nil, false, err
 	}
-	return shim.Success(realEstateListByte)
+	return realEstate, false, nil
+}
+
+// QueryRealEstateList 查询房地产(可查询所有，也可根据所有人查询名下房产，或查询所有人名下的某个房产)
+func (c *RealEstateContract) QueryRealEstateList(ctx contractapi.TransactionContextInterface, proprietor string, realEstateId string) (_ []model.RealEstate, err error) {
+	defer errcode.Envelope(&err)
+	return utils.RealEstates(ctx.GetStub()).List(listKeys(proprietor, realEstateId)...)
 }
@@ -0,0 +1,203 @@
+package api
+
+import (
+	"chaincode/model"
+	"chaincode/pkg/errcode"
+	"chaincode/pkg/events"
+	"chaincode/pkg/utils"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// InitSeed 链码初始化，写入实例化参数中的种子数据，不传参数时写入model.DefaultSeed
+// 种子数据中的账号名和余额是私有字段，也可以通过临时数据的seed传入，此时不写入区块，不能同时传参数
+// 升级链码定义(--init-required)后同样需要以--isInit调用Init，账本中已有数据时不写入：不传参数或种子与已写入的相同时直接返回，否则拒绝
+func InitSeed(stub shim.ChaincodeStubInterface, args []string) pb.Response {
+	if len(args) > 1 {
+		return errcode.Response(errcode.ArgCount())
+	}
+	seed := model.DefaultSeed()
+	transientSeed := new(model.Seed)
+	found, err := utils.GetTransient(stub, model.TransientSeed, transientSeed)
+	if err != nil {
+		return errcode.Response(errcode.ArgFormat("seed", err))
+	}
+	if found {
+		if len(args) == 1 {
+			return errcode.Response(errcode.ArgCount())
+		}
+		seed = transientSeed
+	}
+	if len(args) == 1 {
+		seed = new(model.Seed)
+		if err := json.Unmarshal([]byte(args[0]), seed); err != nil {
+			return errcode.Response(errcode.ArgFormat("seed", err))
+		}
+	}
+	if err := checkSeed(seed); err != nil {
+		return errcode.Response(err)
+	}
+	seedByte, err := json.Marshal(seed)
+	if err != nil {
+		return errcode.Response(errcode.Wrap(err, "序列化种子数据出错"))
+	}
+	sum := sha256.Sum256(seedByte)
+	digest := hex.EncodeToString(sum[:])
+
+	existing, err := seededWith(stub)
+	if err != nil {
+		return errcode.Response(err)
+	}
+	if existing != "" {
+		if (len(args) == 0 && !found) || existing == digest {
+			return shim.Success(nil)
+		}
+		return errcode.Response(errcode.New(errcode.Conflict, "seed.dataExists", fmt.Sprintf("账本中已有数据(%s)，拒绝写入种子数据", existing)))
+	}
+
+	for _, v := range seed.Accounts {
+		account := &model.Account{AccountId: v.AccountId, UserName: v.UserName, Role: v.Role, Balance: v.Balance}
+		if err := utils.Accounts(stub).Put(account); err != nil {
+			return errcode.Response(err)
+		}
+		if err := emitRecord(stub, model.AccountCreated, []string{account.AccountId}, model.AccountKey, account); err != nil {
+			return errcode.Response(err)
+		}
+	}
+	for _, v := range seed.RealEstates {
+		realEstate := &model.RealEstate{RealEstateID: v.RealEstateID, Proprietor: v.Proprietor, TotalArea: v.TotalArea, LivingSpace: v.LivingSpace}
+		if err := utils.RealEstates(stub).Put(realEstate); err != nil {
+			return errcode.Response(err)
+		}
+		if err := events.Emit(stub, model.RealEstateCreated, []string{realEstate.Proprietor}, realEstate); err != nil {
+			return errcode.Response(err)
+		}
+	}
+	record := model.SeedRecord{Digest: digest, TxID: stub.GetTxID(), Accounts: len(seed.Accounts), RealEstates: len(seed.RealEstates), MoneySupply: seed.MoneySupply}
+	recordByte, err := json.Marshal(record)
+	if err != nil {
+		return errcode.Response(errcode.Wrap(err, "序列化种子记录出错"))
+	}
+	if err := stub.PutState(model.SeedKey, recordByte); err != nil {
+		return errcode.Response(errcode.Wrap(err, "写入种子记录出错"))
+	}
+	if err := writeSchemaState(stub, &model.SchemaState{Version: model.SchemaVersion}); err != nil {
+		return errcode.Response(err)
+	}
+	return shim.Success(recordByte)
+}
+
+// QuerySeed 查询Init写入的种子记录，对账时以其中的货币总量为准，导入状态或早于种子数据的账本没有种子记录
+func (c *LedgerContract) QuerySeed(ctx contractapi.TransactionContextInterface) (_ *model.SeedRecord, err error) {
+	defer errcode.Envelope(&err)
+	recordByte, err := ctx.GetStub().GetState(model.SeedKey)
+	if err != nil {
+		return nil, errcode.Wrap(err, "读取种子记录出错")
+	}
+	if recordByte == nil {
+		return nil, errcode.New(errcode.NotFound, "seed.notFound", "账本中没有种子记录")
+	}
+	record := new(model.SeedRecord)
+	if err := json.Unmarshal(recordByte, record); err != nil {
+		return nil, errcode.Wrap(err, "反序列化种子记录出错")
+	}
+	return record, nil
+}
+
+// checkSeed 校验种子数据，补齐业主角色和货币总量
+func checkSeed(seed *model.Seed) error {
+	invalid := func(format string, a ...interface{}) error {
+		return errcode.New(errcode.Validation, "seed.invalid", fmt.Sprintf(format, a...))
+	}
+	if len(seed.Accounts) == 0 {
+		return invalid("种子数据中没有账户")
+	}
+	roles := make(map[string]string, len(seed.Accounts))
+	var total float64
+	admins := 0
+	for i := range seed.Accounts {
+		v := &seed.Accounts[i]
+		if strings.TrimSpace(v.AccountId) == "" || strings.TrimSpace(v.UserName) == "" {
+			return invalid("第%d个账户的accountId和userName不能为空", i)
+		}
+		if _, dup := roles[v.AccountId]; dup {
+			return invalid("账户%s重复", v.AccountId)
+		}
+		if v.Role == "" {
+			v.Role = model.RoleOwner
+		}
+		if v.Role != model.RoleAdmin && v.Role != model.RoleOwner {
+			return invalid("账户%s的角色%s不支持，可选%s、%s", v.AccountId, v.Role, model.RoleAdmin, model.RoleOwner)
+		}
+		if v.Role == model.RoleAdmin {
+			admins++
+		}
+		if v.Balance < 0 || math.IsNaN(v.Balance) || math.IsInf(v.Balance, 0) {
+			return invalid("账户%s的余额不合法: %v", v.AccountId, v.Balance)
+		}
+		roles[v.AccountId] = v.Role
+		total += v.Balance
+	}
+	if admins == 0 {
+		return invalid("种子数据中至少需要一个管理员")
+	}
+	if seed.MoneySupply == 0 {
+		seed.MoneySupply = total
+	}
+	if math.Abs(seed.MoneySupply-total) > 0.005 {
+		return errcode.New(errcode.Validation, "seed.moneySupply", fmt.Sprintf("货币总量%v与各账户初始余额之和%v不一致", seed.MoneySupply, total)).
+			With("moneySupply", seed.MoneySupply).With("balances", total)
+	}
+	ids := make(map[string]bool, len(seed.RealEstates))
+	for i, v := range seed.RealEstates {
+		if strings.TrimSpace(v.RealEstateID) == "" {
+			return invalid("第%d个房地产的realEstateId不能为空", i)
+		}
+		if ids[v.RealEstateID] {
+			return invalid("房地产%s重复", v.RealEstateID)
+		}
+		ids[v.RealEstateID] = true
+		if roles[v.Proprietor] != model.RoleOwner {
+			return invalid("房地产%s的所有者%s必须是种子数据中的业主", v.RealEstateID, v.Proprietor)
+		}
+		if v.TotalArea <= 0 || v.LivingSpace <= 0 || v.LivingSpace > v.TotalArea {
+			return invalid("房地产%s的总面积和生活空间必须大于0，且生活空间不大于总面积", v.RealEstateID)
+		}
+	}
+	return nil
+}
+
+// seededWith 账本中已有数据时返回写入时的种子摘要，早于种子数据的账本或导入过状态的账本返回其它描述，没有数据时返回空
+func seededWith(stub shim.ChaincodeStubInterface) (string, error) {
+	recordByte, err := stub.GetState(model.SeedKey)
+	if err != nil {
+		return "", errcode.Wrap(err, "读取种子记录出错")
+	}
+	if recordByte != nil {
+		var record model.SeedRecord
+		if err := json.Unmarshal(recordByte, &record); err != nil {
+			return "", errcode.Wrap(err, "反序列化种子记录出错")
+		}
+		return record.Digest, nil
+	}
+	for _, objectType := range stateObjectTypes() {
+		resultIterator, err := stub.GetStateByPartialCompositeKey(objectType, []string{})
+		if err != nil {
+			return "", errcode.Wrap(err, fmt.Sprintf("%s-获取全部数据出错", objectType))
+		}
+		hasNext := resultIterator.HasNext()
+		resultIterator.Close()
+		if hasNext {
+			return model.DocTypes[objectType], nil
+		}
+	}
+	return "", nil
+}
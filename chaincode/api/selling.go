@@ -2,351 +2,324 @@ package api
 
 import (
 	"chaincode/model"
+	"chaincode/pkg/errcode"
+	"chaincode/pkg/events"
 	"chaincode/pkg/utils"
 	"encoding/json"
 	"fmt"
-	"strconv"
 	"time"
 
-	"github.com/hyperledger/fabric/core/chaincode/shim"
-	pb "github.com/hyperledger/fabric/protos/peer"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-contract-api-go/metadata"
 )
 
-// CreateSelling 发起销售
-func CreateSelling(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	// 验证参数
-	if len(args) != 4 {
-		return shim.Error("参数个数不满足")
-	}
-	objectOfSale := args[0]
-	seller := args[1]
-	price := args[2]
-	salePeriod := args[3]
-	if objectOfSale == "" || seller == "" || price == "" || salePeriod == "" {
-		return shim.Error("参数存在空值")
-	}
-	// 参数数据格式转换
-	var formattedPrice float64
-	if val, err := strconv.ParseFloat(price, 64); err != nil {
-		return shim.Error(fmt.Sprintf("price参数格式转换出错: %s", err))
-	} else {
-		formattedPrice = val
-	}
-	var formattedSalePeriod int
-	if val, err := strconv.Atoi(salePeriod); err != nil {
-		return shim.Error(fmt.Sprintf("salePeriod参数格式转换出错: %s", err))
-	} else {
-		formattedSalePeriod = val
+// SellingContract 销售合约
+type SellingContract struct {
+	Contract
+}
+
+// NewSellingContract 销售合约
+func NewSellingContract() *SellingContract {
+	c := &SellingContract{}
+	c.Name = "Selling"
+	c.Info = metadata.InfoMetadata{Title: "销售", Description: "发起、购买、确认和取消房地产销售"}
+	c.Params = map[string][]string{
+		"CreateSelling":           {"objectOfSale", "seller", "price", "salePeriod"},
+		"CreateSellingPrivate":    {"objectOfSale", "seller", "salePeriod"},
+		"CreateSellingByBuy":      {"objectOfSale", "seller", "buyer"},
+		"QuerySellingList":        {"seller", "objectOfSale"},
+		"QuerySellingListByBuyer": {"buyer"},
+		"QuerySellingsByFilter":   {"filter"},
+		"UpdateSelling":           {"objectOfSale", "seller", "buyer", "status"},
+		"QueryOfferPrivate":       {"seller", "objectOfSale"},
+	}
+	c.Evaluate = []string{"QuerySellingList", "QuerySellingListByBuyer", "QuerySellingsByFilter", "QueryOfferPrivate"}
+	c.Parties = map[string][]string{
+		"CreateSelling":        {"seller"},
+		"CreateSellingPrivate": {"seller"},
+		"CreateSellingByBuy":   {"buyer"},
+		"UpdateSelling":        {"seller", "buyer"},
+	}
+	c.BeforeTransaction = guard{
+		contract: &c.Contract,
+		optional: map[string][]string{
+			"QuerySellingList":      {"seller", "objectOfSale"},
+			"QuerySellingsByFilter": {"filter"},
+			"UpdateSelling":         {"buyer"}, //销售中取消时没有买家
+		},
+		private: model.SellingKey, //销售的价格和买家的余额都是私有字段
+	}.before
+	return c
+}
+
+// CreateSelling 发起销售，salePeriod为智能合约的有效期(单位为天)
+// 价格作为交易参数会写入区块，需要保密时使用CreateSellingPrivate
+func (c *SellingContract) CreateSelling(ctx contractapi.TransactionContextInterface, objectOfSale string, seller string,
+	price float64, salePeriod int) (_ *model.Selling, err error) {
+	defer errcode.Envelope(&err)
+	return createSelling(ctx.GetStub(), objectOfSale, seller, price, salePeriod)
+}
+
+// CreateSellingPrivate 发起销售，价格通过临时数据的offer传入，格式见model.OfferInput，不会出现在区块中
+// 返回值同样写入区块，不包含价格，价格通过QueryOfferPrivate查询
+func (c *SellingContract) CreateSellingPrivate(ctx contractapi.TransactionContextInterface, objectOfSale string, seller string,
+	salePeriod int) (_ *model.Selling, err error) {
+	defer errcode.Envelope(&err)
+	var offer model.OfferInput
+	if err := transientArg(ctx.GetStub(), model.TransientOffer, &offer); err != nil {
+		return nil, err
+	}
+	selling, err := createSelling(ctx.GetStub(), objectOfSale, seller, offer.Price, salePeriod)
+	if err != nil {
+		return nil, err
 	}
+	selling.Price = 0
+	return selling, nil
+}
+
+func createSelling(stub shim.ChaincodeStubInterface, objectOfSale string, seller string, price float64, salePeriod int) (*model.Selling, error) {
 	//判断objectOfSale是否属于seller
-	resultsRealEstate, err := utils.GetStateByPartialCompositeKeys2(stub, model.RealEstateKey, []string{seller, objectOfSale})
-	if err != nil || len(resultsRealEstate) != 1 {
-		return shim.Error(fmt.Sprintf("验证%s属于%s失败: %s", objectOfSale, seller, err))
-	}
-	var realEstate model.RealEstate
-	if err = json.Unmarshal(resultsRealEstate[0], &realEstate); err != nil {
-		return shim.Error(fmt.Sprintf("CreateSelling-反序列化出错: %s", err))
+	realEstate, err := utils.RealEstates(stub).Get(seller, objectOfSale)
+	if err != nil {
+		return nil, errcode.Wrap(err, fmt.Sprintf("验证%s属于%s失败", objectOfSale, seller))
 	}
 	//判断记录是否已存在，不能重复发起销售
 	//若Encumbrance为true即说明此房产已经正在担保状态
 	if realEstate.Encumbrance {
-		return shim.Error("此房地产已经作为担保状态，不能重复发起销售")
+		return nil, errcode.New(errcode.Conflict, "realEstate.encumbered", "此房地产已经作为担保状态，不能重复发起销售")
 	}
 	createTime, _ := stub.GetTxTimestamp()
 	selling := &model.Selling{
 		ObjectOfSale:  objectOfSale,
 		Seller:        seller,
 		Buyer:         "",
-		Price:         formattedPrice,
+		Price:         price,
 		CreateTime:    time.Unix(int64(createTime.GetSeconds()), int64(createTime.GetNanos())).Local().Format("2006-01-02 15:04:05"),
-		SalePeriod:    formattedSalePeriod,
+		SalePeriod:    salePeriod,
 		SellingStatus: model.SellingStatusConstant()["saleStart"],
 	}
 	// 写入账本
-	if err := utils.WriteLedger(selling, stub, model.SellingKey, []string{selling.Seller, selling.ObjectOfSale}); err != nil {
-		return shim.Error(fmt.Sprintf("%s", err))
+	if err := utils.Sellings(stub).Put(selling); err != nil {
+		return nil, err
 	}
 	//将房子状态设置为正在担保状态
 	realEstate.Encumbrance = true
-	if err := utils.WriteLedger(realEstate, stub, model.RealEstateKey, []string{realEstate.Proprietor, realEstate.RealEstateID}); err != nil {
-		return shim.Error(fmt.Sprintf("%s", err))
+	if err := utils.RealEstates(stub).Put(realEstate); err != nil {
+		return nil, err
 	}
-	//将成功创建的信息返回
-	sellingByte, err := json.Marshal(selling)
-	if err != nil {
-		return shim.Error(fmt.Sprintf("序列化成功创建的信息出错: %s", err))
+	if err := emitRecord(stub, model.SellingCreated, []string{seller}, model.SellingKey, selling); err != nil {
+		return nil, err
 	}
-	// 成功返回
-	return shim.Success(sellingByte)
+	return selling, nil
 }
 
 // CreateSellingByBuy 参与销售(买家购买)
-func CreateSellingByBuy(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	// 验证参数
-	if len(args) != 3 {
-		return shim.Error("参数个数不满足")
-	}
-	objectOfSale := args[0]
-	seller := args[1]
-	buyer := args[2]
-	if objectOfSale == "" || seller == "" || buyer == "" {
-		return shim.Error("参数存在空值")
-	}
+func (c *SellingContract) CreateSellingByBuy(ctx contractapi.TransactionContextInterface, objectOfSale string, seller string, buyer string) (_ *model.SellingBuy, err error) {
+	defer errcode.Envelope(&err)
+	stub := ctx.GetStub()
 	if seller == buyer {
-		return shim.Error("买家和卖家不能同一人")
+		return nil, errcode.New(errcode.Validation, "selling.sameAccount", "买家和卖家不能同一人")
 	}
 	//根据objectOfSale和seller获取想要购买的房产信息，确认存在该房产
-	resultsRealEstate, err := utils.GetStateByPartialCompositeKeys2(stub, model.RealEstateKey, []string{seller, objectOfSale})
-	if err != nil || len(resultsRealEstate) != 1 {
-		return shim.Error(fmt.Sprintf("根据%s和%s获取想要购买的房产信息失败: %s", objectOfSale, seller, err))
+	if _, err := utils.RealEstates(stub).Get(seller, objectOfSale); err != nil {
+		return nil, errcode.Wrap(err, fmt.Sprintf("根据%s和%s获取想要购买的房产信息失败", objectOfSale, seller))
 	}
 	//根据objectOfSale和seller获取销售信息
-	resultsSelling, err := utils.GetStateByPartialCompositeKeys2(stub, model.SellingKey, []string{seller, objectOfSale})
-	if err != nil || len(resultsSelling) != 1 {
-		return shim.Error(fmt.Sprintf("根据%s和%s获取销售信息失败: %s", objectOfSale, seller, err))
-	}
-	var selling model.Selling
-	if err = json.Unmarshal(resultsSelling[0], &selling); err != nil {
-		return shim.Error(fmt.Sprintf("CreateSellingBuy-反序列化出错: %s", err))
+	selling, err := utils.Sellings(stub).Get(seller, objectOfSale)
+	if err != nil {
+		return nil, errcode.Wrap(err, fmt.Sprintf("根据%s和%s获取销售信息失败", objectOfSale, seller))
 	}
 	//判断selling的状态是否为销售中
 	if selling.SellingStatus != model.SellingStatusConstant()["saleStart"] {
-		return shim.Error("此交易不属于销售中状态，已经无法购买")
+		return nil, errcode.New(errcode.Conflict, "selling.notOnSale", "此交易不属于销售中状态，已经无法购买")
 	}
 	//根据buyer获取买家信息
-	resultsAccount, err := utils.GetStateByPartialCompositeKeys(stub, model.AccountKey, []string{buyer})
-	if err != nil || len(resultsAccount) != 1 {
-		return shim.Error(fmt.Sprintf("buyer买家信息验证失败%s", err))
-	}
-	var buyerAccount model.Account
-	if err = json.Unmarshal(resultsAccount[0], &buyerAccount); err != nil {
-		return shim.Error(fmt.Sprintf("查询buyer买家信息-反序列化出错: %s", err))
+	buyerAccount, err := utils.Accounts(stub).Get(buyer)
+	if err != nil {
+		return nil, errcode.Wrap(err, "buyer买家信息验证失败")
 	}
-	if buyerAccount.UserName == "管理员" {
-		return shim.Error(fmt.Sprintf("管理员不能购买%s", err))
+	if buyerAccount.IsAdmin() {
+		return nil, errcode.New(errcode.Forbidden, "selling.adminBuy", "管理员不能购买")
 	}
 	//判断余额是否充足
 	if buyerAccount.Balance < selling.Price {
-		return shim.Error(fmt.Sprintf("房产售价为%f,您的当前余额为%f,购买失败", selling.Price, buyerAccount.Balance))
+		return nil, errcode.New(errcode.InsufficientFunds, "balance.insufficient", fmt.Sprintf("房产售价为%f,您的当前余额为%f,购买失败", selling.Price, buyerAccount.Balance)).
+			With("price", selling.Price).With("balance", buyerAccount.Balance)
 	}
 	//将buyer写入交易selling,修改交易状态
 	selling.Buyer = buyer
 	selling.SellingStatus = model.SellingStatusConstant()["delivery"]
-	if err := utils.WriteLedger(selling, stub, model.SellingKey, []string{selling.Seller, selling.ObjectOfSale}); err != nil {
-		return shim.Error(fmt.Sprintf("将buyer写入交易selling,修改交易状态 失败%s", err))
+	if err := utils.Sellings(stub).Put(selling); err != nil {
+		return nil, errcode.Wrap(err, "将buyer写入交易selling,修改交易状态 失败")
 	}
 	createTime, _ := stub.GetTxTimestamp()
 	//将本次购买交易写入账本,可供买家查询
 	sellingBuy := &model.SellingBuy{
 		Buyer:      buyer,
 		CreateTime: time.Unix(int64(createTime.GetSeconds()), int64(createTime.GetNanos())).Local().Format("2006-01-02 15:04:05"),
-		Selling:    selling,
+		TxID:       stub.GetTxID(),
+		Selling:    *selling,
 	}
-	if err := utils.WriteLedger(sellingBuy, stub, model.SellingBuyKey, []string{sellingBuy.Buyer, sellingBuy.CreateTime}); err != nil {
-		return shim.Error(fmt.Sprintf("将本次购买交易写入账本失败%s", err))
-	}
-	sellingBuyByte, err := json.Marshal(sellingBuy)
-	if err != nil {
-		return shim.Error(fmt.Sprintf("序列化成功创建的信息出错: %s", err))
+	if err := utils.SellingBuys(stub).Put(sellingBuy); err != nil {
+		return nil, errcode.Wrap(err, "将本次购买交易写入账本失败")
 	}
 	//购买成功，扣取余额，更新账本余额，注意，此时需要卖家确认收款，款项才会转入卖家账户，此处先扣除买家的余额
 	buyerAccount.Balance -= selling.Price
-	if err := utils.WriteLedger(buyerAccount, stub, model.AccountKey, []string{buyerAccount.AccountId}); err != nil {
-		return shim.Error(fmt.Sprintf("扣取买家余额失败%s", err))
+	if err := utils.Accounts(stub).Put(buyerAccount); err != nil {
+		return nil, errcode.Wrap(err, "扣取买家余额失败")
 	}
-	// 成功返回
-	return shim.Success(sellingBuyByte)
+	if err := emitRecord(stub, model.SellingPurchased, []string{seller, buyer}, model.SellingBuyKey, sellingBuy); err != nil {
+		return nil, err
+	}
+	if err := emitBalanceChanged(stub, buyerAccount, model.BalanceReasonPurchase); err != nil {
+		return nil, err
+	}
+	return sellingBuy, nil
 }
 
 // QuerySellingList 查询销售(可查询所有，也可根据发起销售人查询)(发起的)(供卖家查询)
-func QuerySellingList(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	var sellingList []model.Selling
-	results, err := utils.GetStateByPartialCompositeKeys2(stub, model.SellingKey, args)
-	if err != nil {
-		return shim.Error(fmt.Sprintf("%s", err))
-	}
-	for _, v := range results {
-		if v != nil {
-			var selling model.Selling
-			err := json.Unmarshal(v, &selling)
-			if err != nil {
-				return shim.Error(fmt.Sprintf("QuerySellingList-反序列化出错: %s", err))
-			}
-			sellingList = append(sellingList, selling)
-		}
-	}
-	sellingListByte, err := json.Marshal(sellingList)
-	if err != nil {
-		return shim.Error(fmt.Sprintf("QuerySellingList-序列化出错: %s", err))
-	}
-	return shim.Success(sellingListByte)
+// 价格在私有数据集合中，调用者所在组织不能读取私有数据时为0
+func (c *SellingContract) QuerySellingList(ctx contractapi.TransactionContextInterface, seller string, objectOfSale string) (_ []model.Selling, err error) {
+	defer errcode.Envelope(&err)
+	return utils.Sellings(ctx.GetStub()).List(listKeys(seller, objectOfSale)...)
 }
 
 // QuerySellingListByBuyer 根据参与销售人、买家(买家AccountId)查询销售(参与的)(供买家查询)
-func QuerySellingListByBuyer(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	if len(args) != 1 {
-		return shim.Error(fmt.Sprintf("必须指定买家AccountId查询"))
-	}
-	var sellingBuyList []model.SellingBuy
-	results, err := utils.GetStateByPartialCompositeKeys2(stub, model.SellingBuyKey, args)
-	if err != nil {
-		return shim.Error(fmt.Sprintf("%s", err))
-	}
-	for _, v := range results {
-		if v != nil {
-			var sellingBuy model.SellingBuy
-			err := json.Unmarshal(v, &sellingBuy)
-			if err != nil {
-				return shim.Error(fmt.Sprintf("QuerySellingListByBuyer-反序列化出错: %s", err))
-			}
-			sellingBuyList = append(sellingBuyList, sellingBuy)
-		}
-	}
-	sellingBuyListByte, err := json.Marshal(sellingBuyList)
-	if err != nil {
-		return shim.Error(fmt.Sprintf("QuerySellingListByBuyer-序列化出错: %s", err))
-	}
-	return shim.Success(sellingBuyListByte)
+func (c *SellingContract) QuerySellingListByBuyer(ctx contractapi.TransactionContextInterface, buyer string) (_ []model.SellingBuy, err error) {
+	defer errcode.Envelope(&err)
+	return utils.SellingBuys(ctx.GetStub()).List(buyer)
 }
 
 // UpdateSelling 更新销售状态（买家确认、买卖家取消）
-func UpdateSelling(stub shim.ChaincodeStubInterface, args []string) pb.Response {
-	// 验证参数
-	if len(args) != 4 {
-		return shim.Error("参数个数不满足")
-	}
-	objectOfSale := args[0]
-	seller := args[1]
-	buyer := args[2]
-	status := args[3]
-	if objectOfSale == "" || seller == "" || status == "" {
-		return shim.Error("参数存在空值")
-	}
+// 销售中取消或过期时没有买家，返回销售；其余返回买家的购买记录
+func (c *SellingContract) UpdateSelling(ctx contractapi.TransactionContextInterface, objectOfSale string, seller string,
+	buyer string, status string) (_ json.RawMessage, err error) {
+	defer errcode.Envelope(&err)
+	stub := ctx.GetStub()
 	if buyer == seller {
-		return shim.Error("买家和卖家不能同一人")
+		return nil, errcode.New(errcode.Validation, "selling.sameAccount", "买家和卖家不能同一人")
 	}
 	//根据objectOfSale和seller获取想要购买的房产信息，确认存在该房产
-	resultsRealEstate, err := utils.GetStateByPartialCompositeKeys2(stub, model.RealEstateKey, []string{seller, objectOfSale})
-	if err != nil || len(resultsRealEstate) != 1 {
-		return shim.Error(fmt.Sprintf("根据%s和%s获取想要购买的房产信息失败: %s", objectOfSale, seller, err))
-	}
-	var realEstate model.RealEstate
-	if err = json.Unmarshal(resultsRealEstate[0], &realEstate); err != nil {
-		return shim.Error(fmt.Sprintf("UpdateSellingBySeller-反序列化出错: %s", err))
+	realEstate, err := utils.RealEstates(stub).Get(seller, objectOfSale)
+	if err != nil {
+		return nil, errcode.Wrap(err, fmt.Sprintf("根据%s和%s获取想要购买的房产信息失败", objectOfSale, seller))
 	}
 	//根据objectOfSale和seller获取销售信息
-	resultsSelling, err := utils.GetStateByPartialCompositeKeys2(stub, model.SellingKey, []string{seller, objectOfSale})
-	if err != nil || len(resultsSelling) != 1 {
-		return shim.Error(fmt.Sprintf("根据%s和%s获取销售信息失败: %s", objectOfSale, seller, err))
+	selling, err := utils.Sellings(stub).Get(seller, objectOfSale)
+	if err != nil {
+		return nil, errcode.Wrap(err, fmt.Sprintf("根据%s和%s获取销售信息失败", objectOfSale, seller))
 	}
-	var selling model.Selling
-	if err = json.Unmarshal(resultsSelling[0], &selling); err != nil {
-		return shim.Error(fmt.Sprintf("UpdateSellingBySeller-反序列化出错: %s", err))
+	//只有销售中和交付中的销售可以更新
+	if selling.SellingStatus != model.SellingStatusConstant()["saleStart"] &&
+		selling.SellingStatus != model.SellingStatusConstant()["delivery"] {
+		return nil, errcode.New(errcode.Conflict, "selling.closed", fmt.Sprintf("此交易已%s，无法再更新状态", selling.SellingStatus))
 	}
 	//根据buyer获取买家购买信息sellingBuy
-	var sellingBuy model.SellingBuy
+	var sellingBuy *model.SellingBuy
 	//如果当前状态是saleStart销售中，是不存在买家的
-	if selling.SellingStatus != model.SellingStatusConstant()["saleStart"] {
-		resultsSellingByBuyer, err := utils.GetStateByPartialCompositeKeys2(stub, model.SellingBuyKey, []string{buyer})
-		if err != nil || len(resultsSellingByBuyer) == 0 {
-			return shim.Error(fmt.Sprintf("根据%s获取买家购买信息失败: %s", buyer, err))
-		}
-		for _, v := range resultsSellingByBuyer {
-			if v != nil {
-				var s model.SellingBuy
-				err := json.Unmarshal(v, &s)
-				if err != nil {
-					return shim.Error(fmt.Sprintf("UpdateSellingBySeller-反序列化出错: %s", err))
-				}
-				if s.Selling.ObjectOfSale == objectOfSale && s.Selling.Seller == seller && s.Buyer == buyer {
-					//还必须判断状态必须为交付中,防止房子已经交易过，只是被取消了
-					if s.Selling.SellingStatus == model.SellingStatusConstant()["delivery"] {
-						sellingBuy = s
-						break
-					}
-				}
-			}
+	if selling.SellingStatus == model.SellingStatusConstant()["delivery"] {
+		sellingBuy, err = findDeliverySellingBuy(stub, selling, buyer)
+		if err != nil {
+			return nil, errcode.Wrap(err, fmt.Sprintf("根据%s获取买家购买信息失败", buyer))
 		}
 	}
-	var data []byte
+	var data json.RawMessage
 	//判断销售状态
 	switch status {
 	case "done":
 		//如果是买家确认收款操作,必须确保销售处于交付状态
 		if selling.SellingStatus != model.SellingStatusConstant()["delivery"] {
-			return shim.Error("此交易并不处于交付中，确认收款失败")
+			return nil, errcode.New(errcode.Conflict, "selling.notInDelivery", "此交易并不处于交付中，确认收款失败")
 		}
 		//根据seller获取卖家信息
-		resultsSellerAccount, err := utils.GetStateByPartialCompositeKeys(stub, model.AccountKey, []string{seller})
-		if err != nil || len(resultsSellerAccount) != 1 {
-			return shim.Error(fmt.Sprintf("seller卖家信息验证失败%s", err))
-		}
-		var accountSeller model.Account
-		if err = json.Unmarshal(resultsSellerAccount[0], &accountSeller); err != nil {
-			return shim.Error(fmt.Sprintf("查询seller卖家信息-反序列化出错: %s", err))
+		accountSeller, err := utils.Accounts(stub).Get(seller)
+		if err != nil {
+			return nil, errcode.Wrap(err, "seller卖家信息验证失败")
 		}
 		//确认收款,将款项加入到卖家账户
 		accountSeller.Balance += selling.Price
-		if err := utils.WriteLedger(accountSeller, stub, model.AccountKey, []string{accountSeller.AccountId}); err != nil {
-			return shim.Error(fmt.Sprintf("卖家确认接收资金失败%s", err))
+		if err := utils.Accounts(stub).Put(accountSeller); err != nil {
+			return nil, errcode.Wrap(err, "卖家确认接收资金失败")
 		}
 		//将房产信息转入买家，并重置担保状态
 		realEstate.Proprietor = buyer
 		realEstate.Encumbrance = false
 		//realEstate.RealEstateID = stub.GetTxID() //重新更新房产ID
-		if err := utils.WriteLedger(realEstate, stub, model.RealEstateKey, []string{realEstate.Proprietor, realEstate.RealEstateID}); err != nil {
-			return shim.Error(fmt.Sprintf("%s", err))
+		if err := utils.RealEstates(stub).Put(realEstate); err != nil {
+			return nil, err
 		}
 		//清除原来的房产信息
-		if err := utils.DelLedger(stub, model.RealEstateKey, []string{seller, objectOfSale}); err != nil {
-			return shim.Error(fmt.Sprintf("%s", err))
+		if err := utils.RealEstates(stub).Delete(seller, objectOfSale); err != nil {
+			return nil, err
 		}
 		//订单状态设置为完成，写入账本
 		selling.SellingStatus = model.SellingStatusConstant()["done"]
 		selling.ObjectOfSale = realEstate.RealEstateID //重新更新房产ID
-		if err := utils.WriteLedger(selling, stub, model.SellingKey, []string{selling.Seller, objectOfSale}); err != nil {
-			return shim.Error(fmt.Sprintf("%s", err))
+		selling.DoneTime = txTime(stub)
+		if err := utils.Sellings(stub).Put(selling); err != nil {
+			return nil, err
 		}
-		sellingBuy.Selling = selling
-		if err := utils.WriteLedger(sellingBuy, stub, model.SellingBuyKey, []string{sellingBuy.Buyer, sellingBuy.CreateTime}); err != nil {
-			return shim.Error(fmt.Sprintf("将本次购买交易写入账本失败%s", err))
+		sellingBuy.Selling = *selling
+		if err := utils.SellingBuys(stub).Put(sellingBuy); err != nil {
+			return nil, errcode.Wrap(err, "将本次购买交易写入账本失败")
 		}
-		data, err = json.Marshal(sellingBuy)
-		if err != nil {
-			return shim.Error(fmt.Sprintf("序列化购买交易的信息出错: %s", err))
+		if err := emitRecord(stub, model.SellingCompleted, []string{seller, buyer}, model.SellingBuyKey, sellingBuy); err != nil {
+			return nil, err
 		}
-		break
-	case "cancelled":
-		data, err = closeSelling("cancelled", selling, realEstate, sellingBuy, buyer, stub)
+		if err := emitBalanceChanged(stub, accountSeller, model.BalanceReasonIncome); err != nil {
+			return nil, err
+		}
+		if err := emitTransferred(stub, realEstate.RealEstateID, seller, buyer, model.TransferReasonSelling); err != nil {
+			return nil, err
+		}
+		data, err = json.Marshal(sellingBuy)
 		if err != nil {
-			return shim.Error(fmt.Sprintf("%s", err))
+			return nil, errcode.Wrap(err, "序列化购买交易的信息出错")
 		}
-		break
-	case "expired":
-		data, err = closeSelling("expired", selling, realEstate, sellingBuy, buyer, stub)
+	case "cancelled", "expired":
+		data, err = closeSelling(status, selling, realEstate, sellingBuy, stub)
 		if err != nil {
-			return shim.Error(fmt.Sprintf("%s", err))
+			return nil, err
 		}
-		break
 	default:
-		return shim.Error(fmt.Sprintf("%s状态不支持", status))
+		return nil, errcode.StatusUnsupported(status)
+	}
+	return data, nil
+}
+
+// findDeliverySellingBuy 查找买家对该销售的交付中购买记录
+// 买家可能多次参与同一房产的销售(之前的已被取消)，所以必须匹配交付中状态
+func findDeliverySellingBuy(stub shim.ChaincodeStubInterface, selling *model.Selling, buyer string) (*model.SellingBuy, error) {
+	sellingBuyList, err := utils.SellingBuys(stub).List(buyer)
+	if err != nil {
+		return nil, err
 	}
-	return shim.Success(data)
+	for i, s := range sellingBuyList {
+		if s.Selling.ObjectOfSale == selling.ObjectOfSale && s.Selling.Seller == selling.Seller && s.Buyer == buyer &&
+			s.Selling.SellingStatus == model.SellingStatusConstant()["delivery"] {
+			return &sellingBuyList[i], nil
+		}
+	}
+	return nil, &utils.NotFoundError{ObjectType: model.SellingBuyKey, Keys: []string{buyer, selling.Seller, selling.ObjectOfSale}}
 }
 
 // closeSelling 不管是取消还是过期，都分两种情况
 // 1、当前处于saleStart销售状态
-// 2、当前处于delivery交付中状态
-func closeSelling(closeStart string, selling model.Selling, realEstate model.RealEstate, sellingBuy model.SellingBuy, buyer string, stub shim.ChaincodeStubInterface) ([]byte, error) {
+// 2、当前处于delivery交付中状态，此时sellingBuy为买家的购买记录
+func closeSelling(closeStart string, selling *model.Selling, realEstate *model.RealEstate, sellingBuy *model.SellingBuy, stub shim.ChaincodeStubInterface) (json.RawMessage, error) {
 	switch selling.SellingStatus {
 	case model.SellingStatusConstant()["saleStart"]:
 		selling.SellingStatus = model.SellingStatusConstant()[closeStart]
 		//重置房产信息担保状态
 		realEstate.Encumbrance = false
-		if err := utils.WriteLedger(realEstate, stub, model.RealEstateKey, []string{realEstate.Proprietor, realEstate.RealEstateID}); err != nil {
+		if err := utils.RealEstates(stub).Put(realEstate); err != nil {
 			return nil, err
 		}
-		if err := utils.WriteLedger(selling, stub, model.SellingKey, []string{selling.Seller, selling.ObjectOfSale}); err != nil {
+		if err := utils.Sellings(stub).Put(selling); err != nil {
+			return nil, err
+		}
+		if err := emitSellingClosed(stub, closeStart, selling, ""); err != nil {
 			return nil, err
 		}
 		data, err := json.Marshal(selling)
@@ -355,32 +328,34 @@ func closeSelling(closeStart string, selling model.Selling, realEstate model.Rea
 		}
 		return data, nil
 	case model.SellingStatusConstant()["delivery"]:
-		//根据buyer获取卖家信息
-		resultsBuyerAccount, err := utils.GetStateByPartialCompositeKeys(stub, model.AccountKey, []string{buyer})
-		if err != nil || len(resultsBuyerAccount) != 1 {
-			return nil, err
-		}
-		var accountBuyer model.Account
-		if err = json.Unmarshal(resultsBuyerAccount[0], &accountBuyer); err != nil {
+		//根据buyer获取买家信息
+		accountBuyer, err := utils.Accounts(stub).Get(sellingBuy.Buyer)
+		if err != nil {
 			return nil, err
 		}
 		//此时取消操作，需要将资金退还给买家
 		accountBuyer.Balance += selling.Price
-		if err := utils.WriteLedger(accountBuyer, stub, model.AccountKey, []string{accountBuyer.AccountId}); err != nil {
+		if err := utils.Accounts(stub).Put(accountBuyer); err != nil {
 			return nil, err
 		}
 		//重置房产信息担保状态
 		realEstate.Encumbrance = false
-		if err := utils.WriteLedger(realEstate, stub, model.RealEstateKey, []string{realEstate.Proprietor, realEstate.RealEstateID}); err != nil {
+		if err := utils.RealEstates(stub).Put(realEstate); err != nil {
 			return nil, err
 		}
 		//更新销售状态
 		selling.SellingStatus = model.SellingStatusConstant()[closeStart]
-		if err := utils.WriteLedger(selling, stub, model.SellingKey, []string{selling.Seller, selling.ObjectOfSale}); err != nil {
+		if err := utils.Sellings(stub).Put(selling); err != nil {
 			return nil, err
 		}
-		sellingBuy.Selling = selling
-		if err := utils.WriteLedger(sellingBuy, stub, model.SellingBuyKey, []string{sellingBuy.Buyer, sellingBuy.CreateTime}); err != nil {
+		sellingBuy.Selling = *selling
+		if err := utils.SellingBuys(stub).Put(sellingBuy); err != nil {
+			return nil, err
+		}
+		if err := emitSellingClosed(stub, closeStart, selling, sellingBuy.Buyer); err != nil {
+			return nil, err
+		}
+		if err := emitBalanceChanged(stub, accountBuyer, model.BalanceReasonRefund); err != nil {
 			return nil, err
 		}
 		data, err := json.Marshal(sellingBuy)
@@ -389,6 +364,29 @@ func closeSelling(closeStart string, selling model.Selling, realEstate model.Rea
 		}
 		return data, nil
 	default:
-		return nil, nil
+		return nil, errcode.New(errcode.Conflict, "selling.closed", fmt.Sprintf("此交易已%s，无法再关闭", selling.SellingStatus))
+	}
+}
+
+// emitSellingClosed 记录销售取消或过期事件
+func emitSellingClosed(stub shim.ChaincodeStubInterface, closeStart string, selling *model.Selling, buyer string) error {
+	eventType := model.SellingCancelled
+	if closeStart == "expired" {
+		eventType = model.SellingExpired
+	}
+	accounts := []string{selling.Seller}
+	if buyer != "" {
+		accounts = append(accounts, buyer)
 	}
+	public, err := publicRecord(model.SellingKey, selling)
+	if err != nil {
+		return err
+	}
+	return events.Emit(stub, eventType, accounts, model.SellingClosedPayload{Selling: public, Buyer: buyer})
+}
+
+// txTime 交易时间，格式与CreateTime一致
+func txTime(stub shim.ChaincodeStubInterface) string {
+	timestamp, _ := stub.GetTxTimestamp()
+	return time.Unix(timestamp.GetSeconds(), int64(timestamp.GetNanos())).Local().Format("2006-01-02 15:04:05")
 }
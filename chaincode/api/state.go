@@ -0,0 +1,241 @@
+package api
+
+import (
+	"bytes"
+	"chaincode/model"
+	"chaincode/pkg/errcode"
+	"chaincode/pkg/events"
+	"chaincode/pkg/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// stateObjectTypes 导出、导入的复合主键前缀，即model.DocTypes中的全部类型，按名称排序
+func stateObjectTypes() []string {
+	objectTypes := make([]string, 0, len(model.DocTypes))
+	for objectType := range model.DocTypes {
+		objectTypes = append(objectTypes, objectType)
+	}
+	sort.Strings(objectTypes)
+	return objectTypes
+}
+
+// ExportState 导出链码的全部状态(管理员)，用于在测试、演示环境中复现
+// 调用者可以读取私有数据时导出合并了私有字段的完整记录，否则只导出公开记录，后者不能用于importState
+func (c *LedgerContract) ExportState(ctx contractapi.TransactionContextInterface, operator string) (_ *model.StateDump, err error) {
+	defer errcode.Envelope(&err)
+	stub := ctx.GetStub()
+	state, err := readSchemaState(stub)
+	if err != nil {
+		return nil, err
+	}
+	//迁移未完成时部分记录仍为旧版本，导出账本已达到的版本
+	dump := &model.StateDump{Version: model.StateDumpVersion, SchemaVersion: state.Version, Entries: make([]model.StateEntry, 0)}
+	for _, objectType := range stateObjectTypes() {
+		entries, err := exportObjectType(stub, objectType)
+		if err != nil {
+			return nil, err
+		}
+		dump.Entries = append(dump.Entries, entries...)
+	}
+	return dump, nil
+}
+
+// exportObjectType 某一复合主键前缀下的全部记录，按复合主键排序
+func exportObjectType(stub shim.ChaincodeStubInterface, objectType string) ([]model.StateEntry, error) {
+	resultIterator, err := stub.GetStateByPartialCompositeKey(objectType, []string{})
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("%s-获取全部数据出错: %s", objectType, err))
+	}
+	defer resultIterator.Close()
+
+	var entries []model.StateEntry
+	for resultIterator.HasNext() {
+		val, err := resultIterator.Next()
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("%s-返回的数据出错: %s", objectType, err))
+		}
+		if err := utils.CheckDoc(objectType, val.GetValue()); err != nil {
+			return nil, err
+		}
+		_, keys, err := stub.SplitCompositeKey(val.GetKey())
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("%s-拆分复合主键出错: %s", objectType, err))
+		}
+		value, err := utils.ReadPrivate(stub, objectType, val.GetKey(), val.GetValue())
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, model.StateEntry{ObjectType: objectType, Keys: keys, Value: value})
+	}
+	return entries, nil
+}
+
+// ImportState 用exportState导出的状态初始化新网络(管理员)
+// 仅限首次运行：账本中只有Init写入的账户且从未导入过，导入时删除Init写入而导出文件中没有的账户
+// 导出文件中必须有操作人的管理员账户，否则导入后无法再执行管理员操作
+func (c *LedgerContract) ImportState(ctx contractapi.TransactionContextInterface, operator string, dump model.StateDump) (_ *model.StateImport, err error) {
+	defer errcode.Envelope(&err)
+	stub := ctx.GetStub()
+	if err := checkFirstRun(stub); err != nil {
+		return nil, err
+	}
+	if dump.Version != model.StateDumpVersion || dump.SchemaVersion > model.SchemaVersion {
+		return nil, errcode.New(errcode.Validation, "state.version",
+			fmt.Sprintf("导出文件的格式版本%d(记录结构版本%d)与链码不兼容，支持格式版本%d、记录结构版本不高于%d", dump.Version, dump.SchemaVersion, model.StateDumpVersion, model.SchemaVersion))
+	}
+	values, err := checkStateDump(stub, operator, &dump)
+	if err != nil {
+		return nil, err
+	}
+	//删除Init写入而导出文件中没有的账户，同一交易内不对同一个键既删除又写入
+	accounts, err := exportObjectType(stub, model.AccountKey)
+	if err != nil {
+		return nil, err
+	}
+	for _, account := range accounts {
+		key, err := stub.CreateCompositeKey(model.AccountKey, account.Keys)
+		if err != nil {
+			return nil, errcode.Wrap(err, "创建复合主键出错")
+		}
+		if _, ok := values[key]; ok {
+			continue
+		}
+		if err := utils.DelLedger(stub, model.AccountKey, account.Keys); err != nil {
+			return nil, err
+		}
+	}
+	imported := &model.StateImport{Operator: operator, TxID: stub.GetTxID(), Counts: make(map[string]int)}
+	for i, entry := range dump.Entries {
+		key, err := stub.CreateCompositeKey(entry.ObjectType, entry.Keys)
+		if err != nil {
+			return nil, errcode.Wrap(err, "创建复合主键出错")
+		}
+		//私有字段重新拆分到私有数据集合
+		if _, err := utils.PutRecord(stub, entry.ObjectType, key, values[key]); err != nil {
+			return nil, errcode.Wrap(err, fmt.Sprintf("写入第%d条记录出错", i))
+		}
+		imported.Counts[model.DocTypes[entry.ObjectType]]++
+	}
+	importedByte, err := json.Marshal(imported)
+	if err != nil {
+		return nil, errcode.Wrap(err, "ImportState-序列化出错")
+	}
+	if err := stub.PutState(model.StateImportedKey, importedByte); err != nil {
+		return nil, errcode.Wrap(err, "写入导入记录出错")
+	}
+	//导出文件中的记录可能早于当前版本，导入后通过migrate升级
+	if err := writeSchemaState(stub, &model.SchemaState{Version: dump.SchemaVersion}); err != nil {
+		return nil, err
+	}
+	entries, err := publicEntries(dump.Entries)
+	if err != nil {
+		return nil, err
+	}
+	if err := events.Emit(stub, model.StateImported, []string{}, model.StateDump{Version: dump.Version, SchemaVersion: dump.SchemaVersion, Entries: entries}); err != nil {
+		return nil, err
+	}
+	return imported, nil
+}
+
+// checkFirstRun 未执行过importState，且除账户外没有任何记录
+func checkFirstRun(stub shim.ChaincodeStubInterface) error {
+	imported, err := stub.GetState(model.StateImportedKey)
+	if err != nil {
+		return errcode.Wrap(err, "读取导入记录出错")
+	}
+	if imported != nil {
+		return errcode.New(errcode.Conflict, "state.notFirstRun", fmt.Sprintf("账本已导入过状态: %s", imported))
+	}
+	for _, objectType := range stateObjectTypes() {
+		if objectType == model.AccountKey {
+			continue
+		}
+		resultIterator, err := stub.GetStateByPartialCompositeKey(objectType, []string{})
+		if err != nil {
+			return errcode.Wrap(err, fmt.Sprintf("%s-获取全部数据出错", objectType))
+		}
+		hasNext := resultIterator.HasNext()
+		resultIterator.Close()
+		if hasNext {
+			return errcode.New(errcode.Conflict, "state.notFirstRun", fmt.Sprintf("账本中已有%s记录，只能在新网络中导入", model.DocTypes[objectType])).
+				With("objectType", objectType)
+		}
+	}
+	return nil
+}
+
+// checkStateDump 校验导出文件中的每条记录，返回复合主键到压缩后的json
+func checkStateDump(stub shim.ChaincodeStubInterface, operator string, dump *model.StateDump) (map[string][]byte, error) {
+	values := make(map[string][]byte, len(dump.Entries))
+	hasAdmin := false
+	for i, entry := range dump.Entries {
+		invalid := func(format string, a ...interface{}) error {
+			return errcode.New(errcode.Validation, "state.invalid", fmt.Sprintf("第%d条记录%s: ", i, entry.ObjectType)+fmt.Sprintf(format, a...)).
+				With("index", i)
+		}
+		if _, ok := model.DocTypes[entry.ObjectType]; !ok {
+			return nil, invalid("复合主键前缀不支持")
+		}
+		if len(entry.Keys) == 0 {
+			return nil, invalid("复合主键为空")
+		}
+		for _, k := range entry.Keys {
+			if strings.TrimSpace(k) == "" {
+				return nil, invalid("复合主键存在空值")
+			}
+		}
+		key, err := stub.CreateCompositeKey(entry.ObjectType, entry.Keys)
+		if err != nil {
+			return nil, invalid("创建复合主键出错 %s", err)
+		}
+		if _, dup := values[key]; dup {
+			return nil, invalid("复合主键%v重复", entry.Keys)
+		}
+		var value bytes.Buffer
+		if err := json.Compact(&value, entry.Value); err != nil {
+			return nil, invalid("记录不是json %s", err)
+		}
+		if err := utils.CheckDoc(entry.ObjectType, value.Bytes()); err != nil {
+			return nil, invalid("%s", err)
+		}
+		if err := checkPrivateHash(entry.ObjectType, value.Bytes()); err != nil {
+			return nil, invalid("%s", err)
+		}
+		if entry.ObjectType == model.AccountKey && entry.Keys[0] == operator {
+			var account model.Account
+			if err := json.Unmarshal(value.Bytes(), &account); err != nil {
+				return nil, invalid("反序列化出错 %s", err)
+			}
+			hasAdmin = account.AccountId == operator && account.IsAdmin()
+		}
+		values[key] = value.Bytes()
+	}
+	if !hasAdmin {
+		return nil, errcode.New(errcode.Validation, "state.noAdmin", fmt.Sprintf("导出文件中没有管理员账户%s", operator)).With("accountId", operator)
+	}
+	return values, nil
+}
+
+// checkPrivateHash 记录中有privateHash时，其中的私有字段必须与之一致
+// 没有读取私有数据权限时导出的记录只有公开部分，导入后无法还原私有字段
+func checkPrivateHash(objectType string, value []byte) error {
+	var doc model.Doc
+	if err := json.Unmarshal(value, &doc); err != nil || doc.PrivateHash == "" {
+		return err
+	}
+	_, private, err := utils.SplitPrivate(objectType, value)
+	if err != nil {
+		return err
+	}
+	if utils.PrivateHash(private) != doc.PrivateHash {
+		return errors.New("私有字段与privateHash不一致，导出时可能没有读取私有数据的权限")
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"chaincode/model"
+	"chaincode/pkg/errcode"
+	"chaincode/pkg/events"
+	"chaincode/pkg/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+)
+
+// docFactory 复合主键前缀对应的记录结构，嵌套的记录(销售、捐赠)同样需要补齐docType
+type docFactory struct {
+	objectType string
+	newDoc     func() model.Document
+	nested     func(doc model.Document)
+}
+
+// docFactories 各复合主键前缀对应的记录结构
+var docFactories = []docFactory{
+	{objectType: model.AccountKey, newDoc: func() model.Document { return &model.Account{} }},
+	{objectType: model.RealEstateKey, newDoc: func() model.Document { return &model.RealEstate{} }},
+	{objectType: model.SellingKey, newDoc: func() model.Document { return &model.Selling{} }},
+	{objectType: model.SellingBuyKey, newDoc: func() model.Document { return &model.SellingBuy{} }, nested: func(doc model.Document) {
+		doc.(*model.SellingBuy).Selling.SetDoc(model.SellingDocType, model.SchemaVersion)
+	}},
+	{objectType: model.DonatingKey, newDoc: func() model.Document { return &model.Donating{} }},
+	{objectType: model.DonatingGranteeKey, newDoc: func() model.Document { return &model.DonatingGrantee{} }, nested: func(doc model.Document) {
+		doc.(*model.DonatingGrantee).Donating.SetDoc(model.DonatingDocType, model.SchemaVersion)
+	}},
+	{objectType: model.ImportRefKey, newDoc: func() model.Document { return &model.ImportRef{} }},
+}
+
+// findDocFactory 根据复合主键前缀查找记录结构
+func findDocFactory(objectType string) (docFactory, bool) {
+	for _, factory := range docFactories {
+		if factory.objectType == objectType {
+			return factory, true
+		}
+	}
+	return docFactory{}, false
+}
+
+// UpgradeDocType 为账本中已有的记录补齐docType和version(管理员，一次性执行，重复执行无副作用)
+// 同时执行migrations中的全部迁移步骤，相当于不分批的migrate，记录较多时应使用migrate
+// accountId用于验证是否为管理员，返回各记录类型重写的条数
+func (c *LedgerContract) UpgradeDocType(ctx contractapi.TransactionContextInterface, accountId string) (_ map[string]int, err error) {
+	defer errcode.Envelope(&err)
+	stub := ctx.GetStub()
+	state, err := readSchemaState(stub)
+	if err != nil {
+		return nil, err
+	}
+	batch := model.MigrationBatch{From: state.Version, To: model.SchemaVersion}
+	upgraded := make(map[string]int)
+	for _, factory := range docFactories {
+		count, err := upgradeObjectType(stub, factory.objectType, &batch)
+		if err != nil {
+			return nil, err
+		}
+		upgraded[model.DocTypes[factory.objectType]] = count
+	}
+	if err := writeSchemaState(stub, &model.SchemaState{Version: model.SchemaVersion}); err != nil {
+		return nil, err
+	}
+	if len(batch.Entries) != 0 {
+		if batch.Entries, err = publicEntries(batch.Entries); err != nil {
+			return nil, err
+		}
+		if err := events.Emit(stub, model.RecordsMigrated, []string{}, batch); err != nil {
+			return nil, err
+		}
+	}
+	return upgraded, nil
+}
+
+// upgradeObjectType 重写某一复合主键前缀下的全部记录，返回重写的条数
+func upgradeObjectType(stub shim.ChaincodeStubInterface, objectType string, batch *model.MigrationBatch) (int, error) {
+	resultIterator, err := stub.GetStateByPartialCompositeKey(objectType, []string{})
+	if err != nil {
+		return 0, errors.New(fmt.Sprintf("%s-获取全部数据出错: %s", objectType, err))
+	}
+	defer resultIterator.Close()
+
+	count := 0
+	for resultIterator.HasNext() {
+		val, err := resultIterator.Next()
+		if err != nil {
+			return 0, errors.New(fmt.Sprintf("%s-返回的数据出错: %s", objectType, err))
+		}
+		//私有字段在私有数据集合中，合并后再升级，重写时由WriteLedger重新拆分
+		value, err := utils.ReadPrivate(stub, objectType, val.GetKey(), val.GetValue())
+		if err != nil {
+			return 0, err
+		}
+		doc, _, _, err := upgradeDoc(objectType, value)
+		if err != nil {
+			return 0, err
+		}
+		_, keys, err := stub.SplitCompositeKey(val.GetKey())
+		if err != nil {
+			return 0, errors.New(fmt.Sprintf("%s-拆分复合主键出错: %s", objectType, err))
+		}
+		if err := utils.WriteLedger(doc, stub, objectType, keys); err != nil {
+			return 0, err
+		}
+		value, err = json.Marshal(doc)
+		if err != nil {
+			return 0, errors.New(fmt.Sprintf("%s-序列化出错: %s", objectType, err))
+		}
+		batch.Entries = append(batch.Entries, model.StateEntry{ObjectType: objectType, Keys: keys, Value: value})
+		count++
+	}
+	return count, nil
+}
@@ -2,387 +2,1152 @@ package main
 
 import (
 	"bytes"
+	"chaincode/api"
 	"chaincode/model"
+	"chaincode/pkg/ccserver"
+	"chaincode/pkg/errcode"
+	"chaincode/pkg/mockstub"
+	"chaincode/pkg/testkit"
+	"chaincode/pkg/utils"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"testing"
+	"time"
 
-	"github.com/hyperledger/fabric/core/chaincode/shim"
-	pb "github.com/hyperledger/fabric/protos/peer"
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"google.golang.org/grpc"
 )
 
-func initTest(t *testing.T) *shim.MockStub {
-	scc := new(BlockChainRealEstate)
-	stub := shim.NewMockStub("ex01", scc)
-	checkInit(t, stub, [][]byte{[]byte("init")})
-	return stub
-}
-
-func checkInit(t *testing.T, stub *shim.MockStub, args [][]byte) {
-	res := stub.MockInit("1", args)
-	if res.Status != shim.OK {
-		fmt.Println("Init failed", string(res.Message))
-		t.FailNow()
-	}
-}
-
-func checkInvoke(t *testing.T, stub *shim.MockStub, args [][]byte) pb.Response {
-	res := stub.MockInvoke("1", args)
-	if res.Status != shim.OK {
-		fmt.Println("Invoke", args, "failed", string(res.Message))
-		t.FailNow()
-	}
-	return res
-}
-
 // 测试链码初始化
 func TestBlockChainRealEstate_Init(t *testing.T) {
-	initTest(t)
+	k := testkit.New(t)
+	l := k.Ledger()
+	if len(l.Accounts) != 6 {
+		t.Fatalf("期望初始化6个账户，实际%d个", len(l.Accounts))
+	}
+	if l.Balance(testkit.Admin) != 0 || l.Balance(testkit.Owner1) != testkit.InitialBalance {
+		t.Errorf("初始余额不符合预期: %+v", l.Accounts)
+	}
+	if len(l.RealEstates) != 0 || len(l.Sellings) != 0 || len(l.Donatings) != 0 {
+		t.Errorf("初始化后不应有房产和交易")
+	}
 }
 
 // 测试获取账户信息
 func Test_QueryAccountList(t *testing.T) {
-	stub := initTest(t)
-	fmt.Println(fmt.Sprintf("1、测试获取所有数据\n%s",
-		string(checkInvoke(t, stub, [][]byte{
-			[]byte("queryAccountList"),
-		}).Payload)))
-	fmt.Println(fmt.Sprintf("2、测试获取多个数据\n%s",
-		string(checkInvoke(t, stub, [][]byte{
-			[]byte("queryAccountList"),
-			[]byte("5feceb66ffc8"),
-			[]byte("6b86b273ff34"),
-		}).Payload)))
-	fmt.Println(fmt.Sprintf("3、测试获取单个数据\n%s",
-		string(checkInvoke(t, stub, [][]byte{
-			[]byte("queryAccountList"),
-			[]byte("4e07408562be"),
-		}).Payload)))
-	fmt.Println(fmt.Sprintf("4、测试获取无效数据\n%s",
-		string(checkInvoke(t, stub, [][]byte{
-			[]byte("queryAccountList"),
-			[]byte("0"),
-		}).Payload)))
+	k := testkit.New(t)
+	cases := []struct {
+		name string
+		args []string
+		want []string
+	}{
+		{"获取所有数据", nil, []string{testkit.Admin, testkit.Owner1, testkit.Owner2, testkit.Owner3, testkit.Owner4, testkit.Owner5}},
+		{"获取多个数据", []string{testkit.Admin, testkit.Owner1}, []string{testkit.Admin, testkit.Owner1}},
+		{"获取单个数据", []string{testkit.Owner3}, []string{testkit.Owner3}},
+		{"获取无效数据", []string{"0"}, nil},
+	}
+	for _, c := range cases {
+		var accounts []model.Account
+		k.MustDecode(&accounts, append([]string{"queryAccountList"}, c.args...)...)
+		got := make(map[string]bool)
+		for _, a := range accounts {
+			got[a.AccountId] = true
+		}
+		if len(accounts) != len(c.want) {
+			t.Errorf("%s: 期望%d个账户，实际%d个", c.name, len(c.want), len(accounts))
+			continue
+		}
+		for _, id := range c.want {
+			if !got[id] {
+				t.Errorf("%s: 缺少账户%s", c.name, id)
+			}
+		}
+	}
 }
 
 // 测试创建房地产
 func Test_CreateRealEstate(t *testing.T) {
-	stub := initTest(t)
-	//成功
-	checkInvoke(t, stub, [][]byte{
-		[]byte("createRealEstate"),
-		[]byte("5feceb66ffc8"), //操作人
-		[]byte("6b86b273ff34"), //所有者
-		[]byte("50"),           //总面积
-		[]byte("30"),           //生活空间
-	})
-	//操作人权限不足
-	checkInvoke(t, stub, [][]byte{
-		[]byte("createRealEstate"),
-		[]byte("6b86b273ff34"), //操作人
-		[]byte("4e07408562be"), //所有者
-		[]byte("50"),           //总面积
-		[]byte("30"),           //生活空间
-	})
-	//操作人应为管理员且与所有人不能相同
-	checkInvoke(t, stub, [][]byte{
-		[]byte("createRealEstate"),
-		[]byte("5feceb66ffc8"), //操作人
-		[]byte("5feceb66ffc8"), //所有者
-		[]byte("50"),           //总面积
-		[]byte("30"),           //生活空间
-	})
-	//业主proprietor信息验证失败
-	checkInvoke(t, stub, [][]byte{
-		[]byte("createRealEstate"),
-		[]byte("5feceb66ffc8"),    //操作人
-		[]byte("6b86b273ff34555"), //所有者
-		[]byte("50"),              //总面积
-		[]byte("30"),              //生活空间
-	})
-	//参数个数不满足
-	checkInvoke(t, stub, [][]byte{
-		[]byte("createRealEstate"),
-		[]byte("5feceb66ffc8"), //操作人
-		[]byte("6b86b273ff34"), //所有者
-		[]byte("50"),           //总面积
+	k := testkit.New(t)
+	var realEstate model.RealEstate
+	changes := k.Changes(func() {
+		k.MustDecode(&realEstate, "createRealEstate", testkit.Admin, testkit.Owner1, "50", "30")
 	})
-	//参数格式转换出错
-	checkInvoke(t, stub, [][]byte{
-		[]byte("createRealEstate"),
-		[]byte("5feceb66ffc8"), //操作人
-		[]byte("6b86b273ff34"), //所有者
-		[]byte("50f"),          //总面积
-		[]byte("30"),           //生活空间
+	if realEstate.Proprietor != testkit.Owner1 || realEstate.Encumbrance || realEstate.TotalArea != 50 || realEstate.LivingSpace != 30 {
+		t.Errorf("创建的房地产不符合预期: %+v", realEstate)
+	}
+	if fmt.Sprint(testkit.Summary(changes)) != fmt.Sprintf("[+%s[%s %s]]", model.RealEstateKey, testkit.Owner1, realEstate.RealEstateID) {
+		t.Errorf("账本变化不符合预期: %v", testkit.Summary(changes))
+	}
+	cases := []struct {
+		name string
+		args []string
+		code errcode.Code
+		key  string
+	}{
+		{"操作人权限不足", []string{testkit.Owner1, testkit.Owner3, "50", "30"}, errcode.Forbidden, "auth.notAdmin"},
+		{"操作人应为管理员且与所有人不能相同", []string{testkit.Admin, testkit.Admin, "50", "30"}, errcode.Validation, "realEstate.sameAccount"},
+		{"业主proprietor信息验证失败", []string{testkit.Admin, "6b86b273ff34555", "50", "30"}, errcode.NotFound, "account.notFound"},
+		{"参数个数不满足", []string{testkit.Admin, testkit.Owner1, "50"}, errcode.Validation, "args.count"},
+		{"参数格式转换出错", []string{testkit.Admin, testkit.Owner1, "50f", "30"}, errcode.Validation, "args.format"},
+		{"参数存在空值", []string{testkit.Admin, "", "50", "30"}, errcode.Validation, "args.empty"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			k.T = t
+			k.MustFail(c.code, c.key, append([]string{"createRealEstate"}, c.args...)...)
+		})
+	}
+	k.T = t
+	if n := len(k.Ledger().RealEstates); n != 1 {
+		t.Errorf("失败的调用不应创建房地产，实际共%d个", n)
+	}
+
+	//操作人以调用者证书中的realty.accountId为准，不能冒用管理员的账户ID
+	k.Account = testkit.Owner1
+	k.MustFail(errcode.Forbidden, "auth.operatorMismatch", "createRealEstate", testkit.Admin, testkit.Owner3, "50", "30")
+	k.Account = ""
+	k.Creator = mockstub.Identity("JDMSP")
+	k.MustFail(errcode.Unauthenticated, "auth.noAccountAttr", "createRealEstate", testkit.Admin, testkit.Owner3, "50", "30")
+	k.Creator = nil
+	k.Account = testkit.Admin
+	k.MustSucceed("createRealEstate", testkit.Admin, testkit.Owner3, "50", "30")
+}
+
+// 测试批量创建房地产，逐行校验，按外部编号去重
+func Test_BatchCreateRealEstate(t *testing.T) {
+	k := testkit.New(t)
+	rows := `[
+		{"ref":"A-1","proprietor":"` + testkit.Owner1 + `","totalArea":100,"livingSpace":80},
+		{"ref":"A-2","proprietor":"` + testkit.Admin + `","totalArea":100,"livingSpace":80},
+		{"ref":"A-3","proprietor":"6b86b273ff34555","totalArea":100,"livingSpace":80},
+		{"ref":"A-4","proprietor":"` + testkit.Owner3 + `","totalArea":0,"livingSpace":80},
+		{"ref":"A-1","proprietor":"` + testkit.Owner3 + `","totalArea":60,"livingSpace":40},
+		{"proprietor":"` + testkit.Owner3 + `","totalArea":60,"livingSpace":40},
+		{"ref":"A-5","proprietor":"` + testkit.Owner3 + `","totalArea":60,"livingSpace":80}
+	]`
+	var result model.BatchResult
+	changes := k.Changes(func() {
+		k.MustDecode(&result, "batchCreateRealEstate", testkit.Admin, rows)
 	})
+	if result.Created != 2 || result.Existing != 0 || result.Failed != 5 || len(result.Results) != 7 {
+		t.Fatalf("批量创建结果不符合预期: %+v", result)
+	}
+	wantKeys := []string{"", "realEstate.sameAccount", "account.notFound", "realEstate.area", "importRef.duplicate", "", "realEstate.area"}
+	for i, r := range result.Results {
+		key := ""
+		if r.Error != nil {
+			key = r.Error.Key
+		}
+		if r.Index != i || key != wantKeys[i] || (key == "") != (r.Status == model.RowCreated) {
+			t.Errorf("第%d行结果不符合预期: %+v", i, r)
+		}
+	}
+	if result.Results[0].RealEstate.RealEstateID == result.Results[5].RealEstate.RealEstateID {
+		t.Error("同一交易内新建的房地产ID不能相同")
+	}
+	if got := testkit.CountByType(changes); got[model.RealEstateKey] != 2 || got[model.ImportRefKey] != 1 {
+		t.Errorf("账本变化不符合预期: %v", testkit.Summary(changes))
+	}
+	if len(k.Events) != 1 || len(k.Events[0].Events) != 2 {
+		t.Errorf("应在一个事件中写出2个RealEstateCreated: %+v", k.Events)
+	}
+
+	// 重新提交已导入的外部编号，返回已有的房地产而不重复新建
+	var again model.BatchResult
+	k.MustDecode(&again, "batchCreateRealEstate", testkit.Admin,
+		`[{"ref":"A-1","proprietor":"`+testkit.Owner1+`","totalArea":100,"livingSpace":80}]`)
+	if again.Existing != 1 || again.Results[0].Status != model.RowExists ||
+		again.Results[0].RealEstate.RealEstateID != result.Results[0].RealEstate.RealEstateID {
+		t.Errorf("已导入的外部编号应返回已有的房地产: %+v", again)
+	}
+	if n := len(k.Ledger().RealEstates); n != 2 {
+		t.Errorf("重复导入不应新建房地产，实际共%d个", n)
+	}
+
+	tooMany := make([]model.RealEstateRow, model.MaxBatchRealEstates+1)
+	tooManyByte, _ := json.Marshal(tooMany)
+	cases := []struct {
+		name string
+		args []string
+		code errcode.Code
+		key  string
+	}{
+		{"操作人权限不足", []string{testkit.Owner1, rows}, errcode.Forbidden, "auth.notAdmin"},
+		{"参数个数不满足", []string{testkit.Admin}, errcode.Validation, "args.count"},
+		{"参数格式转换出错", []string{testkit.Admin, "{}"}, errcode.Validation, "args.format"},
+		{"没有数据", []string{testkit.Admin, "[]"}, errcode.Validation, "realEstate.batchSize"},
+		{"超过最大条数", []string{testkit.Admin, string(tooManyByte)}, errcode.Validation, "realEstate.batchSize"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			k.T = t
+			k.MustFail(c.code, c.key, append([]string{"batchCreateRealEstate"}, c.args...)...)
+		})
+	}
 }
 
-//手动创建一些房地产
-func checkCreateRealEstate(stub *shim.MockStub, t *testing.T) []model.RealEstate {
+// createRealEstates 手动创建一些房地产，前两个属于Owner1，后两个分别属于Owner3、Owner5
+func createRealEstates(k *testkit.Kit) []model.RealEstate {
+	k.T.Helper()
 	var realEstateList []model.RealEstate
-	var realEstate model.RealEstate
-	//成功
-	resp1 := checkInvoke(t, stub, [][]byte{
-		[]byte("createRealEstate"),
-		[]byte("5feceb66ffc8"), //操作人
-		[]byte("6b86b273ff34"), //所有者
-		[]byte("50"),           //总面积
-		[]byte("30"),           //生活空间
-	})
-	resp2 := checkInvoke(t, stub, [][]byte{
-		[]byte("createRealEstate"),
-		[]byte("5feceb66ffc8"), //操作人
-		[]byte("6b86b273ff34"), //所有者
-		[]byte("80"),           //总面积
-		[]byte("60.8"),         //生活空间
-	})
-	resp3 := checkInvoke(t, stub, [][]byte{
-		[]byte("createRealEstate"),
-		[]byte("5feceb66ffc8"), //操作人
-		[]byte("4e07408562be"), //所有者
-		[]byte("60"),           //总面积
-		[]byte("40"),           //生活空间
-	})
-	resp4 := checkInvoke(t, stub, [][]byte{
-		[]byte("createRealEstate"),
-		[]byte("5feceb66ffc8"), //操作人
-		[]byte("ef2d127de37b"), //所有者
-		[]byte("80"),           //总面积
-		[]byte("60"),           //生活空间
-	})
-	json.Unmarshal(bytes.NewBuffer(resp1.Payload).Bytes(), &realEstate)
-	realEstateList = append(realEstateList, realEstate)
-	json.Unmarshal(bytes.NewBuffer(resp2.Payload).Bytes(), &realEstate)
-	realEstateList = append(realEstateList, realEstate)
-	json.Unmarshal(bytes.NewBuffer(resp3.Payload).Bytes(), &realEstate)
-	realEstateList = append(realEstateList, realEstate)
-	json.Unmarshal(bytes.NewBuffer(resp4.Payload).Bytes(), &realEstate)
-	realEstateList = append(realEstateList, realEstate)
+	for _, args := range [][]string{
+		{testkit.Owner1, "50", "30"},
+		{testkit.Owner1, "80", "60.8"},
+		{testkit.Owner3, "60", "40"},
+		{testkit.Owner5, "80", "60"},
+	} {
+		var realEstate model.RealEstate
+		k.MustDecode(&realEstate, append([]string{"createRealEstate", testkit.Admin}, args...)...)
+		realEstateList = append(realEstateList, realEstate)
+	}
 	return realEstateList
 }
 
 // 测试获取房地产信息
 func Test_QueryRealEstateList(t *testing.T) {
-	stub := initTest(t)
-	realEstateList := checkCreateRealEstate(stub, t)
-
-	fmt.Println(fmt.Sprintf("1、测试获取所有数据\n%s",
-		string(checkInvoke(t, stub, [][]byte{
-			[]byte("queryRealEstateList"),
-		}).Payload)))
-	fmt.Println(fmt.Sprintf("2、测试获取指定数据\n%s",
-		string(checkInvoke(t, stub, [][]byte{
-			[]byte("queryRealEstateList"),
-			[]byte(realEstateList[0].Proprietor),
-			[]byte(realEstateList[0].RealEstateID),
-		}).Payload)))
-	fmt.Println(fmt.Sprintf("3、测试获取无效数据\n%s",
-		string(checkInvoke(t, stub, [][]byte{
-			[]byte("queryRealEstateList"),
-			[]byte("0"),
-		}).Payload)))
+	k := testkit.New(t)
+	realEstateList := createRealEstates(k)
+	cases := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"获取所有数据", nil, 4},
+		{"获取所有者名下数据", []string{testkit.Owner1}, 2},
+		{"获取指定数据", []string{realEstateList[0].Proprietor, realEstateList[0].RealEstateID}, 1},
+		{"获取无效数据", []string{"0"}, 0},
+	}
+	for _, c := range cases {
+		var realEstates []model.RealEstate
+		k.MustDecode(&realEstates, append([]string{"queryRealEstateList"}, c.args...)...)
+		if len(realEstates) != c.want {
+			t.Errorf("%s: 期望%d条，实际%d条", c.name, c.want, len(realEstates))
+		}
+	}
 }
 
 // 测试发起销售
 func Test_CreateSelling(t *testing.T) {
-	stub := initTest(t)
-	realEstateList := checkCreateRealEstate(stub, t)
-	//成功
-	checkInvoke(t, stub, [][]byte{
-		[]byte("createSelling"),
-		[]byte(realEstateList[0].RealEstateID), //销售对象(正在出售的房地产RealEstateID)
-		[]byte(realEstateList[0].Proprietor),   //卖家(卖家AccountId)
-		[]byte("50"),                           //价格
-		[]byte("30"),                           //智能合约的有效期(单位为天)
-	})
-	//验证销售对象objectOfSale属于卖家seller失败
-	checkInvoke(t, stub, [][]byte{
-		[]byte("createSelling"),
-		[]byte(realEstateList[0].RealEstateID), //销售对象(正在出售的房地产RealEstateID)
-		[]byte(realEstateList[2].Proprietor),   //卖家(卖家AccountId)
-		[]byte("50"),                           //价格
-		[]byte("30"),                           //智能合约的有效期(单位为天)
-	})
-	checkInvoke(t, stub, [][]byte{
-		[]byte("createSelling"),
-		[]byte("123"),                        //销售对象(正在出售的房地产RealEstateID)
-		[]byte(realEstateList[0].Proprietor), //卖家(卖家AccountId)
-		[]byte("50"),                         //价格
-		[]byte("30"),                         //智能合约的有效期(单位为天)
-	})
-	//参数错误
-	checkInvoke(t, stub, [][]byte{
-		[]byte("createSelling"),
-		[]byte(realEstateList[0].RealEstateID), //销售对象(正在出售的房地产RealEstateID)
-		[]byte(realEstateList[0].Proprietor),   //卖家(卖家AccountId)
-		[]byte("50"),                           //价格
-	})
-	checkInvoke(t, stub, [][]byte{
-		[]byte("createSelling"),
-		[]byte(""),                           //销售对象(正在出售的房地产RealEstateID)
-		[]byte(realEstateList[0].Proprietor), //卖家(卖家AccountId)
-		[]byte("50"),                         //价格
-		[]byte("30"),                         //智能合约的有效期(单位为天)
+	k := testkit.New(t)
+	realEstateList := createRealEstates(k)
+	target := realEstateList[0]
+	changes := k.Changes(func() {
+		k.MustSucceed("createSelling", target.RealEstateID, target.Proprietor, "50", "30")
 	})
+	if got := testkit.CountByType(changes); len(got) != 2 || got[model.SellingKey] != 1 || got[model.RealEstateKey] != 1 {
+		t.Errorf("发起销售应新增销售并更新房产担保状态，实际: %v", testkit.Summary(changes))
+	}
+	l := k.Ledger()
+	selling := l.Selling(target.Proprietor, target.RealEstateID)
+	if selling == nil || selling.SellingStatus != model.SellingStatusConstant()["saleStart"] || selling.Price != 50 || selling.SalePeriod != 30 {
+		t.Errorf("销售不符合预期: %+v", selling)
+	}
+	if !l.RealEstate(target.RealEstateID).Encumbrance {
+		t.Error("发起销售后房产应处于担保状态")
+	}
+	cases := []struct {
+		name string
+		args []string
+		code errcode.Code
+		key  string
+	}{
+		{"重复发起销售", []string{target.RealEstateID, target.Proprietor, "50", "30"}, errcode.Conflict, "realEstate.encumbered"},
+		{"销售对象不属于卖家", []string{realEstateList[1].RealEstateID, realEstateList[2].Proprietor, "50", "30"}, errcode.NotFound, "realEstate.notFound"},
+		{"销售对象不存在", []string{"123", target.Proprietor, "50", "30"}, errcode.NotFound, "realEstate.notFound"},
+		{"参数个数不满足", []string{target.RealEstateID, target.Proprietor, "50"}, errcode.Validation, "args.count"},
+		{"参数存在空值", []string{"", target.Proprietor, "50", "30"}, errcode.Validation, "args.empty"},
+		{"价格格式出错", []string{realEstateList[1].RealEstateID, target.Proprietor, "abc", "30"}, errcode.Validation, "args.format"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			k.T = t
+			k.MustFail(c.code, c.key, append([]string{"createSelling"}, c.args...)...)
+		})
+	}
 }
 
-// 测试销售发起、购买等操作
+// 测试销售发起、购买、确认收款
 func Test_QuerySellingList(t *testing.T) {
-	stub := initTest(t)
-	realEstateList := checkCreateRealEstate(stub, t)
-	//先发起
-	fmt.Println(fmt.Sprintf("发起\n%s", string(checkInvoke(t, stub, [][]byte{
-		[]byte("createSelling"),
-		[]byte(realEstateList[0].RealEstateID), //销售对象(正在出售的房地产RealEstateID)
-		[]byte(realEstateList[0].Proprietor),   //卖家(卖家AccountId)
-		[]byte("500000"),                       //价格
-		[]byte("30"),                           //智能合约的有效期(单位为天)
-	}).Payload)))
-	fmt.Println(fmt.Sprintf("发起\n%s", string(checkInvoke(t, stub, [][]byte{
-		[]byte("createSelling"),
-		[]byte(realEstateList[2].RealEstateID), //销售对象(正在出售的房地产RealEstateID)
-		[]byte(realEstateList[2].Proprietor),   //卖家(卖家AccountId)
-		[]byte("600000"),                       //价格
-		[]byte("40"),                           //智能合约的有效期(单位为天)
-	}).Payload)))
-	//查询成功
-	fmt.Println(fmt.Sprintf("1、查询所有\n%s", string(checkInvoke(t, stub, [][]byte{
-		[]byte("querySellingList"),
-	}).Payload)))
-	fmt.Println(fmt.Sprintf("2、查询指定%s\n%s", realEstateList[0].Proprietor, string(checkInvoke(t, stub, [][]byte{
-		[]byte("querySellingList"),
-		[]byte(realEstateList[0].Proprietor),
-	}).Payload)))
-	//购买
-	fmt.Println(fmt.Sprintf("3、购买前先查询%s的账户余额\n%s", realEstateList[2].Proprietor, string(checkInvoke(t, stub, [][]byte{
-		[]byte("queryAccountList"),
-		[]byte(realEstateList[2].Proprietor),
-	}).Payload)))
-	fmt.Println(fmt.Sprintf("4、开始购买\n%s", string(checkInvoke(t, stub, [][]byte{
-		[]byte("createSellingByBuy"),
-		[]byte(realEstateList[0].RealEstateID), //销售对象(正在出售的房地产RealEstateID)
-		[]byte(realEstateList[0].Proprietor),   //卖家(卖家AccountId)
-		[]byte(realEstateList[2].Proprietor),   //买家(买家AccountId)
-	}).Payload)))
-	fmt.Println(fmt.Sprintf("》购买后再次查询%s的账户余额\n%s", realEstateList[2].Proprietor, string(checkInvoke(t, stub, [][]byte{
-		[]byte("queryAccountList"),
-		[]byte(realEstateList[2].Proprietor),
-	}).Payload)))
-	fmt.Println(fmt.Sprintf("》卖家查询购买成功信息\n%s", string(checkInvoke(t, stub, [][]byte{
-		[]byte("querySellingList"),
-		[]byte(realEstateList[0].Proprietor), //买家(买家AccountId)
-	}).Payload)))
-	fmt.Println(fmt.Sprintf("》买家查询购买成功信息\n%s", string(checkInvoke(t, stub, [][]byte{
-		[]byte("querySellingListByBuyer"),
-		[]byte(realEstateList[2].Proprietor), //买家(买家AccountId)
-	}).Payload)))
-	fmt.Println(fmt.Sprintf("》确认收款前卖家%s的账户余额\n%s", realEstateList[0].Proprietor, string(checkInvoke(t, stub, [][]byte{
-		[]byte("queryAccountList"),
-		[]byte(realEstateList[0].Proprietor),
-	}).Payload)))
-	fmt.Println(fmt.Sprintf("》确认收款前买家%s的账户余额\n%s", realEstateList[2].Proprietor, string(checkInvoke(t, stub, [][]byte{
-		[]byte("queryAccountList"),
-		[]byte(realEstateList[2].Proprietor),
-	}).Payload)))
-	fmt.Println(fmt.Sprintf("》确认收款前卖家%s的房产信息\n%s", realEstateList[0].Proprietor, string(checkInvoke(t, stub, [][]byte{
-		[]byte("queryRealEstateList"),
-		[]byte(realEstateList[0].Proprietor),
-	}).Payload)))
-	fmt.Println(fmt.Sprintf("》确认收款前买家%s的房产信息\n%s", realEstateList[2].Proprietor, string(checkInvoke(t, stub, [][]byte{
-		[]byte("queryRealEstateList"),
-		[]byte(realEstateList[2].Proprietor),
-	}).Payload)))
-	fmt.Println(fmt.Sprintf("》卖家确认收款\n%s", string(checkInvoke(t, stub, [][]byte{
-		[]byte("updateSelling"),
-		[]byte(realEstateList[0].RealEstateID), //销售对象(正在出售的房地产RealEstateID)
-		[]byte(realEstateList[0].Proprietor),   //卖家(卖家AccountId)
-		[]byte(realEstateList[2].Proprietor),   //买家(买家AccountId)
-		[]byte("done"),                         //确认收款
-	}).Payload)))
-	//fmt.Println(fmt.Sprintf("》卖家取消收款\n%s", string(checkInvoke(t, stub, [][]byte{
-	//	[]byte("updateSelling"),
-	//	[]byte(realEstateList[0].RealEstateID), //销售对象(正在出售的房地产RealEstateID)
-	//	[]byte(realEstateList[0].Proprietor),   //卖家(卖家AccountId)
-	//	[]byte(realEstateList[2].Proprietor),   //买家(买家AccountId)
-	//	[]byte("cancelled"),                    //取消收款
-	//}).Payload)))
-	fmt.Println(fmt.Sprintf("》确认收款后卖家%s的账户余额\n%s", realEstateList[0].Proprietor, string(checkInvoke(t, stub, [][]byte{
-		[]byte("queryAccountList"),
-		[]byte(realEstateList[0].Proprietor),
-	}).Payload)))
-	fmt.Println(fmt.Sprintf("》确认收款后买家%s的账户余额\n%s", realEstateList[2].Proprietor, string(checkInvoke(t, stub, [][]byte{
-		[]byte("queryAccountList"),
-		[]byte(realEstateList[2].Proprietor),
-	}).Payload)))
-	fmt.Println(fmt.Sprintf("》确认收款后卖家%s的房产信息\n%s", realEstateList[0].Proprietor, string(checkInvoke(t, stub, [][]byte{
-		[]byte("queryRealEstateList"),
-		[]byte(realEstateList[0].Proprietor),
-	}).Payload)))
-	fmt.Println(fmt.Sprintf("》确认收款后买家%s的房产信息\n%s", realEstateList[2].Proprietor, string(checkInvoke(t, stub, [][]byte{
-		[]byte("queryRealEstateList"),
-		[]byte(realEstateList[2].Proprietor),
-	}).Payload)))
-	fmt.Println(fmt.Sprintf("》卖家查询购买成功信息\n%s", string(checkInvoke(t, stub, [][]byte{
-		[]byte("querySellingList"),
-		[]byte(realEstateList[0].Proprietor), //买家(买家AccountId)
-	}).Payload)))
-	fmt.Println(fmt.Sprintf("》买家查询购买成功信息\n%s", string(checkInvoke(t, stub, [][]byte{
-		[]byte("querySellingListByBuyer"),
-		[]byte(realEstateList[2].Proprietor), //买家(买家AccountId)
-	}).Payload)))
+	k := testkit.New(t)
+	realEstateList := createRealEstates(k)
+	target := realEstateList[0]
+	seller, buyer := target.Proprietor, realEstateList[2].Proprietor
+	k.MustSucceed("createSelling", target.RealEstateID, seller, "500000", "30")
+	k.MustSucceed("createSelling", realEstateList[2].RealEstateID, buyer, "600000", "40")
+
+	querySellings := func(args ...string) []model.Selling {
+		var sellings []model.Selling
+		k.MustDecode(&sellings, append([]string{"querySellingList"}, args...)...)
+		return sellings
+	}
+	if n := len(querySellings()); n != 2 {
+		t.Errorf("查询所有销售期望2条，实际%d条", n)
+	}
+	if sellings := querySellings(seller); len(sellings) != 1 || sellings[0].ObjectOfSale != target.RealEstateID {
+		t.Errorf("查询卖家%s的销售不符合预期: %+v", seller, sellings)
+	}
+
+	//购买后买家余额预扣，房产仍在卖家名下
+	var sellingBuy model.SellingBuy
+	k.MustDecode(&sellingBuy, "createSellingByBuy", target.RealEstateID, seller, buyer)
+	if sellingBuy.Buyer != buyer || sellingBuy.Selling.SellingStatus != model.SellingStatusConstant()["delivery"] {
+		t.Errorf("购买记录不符合预期: %+v", sellingBuy)
+	}
+	l := k.Ledger()
+	if l.Balance(buyer) != testkit.InitialBalance-500000 || l.Balance(seller) != testkit.InitialBalance {
+		t.Errorf("购买后余额不符合预期: 买家%f 卖家%f", l.Balance(buyer), l.Balance(seller))
+	}
+	if r := l.RealEstate(target.RealEstateID); r.Proprietor != seller || !r.Encumbrance {
+		t.Errorf("确认收款前房产应仍在卖家名下且处于担保状态: %+v", r)
+	}
+	var buys []model.SellingBuy
+	k.MustDecode(&buys, "querySellingListByBuyer", buyer)
+	if len(buys) != 1 || buys[0].Selling.ObjectOfSale != target.RealEstateID {
+		t.Errorf("买家查询购买信息不符合预期: %+v", buys)
+	}
+
+	//卖家确认收款后价款转给卖家，房产过户给买家
+	k.MustSucceed("updateSelling", target.RealEstateID, seller, buyer, "done")
+	l = k.Ledger()
+	if l.Balance(buyer) != testkit.InitialBalance-500000 || l.Balance(seller) != testkit.InitialBalance+500000 {
+		t.Errorf("确认收款后余额不符合预期: 买家%f 卖家%f", l.Balance(buyer), l.Balance(seller))
+	}
+	if r := l.RealEstate(target.RealEstateID); r.Proprietor != buyer || r.Encumbrance {
+		t.Errorf("确认收款后房产应过户给买家并解除担保: %+v", r)
+	}
+	if sellings := querySellings(seller); len(sellings) != 1 || sellings[0].SellingStatus != model.SellingStatusConstant()["done"] {
+		t.Errorf("确认收款后销售状态不符合预期: %+v", sellings)
+	}
+	k.MustDecode(&buys, "querySellingListByBuyer", buyer)
+	if len(buys) != 1 || buys[0].Selling.SellingStatus != model.SellingStatusConstant()["done"] {
+		t.Errorf("确认收款后买家的购买记录不符合预期: %+v", buys)
+	}
+	//房产已不在卖家名下，不能再更新
+	k.MustFail(errcode.NotFound, "realEstate.notFound", "updateSelling", target.RealEstateID, seller, buyer, "cancelled")
 }
 
 // 测试捐赠合约
 func Test_Donating(t *testing.T) {
-	stub := initTest(t)
-	realEstateList := checkCreateRealEstate(stub, t)
-
-	fmt.Println(fmt.Sprintf("获取房地产信息\n%s",
-		string(checkInvoke(t, stub, [][]byte{
-			[]byte("queryRealEstateList"),
-		}).Payload)))
-	//先发起
-	fmt.Println(fmt.Sprintf("发起捐赠\n%s", string(checkInvoke(t, stub, [][]byte{
-		[]byte("createDonating"),
-		[]byte(realEstateList[0].RealEstateID),
-		[]byte(realEstateList[0].Proprietor),
-		[]byte(realEstateList[2].Proprietor),
-	}).Payload)))
-
-	fmt.Println(fmt.Sprintf("获取房地产信息\n%s",
-		string(checkInvoke(t, stub, [][]byte{
-			[]byte("queryRealEstateList"),
-		}).Payload)))
-
-	fmt.Println(fmt.Sprintf("1、查询所有\n%s", string(checkInvoke(t, stub, [][]byte{
-		[]byte("queryDonatingList"),
-	}).Payload)))
-	fmt.Println(fmt.Sprintf("2、查询指定%s\n%s", realEstateList[0].Proprietor, string(checkInvoke(t, stub, [][]byte{
-		[]byte("queryDonatingList"),
-		[]byte(realEstateList[2].Proprietor),
-	}).Payload)))
-	fmt.Println(fmt.Sprintf("3、查询指定受赠%s\n%s", realEstateList[0].Proprietor, string(checkInvoke(t, stub, [][]byte{
-		[]byte("queryDonatingListByGrantee"),
-		[]byte(realEstateList[2].Proprietor),
-	}).Payload)))
-
-	//fmt.Println(fmt.Sprintf("4、接受受赠%s\n%s", realEstateList[0].Proprietor, string(checkInvoke(t, stub, [][]byte{
-	//	[]byte("updateDonating"),
-	//	[]byte(realEstateList[0].RealEstateID),
-	//	[]byte(realEstateList[0].Proprietor),
-	//	[]byte(realEstateList[2].Proprietor),
-	//	[]byte("done"),
-	//}).Payload)))
-	fmt.Println(fmt.Sprintf("4、取消受赠%s\n%s", realEstateList[0].Proprietor, string(checkInvoke(t, stub, [][]byte{
-		[]byte("updateDonating"),
-		[]byte(realEstateList[0].RealEstateID),
-		[]byte(realEstateList[0].Proprietor),
-		[]byte(realEstateList[2].Proprietor),
-		[]byte("cancelled"),
-	}).Payload)))
-
-	fmt.Println(fmt.Sprintf("获取房地产信息\n%s",
-		string(checkInvoke(t, stub, [][]byte{
-			[]byte("queryRealEstateList"),
-		}).Payload)))
+	k := testkit.New(t)
+	realEstateList := createRealEstates(k)
+	target := realEstateList[0]
+	donor, grantee := target.Proprietor, realEstateList[2].Proprietor
+
+	k.MustFail(errcode.Forbidden, "donating.toAdmin", "createDonating", target.RealEstateID, donor, testkit.Admin)
+	k.MustFail(errcode.Validation, "donating.sameAccount", "createDonating", target.RealEstateID, donor, donor)
+
+	k.MustSucceed("createDonating", target.RealEstateID, donor, grantee)
+	if !k.Ledger().RealEstate(target.RealEstateID).Encumbrance {
+		t.Error("发起捐赠后房产应处于担保状态")
+	}
+	k.MustFail(errcode.Conflict, "realEstate.encumbered", "createSelling", target.RealEstateID, donor, "100", "30")
+
+	var donatings []model.Donating
+	k.MustDecode(&donatings, "queryDonatingList")
+	if len(donatings) != 1 {
+		t.Errorf("查询所有捐赠期望1条，实际%d条", len(donatings))
+	}
+	k.MustDecode(&donatings, "queryDonatingList", donor)
+	if len(donatings) != 1 || donatings[0].Grantee != grantee {
+		t.Errorf("查询捐赠人%s的捐赠不符合预期: %+v", donor, donatings)
+	}
+	var received []model.DonatingGrantee
+	k.MustDecode(&received, "queryDonatingListByGrantee", grantee)
+	if len(received) != 1 || received[0].Donating.DonatingStatus != model.DonatingStatusConstant()["donatingStart"] {
+		t.Errorf("查询受赠人%s的受赠不符合预期: %+v", grantee, received)
+	}
+
+	//取消捐赠后解除担保，房产仍在捐赠人名下
+	k.MustSucceed("updateDonating", target.RealEstateID, donor, grantee, "cancelled")
+	if r := k.Ledger().RealEstate(target.RealEstateID); r.Proprietor != donor || r.Encumbrance {
+		t.Errorf("取消捐赠后房产不符合预期: %+v", r)
+	}
+	k.MustFail(errcode.Conflict, "donating.notStarted", "updateDonating", target.RealEstateID, donor, grantee, "done")
+
+	//再次捐赠并由受赠人接收，房产过户且余额不变
+	k.MustSucceed("createDonating", target.RealEstateID, donor, grantee)
+	k.MustSucceed("updateDonating", target.RealEstateID, donor, grantee, "done")
+	l := k.Ledger()
+	if r := l.RealEstate(target.RealEstateID); r.Proprietor != grantee || r.Encumbrance {
+		t.Errorf("接收捐赠后房产应过户给受赠人: %+v", r)
+	}
+	if l.Balance(donor) != testkit.InitialBalance || l.Balance(grantee) != testkit.InitialBalance {
+		t.Errorf("捐赠不应改变余额: 捐赠人%f 受赠人%f", l.Balance(donor), l.Balance(grantee))
+	}
+}
+
+// 测试当事人校验：发起、购买、确认销售和捐赠时调用者证书中的realty.accountId必须是对应的当事人，管理员可以代为操作
+func Test_Parties(t *testing.T) {
+	k := testkit.New(t)
+	realEstateList := createRealEstates(k)
+	target, donated := realEstateList[0], realEstateList[2]
+	steps := []struct {
+		name    string
+		account string
+		args    []string
+		key     string //为空表示调用成功
+	}{
+		{"冒用卖家发起销售", testkit.Owner3, []string{"createSelling", target.RealEstateID, testkit.Owner1, "50", "30"}, "auth.notParty"},
+		{"以合约函数名调用同样校验", testkit.Owner3, []string{"Selling:CreateSelling", target.RealEstateID, testkit.Owner1, "50", "30"}, "auth.notParty"},
+		{"管理员代卖家发起销售", testkit.Admin, []string{"createSelling", target.RealEstateID, testkit.Owner1, "50", "30"}, ""},
+		{"卖家冒用买家购买", testkit.Owner1, []string{"createSellingByBuy", target.RealEstateID, testkit.Owner1, testkit.Owner3}, "auth.notParty"},
+		{"买家购买", testkit.Owner3, []string{"createSellingByBuy", target.RealEstateID, testkit.Owner1, testkit.Owner3}, ""},
+		{"其他账户确认收款", testkit.Owner5, []string{"updateSelling", target.RealEstateID, testkit.Owner1, testkit.Owner3, "done"}, "auth.notParty"},
+		{"买家确认收款", testkit.Owner3, []string{"updateSelling", target.RealEstateID, testkit.Owner1, testkit.Owner3, "done"}, ""},
+		{"受赠人冒用捐赠人发起捐赠", testkit.Owner5, []string{"createDonating", donated.RealEstateID, testkit.Owner3, testkit.Owner5}, "auth.notParty"},
+		{"捐赠人发起捐赠", testkit.Owner3, []string{"createDonating", donated.RealEstateID, testkit.Owner3, testkit.Owner5}, ""},
+		{"其他账户接收捐赠", testkit.Owner1, []string{"updateDonating", donated.RealEstateID, testkit.Owner3, testkit.Owner5, "done"}, "auth.notParty"},
+		{"受赠人接收捐赠", testkit.Owner5, []string{"updateDonating", donated.RealEstateID, testkit.Owner3, testkit.Owner5, "done"}, ""},
+	}
+	for _, step := range steps {
+		k.Account = step.account
+		if step.key == "" {
+			k.MustSucceed(step.args...)
+		} else {
+			k.MustFail(errcode.Forbidden, step.key, step.args...)
+		}
+	}
+	k.Account = ""
+	l := k.Ledger()
+	if l.RealEstate(target.RealEstateID).Proprietor != testkit.Owner3 || l.RealEstate(donated.RealEstateID).Proprietor != testkit.Owner5 {
+		t.Errorf("当事人完成的销售和捐赠应过户: %+v", l.RealEstates)
+	}
+
+	//证书中没有realty.accountId时不能以任何当事人身份操作
+	k.Creator = mockstub.Identity("JDMSP")
+	k.MustFail(errcode.Unauthenticated, "auth.noAccountAttr", "createSelling", realEstateList[1].RealEstateID, testkit.Owner1, "50", "30")
+	k.Creator = nil
+}
+
+// 测试根据筛选条件查询销售和房地产(MockStub不支持富查询，走遍历降级)
+func Test_QueryByFilter(t *testing.T) {
+	k := testkit.New(t)
+	realEstateList := createRealEstates(k)
+	k.MustSucceed("createSelling", realEstateList[0].RealEstateID, realEstateList[0].Proprietor, "500000", "30")
+	k.MustSucceed("createSelling", realEstateList[2].RealEstateID, realEstateList[2].Proprietor, "600000", "40")
+	k.MustSucceed("createSelling", realEstateList[3].RealEstateID, realEstateList[3].Proprietor, "700000", "40")
+	k.MustSucceed("createSellingByBuy", realEstateList[3].RealEstateID, realEstateList[3].Proprietor, realEstateList[0].Proprietor)
+	cases := []struct {
+		filter string
+		want   int
+	}{
+		{``, 3},
+		{`{"minPrice":550000}`, 2},
+		{`{"minPrice":550000,"maxPrice":650000}`, 1},
+		{`{"sellingStatus":"saleStart"}`, 2},
+		{`{"sellingStatus":"delivery","seller":"` + realEstateList[3].Proprietor + `"}`, 1},
+		{`{"seller":"` + realEstateList[0].Proprietor + `"}`, 1},
+		{`{"minArea":55,"maxArea":70}`, 1},
+		{`{"minArea":75}`, 1},
+		{`{"minArea":1000}`, 0},
+	}
+	for _, c := range cases {
+		var sellingList []model.Selling
+		k.MustDecode(&sellingList, "querySellingsByFilter", c.filter)
+		if len(sellingList) != c.want {
+			t.Errorf("querySellingsByFilter %s 期望%d条，实际%d条", c.filter, c.want, len(sellingList))
+		}
+	}
+	var realEstates []model.RealEstate
+	k.MustDecode(&realEstates, "queryRealEstatesByFilter", `{"encumbrance":false,"minTotalArea":70}`)
+	if len(realEstates) != 1 || realEstates[0].RealEstateID != realEstateList[1].RealEstateID {
+		t.Errorf("queryRealEstatesByFilter 结果不符合预期: %v", realEstates)
+	}
+	//富查询出错时返回错误，只有状态数据库不支持富查询时才降级为遍历
+	k.QueryError = errors.New("couchdb: request timeout")
+	k.MustFail(errcode.Internal, "internal", "querySellingsByFilter", `{"sellingStatus":"saleStart"}`)
+	k.MustFail(errcode.Internal, "internal", "queryRealEstatesByFilter", `{"minTotalArea":70}`)
+	k.QueryError = errors.New("ExecuteQuery not supported for leveldb")
+	k.MustDecode(&realEstates, "queryRealEstatesByFilter", `{"encumbrance":false,"minTotalArea":70}`)
+	if len(realEstates) != 1 {
+		t.Errorf("LevelDB不支持富查询时应降级为遍历: %v", realEstates)
+	}
+	k.QueryError = nil
+	//非法的筛选条件
+	for _, filter := range []string{`{"minPrice":-1}`, `{"minPrice":10,"maxPrice":5}`, `{"sellingStatus":"unknown"}`, `{"foo":1}`, `{`} {
+		k.MustFail(errcode.Validation, "", "querySellingsByFilter", filter)
+	}
+}
+
+// 测试记录写入时自动设置docType，以及旧记录的一次性升级
+func Test_UpgradeDocType(t *testing.T) {
+	k := testkit.New(t)
+	realEstateList := createRealEstates(k)
+	if realEstateList[0].DocType != model.RealEstateDocType || realEstateList[0].Version != model.SchemaVersion {
+		t.Fatalf("新建的房地产docType/version未设置: %+v", realEstateList[0].Doc)
+	}
+	//模拟升级前写入的旧记录，旧账户带入的余额不在初始总额中，之后不再检查不变量
+	k.Invariants = nil
+	legacyKey := k.PutState(model.AccountKey, []string{"legacy000001"}, []byte(`{"accountId":"legacy000001","userName":"旧业主","balance":100}`))
+	k.MustFail(errcode.Forbidden, "auth.notAdmin", "upgradeDocType", testkit.Owner1)
+	var upgraded map[string]int
+	k.MustDecode(&upgraded, "upgradeDocType", testkit.Admin)
+	if upgraded[model.AccountDocType] != 7 || upgraded[model.RealEstateDocType] != 4 {
+		t.Errorf("升级条数不符合预期: %v", upgraded)
+	}
+	account := k.Ledger().Account("legacy000001")
+	if account == nil || account.DocType != model.AccountDocType || account.Version != model.SchemaVersion || account.Balance != 100 {
+		t.Errorf("旧记录升级结果不符合预期: %+v", account)
+	}
+	//类型不匹配的记录读取时应当报错
+	k.Stub.State[legacyKey] = []byte(`{"accountId":"legacy000001","docType":"selling","version":1}`)
+	if res := k.Invoke("queryAccountList", "legacy000001"); res.Status == 200 {
+		t.Error("docType不匹配的记录读取应当失败")
+	}
+}
+
+// 测试交付中的销售必须由真实买家取消，不能借用其他账户退款
+func Test_UpdateSellingWrongBuyer(t *testing.T) {
+	k := testkit.New(t)
+	realEstateList := createRealEstates(k)
+	target := realEstateList[0]
+	seller, buyer, other := target.Proprietor, realEstateList[2].Proprietor, realEstateList[3].Proprietor
+	k.MustSucceed("createSelling", target.RealEstateID, seller, "500000", "30")
+	k.MustSucceed("createSellingByBuy", target.RealEstateID, seller, buyer)
+
+	before := k.Snapshot()
+	k.MustFail(errcode.NotFound, "sellingBuy.notFound", "updateSelling", target.RealEstateID, seller, other, "cancelled")
+	if changes := before.Diff(k.Snapshot()); len(changes) != 0 {
+		t.Errorf("非买家取消失败后账本不应变化: %v", testkit.Summary(changes))
+	}
+
+	//真实买家取消后全额退款
+	k.MustSucceed("updateSelling", target.RealEstateID, seller, buyer, "cancelled")
+	l := k.Ledger()
+	if l.Balance(buyer) != testkit.InitialBalance || l.Balance(other) != testkit.InitialBalance {
+		t.Errorf("买家取消后应全额退款: 买家%f 其他%f", l.Balance(buyer), l.Balance(other))
+	}
+	//已取消的销售不能再次取消
+	k.MustFail(errcode.Conflict, "selling.closed", "updateSelling", target.RealEstateID, seller, buyer, "cancelled")
+}
+
+// 测试链码错误以错误信封的形式返回，应用层据此转换HTTP状态码
+func Test_ErrorEnvelope(t *testing.T) {
+	k := testkit.New(t)
+	realEstateList := createRealEstates(k)
+	k.MustSucceed("createSelling", realEstateList[0].RealEstateID, realEstateList[0].Proprietor, "50000000", "30")
+	cases := []struct {
+		args []string
+		code errcode.Code
+		key  string
+	}{
+		{[]string{"unknown"}, errcode.Validation, "function.unknown"},
+		{[]string{"createRealEstate"}, errcode.Validation, "args.count"},
+		{[]string{"querySellingsByFilter", `{"minPrice":-1}`}, errcode.Validation, "filter.invalid"},
+		{[]string{"upgradeDocType", "000000000000"}, errcode.Unauthenticated, "auth.operatorNotFound"},
+		{[]string{"upgradeDocType", realEstateList[0].Proprietor}, errcode.Forbidden, "auth.notAdmin"},
+		{[]string{"createSellingByBuy", realEstateList[1].RealEstateID, realEstateList[1].Proprietor, realEstateList[2].Proprietor}, errcode.NotFound, "selling.notFound"},
+		{[]string{"createSellingByBuy", realEstateList[0].RealEstateID, realEstateList[0].Proprietor, realEstateList[2].Proprietor}, errcode.InsufficientFunds, "balance.insufficient"},
+	}
+	for _, c := range cases {
+		k.MustFail(c.code, c.key, c.args...)
+	}
+}
+
+// 测试每笔交易的状态变化合并为一个事件写出，失败的交易不写出事件
+func Test_Events(t *testing.T) {
+	k := testkit.New(t)
+	realEstateList := createRealEstates(k)
+	seller := realEstateList[0].Proprietor
+	buyer := realEstateList[2].Proprietor
+	check := func(args []string, want ...model.EventType) {
+		t.Helper()
+		k.Invoke(args...)
+		var got []model.EventType
+		if len(k.Events) > 1 {
+			t.Fatalf("%s 最多写出一个事件，实际%d个", args[0], len(k.Events))
+		}
+		if len(k.Events) == 1 {
+			if k.Events[0].Version != model.EventVersion || k.Events[0].TxID == "" {
+				t.Errorf("%s 事件批次信息不符合预期: %+v", args[0], k.Events[0])
+			}
+			for _, event := range k.Events[0].Events {
+				got = append(got, event.Type)
+				//事件对通道上的所有组织可见，不能包含私有字段
+				for _, field := range []string{`"price":`, `"balance":`, `"userName":`, `"refund":`} {
+					if bytes.Contains(event.Payload, []byte(field)) {
+						t.Errorf("%s 的%s事件不应包含%s: %s", args[0], event.Type, field, event.Payload)
+					}
+				}
+			}
+		}
+		if fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Errorf("%s 期望事件%v，实际%v", args[0], want, got)
+		}
+	}
+	check([]string{"createSelling", realEstateList[0].RealEstateID, seller, "500000", "30"}, model.SellingCreated)
+	check([]string{"createSelling", realEstateList[0].RealEstateID, seller, "500000", "30"})
+	check([]string{"createSellingByBuy", realEstateList[0].RealEstateID, seller, buyer}, model.SellingPurchased, model.BalanceChanged)
+	check([]string{"updateSelling", realEstateList[0].RealEstateID, seller, buyer, "done"}, model.SellingCompleted, model.BalanceChanged, model.RealEstateTransferred)
+	check([]string{"queryAccountList"})
+
+	check([]string{"createSelling", realEstateList[1].RealEstateID, realEstateList[1].Proprietor, "100", "30"}, model.SellingCreated)
+	check([]string{"createSellingByBuy", realEstateList[1].RealEstateID, realEstateList[1].Proprietor, buyer}, model.SellingPurchased, model.BalanceChanged)
+	check([]string{"updateSelling", realEstateList[1].RealEstateID, realEstateList[1].Proprietor, buyer, "cancelled"}, model.SellingCancelled, model.BalanceChanged)
+
+	check([]string{"createDonating", realEstateList[3].RealEstateID, realEstateList[3].Proprietor, buyer}, model.DonationCreated)
+	check([]string{"updateDonating", realEstateList[3].RealEstateID, realEstateList[3].Proprietor, buyer, "done"}, model.DonationAccepted, model.RealEstateTransferred)
+
+	//完成的销售和捐赠记录完成时间，取消的销售没有
+	l := k.Ledger()
+	if l.Selling(seller, realEstateList[0].RealEstateID).DoneTime == "" || l.Selling(realEstateList[1].Proprietor, realEstateList[1].RealEstateID).DoneTime != "" {
+		t.Error("只有完成的销售应记录完成时间")
+	}
+	if l.Donating(realEstateList[3].Proprietor, realEstateList[3].RealEstateID, buyer).DoneTime == "" {
+		t.Error("完成的捐赠应记录完成时间")
+	}
+}
+
+// 测试同一买家、受赠人在同一秒内参与多笔交易时，每笔交易的记录都能查到并完成
+func Test_SameSecondParticipation(t *testing.T) {
+	k := testkit.New(t)
+	realEstateList := createRealEstates(k)
+	buyer := realEstateList[2].Proprietor
+	k.Tick = 0
+	k.MustSucceed("createSelling", realEstateList[0].RealEstateID, realEstateList[0].Proprietor, "100", "30")
+	k.MustSucceed("createSelling", realEstateList[1].RealEstateID, realEstateList[1].Proprietor, "200", "30")
+	k.MustSucceed("createSellingByBuy", realEstateList[0].RealEstateID, realEstateList[0].Proprietor, buyer)
+	k.MustSucceed("createSellingByBuy", realEstateList[1].RealEstateID, realEstateList[1].Proprietor, buyer)
+	var buys []model.SellingBuy
+	k.MustDecode(&buys, "querySellingListByBuyer", buyer)
+	if len(buys) != 2 {
+		t.Fatalf("同一秒内的两次购买应有两条记录，实际%d条", len(buys))
+	}
+	k.MustSucceed("updateSelling", realEstateList[0].RealEstateID, realEstateList[0].Proprietor, buyer, "done")
+	k.MustSucceed("updateSelling", realEstateList[1].RealEstateID, realEstateList[1].Proprietor, buyer, "cancelled")
+
+	grantee := realEstateList[3].Proprietor
+	k.MustSucceed("createDonating", realEstateList[0].RealEstateID, buyer, grantee)
+	k.MustSucceed("createDonating", realEstateList[2].RealEstateID, buyer, grantee)
+	k.MustSucceed("updateDonating", realEstateList[0].RealEstateID, buyer, grantee, "done")
+	k.MustSucceed("updateDonating", realEstateList[2].RealEstateID, buyer, grantee, "cancelled")
+}
+
+// 测试导出的状态可以在新网络中恢复，恢复后再导出的内容不变，只能在首次运行时导入一次
+func Test_ExportImportState(t *testing.T) {
+	k := testkit.New(t)
+	realEstateList := createRealEstates(k)
+	seller, buyer := realEstateList[0].Proprietor, realEstateList[2].Proprietor
+	k.MustSucceed("createSelling", realEstateList[0].RealEstateID, seller, "500000", "30")
+	k.MustSucceed("createSellingByBuy", realEstateList[0].RealEstateID, seller, buyer)
+	k.MustSucceed("updateSelling", realEstateList[0].RealEstateID, seller, buyer, "done")
+	k.MustSucceed("createSelling", realEstateList[1].RealEstateID, seller, "100", "30")
+	k.MustSucceed("createSellingByBuy", realEstateList[1].RealEstateID, seller, buyer)
+	k.MustSucceed("createDonating", realEstateList[3].RealEstateID, realEstateList[3].Proprietor, buyer)
+	dump := k.MustSucceed("exportState", testkit.Admin)
+	if again := k.MustSucceed("exportState", testkit.Admin); !bytes.Equal(dump, again) {
+		t.Fatal("相同的账本状态应导出相同的内容")
+	}
+	if len(k.Events) != 0 {
+		t.Errorf("导出不应写出事件: %+v", k.Events)
+	}
+	//只有管理员可以导出，操作人须与调用者证书中的账户一致
+	k.MustFail(errcode.Forbidden, "auth.notAdmin", "exportState", seller)
+	k.Account = seller
+	k.MustFail(errcode.Forbidden, "auth.operatorMismatch", "exportState", testkit.Admin)
+	k.Account = ""
+
+	restored := testkit.New(t)
+	var imported model.StateImport
+	restored.MustDecode(&imported, "importState", testkit.Admin, string(dump))
+	if imported.Counts[model.AccountDocType] != 6 || imported.Counts[model.RealEstateDocType] != 4 || imported.Counts[model.SellingBuyDocType] != 2 {
+		t.Errorf("导入的条数不符合预期: %+v", imported.Counts)
+	}
+	if len(restored.Events) != 1 || restored.Events[0].Events[0].Type != model.StateImported {
+		t.Errorf("导入应写出StateImported事件: %+v", restored.Events)
+	}
+	if got := restored.MustSucceed("exportState", testkit.Admin); !bytes.Equal(dump, got) {
+		t.Fatalf("恢复后导出的内容与原账本不一致:\n%s\n%s", dump, got)
+	}
+	//恢复后可以继续交易
+	restored.MustSucceed("updateSelling", realEstateList[1].RealEstateID, seller, buyer, "done")
+	restored.MustFail(errcode.Conflict, "state.notFirstRun", "importState", testkit.Admin, string(dump))
+
+	used := testkit.New(t)
+	createRealEstates(used)
+	used.MustFail(errcode.Conflict, "state.notFirstRun", "importState", testkit.Admin, string(dump))
+
+	//导出文件中没有的账户在导入时删除
+	fresh := testkit.New(t)
+	var freshDump model.StateDump
+	fresh.MustDecode(&freshDump, "exportState", testkit.Admin)
+	without := func(accountId string) string {
+		trimmed := freshDump
+		trimmed.Entries = nil
+		for _, entry := range freshDump.Entries {
+			if entry.Keys[0] != accountId {
+				trimmed.Entries = append(trimmed.Entries, entry)
+			}
+		}
+		trimmedByte, _ := json.Marshal(trimmed)
+		return string(trimmedByte)
+	}
+	fresh.Invariants = testkit.DefaultInvariants(4 * testkit.InitialBalance)
+	fresh.MustSucceed("importState", testkit.Admin, without(testkit.Owner5))
+	if l := fresh.Ledger(); len(l.Accounts) != 5 || l.Account(testkit.Owner5) != nil {
+		t.Errorf("导出文件中没有的账户应被删除: %+v", l.Accounts)
+	}
+
+	unknownType := freshDump
+	unknownType.Entries = append([]model.StateEntry{{ObjectType: "unknown-key", Keys: []string{"1"}, Value: []byte("{}")}}, freshDump.Entries...)
+	unknownTypeByte, _ := json.Marshal(unknownType)
+	cases := []struct {
+		name string
+		args []string
+		code errcode.Code
+		key  string
+	}{
+		{"操作人权限不足", []string{testkit.Owner1, string(dump)}, errcode.Forbidden, "auth.notAdmin"},
+		{"参数个数不满足", []string{testkit.Admin}, errcode.Validation, "args.count"},
+		{"参数格式转换出错", []string{testkit.Admin, "[]"}, errcode.Validation, "args.format"},
+		{"格式版本不兼容", []string{testkit.Admin, `{"version":2,"entries":[]}`}, errcode.Validation, "state.version"},
+		{"没有管理员账户", []string{testkit.Admin, without(testkit.Admin)}, errcode.Validation, "state.noAdmin"},
+		{"复合主键前缀不支持", []string{testkit.Admin, string(unknownTypeByte)}, errcode.Validation, "state.invalid"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			k := testkit.New(t)
+			k.MustFail(c.code, c.key, append([]string{"importState"}, c.args...)...)
+		})
+	}
+}
+
+// 测试实例化参数中的种子数据，升级时重复Init不覆盖已有数据
+func Test_InitSeed(t *testing.T) {
+	seed := `{"accounts":[{"accountId":"a00000000001","userName":"物业","role":"admin"},` +
+		`{"accountId":"a00000000002","userName":"张三","balance":1000},{"accountId":"a00000000003","userName":"李四","balance":500.5}],` +
+		`"realEstates":[{"realEstateId":"r00000000001","proprietor":"a00000000002","totalArea":120,"livingSpace":90}],"moneySupply":1500.5}`
+	k := testkit.NewWithArgs(t, seed)
+	var record model.SeedRecord
+	k.MustDecode(&record, "Ledger:QuerySeed")
+	if record.Accounts != 3 || record.RealEstates != 1 || record.MoneySupply != 1500.5 {
+		t.Fatalf("种子记录不符合预期: %+v", record)
+	}
+	l := k.Ledger()
+	if len(l.Accounts) != 3 || l.Account("a00000000002").Role != model.RoleOwner || l.Account("a00000000001").Role != model.RoleAdmin {
+		t.Fatalf("种子账户不符合预期: %+v", l.Accounts)
+	}
+	if len(l.RealEstates) != 1 || l.RealEstates[0].Proprietor != "a00000000002" {
+		t.Fatalf("种子房地产不符合预期: %+v", l.RealEstates)
+	}
+	//种子中的管理员按角色判断，不依赖用户名
+	k.MustSucceed("createRealEstate", "a00000000001", "a00000000003", "80", "60")
+	k.MustFail(errcode.Forbidden, "auth.notAdmin", "createRealEstate", "a00000000002", "a00000000003", "80", "60")
+
+	//升级时不传参数或传入相同的种子不写入任何数据
+	before := k.Snapshot()
+	for _, args := range [][]string{nil, {seed}} {
+		if res := k.Init(args...); res.Status != 200 || len(k.Events) != 0 {
+			t.Fatalf("已有数据时Init应跳过: %s %+v", res.Message, k.Events)
+		}
+	}
+	if after := k.Snapshot(); len(after) != len(before) {
+		t.Fatal("重复Init不应修改账本")
+	}
+	res := k.Init(`{"accounts":[{"accountId":"a00000000001","userName":"物业","role":"admin"}]}`)
+	if res.Status == 200 || !bytes.Contains([]byte(res.Message), []byte("seed.dataExists")) {
+		t.Fatalf("已有数据时应拒绝不同的种子: %s", res.Message)
+	}
+
+	//早于种子记录的账本同样视为已有数据
+	legacy := testkit.New(t)
+	delete(legacy.Stub.State, model.SeedKey)
+	legacy.MustFail(errcode.NotFound, "seed.notFound", "Ledger:QuerySeed")
+	if res := legacy.Init(seed); res.Status == 200 {
+		t.Fatal("已有账户的旧账本应拒绝种子数据")
+	}
+	if res := legacy.Init(); res.Status != 200 {
+		t.Fatalf("旧账本升级时Init应跳过: %s", res.Message)
+	}
+	//没有角色的旧账户仍按用户名识别管理员
+	legacy.Invariants = nil
+	legacy.PutState(model.AccountKey, []string{"legacy000001"}, []byte(`{"accountId":"legacy000001","userName":"管理员","balance":0}`))
+	legacy.MustSucceed("createRealEstate", "legacy000001", testkit.Owner1, "80", "60")
+
+	cases := []struct {
+		name string
+		seed string
+		key  string
+	}{
+		{"不是json", "[]", "args.format"},
+		{"没有账户", `{"accounts":[]}`, "seed.invalid"},
+		{"没有管理员", `{"accounts":[{"accountId":"a1","userName":"张三"}]}`, "seed.invalid"},
+		{"账户重复", `{"accounts":[{"accountId":"a1","userName":"物业","role":"admin"},{"accountId":"a1","userName":"张三"}]}`, "seed.invalid"},
+		{"角色不支持", `{"accounts":[{"accountId":"a1","userName":"物业","role":"root"}]}`, "seed.invalid"},
+		{"余额为负", `{"accounts":[{"accountId":"a1","userName":"物业","role":"admin"},{"accountId":"a2","userName":"张三","balance":-1}]}`, "seed.invalid"},
+		{"货币总量不一致", `{"accounts":[{"accountId":"a1","userName":"物业","role":"admin"},{"accountId":"a2","userName":"张三","balance":10}],"moneySupply":20}`, "seed.moneySupply"},
+		{"房地产所有者不是业主", `{"accounts":[{"accountId":"a1","userName":"物业","role":"admin"}],"realEstates":[{"realEstateId":"r1","proprietor":"a1","totalArea":10,"livingSpace":5}]}`, "seed.invalid"},
+		{"生活空间大于总面积", `{"accounts":[{"accountId":"a1","userName":"物业","role":"admin"},{"accountId":"a2","userName":"张三"}],"realEstates":[{"realEstateId":"r1","proprietor":"a2","totalArea":10,"livingSpace":50}]}`, "seed.invalid"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			stub := testkit.New(t).Stub
+			for key := range stub.State {
+				delete(stub.State, key)
+			}
+			res := stub.MockInit("invalid-seed", [][]byte{[]byte("init"), []byte(c.seed)})
+			if res.Status == 200 || !bytes.Contains([]byte(res.Message), []byte(`"`+c.key+`"`)) {
+				t.Errorf("期望%s，实际%d: %s", c.key, res.Status, res.Message)
+			}
+			if len(stub.State) != 0 {
+				t.Errorf("校验失败时不应写入数据: %d条", len(stub.State))
+			}
+		})
+	}
+}
+
+// 测试分批迁移记录结构：预览不写入，进度保存在账本中，重复调用直到完成
+func Test_Migrate(t *testing.T) {
+	k := testkit.New(t)
+	realEstateList := createRealEstates(k)
+	seller, buyer := realEstateList[0].Proprietor, realEstateList[2].Proprietor
+	k.MustSucceed("createSelling", realEstateList[0].RealEstateID, seller, "500000", "30")
+	k.MustSucceed("createSellingByBuy", realEstateList[0].RealEstateID, seller, buyer)
+	//模拟上一版本链码写入的账本：记录为版本1，旧账户没有角色，账本没有记录结构版本
+	for key, value := range k.Stub.State {
+		k.Stub.State[key] = bytes.ReplaceAll(value, []byte(fmt.Sprintf(`"version":%d`, model.SchemaVersion)), []byte(`"version":1`))
+	}
+	delete(k.Stub.State, model.SchemaKey)
+	k.PutState(model.AccountKey, []string{"legacy000001"}, []byte(`{"accountId":"legacy000001","userName":"旧业主","balance":0}`))
+	k.PutState(model.AccountKey, []string{"legacy000002"}, []byte(`{"accountId":"legacy000002","userName":"管理员","balance":0,"docType":"account","version":1}`))
+
+	var report model.MigrationReport
+	changes := k.Changes(func() {
+		k.MustDecode(&report, "migrate", testkit.Admin, "5", "true")
+	})
+	if len(changes) != 0 || len(k.Events) != 0 {
+		t.Fatalf("预览不应写入: %+v", changes)
+	}
+	if !report.DryRun || report.Done || report.From != 0 || report.To != model.SchemaVersion || report.Scanned != 5 || report.Migrated != 5 || report.Cursor == nil {
+		t.Fatalf("预览结果不符合预期: %+v", report)
+	}
+	if c := report.Changes[0]; c.ObjectType != model.AccountKey || c.Keys[0] != testkit.Owner4 || c.FromVersion != 1 || len(c.Steps) != 2 {
+		t.Errorf("第一条变化不符合预期: %+v", c)
+	}
+	cursor, _ := json.Marshal(report.Cursor)
+	var page model.MigrationReport
+	k.MustDecode(&page, "migrate", testkit.Admin, "5", "true", string(cursor))
+	if page.Scanned != 5 || page.Changes[0].Keys[0] == report.Changes[0].Keys[0] {
+		t.Fatalf("传入cursor应从上次预览的位置继续: %+v", page)
+	}
+
+	total, calls := 0, 0
+	for !report.Done || report.DryRun {
+		k.MustDecode(&report, "migrate", testkit.Admin, "5", "false")
+		total += report.Migrated
+		calls++
+		if len(k.Events) != 1 || k.Events[0].Events[0].Type != model.RecordsMigrated {
+			t.Fatalf("每批迁移应写出RecordsMigrated事件: %+v", k.Events)
+		}
+		var state model.SchemaState
+		if err := json.Unmarshal(k.Stub.State[model.SchemaKey], &state); err != nil {
+			t.Fatal(err)
+		}
+		if report.Done != (state.Migration == nil) || (state.Migration != nil && state.Migration.Cursor.Keys == nil) || (report.Done && state.Version != model.SchemaVersion) {
+			t.Fatalf("迁移进度不符合预期: %+v %+v", report, state)
+		}
+	}
+	if calls != 3 || total != 14 {
+		t.Errorf("期望3批迁移14条记录，实际%d批%d条", calls, total)
+	}
+	l := k.Ledger()
+	if l.Account("legacy000001").Role != model.RoleOwner || l.Account("legacy000002").Role != model.RoleAdmin || l.Account(testkit.Owner1).Role != model.RoleOwner {
+		t.Errorf("旧账户应补齐角色: %+v", l.Accounts)
+	}
+	for key, value := range k.Stub.State {
+		if key != model.SchemaKey && key != model.SeedKey && !bytes.Contains(value, []byte(fmt.Sprintf(`"version":%d`, model.SchemaVersion))) {
+			t.Errorf("记录%q未迁移: %s", key, value)
+		}
+	}
+	var sellingBuys []model.SellingBuy
+	k.MustDecode(&sellingBuys, "querySellingListByBuyer", buyer)
+	if len(sellingBuys) != 1 || sellingBuys[0].Version != model.SchemaVersion || sellingBuys[0].Selling.Version != model.SchemaVersion {
+		t.Errorf("嵌套的销售记录应一起迁移: %+v", sellingBuys)
+	}
+	k.MustDecode(&report, "migrate", testkit.Admin, "5", "false")
+	if !report.Done || report.Scanned != 0 || len(k.Events) != 0 {
+		t.Errorf("已是当前版本时不应再扫描: %+v", report)
+	}
+
+	k.MustFail(errcode.Forbidden, "auth.notAdmin", "migrate", testkit.Owner1, "5", "false")
+	//操作人必须与调用者证书中的realty.accountId一致，组织管理员的证书没有该属性
+	k.Account = testkit.Owner1
+	k.MustFail(errcode.Forbidden, "auth.operatorMismatch", "migrate", testkit.Admin, "5", "true")
+	k.Account = ""
+	k.Creator = mockstub.Identity("JDMSP")
+	k.MustFail(errcode.Unauthenticated, "auth.noAccountAttr", "migrate", testkit.Admin, "5", "true")
+	k.Creator = nil
+	k.MustFail(errcode.Validation, "migrate.batchSize", "migrate", testkit.Admin, "0", "false")
+	k.MustFail(errcode.Validation, "args.format", "migrate", testkit.Admin, "5", "maybe")
+	k.MustFail(errcode.Validation, "migrate.cursor", "migrate", testkit.Admin, "5", "false", string(cursor))
+}
+
+// 测试按"合约名:交易函数名"调用、元数据和旧函数名的兼容
+func Test_ContractAPI(t *testing.T) {
+	k := testkit.New(t)
+	var realEstate model.RealEstate
+	k.MustDecode(&realEstate, "RealEstate:CreateRealEstate", testkit.Admin, testkit.Owner1, "120", "90.5")
+	if realEstate.Proprietor != testkit.Owner1 || realEstate.TotalArea != 120 || realEstate.LivingSpace != 90.5 {
+		t.Fatalf("新建的房地产不符合预期: %+v", realEstate)
+	}
+	var byContract, byLegacy []model.RealEstate
+	k.MustDecode(&byContract, "RealEstate:QueryRealEstateList", testkit.Owner1, "")
+	k.MustDecode(&byLegacy, "queryRealEstateList", testkit.Owner1)
+	if len(byContract) != 1 || fmt.Sprint(byContract) != fmt.Sprint(byLegacy) {
+		t.Errorf("合约函数与旧函数的结果应一致: %+v %+v", byContract, byLegacy)
+	}
+	var accounts []model.Account
+	k.MustDecode(&accounts, "Account:QueryAccountList", fmt.Sprintf(`[%q]`, testkit.Owner2))
+	if len(accounts) != 1 || accounts[0].AccountId != testkit.Owner2 {
+		t.Errorf("按账户查询不符合预期: %+v", accounts)
+	}
+	var selling model.Selling
+	k.MustDecode(&selling, "Selling:CreateSelling", realEstate.RealEstateID, testkit.Owner1, "500", "30")
+	var sellings []model.Selling
+	k.MustDecode(&sellings, "Selling:QuerySellingsByFilter", `{"minPrice":100}`)
+	if len(sellings) != 1 || sellings[0].ObjectOfSale != realEstate.RealEstateID {
+		t.Errorf("按筛选条件查询销售不符合预期: %+v", sellings)
+	}
+	if payload := k.MustSucceed("Ledger:Hello"); string(payload) != "hello world" {
+		t.Errorf("Hello返回不符合预期: %s", payload)
+	}
+
+	cases := []struct {
+		args []string
+		code errcode.Code
+		key  string
+	}{
+		{[]string{"RealEstate:CreateRealEstate", testkit.Admin, testkit.Owner1, "120"}, errcode.Validation, "args.count"},
+		{[]string{"RealEstate:CreateRealEstate", testkit.Admin, testkit.Owner1, "", "90"}, errcode.Validation, "args.empty"},
+		{[]string{"RealEstate:CreateRealEstate", testkit.Owner2, testkit.Owner1, "120", "90"}, errcode.Forbidden, "auth.notAdmin"},
+		{[]string{"Selling:QuerySellingsByFilter", `{"foo":1}`}, errcode.Validation, "args.format"},
+		{[]string{"Selling:QuerySellingsByFilter", `{"minPrice":-1}`}, errcode.Validation, "filter.invalid"},
+		{[]string{"querySellingsByFilter", `{"foo":1}`}, errcode.Validation, "filter.invalid"},
+		{[]string{"queryRealEstateList", "a", "b", "c"}, errcode.Validation, "args.count"},
+		{[]string{"querySellingListByBuyer"}, errcode.Validation, "args.required"},
+		{[]string{"Account:Unknown"}, errcode.Validation, "function.unknown"},
+	}
+	for _, c := range cases {
+		k.MustFail(c.code, c.key, c.args...)
+	}
+	// contractapi转换参数出错时，错误详情中为合约声明的参数名
+	if e := k.MustFail(errcode.Validation, "args.format", "RealEstate:CreateRealEstate", testkit.Admin, testkit.Owner1, "120", "abc"); e.Details["arg"] != "livingSpace" {
+		t.Errorf("参数名不符合预期: %+v", e)
+	}
+	if e := k.MustFail(errcode.Validation, "args.format", "Selling:QuerySellingsByFilter", `{"foo":1}`); e.Details["arg"] != "filter" {
+		t.Errorf("参数名不符合预期: %+v", e)
+	}
+	// 未指定合约名时调用默认合约Account
+	k.MustDecode(&accounts, "QueryAccountList", "[]")
+	if len(accounts) == 0 {
+		t.Errorf("默认合约的查询结果为空")
+	}
+
+	var metadata struct {
+		Contracts map[string]struct {
+			Transactions []struct {
+				Name       string   `json:"name"`
+				Tag        []string `json:"tag"`
+				Parameters []struct {
+					Name   string                 `json:"name"`
+					Schema map[string]interface{} `json:"schema"`
+				} `json:"parameters"`
+			} `json:"transactions"`
+		} `json:"contracts"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]interface{} `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	k.MustDecode(&metadata, "org.hyperledger.fabric:GetMetadata")
+	// 每个交易函数都需要声明参数名，个数与元数据一致
+	for _, c := range api.Contracts() {
+		contract, ok := metadata.Contracts[c.GetName()]
+		if !ok {
+			t.Errorf("元数据缺少合约%s", c.GetName())
+		}
+		for _, tx := range contract.Transactions {
+			names, ok := c.(interface {
+				ParamNames(string) ([]string, bool)
+			}).ParamNames(tx.Name)
+			if !ok || len(names) != len(tx.Parameters) {
+				t.Errorf("%s:%s声明的参数名%v与元数据的参数个数%d不一致", c.GetName(), tx.Name, names, len(tx.Parameters))
+			}
+		}
+	}
+	found := false
+	for _, tx := range metadata.Contracts["RealEstate"].Transactions {
+		if tx.Name != "CreateRealEstate" {
+			continue
+		}
+		found = true
+		if fmt.Sprint(tx.Tag) != "[submit]" || len(tx.Parameters) != 4 || tx.Parameters[2].Schema["type"] != "number" {
+			t.Errorf("CreateRealEstate的元数据不符合预期: %+v", tx)
+		}
+	}
+	if !found {
+		t.Errorf("元数据缺少CreateRealEstate")
+	}
+	for _, tx := range metadata.Contracts["Selling"].Transactions {
+		if tx.Name == "QuerySellingList" && fmt.Sprint(tx.Tag) != "[evaluate]" {
+			t.Errorf("QuerySellingList应标记为evaluate: %+v", tx)
+		}
+	}
+	if _, ok := metadata.Components.Schemas["RealEstate"].Properties["realEstateId"]; !ok {
+		t.Errorf("元数据缺少RealEstate结构: %+v", metadata.Components.Schemas)
+	}
+}
+
+// 测试链码即服务：模拟peer连接链码服务，注册后调用交易函数
+func Test_ChaincodeServer(t *testing.T) {
+	server := &ccserver.Server{CCID: "fabric-realty_1.0:abc", Address: "127.0.0.1:0", CC: new(BlockChainRealEstate)}
+	if err := server.Listen(); err != nil {
+		t.Fatal(err)
+	}
+	go server.Serve()
+	defer server.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, server.Addr().String(), grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	stream, err := conn.NewStream(ctx, &grpc.StreamDesc{ServerStreams: true, ClientStreams: true}, "/protos.Chaincode/Connect")
+	if err != nil {
+		t.Fatal(err)
+	}
+	recv := func(want pb.ChaincodeMessage_Type) *pb.ChaincodeMessage {
+		msg := new(pb.ChaincodeMessage)
+		if err := stream.RecvMsg(msg); err != nil {
+			t.Fatal(err)
+		}
+		if msg.Type != want {
+			t.Fatalf("期望收到%s，实际%s: %s", want, msg.Type, msg.Payload)
+		}
+		return msg
+	}
+	send := func(msg *pb.ChaincodeMessage) {
+		if err := stream.SendMsg(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	register := recv(pb.ChaincodeMessage_REGISTER)
+	var id pb.ChaincodeID
+	if err := proto.Unmarshal(register.Payload, &id); err != nil || id.Name != server.CCID {
+		t.Fatalf("注册的链码ID不符合预期: %v %v", id.Name, err)
+	}
+	send(&pb.ChaincodeMessage{Type: pb.ChaincodeMessage_REGISTERED})
+	send(&pb.ChaincodeMessage{Type: pb.ChaincodeMessage_READY})
+
+	input, _ := proto.Marshal(&pb.ChaincodeInput{Args: [][]byte{[]byte("org.hyperledger.fabric:GetMetadata")}})
+	send(&pb.ChaincodeMessage{Type: pb.ChaincodeMessage_TRANSACTION, Txid: "tx1", ChannelId: "appchannel", Payload: input})
+	completed := recv(pb.ChaincodeMessage_COMPLETED)
+	var resp pb.Response
+	if err := proto.Unmarshal(completed.Payload, &resp); err != nil {
+		t.Fatal(err)
+	}
+	if completed.Txid != "tx1" || resp.Status != shim.OK || !bytes.Contains(resp.Payload, []byte(`"Ledger"`)) {
+		t.Errorf("交易结果不符合预期: %s %d %s", completed.Txid, resp.Status, resp.Payload)
+	}
+}
+
+// 测试私有数据集合：公开记录中没有余额和价格，其他组织只能看到公开部分
+func Test_PrivateData(t *testing.T) {
+	k := testkit.New(t)
+	realEstateList := createRealEstates(k)
+	target := realEstateList[0]
+	k.MustSucceed("createSelling", target.RealEstateID, target.Proprietor, "500000", "30")
+
+	accountKey, _ := k.Stub.CreateCompositeKey(model.AccountKey, []string{testkit.Owner1})
+	sellingKey, _ := k.Stub.CreateCompositeKey(model.SellingKey, []string{target.Proprietor, target.RealEstateID})
+	for _, c := range []struct {
+		key        string
+		collection string
+		field      string
+	}{
+		{accountKey, model.AccountCollection, `"balance"`},
+		{sellingKey, model.OfferCollection, `"price"`},
+	} {
+		public, private := k.Stub.State[c.key], k.Stub.PvtState[c.collection][c.key]
+		var doc model.Doc
+		if err := json.Unmarshal(public, &doc); err != nil || doc.PrivateHash == "" || doc.PrivateHash != utils.PrivateHash(private) {
+			t.Fatalf("%s的privateHash与私有数据不一致: %s", c.key, public)
+		}
+		if bytes.Contains(public, []byte(c.field)) || !bytes.Contains(private, []byte(c.field)) {
+			t.Errorf("%s应只在私有数据中: %s %s", c.field, public, private)
+		}
+	}
+	var account model.AccountPrivate
+	k.MustDecode(&account, "Account:QueryAccountPrivate", testkit.Owner1)
+	if account.Balance != testkit.InitialBalance || account.UserName == "" {
+		t.Errorf("账户私有部分不符合预期: %+v", account)
+	}
+	var offer model.OfferPrivate
+	k.MustDecode(&offer, "Selling:QueryOfferPrivate", target.Proprietor, target.RealEstateID)
+	if offer.Price != 500000 {
+		t.Errorf("销售私有部分不符合预期: %+v", offer)
+	}
+
+	//通过临时数据传入价格，交易参数和返回值中都没有价格
+	k.Transient = map[string][]byte{model.TransientOffer: []byte(`{"price":100}`)}
+	var selling model.Selling
+	k.MustDecode(&selling, "Selling:CreateSellingPrivate", realEstateList[2].RealEstateID, realEstateList[2].Proprietor, "30")
+	if selling.Price != 0 || k.Ledger().Selling(realEstateList[2].Proprietor, realEstateList[2].RealEstateID).Price != 100 {
+		t.Errorf("通过临时数据发起的销售不符合预期: %+v", selling)
+	}
+	k.MustFail(errcode.Validation, "transient.missing", "Selling:CreateSellingPrivate", realEstateList[3].RealEstateID, realEstateList[3].Proprietor, "30")
+	k.Transient = map[string][]byte{model.TransientOffer: []byte(`{"price":"100"}`)}
+	k.MustFail(errcode.Validation, "args.format", "Selling:CreateSellingPrivate", realEstateList[3].RealEstateID, realEstateList[3].Proprietor, "30")
+
+	//不在集合中的组织只能看到公开部分，不能读写私有数据
+	k.MSPID = "OtherMSP"
+	var accounts []model.Account
+	k.MustDecode(&accounts, "queryAccountList", testkit.Owner1)
+	if len(accounts) != 1 || accounts[0].Balance != 0 || accounts[0].UserName != "" || accounts[0].PrivateHash == "" {
+		t.Errorf("其他组织查询到的账户应只有公开部分: %+v", accounts)
+	}
+	k.MustFail(errcode.Forbidden, "private.forbidden", "Account:QueryAccountPrivate", testkit.Owner1)
+	k.MustFail(errcode.Forbidden, "private.forbidden", "Selling:QueryOfferPrivate", target.Proprietor, target.RealEstateID)
+	k.MustFail(errcode.Forbidden, "private.forbidden", "createSelling", realEstateList[3].RealEstateID, realEstateList[3].Proprietor, "100", "30")
+	//Taobao的peer节点保存私有数据，但Taobao的客户端不能读取
+	k.MSPID = "TaobaoMSP"
+	k.MustFail(errcode.Forbidden, "private.forbidden", "Account:QueryAccountPrivate", testkit.Owner1)
+	k.MSPID = "JDMSP"
+	k.MustDecode(&account, "Account:QueryAccountPrivate", testkit.Owner1)
+
+	//私有数据被篡改时读取报错
+	k.Invariants = nil
+	k.Stub.PvtState[model.AccountCollection][accountKey] = []byte(`{"balance":1,"userName":"x"}`)
+	if res := k.Invoke("queryAccountList", testkit.Owner1); res.Status == shim.OK {
+		t.Errorf("私有数据与哈希不一致时应报错: %s", res.Payload)
+	}
+
+	//Init的种子数据通过临时数据传入
+	seeded := testkit.New(t)
+	for key := range seeded.Stub.State {
+		delete(seeded.Stub.State, key)
+	}
+	seeded.Stub.Keys.Init()
+	seeded.Stub.PvtState = make(map[string]map[string][]byte)
+	seeded.Invariants = nil
+	seeded.Transient = map[string][]byte{model.TransientSeed: []byte(`{"accounts":[{"accountId":"a00000000001","userName":"物业","role":"admin"},` +
+		`{"accountId":"a00000000002","userName":"张三","balance":1000}]}`)}
+	if res := seeded.Init(); res.Status != shim.OK {
+		t.Fatalf("通过临时数据初始化失败: %s", res.Message)
+	}
+	if l := seeded.Ledger(); len(l.Accounts) != 2 || l.Balance("a00000000002") != 1000 || l.Account("a00000000002").UserName != "张三" {
+		t.Errorf("临时数据中的种子账户不符合预期: %+v", l.Accounts)
+	}
+	seeded.Transient = map[string][]byte{model.TransientSeed: []byte(`{"accounts":[]}`)}
+	if res := seeded.Init(`{"accounts":[]}`); res.Status == shim.OK {
+		t.Error("同时通过临时数据和参数传入种子时应报错")
+	}
 }
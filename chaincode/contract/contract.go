@@ -0,0 +1,154 @@
+package contract
+
+import (
+	"chaincode/api"
+	"chaincode/pkg/errcode"
+	"chaincode/pkg/events"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// BlockChainRealEstate 房地产交易链码，main中启动，也可以在进程内通过shimtest.MockStub调用
+// 交易函数按合约分组，由fabric-contract-api-go以"合约名:交易函数名"分发，见api.Contracts；改为合约之前的函数名仍然可用，见api.Legacy
+type BlockChainRealEstate struct {
+}
+
+// paramNames 合约声明的交易函数参数名，见api.Contract
+type paramNames interface {
+	GetName() string
+	ParamNames(function string) ([]string, bool)
+}
+
+var (
+	// chaincode 由各合约组成，启动时构建一次
+	chaincode, contracts = newChaincode()
+	// managingParameter contractapi转换参数出错时的消息前缀，之后为serializer返回的错误信封
+	managingParameter = regexp.MustCompile(`^Error managing parameter param(\d+)\. `)
+)
+
+func newChaincode() (*contractapi.ContractChaincode, map[string]paramNames) {
+	list := api.Contracts()
+	cc, err := contractapi.NewChaincode(list...)
+	if err != nil {
+		panic(fmt.Sprintf("构建合约出错: %s", err))
+	}
+	cc.TransactionSerializer = serializer{}
+	contracts := make(map[string]paramNames)
+	for _, c := range list {
+		contracts[c.GetName()] = c.(paramNames)
+	}
+	return cc, contracts
+}
+
+// Init 链码初始化，实例化参数为可选的种子数据json，见api.InitSeed
+func (t *BlockChainRealEstate) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	fmt.Println("链码初始化")
+	recorder := events.NewRecorder(stub)
+	_, args := recorder.GetFunctionAndParameters()
+	resp := api.InitSeed(recorder, args)
+	if resp.Status >= shim.ERRORTHRESHOLD {
+		return resp
+	}
+	if err := recorder.Flush(); err != nil {
+		return errcode.Response(err)
+	}
+	return resp
+}
+
+// Invoke 实现Invoke接口调用智能合约
+// 调用成功后将交易内记录的事件合并为一个链码事件写出
+func (t *BlockChainRealEstate) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	function, args := stub.GetFunctionAndParameters()
+	if target, adapt, ok := api.Legacy(function); ok {
+		if adapt != nil {
+			adapted, err := adapt(args)
+			if err != nil {
+				return errcode.Response(err)
+			}
+			args = adapted
+		}
+		function = target
+		stub = &legacyStub{ChaincodeStubInterface: stub, function: target, args: args}
+	}
+	recorder := events.NewRecorder(stub)
+	resp := chaincode.Invoke(recorder)
+	if resp.Status >= shim.ERRORTHRESHOLD {
+		return errcode.Response(t.normalize(function, resp.Message))
+	}
+	if err := recorder.Flush(); err != nil {
+		return errcode.Response(err)
+	}
+	return resp
+}
+
+// normalize 交易函数和guard返回的错误已是错误信封，contractapi自身的错误只有英文消息，转换为对应的错误码
+func (t *BlockChainRealEstate) normalize(function string, message string) error {
+	if e, ok := errcode.Parse(message); ok {
+		return e
+	}
+	if m := managingParameter.FindStringSubmatch(message); m != nil {
+		e, ok := errcode.Parse(message[len(m[0]):])
+		if !ok {
+			return errcode.New(errcode.Validation, "args.format", message)
+		}
+		if names := t.paramNames(function); names != nil {
+			index, _ := strconv.Atoi(m[1])
+			if index < len(names) {
+				e.Message = strings.Replace(e.Message, "param"+m[1], names[index], 1)
+				e.With("arg", names[index])
+			}
+		}
+		return e
+	}
+	switch {
+	case strings.HasPrefix(message, "Contract not found"), strings.HasPrefix(message, "Function "), strings.HasPrefix(message, "Blank function"):
+		return errcode.New(errcode.Validation, "function.unknown", fmt.Sprintf("没有该功能: %s", function))
+	case strings.HasPrefix(message, "Incorrect number of params"):
+		return errcode.ArgCount()
+	}
+	return errors.New(message)
+}
+
+// paramNames 交易函数声明的参数名，未指定合约名时为默认合约
+func (t *BlockChainRealEstate) paramNames(function string) []string {
+	contract := chaincode.DefaultContract
+	if i := strings.LastIndex(function, ":"); i >= 0 {
+		contract = function[:i]
+	}
+	c, ok := contracts[contract]
+	if !ok {
+		return nil
+	}
+	names, _ := c.ParamNames(api.FunctionName(function))
+	return names
+}
+
+// legacyStub 旧函数名调用时，以转换后的交易函数名和参数代替原来的函数名和参数
+type legacyStub struct {
+	shim.ChaincodeStubInterface
+	function string
+	args     []string
+}
+
+func (s *legacyStub) GetFunctionAndParameters() (string, []string) {
+	return s.function, s.args
+}
+
+func (s *legacyStub) GetStringArgs() []string {
+	return append([]string{s.function}, s.args...)
+}
+
+func (s *legacyStub) GetArgs() [][]byte {
+	args := [][]byte{[]byte(s.function)}
+	for _, arg := range s.args {
+		args = append(args, []byte(arg))
+	}
+	return args
+}
@@ -0,0 +1,99 @@
+package contract
+
+import (
+	"bytes"
+	"chaincode/pkg/errcode"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+
+	"github.com/hyperledger/fabric-contract-api-go/metadata"
+)
+
+// serializer 交易函数参数和返回值的转换，代替contractapi默认的JSONSerializer
+// 数值、布尔和json参数为空时为args.empty，结构体指针为空时为nil，表示可选参数；json不允许出现未知字段
+// 转换出错时返回错误信封，参数名为元数据中的param0、param1...，由BlockChainRealEstate换成合约声明的参数名
+type serializer struct{}
+
+// FromString 按参数类型转换字符串参数
+func (s serializer) FromString(raw string, t reflect.Type, param *metadata.ParameterMetadata, _ *metadata.ComponentMetadata) (value reflect.Value, err error) {
+	defer errcode.Envelope(&err)
+	name := "param"
+	if param != nil {
+		name = param.Name
+	}
+	if raw == "" {
+		switch t.Kind() {
+		case reflect.String:
+		case reflect.Ptr:
+			return reflect.Zero(t), nil
+		default:
+			return reflect.Value{}, errcode.ArgEmpty().With("arg", name)
+		}
+	}
+	value = reflect.New(t).Elem()
+	switch t.Kind() {
+	case reflect.String:
+		value.SetString(raw)
+	case reflect.Bool:
+		var b bool
+		if b, err = strconv.ParseBool(raw); err == nil {
+			value.SetBool(b)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		var i int64
+		if i, err = strconv.ParseInt(raw, 10, t.Bits()); err == nil {
+			value.SetInt(i)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		var u uint64
+		if u, err = strconv.ParseUint(raw, 10, t.Bits()); err == nil {
+			value.SetUint(u)
+		}
+	case reflect.Float32, reflect.Float64:
+		var f float64
+		if f, err = strconv.ParseFloat(raw, t.Bits()); err == nil {
+			value.SetFloat(f)
+		}
+	case reflect.Ptr:
+		ptr := reflect.New(t.Elem())
+		if err = decodeJSON(raw, ptr.Interface()); err == nil {
+			value = ptr
+		}
+	default:
+		err = decodeJSON(raw, value.Addr().Interface())
+	}
+	if err != nil {
+		return reflect.Value{}, errcode.ArgFormat(name, err)
+	}
+	return value, nil
+}
+
+// ToString 字符串原样返回，nil指针不返回内容，其余序列化为json
+func (s serializer) ToString(result reflect.Value, _ reflect.Type, _ *metadata.ReturnMetadata, _ *metadata.ComponentMetadata) (string, error) {
+	switch {
+	case result.Kind() == reflect.String:
+		return result.String(), nil
+	case result.Kind() == reflect.Ptr && result.IsNil():
+		return "", nil
+	}
+	bytes, err := json.Marshal(result.Interface())
+	if err != nil {
+		return "", errcode.Wrap(err, "序列化出错")
+	}
+	return string(bytes), nil
+}
+
+// decodeJSON 不允许出现未知字段
+func decodeJSON(raw string, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader([]byte(raw)))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return err
+	}
+	if decoder.More() {
+		return errors.New("json之后存在多余的内容")
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package model
+
+// MaxBatchRealEstates 单次批量新建房地产的最大条数，避免单个交易的读写集过大
+const MaxBatchRealEstates = 200
+
+// RealEstateRow 批量新建房地产的一行
+// Ref为外部编号，可不传，传入时同一编号只导入一次
+type RealEstateRow struct {
+	Ref         string  `json:"ref"`         //外部编号
+	Proprietor  string  `json:"proprietor"`  //所有者(业主AccountId)
+	TotalArea   float64 `json:"totalArea"`   //总面积
+	LivingSpace float64 `json:"livingSpace"` //生活空间
+}
+
+// 批量新建每一行的结果
+const (
+	RowCreated = "created" //新建成功
+	RowExists  = "exists"  //外部编号已导入过，返回已有的房地产
+	RowFailed  = "failed"  //校验失败，未写入账本
+)
+
+// RowError 某一行校验失败的原因，与链码错误信封的字段一致
+type RowError struct {
+	Code    string `json:"code"`
+	Key     string `json:"key"`
+	Message string `json:"message"`
+}
+
+// RowResult 批量新建某一行的结果，Index为该行在请求中的下标(从0开始)
+type RowResult struct {
+	Index      int         `json:"index"`
+	Ref        string      `json:"ref,omitempty"`
+	Status     string      `json:"status"`
+	RealEstate *RealEstate `json:"realEstate,omitempty"`
+	Error      *RowError   `json:"error,omitempty"`
+}
+
+// BatchResult 批量新建房地产的结果
+type BatchResult struct {
+	Created  int         `json:"created"`  //新建条数
+	Existing int         `json:"existing"` //已导入过的条数
+	Failed   int         `json:"failed"`   //校验失败条数
+	Results  []RowResult `json:"results"`  //每一行的结果，与请求顺序一致
+}
@@ -0,0 +1,23 @@
+package model
+
+// SellingFilter 销售筛选条件
+// 数值类条件为0时表示不限制，SellingStatus取SellingStatusConstant的键(如saleStart)
+type SellingFilter struct {
+	Seller        string  `json:"seller"`        //发起销售人、卖家(卖家AccountId)
+	SellingStatus string  `json:"sellingStatus"` //销售状态
+	MinPrice      float64 `json:"minPrice"`      //最低价格
+	MaxPrice      float64 `json:"maxPrice"`      //最高价格
+	MinArea       float64 `json:"minArea"`       //销售对象最小总面积
+	MaxArea       float64 `json:"maxArea"`       //销售对象最大总面积
+}
+
+// RealEstateFilter 房地产筛选条件
+// 数值类条件为0时表示不限制，Encumbrance为nil时表示不限制，否则只能为布尔值(contractapi不支持*bool类型的字段)
+type RealEstateFilter struct {
+	Proprietor     string      `json:"proprietor"`     //所有者(业主AccountId)
+	Encumbrance    interface{} `json:"encumbrance"`    //是否作为担保
+	MinTotalArea   float64     `json:"minTotalArea"`   //最小总面积
+	MaxTotalArea   float64     `json:"maxTotalArea"`   //最大总面积
+	MinLivingSpace float64     `json:"minLivingSpace"` //最小生活空间
+	MaxLivingSpace float64     `json:"maxLivingSpace"` //最大生活空间
+}
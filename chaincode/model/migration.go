@@ -0,0 +1,55 @@
+package model
+
+// SchemaKey 账本的记录结构版本及进行中的迁移
+// 种子数据写入时为当前版本，早于迁移机制的账本没有此记录，视为版本0
+const SchemaKey = "schema"
+
+// MaxMigrationBatch 单次migrate扫描的最大记录数，避免单个交易的读写集过大
+const MaxMigrationBatch = 500
+
+// SchemaState 账本的记录结构版本
+type SchemaState struct {
+	Version   int                `json:"version"`             //账本中全部记录已达到的结构版本
+	Migration *MigrationProgress `json:"migration,omitempty"` //进行中的迁移，全部完成后清空
+}
+
+// MigrationProgress 进行中的迁移，下一次migrate从Cursor之后继续
+type MigrationProgress struct {
+	To       int             `json:"to"`       //目标版本
+	Cursor   MigrationCursor `json:"cursor"`   //最后处理的记录
+	Scanned  int             `json:"scanned"`  //累计扫描的记录数
+	Migrated int             `json:"migrated"` //累计重写的记录数
+}
+
+// MigrationCursor 迁移的位置，按复合主键前缀、再按复合主键的顺序处理
+type MigrationCursor struct {
+	ObjectType string   `json:"objectType"`
+	Keys       []string `json:"keys"`
+}
+
+// MigrationChange 一条需要重写的记录
+type MigrationChange struct {
+	ObjectType  string   `json:"objectType"`
+	Keys        []string `json:"keys"`
+	FromVersion int      `json:"fromVersion"` //记录当前的结构版本
+	Steps       []string `json:"steps"`       //依次执行的迁移步骤
+}
+
+// MigrationReport 一次migrate的结果，DryRun时只报告不写入
+type MigrationReport struct {
+	From     int               `json:"from"`             //执行前账本的结构版本
+	To       int               `json:"to"`               //目标版本
+	DryRun   bool              `json:"dryRun"`           //是否只报告不写入
+	Done     bool              `json:"done"`             //是否已处理完全部记录
+	Cursor   *MigrationCursor  `json:"cursor,omitempty"` //未完成时下一批开始前的位置
+	Scanned  int               `json:"scanned"`          //本批扫描的记录数
+	Migrated int               `json:"migrated"`         //本批重写(DryRun时为需要重写)的记录数
+	Changes  []MigrationChange `json:"changes"`
+}
+
+// MigrationBatch 一批迁移后的记录，作为RecordsMigrated事件的Payload
+type MigrationBatch struct {
+	From    int          `json:"from"`
+	To      int          `json:"to"`
+	Entries []StateEntry `json:"entries"`
+}
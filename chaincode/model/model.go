@@ -1,10 +1,61 @@
 package model
 
+// Doc 所有写入账本的记录的公共字段
+// DocType用于在富查询、索引及区块浏览器中区分记录类型(复合主键前缀对它们不可见)
+// Version为记录的结构版本，用于后续结构升级
+// PrivateHash为私有数据集合中私有部分的哈希，只有PrivateFields中的记录有，见private.go
+// 三者均由utils.WriteLedger统一设置，不需要手动赋值
+type Doc struct {
+	DocType     string `json:"docType"`               //记录类型
+	Version     int    `json:"version"`               //结构版本
+	PrivateHash string `json:"privateHash,omitempty"` //私有部分的哈希
+}
+
+// SetDoc 设置记录类型和结构版本
+func (d *Doc) SetDoc(docType string, version int) {
+	d.DocType = docType
+	d.Version = version
+}
+
+// SetPrivateHash 设置私有部分的哈希
+func (d *Doc) SetPrivateHash(hash string) {
+	d.PrivateHash = hash
+}
+
+// Document 可写入账本的记录，嵌入Doc即可实现
+type Document interface {
+	SetDoc(docType string, version int)
+	SetPrivateHash(hash string)
+}
+
 // Account 账户，虚拟管理员和若干业主账号
 type Account struct {
-	AccountId string  `json:"accountId"` //账号ID
-	UserName  string  `json:"userName"`  //账号名
-	Balance   float64 `json:"balance"`   //余额
+	AccountId string  `json:"accountId"`      //账号ID
+	UserName  string  `json:"userName"`       //账号名
+	Balance   float64 `json:"balance"`        //余额
+	Role      string  `json:"role,omitempty"` //角色，旧记录为空
+	Doc
+}
+
+// 账户角色
+const (
+	RoleAdmin = "admin" //管理员
+	RoleOwner = "owner" //业主
+)
+
+// AccountIdAttr 平台账户的Fabric身份证书中账户ID的属性名，由应用层向Fabric CA注册身份时写入
+// 管理员操作以该属性确认操作人，见api.checkAdmin
+const AccountIdAttr = "realty.accountId"
+
+// AdminUserName 旧记录没有角色，账号名为管理员的即管理员
+const AdminUserName = "管理员"
+
+// IsAdmin 是否管理员
+func (a *Account) IsAdmin() bool {
+	if a.Role != "" {
+		return a.Role == RoleAdmin
+	}
+	return a.UserName == AdminUserName
 }
 
 // RealEstate 房地产作为担保出售、捐赠或质押时Encumbrance为true，默认状态false。
@@ -16,6 +67,7 @@ type RealEstate struct {
 	Encumbrance  bool    `json:"encumbrance"`  //是否作为担保
 	TotalArea    float64 `json:"totalArea"`    //总面积
 	LivingSpace  float64 `json:"livingSpace"`  //生活空间
+	Doc
 }
 
 // Selling 销售要约
@@ -23,13 +75,15 @@ type RealEstate struct {
 // 买家初始为空
 // Seller和ObjectOfSale一起作为复合键,保证可以通过seller查询到名下所有发起的销售
 type Selling struct {
-	ObjectOfSale  string  `json:"objectOfSale"`  //销售对象(正在出售的房地产RealEstateID)
-	Seller        string  `json:"seller"`        //发起销售人、卖家(卖家AccountId)
-	Buyer         string  `json:"buyer"`         //参与销售人、买家(买家AccountId)
-	Price         float64 `json:"price"`         //价格
-	CreateTime    string  `json:"createTime"`    //创建时间
-	SalePeriod    int     `json:"salePeriod"`    //智能合约的有效期(单位为天)
-	SellingStatus string  `json:"sellingStatus"` //销售状态
+	ObjectOfSale  string  `json:"objectOfSale"`       //销售对象(正在出售的房地产RealEstateID)
+	Seller        string  `json:"seller"`             //发起销售人、卖家(卖家AccountId)
+	Buyer         string  `json:"buyer"`              //参与销售人、买家(买家AccountId)
+	Price         float64 `json:"price"`              //价格
+	CreateTime    string  `json:"createTime"`         //创建时间
+	SalePeriod    int     `json:"salePeriod"`         //智能合约的有效期(单位为天)
+	SellingStatus string  `json:"sellingStatus"`      //销售状态
+	DoneTime      string  `json:"doneTime,omitempty"` //卖家确认收款的时间，旧记录为空
+	Doc
 }
 
 // SellingStatusConstant 销售状态
@@ -45,22 +99,27 @@ var SellingStatusConstant = func() map[string]string {
 
 // SellingBuy 买家参与销售
 // 销售对象不能是买家发起的
-// Buyer和CreateTime作为复合键,保证可以通过buyer查询到名下所有参与的销售
+// Buyer、CreateTime和TxID作为复合键,保证可以通过buyer查询到名下所有参与的销售
+// CreateTime只精确到秒，同一买家同一秒内的多次购买由TxID区分
 type SellingBuy struct {
-	Buyer      string  `json:"buyer"`      //参与销售人、买家(买家AccountId)
-	CreateTime string  `json:"createTime"` //创建时间
-	Selling    Selling `json:"selling"`    //销售对象
+	Buyer      string  `json:"buyer"`          //参与销售人、买家(买家AccountId)
+	CreateTime string  `json:"createTime"`     //创建时间
+	TxID       string  `json:"txId,omitempty"` //购买时的交易ID，旧记录为空
+	Selling    Selling `json:"selling"`        //销售对象
+	Doc
 }
 
 // Donating 捐赠要约
 // 需要确定ObjectOfDonating是否属于Donor
 // 需要指定受赠人Grantee，并等待受赠人同意接收
 type Donating struct {
-	ObjectOfDonating string `json:"objectOfDonating"` //捐赠对象(正在捐赠的房地产RealEstateID)
-	Donor            string `json:"donor"`            //捐赠人(捐赠人AccountId)
-	Grantee          string `json:"grantee"`          //受赠人(受赠人AccountId)
-	CreateTime       string `json:"createTime"`       //创建时间
-	DonatingStatus   string `json:"donatingStatus"`   //捐赠状态
+	ObjectOfDonating string `json:"objectOfDonating"`   //捐赠对象(正在捐赠的房地产RealEstateID)
+	Donor            string `json:"donor"`              //捐赠人(捐赠人AccountId)
+	Grantee          string `json:"grantee"`            //受赠人(受赠人AccountId)
+	CreateTime       string `json:"createTime"`         //创建时间
+	DonatingStatus   string `json:"donatingStatus"`     //捐赠状态
+	DoneTime         string `json:"doneTime,omitempty"` //受赠人确认受赠的时间，旧记录为空
+	Doc
 }
 
 // DonatingStatusConstant 捐赠状态
@@ -73,10 +132,23 @@ var DonatingStatusConstant = func() map[string]string {
 }
 
 // DonatingGrantee 供受赠人查询的
+// Grantee、CreateTime和TxID作为复合键，同一受赠人同一秒内收到的多次捐赠由TxID区分
 type DonatingGrantee struct {
-	Grantee    string   `json:"grantee"`    //受赠人(受赠人AccountId)
-	CreateTime string   `json:"createTime"` //创建时间
-	Donating   Donating `json:"donating"`   //捐赠对象
+	Grantee    string   `json:"grantee"`        //受赠人(受赠人AccountId)
+	CreateTime string   `json:"createTime"`     //创建时间
+	TxID       string   `json:"txId,omitempty"` //发起捐赠时的交易ID，旧记录为空
+	Donating   Donating `json:"donating"`       //捐赠对象
+	Doc
+}
+
+// ImportRef 批量导入时的外部编号(如原登记系统的产权证号)
+// Ref作为复合键,保证同一外部编号只导入一次,导入中断后重新提交不会重复新建房地产
+type ImportRef struct {
+	Ref          string `json:"ref"`          //外部编号
+	RealEstateID string `json:"realEstateId"` //导入生成的房地产ID
+	Proprietor   string `json:"proprietor"`   //导入时的所有者
+	TxID         string `json:"txId"`         //导入时的交易ID
+	Doc
 }
 
 const (
@@ -86,4 +158,33 @@ const (
 	SellingBuyKey      = "selling-buy-key"
 	DonatingKey        = "donating-key"
 	DonatingGranteeKey = "donating-grantee-key"
+	ImportRefKey       = "import-ref-key"
 )
+
+// 记录类型
+const (
+	AccountDocType         = "account"
+	RealEstateDocType      = "realEstate"
+	SellingDocType         = "selling"
+	SellingBuyDocType      = "sellingBuy"
+	DonatingDocType        = "donating"
+	DonatingGranteeDocType = "donatingGrantee"
+	ImportRefDocType       = "importRef"
+)
+
+// SchemaVersion 当前记录结构版本，没有docType的旧记录视为版本0
+// 修改记录结构时递增，并在api/migrate.go的migrations中追加对应的迁移步骤
+// 2: 账户增加角色
+// 3: 账号名、余额和销售价格移入私有数据集合
+const SchemaVersion = 3
+
+// DocTypes 复合主键前缀与记录类型的对应关系
+var DocTypes = map[string]string{
+	AccountKey:         AccountDocType,
+	RealEstateKey:      RealEstateDocType,
+	SellingKey:         SellingDocType,
+	SellingBuyKey:      SellingBuyDocType,
+	DonatingKey:        DonatingDocType,
+	DonatingGranteeKey: DonatingGranteeDocType,
+	ImportRefKey:       ImportRefDocType,
+}
@@ -0,0 +1,54 @@
+package model
+
+// 私有数据集合，定义见network/collections_config.json
+// 两个集合的成员都是JD组织及Taobao组织的peer节点：业务数据由JD的应用写入和读取，
+// Taobao的peer保存私有数据以便在AND背书策略下执行链码，Taobao的客户端和管理员不能读写，通道上的其他节点只有Fabric记录的哈希
+const (
+	AccountCollection = "accountPrivate" //账户的账号名和余额
+	OfferCollection   = "offerPrivate"   //销售的价格
+)
+
+// PrivateReaders 可以读写私有数据的调用者所在组织的MSP ID，与collections_config.json中集合的member成员保持一致
+var PrivateReaders = []string{"JDMSP"}
+
+// PrivateSpec 记录中保存在私有数据集合中的字段
+// Fields为json字段名，嵌套记录的字段用"."分隔，如selling.price
+type PrivateSpec struct {
+	Collection string
+	Fields     []string
+}
+
+// PrivateFields 复合主键前缀对应的私有字段
+// 写入时从公开记录中移出，以相同的复合主键写入私有数据集合，公开记录的privateHash为私有部分的SHA-256
+var PrivateFields = map[string]PrivateSpec{
+	AccountKey:    {Collection: AccountCollection, Fields: []string{"userName", "balance"}},
+	SellingKey:    {Collection: OfferCollection, Fields: []string{"price"}},
+	SellingBuyKey: {Collection: OfferCollection, Fields: []string{"selling.price"}},
+}
+
+// 临时数据(transient map)的键，临时数据不写入区块，私有字段应通过临时数据传入
+const (
+	TransientSeed  = "seed"  //Init的种子数据，格式同Seed
+	TransientOffer = "offer" //发起销售的价格，格式同OfferInput
+)
+
+// OfferInput 通过临时数据传入的销售价格
+type OfferInput struct {
+	Price float64 `json:"price"` //价格
+}
+
+// AccountPrivate 账户的私有部分，只返回给PrivateReaders中的组织
+type AccountPrivate struct {
+	AccountId   string  `json:"accountId"`   //账号ID
+	UserName    string  `json:"userName"`    //账号名
+	Balance     float64 `json:"balance"`     //余额
+	PrivateHash string  `json:"privateHash"` //公开记录中的哈希，与私有部分一致
+}
+
+// OfferPrivate 销售的私有部分，只返回给PrivateReaders中的组织
+type OfferPrivate struct {
+	ObjectOfSale string  `json:"objectOfSale"` //销售对象
+	Seller       string  `json:"seller"`       //卖家
+	Price        float64 `json:"price"`        //价格
+	PrivateHash  string  `json:"privateHash"`  //公开记录中的哈希，与私有部分一致
+}
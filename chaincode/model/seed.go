@@ -0,0 +1,51 @@
+package model
+
+// SeedKey 记录Init写入的种子数据，存在时不再写入
+const SeedKey = "seed"
+
+// Seed 链码Init的种子数据，以json作为实例化参数传入，不传时使用DefaultSeed
+type Seed struct {
+	Accounts    []SeedAccount    `json:"accounts"`
+	RealEstates []SeedRealEstate `json:"realEstates"`
+	MoneySupply float64          `json:"moneySupply"` //货币总量，不为0时必须等于各账户初始余额之和
+}
+
+// SeedAccount 初始账户，Role为空时为业主
+type SeedAccount struct {
+	AccountId string  `json:"accountId"`
+	UserName  string  `json:"userName"`
+	Role      string  `json:"role"`
+	Balance   float64 `json:"balance"`
+}
+
+// SeedRealEstate 初始房地产，所有者必须是种子数据中的业主
+type SeedRealEstate struct {
+	RealEstateID string  `json:"realEstateId"`
+	Proprietor   string  `json:"proprietor"`
+	TotalArea    float64 `json:"totalArea"`
+	LivingSpace  float64 `json:"livingSpace"`
+}
+
+// SeedRecord Init写入种子数据的记录，Digest为种子数据的SHA-256，用相同的种子重复Init不会报错
+type SeedRecord struct {
+	Digest      string  `json:"digest"`
+	TxID        string  `json:"txId"`
+	Accounts    int     `json:"accounts"`
+	RealEstates int     `json:"realEstates"`
+	MoneySupply float64 `json:"moneySupply"`
+}
+
+// DefaultSeed 演示用的管理员和5个业主账户，每个业主余额5000000
+func DefaultSeed() *Seed {
+	seed := &Seed{Accounts: []SeedAccount{{AccountId: "5feceb66ffc8", UserName: AdminUserName, Role: RoleAdmin}}}
+	for i, accountId := range []string{"6b86b273ff34", "d4735e3a265e", "4e07408562be", "4b227777d4dd", "ef2d127de37b"} {
+		seed.Accounts = append(seed.Accounts, SeedAccount{
+			AccountId: accountId,
+			UserName:  []string{"①号业主", "②号业主", "③号业主", "④号业主", "⑤号业主"}[i],
+			Role:      RoleOwner,
+			Balance:   5000000,
+		})
+	}
+	seed.MoneySupply = 25000000
+	return seed
+}
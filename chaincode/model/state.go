@@ -0,0 +1,32 @@
+package model
+
+import "encoding/json"
+
+// StateDumpVersion 账本导出文件的格式版本，结构发生不兼容变化时递增
+// 应用层的镜像定义见application/server/model/state.go，两边需同步修改
+const StateDumpVersion = 1
+
+// StateImportedKey 记录importState已执行，存在时不能再导入
+const StateImportedKey = "state-imported"
+
+// StateDump 链码的全部状态，由exportState导出，importState在新网络中恢复
+// Entries按复合主键前缀、再按复合主键排序，相同的账本状态导出相同的内容
+type StateDump struct {
+	Version       int          `json:"version"`       //导出文件的格式版本
+	SchemaVersion int          `json:"schemaVersion"` //导出时链码的记录结构版本
+	Entries       []StateEntry `json:"entries"`
+}
+
+// StateEntry 账本中的一条记录，Value为账本中的原始json
+type StateEntry struct {
+	ObjectType string          `json:"objectType"` //复合主键前缀
+	Keys       []string        `json:"keys"`       //复合主键的各部分
+	Value      json.RawMessage `json:"value"`
+}
+
+// StateImport importState的执行记录
+type StateImport struct {
+	Operator string         `json:"operator"` //执行导入的管理员
+	TxID     string         `json:"txId"`     //导入时的交易ID
+	Counts   map[string]int `json:"counts"`   //各记录类型导入的条数
+}
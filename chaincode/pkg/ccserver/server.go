@@ -0,0 +1,141 @@
+package ccserver
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
+)
+
+// 链码即服务(chaincode-as-a-service)：链码作为独立进程监听地址，由peer通过外部构建器连接过来
+// peer调用链码的protos.Chaincode/Connect服务，由shim.ChaincodeServer处理注册和交易；
+// 与shim.ChaincodeServer.Start不同，监听和TLS在此配置，便于使用随机端口并读取TLS文件
+
+// 环境变量，与fabric-samples中链码即服务的约定一致
+const (
+	EnvAddress      = "CHAINCODE_SERVER_ADDRESS" //监听地址，设置时以服务方式启动
+	EnvCCID         = "CHAINCODE_ID"             //peer lifecycle chaincode queryinstalled返回的package ID
+	EnvTLSDisabled  = "CHAINCODE_TLS_DISABLED"   //为true时不启用TLS
+	EnvTLSKey       = "CHAINCODE_TLS_KEY"        //服务端私钥文件
+	EnvTLSCert      = "CHAINCODE_TLS_CERT"       //服务端证书文件
+	EnvClientCACert = "CHAINCODE_CLIENT_CA_CERT" //校验peer客户端证书的CA文件，为空时不校验客户端证书
+)
+
+// Server 链码服务
+type Server struct {
+	CCID    string
+	Address string
+	CC      shim.Chaincode
+	TLS     *tls.Config //为nil时不启用TLS
+
+	listener net.Listener
+	server   *grpc.Server
+}
+
+// FromEnv 按环境变量创建链码服务，未设置CHAINCODE_SERVER_ADDRESS时返回nil，此时应使用shim.Start
+func FromEnv(cc shim.Chaincode) (*Server, error) {
+	address := os.Getenv(EnvAddress)
+	if address == "" {
+		return nil, nil
+	}
+	s := &Server{CCID: os.Getenv(EnvCCID), Address: address, CC: cc}
+	if s.CCID == "" {
+		return nil, errors.New(fmt.Sprintf("以服务方式启动时必须设置%s", EnvCCID))
+	}
+	if strings.EqualFold(os.Getenv(EnvTLSDisabled), "true") {
+		return s, nil
+	}
+	keyFile, certFile := os.Getenv(EnvTLSKey), os.Getenv(EnvTLSCert)
+	if keyFile == "" || certFile == "" {
+		return nil, errors.New(fmt.Sprintf("启用TLS时必须设置%s和%s，不启用时设置%s=true", EnvTLSKey, EnvTLSCert, EnvTLSDisabled))
+	}
+	config, err := loadTLS(keyFile, certFile, os.Getenv(EnvClientCACert))
+	if err != nil {
+		return nil, err
+	}
+	s.TLS = config
+	return s, nil
+}
+
+// loadTLS 读取服务端证书，指定了客户端CA时要求并校验peer的客户端证书
+func loadTLS(keyFile, certFile, clientCAFile string) (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("读取TLS证书出错: %s", err))
+	}
+	config := &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}
+	if clientCAFile == "" {
+		return config, nil
+	}
+	caPEM, err := ioutil.ReadFile(clientCAFile)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("读取客户端CA证书出错: %s", err))
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caPEM) {
+		return nil, errors.New(fmt.Sprintf("客户端CA证书%s中没有可用的证书", clientCAFile))
+	}
+	config.ClientCAs = pool
+	config.ClientAuth = tls.RequireAndVerifyClientCert
+	return config, nil
+}
+
+// Listen 监听地址，Address为":0"等随机端口时可通过Addr获取实际地址
+func (s *Server) Listen() error {
+	if s.CCID == "" || s.CC == nil {
+		return errors.New("链码服务缺少CCID或链码实现")
+	}
+	listener, err := net.Listen("tcp", s.Address)
+	if err != nil {
+		return errors.New(fmt.Sprintf("监听%s出错: %s", s.Address, err))
+	}
+	// keepalive与peer连接链码时的设置一致
+	opts := []grpc.ServerOption{
+		grpc.KeepaliveParams(keepalive.ServerParameters{Time: time.Minute, Timeout: 20 * time.Second}),
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{MinTime: time.Minute, PermitWithoutStream: true}),
+	}
+	if s.TLS != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(s.TLS)))
+	}
+	s.listener = listener
+	s.server = grpc.NewServer(opts...)
+	pb.RegisterChaincodeServer(s.server, &shim.ChaincodeServer{CCID: s.CCID, CC: s.CC})
+	return nil
+}
+
+// Addr 实际监听的地址
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// Serve 处理peer的连接，直到Stop或出错
+func (s *Server) Serve() error {
+	return s.server.Serve(s.listener)
+}
+
+// Start 监听并处理peer的连接
+func (s *Server) Start() error {
+	if err := s.Listen(); err != nil {
+		return err
+	}
+	fmt.Printf("链码服务%s监听%s\n", s.CCID, s.Addr())
+	return s.Serve()
+}
+
+// Stop 停止服务并断开全部连接
+func (s *Server) Stop() {
+	if s.server != nil {
+		s.server.Stop()
+	}
+}
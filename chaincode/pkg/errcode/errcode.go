@@ -0,0 +1,156 @@
+package errcode
+
+import (
+	"chaincode/model"
+	"chaincode/pkg/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// Code 机器可读的错误码，应用层据此转换为HTTP状态码
+type Code string
+
+const (
+	Validation        Code = "VALIDATION"         //参数校验失败
+	Unauthenticated   Code = "UNAUTHENTICATED"    //操作人身份无法确认
+	Forbidden         Code = "FORBIDDEN"          //操作人权限不足
+	NotFound          Code = "NOT_FOUND"          //记录不存在
+	Conflict          Code = "CONFLICT"           //记录状态冲突
+	InsufficientFunds Code = "INSUFFICIENT_FUNDS" //余额不足
+	Internal          Code = "INTERNAL"           //链码内部错误
+)
+
+// Error 链码错误信封，序列化为json后作为shim.Error的消息返回
+// Key为消息键，应用层根据它给出中英文提示，Message为链码给出的中文描述
+type Error struct {
+	Code    Code                   `json:"code"`
+	Key     string                 `json:"key"`
+	Message string                 `json:"message"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// With 附加错误详情
+func (e *Error) With(key string, value interface{}) *Error {
+	if e.Details == nil {
+		e.Details = make(map[string]interface{})
+	}
+	e.Details[key] = value
+	return e
+}
+
+// New 创建错误
+func New(code Code, key string, message string) *Error {
+	return &Error{Code: code, Key: key, Message: message}
+}
+
+// From 将任意错误转换为错误信封，仓库返回的记录不存在、记录冲突、没有私有数据权限会转换为对应的错误码
+func From(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	var notFound *utils.NotFoundError
+	if errors.As(err, &notFound) {
+		return New(NotFound, docKey(notFound.ObjectType, "notFound"), err.Error()).
+			With("objectType", notFound.ObjectType).With("keys", notFound.Keys)
+	}
+	var conflict *utils.ConflictError
+	if errors.As(err, &conflict) {
+		return New(Conflict, docKey(conflict.ObjectType, "conflict"), err.Error()).
+			With("objectType", conflict.ObjectType).With("keys", conflict.Keys)
+	}
+	var private *utils.PrivateForbiddenError
+	if errors.As(err, &private) {
+		return New(Forbidden, "private.forbidden", err.Error()).
+			With("objectType", private.ObjectType).With("mspId", private.MSPID)
+	}
+	return New(Internal, "internal", err.Error())
+}
+
+// Wrap 为错误添加上下文描述，保留原有的错误码、消息键和详情
+func Wrap(err error, message string) *Error {
+	e := From(err)
+	return &Error{Code: e.Code, Key: e.Key, Message: fmt.Sprintf("%s: %s", message, e.Message), Details: e.Details}
+}
+
+// Response 以错误信封的形式返回链码错误
+func Response(err error) pb.Response {
+	bytes, marshalErr := json.Marshal(From(err))
+	if marshalErr != nil {
+		return shim.Error(err.Error())
+	}
+	return shim.Error(string(bytes))
+}
+
+// envelope Error()为错误信封的json，fabric-contract-api-go只把交易函数返回错误的Error()写入shim.Error
+type envelope struct {
+	err *Error
+}
+
+func (e envelope) Error() string {
+	bytes, err := json.Marshal(e.err)
+	if err != nil {
+		return e.err.Message
+	}
+	return string(bytes)
+}
+
+func (e envelope) Unwrap() error {
+	return e.err
+}
+
+// Envelope 把交易函数返回的错误转换为错误信封，交易函数以命名的返回值defer调用
+func Envelope(err *error) {
+	if *err == nil {
+		return
+	}
+	if _, ok := (*err).(envelope); ok {
+		return
+	}
+	*err = envelope{err: From(*err)}
+}
+
+// Parse 解析shim.Error中的错误信封，消息不是错误信封时返回false
+func Parse(message string) (*Error, bool) {
+	e := new(Error)
+	if err := json.Unmarshal([]byte(message), e); err != nil || e.Code == "" || e.Key == "" {
+		return nil, false
+	}
+	return e, true
+}
+
+// docKey 根据复合主键前缀生成消息键，如selling.notFound
+func docKey(objectType string, suffix string) string {
+	if docType, ok := model.DocTypes[objectType]; ok {
+		return docType + "." + suffix
+	}
+	return "record." + suffix
+}
+
+// ArgCount 参数个数不满足
+func ArgCount() *Error {
+	return New(Validation, "args.count", "参数个数不满足")
+}
+
+// ArgEmpty 参数存在空值
+func ArgEmpty() *Error {
+	return New(Validation, "args.empty", "参数存在空值")
+}
+
+// ArgFormat 参数格式转换出错
+func ArgFormat(name string, err error) *Error {
+	return New(Validation, "args.format", fmt.Sprintf("%s参数格式转换出错: %s", name, err)).With("arg", name)
+}
+
+// StatusUnsupported 状态不支持
+func StatusUnsupported(status string) *Error {
+	return New(Validation, "status.unsupported", fmt.Sprintf("%s状态不支持", status)).With("status", status)
+}
@@ -0,0 +1,73 @@
+package events
+
+import (
+	"chaincode/model"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+)
+
+// Recorder 收集一笔交易内的事件，交易成功后由Flush合并为一个EventBatch写出
+type Recorder struct {
+	shim.ChaincodeStubInterface
+	events []model.Event
+}
+
+// NewRecorder 包装stub，之后的链码调用都应使用返回的Recorder
+func NewRecorder(stub shim.ChaincodeStubInterface) *Recorder {
+	return &Recorder{ChaincodeStubInterface: stub}
+}
+
+// Emit 记录事件，accounts为受影响的账户
+// stub不是Recorder时(如单独测试某个api)，直接写出只含该事件的EventBatch
+func Emit(stub shim.ChaincodeStubInterface, eventType model.EventType, accounts []string, payload interface{}) error {
+	payloadByte, err := json.Marshal(payload)
+	if err != nil {
+		return errors.New(fmt.Sprintf("%s-序列化事件出错: %s", eventType, err))
+	}
+	event := model.Event{Type: eventType, Accounts: accounts, Payload: payloadByte}
+	if recorder, ok := stub.(*Recorder); ok {
+		recorder.events = append(recorder.events, event)
+		return nil
+	}
+	return setEvent(stub, []model.Event{event})
+}
+
+// Events 已记录的事件
+func (r *Recorder) Events() []model.Event {
+	return r.events
+}
+
+// Flush 写出已记录的事件，没有事件时不写出
+func (r *Recorder) Flush() error {
+	if len(r.events) == 0 {
+		return nil
+	}
+	if err := setEvent(r.ChaincodeStubInterface, r.events); err != nil {
+		return err
+	}
+	r.events = nil
+	return nil
+}
+
+func setEvent(stub shim.ChaincodeStubInterface, events []model.Event) error {
+	batch := model.EventBatch{
+		Version: model.EventVersion,
+		TxID:    stub.GetTxID(),
+		Events:  events,
+	}
+	if txTime, err := stub.GetTxTimestamp(); err == nil && txTime != nil {
+		batch.Timestamp = time.Unix(txTime.GetSeconds(), int64(txTime.GetNanos())).Local().Format("2006-01-02 15:04:05")
+	}
+	batchByte, err := json.Marshal(batch)
+	if err != nil {
+		return errors.New(fmt.Sprintf("序列化事件出错: %s", err))
+	}
+	if err := stub.SetEvent(model.EventName, batchByte); err != nil {
+		return errors.New(fmt.Sprintf("写出事件出错: %s", err))
+	}
+	return nil
+}
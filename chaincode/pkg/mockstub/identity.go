@@ -0,0 +1,79 @@
+package mockstub
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-chaincode-go/pkg/attrmgr"
+	"github.com/hyperledger/fabric-protos-go/msp"
+)
+
+// accountIdAttr 与链码model.AccountIdAttr一致
+const accountIdAttr = "realty.accountId"
+
+var (
+	certMu sync.Mutex
+	certs  = make(map[[2]string][]byte) // (MSP ID, 账户ID) -> 序列化身份，生成证书较慢，按调用者缓存
+)
+
+// Identity 只有MSP ID的序列化身份，没有证书
+func Identity(mspID string) []byte {
+	creator, err := proto.Marshal(&msp.SerializedIdentity{Mspid: mspID})
+	if err != nil {
+		panic(err)
+	}
+	return creator
+}
+
+// AccountIdentity 带自签名证书的序列化身份，证书中以Fabric CA的格式写入realty.accountId属性
+// accountId为空时同Identity
+func AccountIdentity(mspID string, accountId string) []byte {
+	if accountId == "" {
+		return Identity(mspID)
+	}
+	certMu.Lock()
+	defer certMu.Unlock()
+	key := [2]string{mspID, accountId}
+	if creator, ok := certs[key]; ok {
+		return creator
+	}
+	creator, err := proto.Marshal(&msp.SerializedIdentity{Mspid: mspID, IdBytes: certificate(accountId)})
+	if err != nil {
+		panic(err)
+	}
+	certs[key] = creator
+	return creator
+}
+
+// certificate 生成带realty.accountId属性的自签名证书(PEM)
+func certificate(accountId string) []byte {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	attrs, err := json.Marshal(&attrmgr.Attributes{Attrs: map[string]string{accountIdAttr: accountId}})
+	if err != nil {
+		panic(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:    big.NewInt(time.Now().UnixNano()),
+		Subject:         pkix.Name{CommonName: accountId, OrganizationalUnit: []string{"client"}},
+		NotBefore:       time.Now().Add(-time.Hour),
+		NotAfter:        time.Now().Add(24 * time.Hour),
+		ExtraExtensions: []pkix.Extension{{Id: attrmgr.AttrOID, Value: attrs}},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &priv.PublicKey, priv)
+	if err != nil {
+		panic(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
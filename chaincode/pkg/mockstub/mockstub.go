@@ -0,0 +1,84 @@
+package mockstub
+
+import (
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// Stub 补齐shimtest.MockStub未实现的临时数据和私有数据的删除，调用者身份使用MockStub.Creator
+// 链码按调用者所在组织决定能否读写私有数据，在MockStub上运行时需要由Chaincode包装
+type Stub struct {
+	*shimtest.MockStub
+	Transient  map[string][]byte //本次调用的临时数据
+	QueryError error             //不为nil时富查询返回该错误，模拟CouchDB出错；为nil时与MockStub一致返回not implemented
+}
+
+// GetQueryResult 富查询，MockStub未实现
+func (s *Stub) GetQueryResult(query string) (shim.StateQueryIteratorInterface, error) {
+	if s.QueryError != nil {
+		return nil, s.QueryError
+	}
+	return s.MockStub.GetQueryResult(query)
+}
+
+// GetTransient 本次调用的临时数据
+func (s *Stub) GetTransient() (map[string][]byte, error) {
+	return s.Transient, nil
+}
+
+// DelPrivateData 删除私有数据，MockStub未实现
+func (s *Stub) DelPrivateData(collection string, key string) error {
+	delete(s.PvtState[collection], key)
+	return nil
+}
+
+// Chaincode 包装链码，调用时以Stub代替MockStub，调用者固定为MSPID所在组织
+type Chaincode struct {
+	CC        shim.Chaincode
+	MSPID     string
+	Account   string            //调用者证书中的realty.accountId，为空时调用者没有证书
+	Transient map[string][]byte //下一次调用的临时数据，调用后清空
+}
+
+// Init 以Stub调用链码的Init
+func (c *Chaincode) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	return c.CC.Init(c.wrap(stub))
+}
+
+// Invoke 以Stub调用链码的Invoke
+func (c *Chaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	return c.CC.Invoke(c.wrap(stub))
+}
+
+func (c *Chaincode) wrap(stub shim.ChaincodeStubInterface) shim.ChaincodeStubInterface {
+	transient := c.Transient
+	c.Transient = nil
+	mock := stub.(*shimtest.MockStub)
+	mock.Creator = AccountIdentity(c.MSPID, c.Account)
+	return &Stub{MockStub: mock, Transient: transient}
+}
+
+// PrivateState 私有数据的副本，MockStub的写入直接生效，失败的调用需要与世界状态一起回滚
+type PrivateState map[string]map[string][]byte
+
+// CopyPrivate 复制MockStub的私有数据
+func CopyPrivate(stub *shimtest.MockStub) PrivateState {
+	return PrivateState(stub.PvtState).copy()
+}
+
+// Restore 把MockStub的私有数据恢复为副本
+func (s PrivateState) Restore(stub *shimtest.MockStub) {
+	stub.PvtState = s.copy()
+}
+
+func (s PrivateState) copy() PrivateState {
+	c := make(PrivateState, len(s))
+	for collection, values := range s {
+		c[collection] = make(map[string][]byte, len(values))
+		for key, value := range values {
+			c[collection][key] = value
+		}
+	}
+	return c
+}
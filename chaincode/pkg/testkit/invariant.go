@@ -0,0 +1,195 @@
+package testkit
+
+import (
+	"chaincode/model"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// Invariant 全局不变量，每次调用链码后对整个账本检查
+type Invariant struct {
+	Name  string
+	Check func(l *Ledger) error
+}
+
+// DefaultInvariants 资金守恒、担保状态与进行中的交易一致、房产归属索引一致、进行中的交易可以被买家和受赠人查到
+func DefaultInvariants(total float64) []Invariant {
+	return []Invariant{
+		{Name: "资金守恒", Check: MoneyConserved(total)},
+		{Name: "担保状态", Check: EncumbranceConsistent},
+		{Name: "归属索引", Check: OwnerIndexConsistent},
+		{Name: "参与记录", Check: ParticipationConsistent},
+	}
+}
+
+// Escrow 交付中的销售由买家预付、尚未转给卖家的价款
+func (l *Ledger) Escrow() float64 {
+	var escrow float64
+	for _, s := range l.Sellings {
+		if s.SellingStatus == model.SellingStatusConstant()["delivery"] {
+			escrow += s.Price
+		}
+	}
+	return escrow
+}
+
+// MoneyConserved 资金守恒：全部账户余额加上交付中托管的价款始终等于初始总额，余额不能为负
+func MoneyConserved(total float64) func(l *Ledger) error {
+	return func(l *Ledger) error {
+		for _, account := range l.Accounts {
+			if account.Balance < 0 {
+				return errors.New(fmt.Sprintf("账户%s余额为负: %f", account.AccountId, account.Balance))
+			}
+		}
+		balance, escrow := l.TotalBalance(), l.Escrow()
+		if math.Abs(balance+escrow-total) > 1e-6*math.Max(1, total) {
+			return errors.New(fmt.Sprintf("余额%f+托管%f不等于初始总额%f", balance, escrow, total))
+		}
+		return nil
+	}
+}
+
+// EncumbranceConsistent 担保中的房产有且只有一个进行中的销售或捐赠，未担保的房产没有
+// 进行中的销售或捐赠必须指向卖家、捐赠人名下的房产
+func EncumbranceConsistent(l *Ledger) error {
+	open := make(map[[2]string][]string) //[所有者, 房产ID] -> 进行中的交易
+	for _, s := range l.Sellings {
+		if s.SellingStatus == model.SellingStatusConstant()["saleStart"] || s.SellingStatus == model.SellingStatusConstant()["delivery"] {
+			key := [2]string{s.Seller, s.ObjectOfSale}
+			open[key] = append(open[key], "销售("+s.SellingStatus+")")
+		}
+	}
+	for _, d := range l.Donatings {
+		if d.DonatingStatus == model.DonatingStatusConstant()["donatingStart"] {
+			key := [2]string{d.Donor, d.ObjectOfDonating}
+			open[key] = append(open[key], "捐赠给"+d.Grantee)
+		}
+	}
+	for _, r := range l.RealEstates {
+		key := [2]string{r.Proprietor, r.RealEstateID}
+		deals := open[key]
+		delete(open, key)
+		if r.Encumbrance && len(deals) != 1 {
+			return errors.New(fmt.Sprintf("担保中的房产%s应有且只有一个进行中的交易，实际: %v", r.RealEstateID, deals))
+		}
+		if !r.Encumbrance && len(deals) != 0 {
+			return errors.New(fmt.Sprintf("未担保的房产%s存在进行中的交易: %v", r.RealEstateID, deals))
+		}
+	}
+	for key, deals := range open {
+		return errors.New(fmt.Sprintf("进行中的交易%v指向的房产%s不在%s名下", deals, key[1], key[0]))
+	}
+	return nil
+}
+
+// OwnerIndexConsistent 复合主键与记录内容一致：账户按[AccountId]、房产按[所有者, ID]、销售按[卖家, 房产ID]、捐赠按[捐赠人, 房产ID, 受赠人]存放
+// 同一房产只能出现在一个所有者名下，所有者、卖家、买家、捐赠人、受赠人都必须是存在的账户
+func OwnerIndexConsistent(l *Ledger) error {
+	accounts := make(map[string]bool)
+	for _, r := range l.Records {
+		if r.ObjectType == model.AccountKey {
+			accounts[strings.Join(r.Attributes, "")] = true
+		}
+	}
+	check := func(objectType string, attributes []string, want ...string) error {
+		if strings.Join(attributes, "\x00") != strings.Join(want, "\x00") {
+			return errors.New(fmt.Sprintf("%s的复合主键%v与记录内容%v不一致", objectType, attributes, want))
+		}
+		return nil
+	}
+	exists := func(role string, accountId string) error {
+		if accountId != "" && !accounts[accountId] {
+			return errors.New(fmt.Sprintf("%s%s不是已有账户", role, accountId))
+		}
+		return nil
+	}
+	var index struct{ account, realEstate, selling, donating int }
+	owners := make(map[string]string)
+	for _, r := range l.Records {
+		var err error
+		switch r.ObjectType {
+		case model.AccountKey:
+			a := l.Accounts[index.account]
+			index.account++
+			err = check(r.ObjectType, r.Attributes, a.AccountId)
+		case model.RealEstateKey:
+			e := l.RealEstates[index.realEstate]
+			index.realEstate++
+			if err = check(r.ObjectType, r.Attributes, e.Proprietor, e.RealEstateID); err != nil {
+				break
+			}
+			if owner, dup := owners[e.RealEstateID]; dup {
+				err = errors.New(fmt.Sprintf("房产%s同时在%s和%s名下", e.RealEstateID, owner, e.Proprietor))
+				break
+			}
+			owners[e.RealEstateID] = e.Proprietor
+			err = exists("所有者", e.Proprietor)
+		case model.SellingKey:
+			s := l.Sellings[index.selling]
+			index.selling++
+			if err = check(r.ObjectType, r.Attributes, s.Seller, s.ObjectOfSale); err == nil {
+				if err = exists("卖家", s.Seller); err == nil {
+					err = exists("买家", s.Buyer)
+				}
+			}
+		case model.DonatingKey:
+			d := l.Donatings[index.donating]
+			index.donating++
+			if err = check(r.ObjectType, r.Attributes, d.Donor, d.ObjectOfDonating, d.Grantee); err == nil {
+				if err = exists("捐赠人", d.Donor); err == nil {
+					err = exists("受赠人", d.Grantee)
+				}
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ParticipationConsistent 交付中的销售在买家名下有且只有一条交付中的购买记录，捐赠中的捐赠在受赠人名下有且只有一条捐赠中的受赠记录
+// 否则买家无法查到自己的购买，卖家也无法确认收款或取消，房产和预付的价款将一直处于担保状态
+func ParticipationConsistent(l *Ledger) error {
+	buys := make(map[[3]string]int) //[卖家, 房产ID, 买家] -> 交付中的购买记录数
+	for _, b := range l.SellingBuys {
+		if b.Selling.SellingStatus == model.SellingStatusConstant()["delivery"] {
+			buys[[3]string{b.Selling.Seller, b.Selling.ObjectOfSale, b.Buyer}]++
+		}
+	}
+	for _, s := range l.Sellings {
+		if s.SellingStatus != model.SellingStatusConstant()["delivery"] {
+			continue
+		}
+		key := [3]string{s.Seller, s.ObjectOfSale, s.Buyer}
+		if buys[key] != 1 {
+			return errors.New(fmt.Sprintf("交付中的销售%v应有且只有一条购买记录，实际%d条", key, buys[key]))
+		}
+		delete(buys, key)
+	}
+	for key := range buys {
+		return errors.New(fmt.Sprintf("购买记录%v对应的销售不在交付中", key))
+	}
+	grants := make(map[[3]string]int) //[捐赠人, 房产ID, 受赠人] -> 捐赠中的受赠记录数
+	for _, g := range l.DonatingGrantees {
+		if g.Donating.DonatingStatus == model.DonatingStatusConstant()["donatingStart"] {
+			grants[[3]string{g.Donating.Donor, g.Donating.ObjectOfDonating, g.Grantee}]++
+		}
+	}
+	for _, d := range l.Donatings {
+		if d.DonatingStatus != model.DonatingStatusConstant()["donatingStart"] {
+			continue
+		}
+		key := [3]string{d.Donor, d.ObjectOfDonating, d.Grantee}
+		if grants[key] != 1 {
+			return errors.New(fmt.Sprintf("捐赠中的捐赠%v应有且只有一条受赠记录，实际%d条", key, grants[key]))
+		}
+		delete(grants, key)
+	}
+	for key := range grants {
+		return errors.New(fmt.Sprintf("受赠记录%v对应的捐赠不在捐赠中", key))
+	}
+	return nil
+}
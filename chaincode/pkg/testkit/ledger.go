@@ -0,0 +1,224 @@
+package testkit
+
+import (
+	"bytes"
+	"chaincode/model"
+	"chaincode/pkg/utils"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+)
+
+// Record 账本中的一条记录
+type Record struct {
+	ObjectType string   //复合主键的类型
+	Attributes []string //复合主键的属性
+	Value      []byte
+}
+
+// Ledger 按类型解析后的账本，记录按复合主键排序
+type Ledger struct {
+	Records          []Record
+	Accounts         []model.Account
+	RealEstates      []model.RealEstate
+	Sellings         []model.Selling
+	SellingBuys      []model.SellingBuy
+	Donatings        []model.Donating
+	DonatingGrantees []model.DonatingGrantee
+	ImportRefs       []model.ImportRef
+}
+
+// Load 读取MockStub的世界状态，记录中的私有字段从私有数据中合并，Records中为公开记录
+func Load(stub *shimtest.MockStub) (*Ledger, error) {
+	l := new(Ledger)
+	for _, key := range Snapshot(stub.State).keys() {
+		objectType, attributes := splitKey(key)
+		value := stub.State[key]
+		l.Records = append(l.Records, Record{ObjectType: objectType, Attributes: attributes, Value: value})
+		var target interface{}
+		switch objectType {
+		case model.AccountKey:
+			l.Accounts = append(l.Accounts, model.Account{})
+			target = &l.Accounts[len(l.Accounts)-1]
+		case model.RealEstateKey:
+			l.RealEstates = append(l.RealEstates, model.RealEstate{})
+			target = &l.RealEstates[len(l.RealEstates)-1]
+		case model.SellingKey:
+			l.Sellings = append(l.Sellings, model.Selling{})
+			target = &l.Sellings[len(l.Sellings)-1]
+		case model.SellingBuyKey:
+			l.SellingBuys = append(l.SellingBuys, model.SellingBuy{})
+			target = &l.SellingBuys[len(l.SellingBuys)-1]
+		case model.DonatingKey:
+			l.Donatings = append(l.Donatings, model.Donating{})
+			target = &l.Donatings[len(l.Donatings)-1]
+		case model.DonatingGranteeKey:
+			l.DonatingGrantees = append(l.DonatingGrantees, model.DonatingGrantee{})
+			target = &l.DonatingGrantees[len(l.DonatingGrantees)-1]
+		case model.ImportRefKey:
+			l.ImportRefs = append(l.ImportRefs, model.ImportRef{})
+			target = &l.ImportRefs[len(l.ImportRefs)-1]
+		default:
+			continue
+		}
+		if spec, ok := model.PrivateFields[objectType]; ok {
+			if err := utils.UnmarshalPrivate(objectType, value, stub.PvtState[spec.Collection][key], target); err != nil {
+				return nil, errors.New(fmt.Sprintf("%s%v合并私有数据出错: %s", objectType, attributes, err))
+			}
+			continue
+		}
+		if err := json.Unmarshal(value, target); err != nil {
+			return nil, errors.New(fmt.Sprintf("%s%v反序列化出错: %s", objectType, attributes, err))
+		}
+	}
+	return l, nil
+}
+
+// Account 根据AccountId查找账户
+func (l *Ledger) Account(accountId string) *model.Account {
+	for i := range l.Accounts {
+		if l.Accounts[i].AccountId == accountId {
+			return &l.Accounts[i]
+		}
+	}
+	return nil
+}
+
+// Balance 账户余额，账户不存在时返回0
+func (l *Ledger) Balance(accountId string) float64 {
+	if account := l.Account(accountId); account != nil {
+		return account.Balance
+	}
+	return 0
+}
+
+// TotalBalance 全部账户余额之和
+func (l *Ledger) TotalBalance() float64 {
+	var total float64
+	for _, account := range l.Accounts {
+		total += account.Balance
+	}
+	return total
+}
+
+// RealEstate 根据RealEstateID查找房产，不论归属
+func (l *Ledger) RealEstate(realEstateId string) *model.RealEstate {
+	for i := range l.RealEstates {
+		if l.RealEstates[i].RealEstateID == realEstateId {
+			return &l.RealEstates[i]
+		}
+	}
+	return nil
+}
+
+// Selling 根据卖家和销售对象查找销售
+func (l *Ledger) Selling(seller string, objectOfSale string) *model.Selling {
+	for i := range l.Sellings {
+		if l.Sellings[i].Seller == seller && l.Sellings[i].ObjectOfSale == objectOfSale {
+			return &l.Sellings[i]
+		}
+	}
+	return nil
+}
+
+// Donating 根据捐赠人、捐赠对象和受赠人查找捐赠
+func (l *Ledger) Donating(donor string, objectOfDonating string, grantee string) *model.Donating {
+	for i := range l.Donatings {
+		d := &l.Donatings[i]
+		if d.Donor == donor && d.ObjectOfDonating == objectOfDonating && d.Grantee == grantee {
+			return d
+		}
+	}
+	return nil
+}
+
+// Snapshot 世界状态的副本
+type Snapshot map[string][]byte
+
+// Snapshot 复制当前世界状态
+func (k *Kit) Snapshot() Snapshot {
+	s := make(Snapshot, len(k.Stub.State))
+	for key, value := range k.Stub.State {
+		s[key] = value
+	}
+	return s
+}
+
+func (s Snapshot) keys() []string {
+	keys := make([]string, 0, len(s))
+	for key := range s {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Change 一个键的变化，Before为nil表示新增，After为nil表示删除
+type Change struct {
+	ObjectType string
+	Attributes []string
+	Before     []byte
+	After      []byte
+}
+
+func (c Change) String() string {
+	switch {
+	case c.Before == nil:
+		return fmt.Sprintf("+%s%v", c.ObjectType, c.Attributes)
+	case c.After == nil:
+		return fmt.Sprintf("-%s%v", c.ObjectType, c.Attributes)
+	default:
+		return fmt.Sprintf("~%s%v", c.ObjectType, c.Attributes)
+	}
+}
+
+// Diff 与after相比发生变化的键，按键排序
+func (s Snapshot) Diff(after Snapshot) []Change {
+	all := make(Snapshot, len(s)+len(after))
+	for key := range s {
+		all[key] = nil
+	}
+	for key := range after {
+		all[key] = nil
+	}
+	var changes []Change
+	for _, key := range all.keys() {
+		if bytes.Equal(s[key], after[key]) {
+			continue
+		}
+		objectType, attributes := splitKey(key)
+		changes = append(changes, Change{ObjectType: objectType, Attributes: attributes, Before: s[key], After: after[key]})
+	}
+	return changes
+}
+
+// splitKey 拆分复合主键，格式为\x00objectType\x00attr1\x00attr2\x00，普通键的类型为空
+func splitKey(key string) (string, []string) {
+	if !strings.HasPrefix(key, "\x00") {
+		return "", []string{key}
+	}
+	parts := strings.Split(key[1:], "\x00")
+	return parts[0], parts[1 : len(parts)-1]
+}
+
+// Summary 变化的简要描述，如[+selling-key[...] ~real-estate-key[...]]，便于断言
+func Summary(changes []Change) []string {
+	summary := make([]string, 0, len(changes))
+	for _, c := range changes {
+		summary = append(summary, c.String())
+	}
+	return summary
+}
+
+// CountByType 按复合主键类型统计变化的键数
+func CountByType(changes []Change) map[string]int {
+	count := make(map[string]int)
+	for _, c := range changes {
+		count[c.ObjectType]++
+	}
+	return count
+}
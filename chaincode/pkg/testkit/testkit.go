@@ -0,0 +1,300 @@
+package testkit
+
+import (
+	"chaincode/api"
+	"chaincode/contract"
+	"chaincode/model"
+	"chaincode/pkg/errcode"
+	"chaincode/pkg/mockstub"
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/ptypes"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shimtest"
+	pb "github.com/hyperledger/fabric-protos-go/peer"
+)
+
+// 链码初始化的账户
+const (
+	Admin  = "5feceb66ffc8"
+	Owner1 = "6b86b273ff34"
+	Owner2 = "d4735e3a265e"
+	Owner3 = "4e07408562be"
+	Owner4 = "4b227777d4dd"
+	Owner5 = "ef2d127de37b"
+)
+
+// InitialBalance 业主账户的初始余额
+const InitialBalance = 5000000
+
+// Epoch 测试时钟的起始时间
+var Epoch = time.Date(2021, 1, 1, 0, 0, 0, 0, time.Local)
+
+// Kit 基于shimtest.MockStub的链码测试工具
+// 每次调用后取出写出的事件并检查全局不变量；调用失败时回滚本次写入(包括私有数据)，与Fabric丢弃未通过背书的交易一致
+type Kit struct {
+	T          testing.TB
+	Stub       *shimtest.MockStub
+	Invariants []Invariant        //每次调用后检查，测试需要写入非法数据时可以置空
+	Events     []model.EventBatch //最近一次调用写出的事件
+	Now        time.Time          //下一笔交易的时间戳，默认每笔交易后前进一秒
+	Tick       time.Duration      //每笔交易后时钟前进的时长
+	MSPID      string             //调用者所在组织，默认为可以读写私有数据的JDMSP
+	Account    string             //调用者证书中的realty.accountId，为空时取本次调用的当事人(见api.Parties)或第一个参数，即以操作人自己的身份调用
+	Creator    []byte             //不为空时代替MSPID和Account作为调用者身份，如没有证书的mockstub.Identity
+	Transient  map[string][]byte  //下一笔交易的临时数据，调用后清空
+	QueryError error              //不为nil时链码的富查询返回该错误，见mockstub.Stub
+	txCount    int
+}
+
+// clock 在调用链码前把MockStub的交易时间戳换成测试时钟，MockStub默认使用当前时间，同一秒内的交易结果不可复现
+// 同时以Kit的MSPID、Account和Transient作为本次调用的调用者身份和临时数据
+type clock struct {
+	cc shim.Chaincode
+	k  *Kit
+}
+
+func (c *clock) Init(stub shim.ChaincodeStubInterface) pb.Response {
+	c.k.stamp()
+	return c.cc.Init(c.k.wrap(stub))
+}
+
+func (c *clock) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
+	c.k.stamp()
+	return c.cc.Invoke(c.k.wrap(stub))
+}
+
+// wrap 设置本次调用的调用者身份，补齐MockStub未实现的临时数据，临时数据只用于一次调用
+func (k *Kit) wrap(stub shim.ChaincodeStubInterface) shim.ChaincodeStubInterface {
+	transient := k.Transient
+	k.Transient = nil
+	mock := stub.(*shimtest.MockStub)
+	mock.Creator = k.Creator
+	if mock.Creator == nil {
+		account := k.Account
+		if args := mock.GetStringArgs(); account == "" && len(args) > 1 {
+			account = args[1]
+			if parties := api.Parties(args[0], args[1:]); len(parties) > 0 {
+				account = parties[0]
+			}
+		}
+		mock.Creator = mockstub.AccountIdentity(k.MSPID, account)
+	}
+	return &mockstub.Stub{MockStub: mock, Transient: transient, QueryError: k.QueryError}
+}
+
+// New 用默认种子数据创建并初始化链码，默认检查DefaultInvariants
+func New(t testing.TB) *Kit {
+	t.Helper()
+	return NewWithArgs(t)
+}
+
+// NewWithArgs 用实例化参数创建并初始化链码，args为空时使用默认种子数据
+func NewWithArgs(t testing.TB, args ...string) *Kit {
+	t.Helper()
+	k := &Kit{T: t, Now: Epoch, Tick: time.Second, MSPID: "JDMSP"}
+	k.Stub = shimtest.NewMockStub("realty", &clock{cc: new(contract.BlockChainRealEstate), k: k})
+	if res := k.Init(args...); res.Status != shim.OK {
+		t.Fatalf("链码初始化失败: %s", res.Message)
+	}
+	k.Invariants = DefaultInvariants(k.Ledger().TotalBalance())
+	if err := k.Check(); err != nil {
+		t.Fatalf("init 之后%s", err)
+	}
+	return k
+}
+
+// Init 再次调用链码的Init，模拟升级链码，失败时回滚本次写入
+func (k *Kit) Init(args ...string) pb.Response {
+	k.T.Helper()
+	bytesArgs := [][]byte{[]byte("init")}
+	for _, arg := range args {
+		bytesArgs = append(bytesArgs, []byte(arg))
+	}
+	before, private := k.Snapshot(), mockstub.CopyPrivate(k.Stub)
+	res := k.Stub.MockInit(k.nextTxID(), bytesArgs)
+	k.Events = k.drainEvents()
+	if res.Status != shim.OK {
+		k.restore(before, private)
+	}
+	if err := k.Check(); err != nil {
+		k.T.Fatalf("init 之后%s", err)
+	}
+	return res
+}
+
+// stamp 设置本次交易的时间戳并推进时钟
+func (k *Kit) stamp() {
+	ts, err := ptypes.TimestampProto(k.Now)
+	if err != nil {
+		k.T.Fatal(err)
+	}
+	k.Stub.TxTimestamp = ts
+	k.Now = k.Now.Add(k.Tick)
+}
+
+// nextTxID 生成与真实交易ID等长的唯一ID(链码会截取TxID作为房地产ID)
+func (k *Kit) nextTxID() string {
+	k.txCount++
+	sum := sha256.Sum256([]byte(strconv.Itoa(k.txCount)))
+	return hex.EncodeToString(sum[:])
+}
+
+// Invoke 调用链码并返回原始响应，不判断成功与否，违反不变量时终止测试
+func (k *Kit) Invoke(args ...string) pb.Response {
+	k.T.Helper()
+	res, err := k.Try(args...)
+	if err != nil {
+		k.T.Fatalf("%s 之后%s", describe(args), err)
+	}
+	return res
+}
+
+// Try 调用链码并检查不变量，违反时返回错误而不终止测试，便于随机测试收集并精简失败的调用序列
+func (k *Kit) Try(args ...string) (pb.Response, error) {
+	k.T.Helper()
+	var bytesArgs [][]byte
+	for _, arg := range args {
+		bytesArgs = append(bytesArgs, []byte(arg))
+	}
+	before, private := k.Snapshot(), mockstub.CopyPrivate(k.Stub)
+	res := k.Stub.MockInvoke(k.nextTxID(), bytesArgs)
+	k.Events = k.drainEvents()
+	if res.Status != shim.OK {
+		k.restore(before, private)
+		if len(k.Events) != 0 {
+			return res, errors.New("失败时不应写出事件")
+		}
+	}
+	return res, k.Check()
+}
+
+// MustSucceed 调用必须成功，返回Payload
+func (k *Kit) MustSucceed(args ...string) []byte {
+	k.T.Helper()
+	res := k.Invoke(args...)
+	if res.Status != shim.OK {
+		k.T.Fatalf("%s 应当成功，实际失败: %s", describe(args), res.Message)
+	}
+	return res.Payload
+}
+
+// MustDecode 调用必须成功，并把Payload反序列化到v
+func (k *Kit) MustDecode(v interface{}, args ...string) {
+	k.T.Helper()
+	payload := k.MustSucceed(args...)
+	if err := json.Unmarshal(payload, v); err != nil {
+		k.T.Fatalf("%s 反序列化出错: %s: %s", describe(args), err, payload)
+	}
+}
+
+// MustFail 调用必须失败并返回错误信封，错误码为code，key不为空时同时检查消息键
+func (k *Kit) MustFail(code errcode.Code, key string, args ...string) *errcode.Error {
+	k.T.Helper()
+	res := k.Invoke(args...)
+	if res.Status == shim.OK {
+		k.T.Fatalf("%s 应当失败，实际成功: %s", describe(args), res.Payload)
+	}
+	e := new(errcode.Error)
+	if err := json.Unmarshal([]byte(res.Message), e); err != nil {
+		k.T.Fatalf("%s 错误信息不是错误信封: %s", describe(args), res.Message)
+	}
+	if e.Code != code || (key != "" && e.Key != key) {
+		k.T.Fatalf("%s 期望错误%s/%s，实际%s/%s: %s", describe(args), code, key, e.Code, e.Key, e.Message)
+	}
+	return e
+}
+
+// PutState 在交易之外直接写入原始数据，用于模拟旧版本或被破坏的记录
+func (k *Kit) PutState(objectType string, attributes []string, value []byte) string {
+	k.T.Helper()
+	key, err := k.Stub.CreateCompositeKey(objectType, attributes)
+	if err != nil {
+		k.T.Fatal(err)
+	}
+	k.Stub.MockTransactionStart(k.nextTxID())
+	if err := k.Stub.PutState(key, value); err != nil {
+		k.T.Fatal(err)
+	}
+	k.Stub.MockTransactionEnd("")
+	return key
+}
+
+// Changes 执行fn，返回其间账本的变化
+func (k *Kit) Changes(fn func()) []Change {
+	before := k.Snapshot()
+	fn()
+	return before.Diff(k.Snapshot())
+}
+
+// Ledger 按类型解析当前账本
+func (k *Kit) Ledger() *Ledger {
+	k.T.Helper()
+	l, err := Load(k.Stub)
+	if err != nil {
+		k.T.Fatalf("解析账本出错: %s", err)
+	}
+	return l
+}
+
+// Check 对当前账本检查全部不变量
+func (k *Kit) Check() error {
+	if len(k.Invariants) == 0 {
+		return nil
+	}
+	l, err := Load(k.Stub)
+	if err != nil {
+		return errors.New(fmt.Sprintf("解析账本出错: %s", err))
+	}
+	for _, invariant := range k.Invariants {
+		if err := invariant.Check(l); err != nil {
+			return errors.New(fmt.Sprintf("违反不变量[%s]: %s", invariant.Name, err))
+		}
+	}
+	return nil
+}
+
+// drainEvents 取出MockStub中已写出的事件，MockStub的事件通道容量有限，不取出会阻塞后续交易
+func (k *Kit) drainEvents() []model.EventBatch {
+	k.T.Helper()
+	var batches []model.EventBatch
+	for {
+		select {
+		case event := <-k.Stub.ChaincodeEventsChannel:
+			if event.EventName != model.EventName {
+				k.T.Fatalf("事件名不符合预期: %s", event.EventName)
+			}
+			var batch model.EventBatch
+			if err := json.Unmarshal(event.Payload, &batch); err != nil {
+				k.T.Fatalf("事件反序列化出错: %s", err)
+			}
+			batches = append(batches, batch)
+		default:
+			return batches
+		}
+	}
+}
+
+// restore 回滚到快照，MockStub的写入直接生效
+func (k *Kit) restore(s Snapshot, private mockstub.PrivateState) {
+	private.Restore(k.Stub)
+	k.Stub.State = make(map[string][]byte, len(s))
+	k.Stub.Keys = list.New()
+	for _, key := range s.keys() {
+		k.Stub.State[key] = s[key]
+		k.Stub.Keys.PushBack(key)
+	}
+}
+
+func describe(args []string) string {
+	return strings.Join(args, " ")
+}
@@ -1,15 +1,25 @@
 package utils
 
 import (
+	"chaincode/model"
 	"encoding/json"
 	"errors"
 	"fmt"
 
-	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
 )
 
 // WriteLedger 写入账本
+// objectType属于model.DocTypes时，obj必须为嵌入了model.Doc的结构体指针，统一在此设置docType和version
+// objectType属于model.PrivateFields时，私有字段写入私有数据集合，并设置obj的privateHash
 func WriteLedger(obj interface{}, stub shim.ChaincodeStubInterface, objectType string, keys []string) error {
+	var doc model.Document
+	if docType, ok := model.DocTypes[objectType]; ok {
+		if doc, ok = obj.(model.Document); !ok {
+			return errors.New(fmt.Sprintf("%s-写入账本的记录必须为model.Document指针: %T", objectType, obj))
+		}
+		doc.SetDoc(docType, model.SchemaVersion)
+	}
 	//创建复合主键
 	var key string
 	if val, err := stub.CreateCompositeKey(objectType, keys); err != nil {
@@ -22,8 +32,12 @@ func WriteLedger(obj interface{}, stub shim.ChaincodeStubInterface, objectType s
 		return errors.New(fmt.Sprintf("%s-序列化json数据失败出错: %s", objectType, err))
 	}
 	//写入区块链账本
-	if err := stub.PutState(key, bytes); err != nil {
-		return errors.New(fmt.Sprintf("%s-写入区块链账本出错: %s", objectType, err))
+	hash, err := PutRecord(stub, objectType, key, bytes)
+	if err != nil {
+		return err
+	}
+	if hash != "" {
+		doc.SetPrivateHash(hash)
 	}
 	return nil
 }
@@ -37,6 +51,9 @@ func DelLedger(stub shim.ChaincodeStubInterface, objectType string, keys []strin
 	} else {
 		key = val
 	}
+	if err := delPrivate(stub, objectType, key); err != nil {
+		return err
+	}
 	//写入区块链账本
 	if err := stub.DelState(key); err != nil {
 		return errors.New(fmt.Sprintf("%s-删除区块链账本出错: %s", objectType, err))
@@ -62,6 +79,9 @@ func GetStateByPartialCompositeKeys(stub shim.ChaincodeStubInterface, objectType
 			if err != nil {
 				return nil, errors.New(fmt.Sprintf("%s-返回的数据出错: %s", objectType, err))
 			}
+			if err := CheckDoc(objectType, val.GetValue()); err != nil {
+				return nil, err
+			}
 
 			results = append(results, val.GetValue())
 		}
@@ -80,6 +100,9 @@ func GetStateByPartialCompositeKeys(stub shim.ChaincodeStubInterface, objectType
 			}
 
 			if bytes != nil {
+				if err := CheckDoc(objectType, bytes); err != nil {
+					return nil, err
+				}
 				results = append(results, bytes)
 			}
 		}
@@ -103,8 +126,51 @@ func GetStateByPartialCompositeKeys2(stub shim.ChaincodeStubInterface, objectTyp
 		if err != nil {
 			return nil, errors.New(fmt.Sprintf("%s-返回的数据出错: %s", objectType, err))
 		}
+		if err := CheckDoc(objectType, val.GetValue()); err != nil {
+			return nil, err
+		}
+
+		results = append(results, val.GetValue())
+	}
+	return results, nil
+}
+
+// GetQueryResult 根据CouchDB富查询语句(Mango selector)查询数据
+// LevelDB及MockStub不支持富查询，此时返回错误，由调用方决定是否降级为遍历复合主键
+func GetQueryResult(stub shim.ChaincodeStubInterface, query string) (results [][]byte, err error) {
+	resultIterator, err := stub.GetQueryResult(query)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("富查询出错: %s", err))
+	}
+	defer resultIterator.Close()
+
+	for resultIterator.HasNext() {
+		val, err := resultIterator.Next()
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("富查询返回的数据出错: %s", err))
+		}
 
 		results = append(results, val.GetValue())
 	}
 	return results, nil
 }
+
+// CheckDoc 校验从账本读取的记录类型和结构版本
+// 没有docType的旧记录(版本0)允许读取，可通过upgradeDocType升级
+func CheckDoc(objectType string, bytes []byte) error {
+	docType, ok := model.DocTypes[objectType]
+	if !ok {
+		return nil
+	}
+	var doc model.Doc
+	if err := json.Unmarshal(bytes, &doc); err != nil {
+		return errors.New(fmt.Sprintf("%s-读取记录类型出错: %s", objectType, err))
+	}
+	if doc.DocType != "" && doc.DocType != docType {
+		return errors.New(fmt.Sprintf("%s-记录类型不匹配，期望%s，实际%s", objectType, docType, doc.DocType))
+	}
+	if doc.Version > model.SchemaVersion {
+		return errors.New(fmt.Sprintf("%s-记录结构版本%d高于当前链码支持的版本%d", objectType, doc.Version, model.SchemaVersion))
+	}
+	return nil
+}
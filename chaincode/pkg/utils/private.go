@@ -0,0 +1,294 @@
+package utils
+
+import (
+	"bytes"
+	"chaincode/model"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-protos-go/msp"
+)
+
+// PrivateForbiddenError 调用者所在组织不在model.PrivateReaders中，不能读写私有数据
+type PrivateForbiddenError struct {
+	ObjectType string
+	MSPID      string
+}
+
+func (e *PrivateForbiddenError) Error() string {
+	return fmt.Sprintf("%s-组织%s不能读写私有数据", e.ObjectType, e.MSPID)
+}
+
+// IsPrivateForbidden 判断是否为没有私有数据权限
+func IsPrivateForbidden(err error) bool {
+	var e *PrivateForbiddenError
+	return errors.As(err, &e)
+}
+
+// CallerMSP 交易提案创建者的MSP ID
+func CallerMSP(stub shim.ChaincodeStubInterface) (string, error) {
+	creator, err := stub.GetCreator()
+	if err != nil {
+		return "", errors.New(fmt.Sprintf("获取调用者身份出错: %s", err))
+	}
+	if len(creator) == 0 {
+		return "", errors.New("交易提案中没有调用者身份")
+	}
+	identity := new(msp.SerializedIdentity)
+	if err := proto.Unmarshal(creator, identity); err != nil {
+		return "", errors.New(fmt.Sprintf("解析调用者身份出错: %s", err))
+	}
+	return identity.Mspid, nil
+}
+
+// CallerAccountId 交易提案创建者证书中的平台账户ID(model.AccountIdAttr)，证书中没有该属性时found为false
+func CallerAccountId(stub shim.ChaincodeStubInterface) (accountId string, found bool, err error) {
+	accountId, found, err = cid.GetAttributeValue(stub, model.AccountIdAttr)
+	if err != nil {
+		return "", false, errors.New(fmt.Sprintf("读取调用者证书属性出错: %s", err))
+	}
+	return accountId, found, nil
+}
+
+// CanReadPrivate 调用者所在组织是否可以读写私有数据
+func CanReadPrivate(stub shim.ChaincodeStubInterface) bool {
+	mspID, err := CallerMSP(stub)
+	if err != nil {
+		return false
+	}
+	for _, reader := range model.PrivateReaders {
+		if reader == mspID {
+			return true
+		}
+	}
+	return false
+}
+
+// RequirePrivate 调用者不能读写私有数据时返回PrivateForbiddenError
+func RequirePrivate(stub shim.ChaincodeStubInterface, objectType string) error {
+	if CanReadPrivate(stub) {
+		return nil
+	}
+	mspID, _ := CallerMSP(stub)
+	return &PrivateForbiddenError{ObjectType: objectType, MSPID: mspID}
+}
+
+// PrivateHash 私有部分的SHA-256
+func PrivateHash(private []byte) string {
+	sum := sha256.Sum256(private)
+	return hex.EncodeToString(sum[:])
+}
+
+// SplitPrivate 把序列化后的记录拆分为公开部分和私有部分，公开部分的privateHash为私有部分的哈希
+// objectType不在model.PrivateFields中时原样返回，private为nil
+func SplitPrivate(objectType string, value []byte) (public []byte, private []byte, err error) {
+	spec, ok := model.PrivateFields[objectType]
+	if !ok {
+		return value, nil, nil
+	}
+	record, err := decodeRecord(value)
+	if err != nil {
+		return nil, nil, errors.New(fmt.Sprintf("%s-拆分私有数据出错: %s", objectType, err))
+	}
+	privateRecord := make(map[string]interface{})
+	for _, field := range spec.Fields {
+		movePath(record, privateRecord, strings.Split(field, "."))
+	}
+	if private, err = json.Marshal(privateRecord); err != nil {
+		return nil, nil, errors.New(fmt.Sprintf("%s-序列化私有数据出错: %s", objectType, err))
+	}
+	record["privateHash"] = PrivateHash(private)
+	if public, err = json.Marshal(record); err != nil {
+		return nil, nil, errors.New(fmt.Sprintf("%s-序列化公开数据出错: %s", objectType, err))
+	}
+	return public, private, nil
+}
+
+// VerifyPrivate 校验私有部分与公开记录中的privateHash一致，返回是否需要合并
+// 公开记录没有privateHash(私有数据移出之前的旧记录)时不需要合并
+func VerifyPrivate(objectType string, public []byte, private []byte) (bool, error) {
+	var doc model.Doc
+	if err := json.Unmarshal(public, &doc); err != nil {
+		return false, errors.New(fmt.Sprintf("%s-读取私有数据哈希出错: %s", objectType, err))
+	}
+	if doc.PrivateHash == "" {
+		return false, nil
+	}
+	if private == nil {
+		return false, errors.New(fmt.Sprintf("%s-私有数据集合中没有对应的记录", objectType))
+	}
+	if PrivateHash(private) != doc.PrivateHash {
+		return false, errors.New(fmt.Sprintf("%s-私有数据与公开记录中的哈希不一致", objectType))
+	}
+	return true, nil
+}
+
+// MergePrivate 把私有部分合并回公开记录，私有部分必须与公开记录中的privateHash一致
+func MergePrivate(objectType string, public []byte, private []byte) ([]byte, error) {
+	if ok, err := VerifyPrivate(objectType, public, private); !ok {
+		return public, err
+	}
+	record, err := decodeRecord(public)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("%s-合并私有数据出错: %s", objectType, err))
+	}
+	privateRecord, err := decodeRecord(private)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("%s-合并私有数据出错: %s", objectType, err))
+	}
+	mergeRecord(record, privateRecord)
+	merged, err := json.Marshal(record)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("%s-序列化合并后的记录出错: %s", objectType, err))
+	}
+	return merged, nil
+}
+
+// UnmarshalPrivate 反序列化公开记录，再以私有部分覆盖其中的私有字段，比MergePrivate少一次序列化
+func UnmarshalPrivate(objectType string, public []byte, private []byte, v interface{}) error {
+	if err := json.Unmarshal(public, v); err != nil {
+		return errors.New(fmt.Sprintf("%s-反序列化出错: %s", objectType, err))
+	}
+	ok, err := VerifyPrivate(objectType, public, private)
+	if !ok {
+		return err
+	}
+	if err := json.Unmarshal(private, v); err != nil {
+		return errors.New(fmt.Sprintf("%s-反序列化私有数据出错: %s", objectType, err))
+	}
+	return nil
+}
+
+// ReadPrivate 读取记录的私有部分并合并到公开记录，key为完整的复合主键
+// 调用者不能读取私有数据时返回公开记录，私有字段为空
+func ReadPrivate(stub shim.ChaincodeStubInterface, objectType string, key string, public []byte) ([]byte, error) {
+	private, readable, err := getPrivate(stub, objectType, key)
+	if err != nil || !readable {
+		return public, err
+	}
+	return MergePrivate(objectType, public, private)
+}
+
+// getPrivate 读取记录的私有部分，不是PrivateFields中的记录或调用者不能读取私有数据时readable为false
+func getPrivate(stub shim.ChaincodeStubInterface, objectType string, key string) (private []byte, readable bool, err error) {
+	spec, ok := model.PrivateFields[objectType]
+	if !ok || !CanReadPrivate(stub) {
+		return nil, false, nil
+	}
+	private, err = stub.GetPrivateData(spec.Collection, key)
+	if err != nil {
+		return nil, false, errors.New(fmt.Sprintf("%s-读取私有数据出错: %s", objectType, err))
+	}
+	return private, true, nil
+}
+
+// PutRecord 写入一条序列化后的完整记录，私有字段写入私有数据集合，返回私有部分的哈希
+func PutRecord(stub shim.ChaincodeStubInterface, objectType string, key string, value []byte) (string, error) {
+	spec, ok := model.PrivateFields[objectType]
+	if !ok {
+		if err := stub.PutState(key, value); err != nil {
+			return "", errors.New(fmt.Sprintf("%s-写入区块链账本出错: %s", objectType, err))
+		}
+		return "", nil
+	}
+	if err := RequirePrivate(stub, objectType); err != nil {
+		return "", err
+	}
+	public, private, err := SplitPrivate(objectType, value)
+	if err != nil {
+		return "", err
+	}
+	if err := stub.PutState(key, public); err != nil {
+		return "", errors.New(fmt.Sprintf("%s-写入区块链账本出错: %s", objectType, err))
+	}
+	if err := stub.PutPrivateData(spec.Collection, key, private); err != nil {
+		return "", errors.New(fmt.Sprintf("%s-写入私有数据出错: %s", objectType, err))
+	}
+	return PrivateHash(private), nil
+}
+
+// delPrivate 删除记录的私有部分
+func delPrivate(stub shim.ChaincodeStubInterface, objectType string, key string) error {
+	spec, ok := model.PrivateFields[objectType]
+	if !ok {
+		return nil
+	}
+	if err := RequirePrivate(stub, objectType); err != nil {
+		return err
+	}
+	if err := stub.DelPrivateData(spec.Collection, key); err != nil {
+		return errors.New(fmt.Sprintf("%s-删除私有数据出错: %s", objectType, err))
+	}
+	return nil
+}
+
+// GetTransient 把临时数据中name对应的json反序列化到v，临时数据中没有该键时返回false
+func GetTransient(stub shim.ChaincodeStubInterface, name string, v interface{}) (bool, error) {
+	transient, err := stub.GetTransient()
+	if err != nil {
+		return false, errors.New(fmt.Sprintf("读取临时数据出错: %s", err))
+	}
+	value, ok := transient[name]
+	if !ok || len(value) == 0 {
+		return false, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(value))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return true, err
+	}
+	return true, nil
+}
+
+// decodeRecord 按字段解析记录，数字保留原样
+func decodeRecord(value []byte) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(value))
+	decoder.UseNumber()
+	var record map[string]interface{}
+	if err := decoder.Decode(&record); err != nil {
+		return nil, err
+	}
+	return record, nil
+}
+
+// movePath 把path指向的字段从from移到to的相同路径，字段不存在时忽略
+func movePath(from, to map[string]interface{}, path []string) {
+	value, ok := from[path[0]]
+	if !ok {
+		return
+	}
+	if len(path) == 1 {
+		to[path[0]] = value
+		delete(from, path[0])
+		return
+	}
+	nested, ok := value.(map[string]interface{})
+	if !ok {
+		return
+	}
+	target, ok := to[path[0]].(map[string]interface{})
+	if !ok {
+		target = make(map[string]interface{})
+		to[path[0]] = target
+	}
+	movePath(nested, target, path[1:])
+}
+
+// mergeRecord 把from中的字段逐层合并到to
+func mergeRecord(to, from map[string]interface{}) {
+	for field, value := range from {
+		nested, ok := value.(map[string]interface{})
+		if target, isMap := to[field].(map[string]interface{}); ok && isMap {
+			mergeRecord(target, nested)
+			continue
+		}
+		to[field] = value
+	}
+}
@@ -0,0 +1,459 @@
+package utils
+
+import (
+	"chaincode/model"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+)
+
+// NotFoundError 根据复合主键找不到记录
+type NotFoundError struct {
+	ObjectType string
+	Keys       []string
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%s-记录不存在: %v", e.ObjectType, e.Keys)
+}
+
+// ConflictError 期望唯一的记录查到了多条
+type ConflictError struct {
+	ObjectType string
+	Keys       []string
+	Count      int
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("%s-期望唯一记录，实际查到%d条: %v", e.ObjectType, e.Count, e.Keys)
+}
+
+// IsNotFound 判断是否为记录不存在
+func IsNotFound(err error) bool {
+	var e *NotFoundError
+	return errors.As(err, &e)
+}
+
+// IsConflict 判断是否为记录冲突
+func IsConflict(err error) bool {
+	var e *ConflictError
+	return errors.As(err, &e)
+}
+
+// repository 基于复合主键的记录仓库，由各类型的Repository包装后使用
+type repository struct {
+	stub       shim.ChaincodeStubInterface
+	objectType string
+}
+
+// get 根据完整的复合主键获取记录
+func (r repository) get(keys []string, doc model.Document) error {
+	key, err := r.stub.CreateCompositeKey(r.objectType, keys)
+	if err != nil {
+		return errors.New(fmt.Sprintf("%s-创建复合主键出错 %s", r.objectType, err))
+	}
+	bytes, err := r.stub.GetState(key)
+	if err != nil {
+		return errors.New(fmt.Sprintf("%s-获取数据出错: %s", r.objectType, err))
+	}
+	if bytes == nil {
+		return &NotFoundError{ObjectType: r.objectType, Keys: keys}
+	}
+	return r.unmarshal(key, bytes, doc)
+}
+
+// mustGetOne 根据部分复合主键获取记录，必须有且只有一条
+func (r repository) mustGetOne(keys []string, doc model.Document) error {
+	resultKeys, results, err := r.scan(keys)
+	if err != nil {
+		return err
+	}
+	switch len(results) {
+	case 0:
+		return &NotFoundError{ObjectType: r.objectType, Keys: keys}
+	case 1:
+		return r.unmarshal(resultKeys[0], results[0], doc)
+	default:
+		return &ConflictError{ObjectType: r.objectType, Keys: keys, Count: len(results)}
+	}
+}
+
+// list 根据部分复合主键获取记录，newDoc为每条记录创建接收的结构体
+func (r repository) list(keys []string, newDoc func() model.Document) error {
+	resultKeys, results, err := r.scan(keys)
+	if err != nil {
+		return err
+	}
+	for i, v := range results {
+		if err := r.unmarshal(resultKeys[i], v, newDoc()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scan 根据部分复合主键获取记录及其完整的复合主键，合并私有数据时需要复合主键
+func (r repository) scan(keys []string) ([]string, [][]byte, error) {
+	resultIterator, err := r.stub.GetStateByPartialCompositeKey(r.objectType, keys)
+	if err != nil {
+		return nil, nil, errors.New(fmt.Sprintf("%s-获取全部数据出错: %s", r.objectType, err))
+	}
+	defer resultIterator.Close()
+
+	var resultKeys []string
+	var results [][]byte
+	for resultIterator.HasNext() {
+		val, err := resultIterator.Next()
+		if err != nil {
+			return nil, nil, errors.New(fmt.Sprintf("%s-返回的数据出错: %s", r.objectType, err))
+		}
+		resultKeys = append(resultKeys, val.GetKey())
+		results = append(results, val.GetValue())
+	}
+	return resultKeys, results, nil
+}
+
+func (r repository) put(doc model.Document, keys []string) error {
+	return WriteLedger(doc, r.stub, r.objectType, keys)
+}
+
+func (r repository) delete(keys []string) error {
+	return DelLedger(r.stub, r.objectType, keys)
+}
+
+// unmarshal 反序列化记录，调用者可以读取私有数据时合并私有部分
+func (r repository) unmarshal(key string, bytes []byte, doc model.Document) error {
+	if err := CheckDoc(r.objectType, bytes); err != nil {
+		return err
+	}
+	private, readable, err := getPrivate(r.stub, r.objectType, key)
+	if err != nil {
+		return err
+	}
+	if readable {
+		return UnmarshalPrivate(r.objectType, bytes, private, doc)
+	}
+	if err := json.Unmarshal(bytes, doc); err != nil {
+		return errors.New(fmt.Sprintf("%s-反序列化出错: %s", r.objectType, err))
+	}
+	return nil
+}
+
+// AccountRepository 账户仓库，复合主键为[AccountId]
+type AccountRepository struct{ repository }
+
+// Accounts 获取账户仓库
+func Accounts(stub shim.ChaincodeStubInterface) AccountRepository {
+	return AccountRepository{repository{stub: stub, objectType: model.AccountKey}}
+}
+
+// Get 根据AccountId获取账户
+func (r AccountRepository) Get(accountId string) (*model.Account, error) {
+	account := new(model.Account)
+	if err := r.get([]string{accountId}, account); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// MustGetOne 根据部分复合主键获取唯一的账户
+func (r AccountRepository) MustGetOne(keys ...string) (*model.Account, error) {
+	account := new(model.Account)
+	if err := r.mustGetOne(keys, account); err != nil {
+		return nil, err
+	}
+	return account, nil
+}
+
+// List 根据部分复合主键获取账户列表，不传keys则获取全部
+func (r AccountRepository) List(keys ...string) ([]model.Account, error) {
+	var accounts []model.Account
+	err := r.list(keys, func() model.Document {
+		accounts = append(accounts, model.Account{})
+		return &accounts[len(accounts)-1]
+	})
+	return accounts, err
+}
+
+// Put 写入账户
+func (r AccountRepository) Put(account *model.Account) error {
+	return r.put(account, []string{account.AccountId})
+}
+
+// Delete 删除账户
+func (r AccountRepository) Delete(accountId string) error {
+	return r.delete([]string{accountId})
+}
+
+// RealEstateRepository 房地产仓库，复合主键为[Proprietor, RealEstateID]
+type RealEstateRepository struct{ repository }
+
+// RealEstates 获取房地产仓库
+func RealEstates(stub shim.ChaincodeStubInterface) RealEstateRepository {
+	return RealEstateRepository{repository{stub: stub, objectType: model.RealEstateKey}}
+}
+
+// Get 根据所有者和房地产ID获取房地产
+func (r RealEstateRepository) Get(proprietor, realEstateId string) (*model.RealEstate, error) {
+	realEstate := new(model.RealEstate)
+	if err := r.get([]string{proprietor, realEstateId}, realEstate); err != nil {
+		return nil, err
+	}
+	return realEstate, nil
+}
+
+// MustGetOne 根据部分复合主键获取唯一的房地产
+func (r RealEstateRepository) MustGetOne(keys ...string) (*model.RealEstate, error) {
+	realEstate := new(model.RealEstate)
+	if err := r.mustGetOne(keys, realEstate); err != nil {
+		return nil, err
+	}
+	return realEstate, nil
+}
+
+// List 根据部分复合主键获取房地产列表，不传keys则获取全部
+func (r RealEstateRepository) List(keys ...string) ([]model.RealEstate, error) {
+	var realEstates []model.RealEstate
+	err := r.list(keys, func() model.Document {
+		realEstates = append(realEstates, model.RealEstate{})
+		return &realEstates[len(realEstates)-1]
+	})
+	return realEstates, err
+}
+
+// Put 写入房地产
+func (r RealEstateRepository) Put(realEstate *model.RealEstate) error {
+	return r.put(realEstate, []string{realEstate.Proprietor, realEstate.RealEstateID})
+}
+
+// Delete 删除房地产
+func (r RealEstateRepository) Delete(proprietor, realEstateId string) error {
+	return r.delete([]string{proprietor, realEstateId})
+}
+
+// SellingRepository 销售仓库，复合主键为[Seller, ObjectOfSale]
+type SellingRepository struct{ repository }
+
+// Sellings 获取销售仓库
+func Sellings(stub shim.ChaincodeStubInterface) SellingRepository {
+	return SellingRepository{repository{stub: stub, objectType: model.SellingKey}}
+}
+
+// Get 根据卖家和销售对象获取销售
+func (r SellingRepository) Get(seller, objectOfSale string) (*model.Selling, error) {
+	selling := new(model.Selling)
+	if err := r.get([]string{seller, objectOfSale}, selling); err != nil {
+		return nil, err
+	}
+	return selling, nil
+}
+
+// MustGetOne 根据部分复合主键获取唯一的销售
+func (r SellingRepository) MustGetOne(keys ...string) (*model.Selling, error) {
+	selling := new(model.Selling)
+	if err := r.mustGetOne(keys, selling); err != nil {
+		return nil, err
+	}
+	return selling, nil
+}
+
+// List 根据部分复合主键获取销售列表，不传keys则获取全部
+func (r SellingRepository) List(keys ...string) ([]model.Selling, error) {
+	var sellings []model.Selling
+	err := r.list(keys, func() model.Document {
+		sellings = append(sellings, model.Selling{})
+		return &sellings[len(sellings)-1]
+	})
+	return sellings, err
+}
+
+// Put 写入销售
+func (r SellingRepository) Put(selling *model.Selling) error {
+	return r.put(selling, []string{selling.Seller, selling.ObjectOfSale})
+}
+
+// Delete 删除销售
+func (r SellingRepository) Delete(seller, objectOfSale string) error {
+	return r.delete([]string{seller, objectOfSale})
+}
+
+// participationKeys 购买、受赠记录的复合主键为[账户, CreateTime, TxID]
+// 没有TxID的旧记录仍按[账户, CreateTime]存放，更新时不会另写一份
+func participationKeys(accountId, createTime, txID string) []string {
+	if txID == "" {
+		return []string{accountId, createTime}
+	}
+	return []string{accountId, createTime, txID}
+}
+
+// SellingBuyRepository 买家参与销售仓库，复合主键为[Buyer, CreateTime, TxID]
+type SellingBuyRepository struct{ repository }
+
+// SellingBuys 获取买家参与销售仓库
+func SellingBuys(stub shim.ChaincodeStubInterface) SellingBuyRepository {
+	return SellingBuyRepository{repository{stub: stub, objectType: model.SellingBuyKey}}
+}
+
+// Get 根据买家、创建时间和交易ID获取买家参与的销售
+func (r SellingBuyRepository) Get(buyer, createTime, txID string) (*model.SellingBuy, error) {
+	sellingBuy := new(model.SellingBuy)
+	if err := r.get(participationKeys(buyer, createTime, txID), sellingBuy); err != nil {
+		return nil, err
+	}
+	return sellingBuy, nil
+}
+
+// MustGetOne 根据部分复合主键获取唯一的买家参与的销售
+func (r SellingBuyRepository) MustGetOne(keys ...string) (*model.SellingBuy, error) {
+	sellingBuy := new(model.SellingBuy)
+	if err := r.mustGetOne(keys, sellingBuy); err != nil {
+		return nil, err
+	}
+	return sellingBuy, nil
+}
+
+// List 根据部分复合主键获取买家参与的销售列表，不传keys则获取全部
+func (r SellingBuyRepository) List(keys ...string) ([]model.SellingBuy, error) {
+	var sellingBuys []model.SellingBuy
+	err := r.list(keys, func() model.Document {
+		sellingBuys = append(sellingBuys, model.SellingBuy{})
+		return &sellingBuys[len(sellingBuys)-1]
+	})
+	return sellingBuys, err
+}
+
+// Put 写入买家参与的销售
+func (r SellingBuyRepository) Put(sellingBuy *model.SellingBuy) error {
+	return r.put(sellingBuy, participationKeys(sellingBuy.Buyer, sellingBuy.CreateTime, sellingBuy.TxID))
+}
+
+// Delete 删除买家参与的销售
+func (r SellingBuyRepository) Delete(buyer, createTime, txID string) error {
+	return r.delete(participationKeys(buyer, createTime, txID))
+}
+
+// DonatingRepository 捐赠仓库，复合主键为[Donor, ObjectOfDonating, Grantee]
+type DonatingRepository struct{ repository }
+
+// Donatings 获取捐赠仓库
+func Donatings(stub shim.ChaincodeStubInterface) DonatingRepository {
+	return DonatingRepository{repository{stub: stub, objectType: model.DonatingKey}}
+}
+
+// Get 根据捐赠人、捐赠对象和受赠人获取捐赠
+func (r DonatingRepository) Get(donor, objectOfDonating, grantee string) (*model.Donating, error) {
+	donating := new(model.Donating)
+	if err := r.get([]string{donor, objectOfDonating, grantee}, donating); err != nil {
+		return nil, err
+	}
+	return donating, nil
+}
+
+// MustGetOne 根据部分复合主键获取唯一的捐赠
+func (r DonatingRepository) MustGetOne(keys ...string) (*model.Donating, error) {
+	donating := new(model.Donating)
+	if err := r.mustGetOne(keys, donating); err != nil {
+		return nil, err
+	}
+	return donating, nil
+}
+
+// List 根据部分复合主键获取捐赠列表，不传keys则获取全部
+func (r DonatingRepository) List(keys ...string) ([]model.Donating, error) {
+	var donatings []model.Donating
+	err := r.list(keys, func() model.Document {
+		donatings = append(donatings, model.Donating{})
+		return &donatings[len(donatings)-1]
+	})
+	return donatings, err
+}
+
+// Put 写入捐赠
+func (r DonatingRepository) Put(donating *model.Donating) error {
+	return r.put(donating, []string{donating.Donor, donating.ObjectOfDonating, donating.Grantee})
+}
+
+// Delete 删除捐赠
+func (r DonatingRepository) Delete(donor, objectOfDonating, grantee string) error {
+	return r.delete([]string{donor, objectOfDonating, grantee})
+}
+
+// DonatingGranteeRepository 受赠人查询的捐赠仓库，复合主键为[Grantee, CreateTime, TxID]
+type DonatingGranteeRepository struct{ repository }
+
+// DonatingGrantees 获取受赠人查询的捐赠仓库
+func DonatingGrantees(stub shim.ChaincodeStubInterface) DonatingGranteeRepository {
+	return DonatingGranteeRepository{repository{stub: stub, objectType: model.DonatingGranteeKey}}
+}
+
+// Get 根据受赠人、创建时间和交易ID获取捐赠
+func (r DonatingGranteeRepository) Get(grantee, createTime, txID string) (*model.DonatingGrantee, error) {
+	donatingGrantee := new(model.DonatingGrantee)
+	if err := r.get(participationKeys(grantee, createTime, txID), donatingGrantee); err != nil {
+		return nil, err
+	}
+	return donatingGrantee, nil
+}
+
+// MustGetOne 根据部分复合主键获取唯一的受赠人捐赠
+func (r DonatingGranteeRepository) MustGetOne(keys ...string) (*model.DonatingGrantee, error) {
+	donatingGrantee := new(model.DonatingGrantee)
+	if err := r.mustGetOne(keys, donatingGrantee); err != nil {
+		return nil, err
+	}
+	return donatingGrantee, nil
+}
+
+// List 根据部分复合主键获取受赠人捐赠列表，不传keys则获取全部
+func (r DonatingGranteeRepository) List(keys ...string) ([]model.DonatingGrantee, error) {
+	var donatingGrantees []model.DonatingGrantee
+	err := r.list(keys, func() model.Document {
+		donatingGrantees = append(donatingGrantees, model.DonatingGrantee{})
+		return &donatingGrantees[len(donatingGrantees)-1]
+	})
+	return donatingGrantees, err
+}
+
+// Put 写入受赠人捐赠
+func (r DonatingGranteeRepository) Put(donatingGrantee *model.DonatingGrantee) error {
+	return r.put(donatingGrantee, participationKeys(donatingGrantee.Grantee, donatingGrantee.CreateTime, donatingGrantee.TxID))
+}
+
+// Delete 删除受赠人捐赠
+func (r DonatingGranteeRepository) Delete(grantee, createTime, txID string) error {
+	return r.delete(participationKeys(grantee, createTime, txID))
+}
+
+// ImportRefRepository 批量导入外部编号仓库，复合主键为[Ref]
+type ImportRefRepository struct{ repository }
+
+// ImportRefs 获取批量导入外部编号仓库
+func ImportRefs(stub shim.ChaincodeStubInterface) ImportRefRepository {
+	return ImportRefRepository{repository{stub: stub, objectType: model.ImportRefKey}}
+}
+
+// Get 根据外部编号获取导入记录
+func (r ImportRefRepository) Get(ref string) (*model.ImportRef, error) {
+	importRef := new(model.ImportRef)
+	if err := r.get([]string{ref}, importRef); err != nil {
+		return nil, err
+	}
+	return importRef, nil
+}
+
+// List 根据部分复合主键获取导入记录列表，不传keys则获取全部
+func (r ImportRefRepository) List(keys ...string) ([]model.ImportRef, error) {
+	var importRefs []model.ImportRef
+	err := r.list(keys, func() model.Document {
+		importRefs = append(importRefs, model.ImportRef{})
+		return &importRefs[len(importRefs)-1]
+	})
+	return importRefs, err
+}
+
+// Put 写入导入记录
+func (r ImportRefRepository) Put(importRef *model.ImportRef) error {
+	return r.put(importRef, []string{importRef.Ref})
+}
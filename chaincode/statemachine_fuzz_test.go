@@ -0,0 +1,25 @@
+//go:build go1.18
+// +build go1.18
+
+package main
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// 原生模糊测试，随机字节解码为调用序列，失败时由go test自行精简输入并保存到testdata/fuzz
+// 每次执行都要重放整个序列，新发现的输入默认会精简一分钟，建议限制精简次数:
+// go test -run '^$' -fuzz FuzzStateMachine -fuzztime 5m -fuzzminimizetime 50x
+func FuzzStateMachine(f *testing.F) {
+	for seed := 0; seed < 8; seed++ {
+		data := make([]byte, 40*stepSize)
+		rand.New(rand.NewSource(int64(seed))).Read(data)
+		f.Add(data)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if i, err, calls := runSteps(t, decodeSteps(data)); i >= 0 {
+			t.Fatalf("第%d步%s，调用序列: %v", i+1, err, calls)
+		}
+	})
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"chaincode/model"
+	"chaincode/pkg/testkit"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// step 随机调用序列中的一步，参数在执行时根据当前账本选取，所以删除其中任意几步后剩下的序列依然有意义
+type step struct {
+	fcn    byte   //调用的函数
+	target byte   //房产、销售或捐赠的下标
+	actor  byte   //卖家、捐赠人的下标，最高位为0时使用真实的所有者
+	other  byte   //买家、受赠人的下标，最高位为0时使用真实的买家、受赠人
+	status byte   //更新的状态
+	price  uint16 //售价，单位为50元，最高约327万，多次购买后可能超过余额
+	wait   bool   //为false时本步与上一步在同一秒内执行
+}
+
+const stepSize = 8
+
+// decodeSteps 每8个字节解码为一步，多余的字节忽略
+func decodeSteps(data []byte) []step {
+	var steps []step
+	for ; len(data) >= stepSize; data = data[stepSize:] {
+		steps = append(steps, step{
+			fcn:    data[0],
+			target: data[1],
+			actor:  data[2],
+			other:  data[3],
+			status: data[4],
+			price:  uint16(data[5])<<8 | uint16(data[6]),
+			wait:   data[7]&1 == 1,
+		})
+	}
+	return steps
+}
+
+// pick 最高位为0时返回真实值，否则从账户中任选一个
+func pick(b byte, real string, accounts []string) string {
+	if b&0x80 == 0 && real != "" {
+		return real
+	}
+	return accounts[int(b)%len(accounts)]
+}
+
+// args 根据当前账本生成本步的调用参数
+func (s step) args(l *testkit.Ledger) []string {
+	var accounts []string
+	for _, a := range l.Accounts {
+		accounts = append(accounts, a.AccountId)
+	}
+	var realEstate model.RealEstate
+	if len(l.RealEstates) > 0 {
+		realEstate = l.RealEstates[int(s.target)%len(l.RealEstates)]
+	}
+	//从全部销售中选取，包括已完成、已取消的，覆盖完成后再购买、再取消等路径
+	var selling model.Selling
+	if len(l.Sellings) > 0 {
+		selling = l.Sellings[int(s.target)%len(l.Sellings)]
+	}
+	switch s.fcn % 6 {
+	case 0:
+		return []string{"createRealEstate", testkit.Admin, accounts[int(s.other)%len(accounts)], "100", "80"}
+	case 1:
+		return []string{"createSelling", realEstate.RealEstateID, pick(s.actor, realEstate.Proprietor, accounts), strconv.Itoa(int(s.price) * 50), "30"}
+	case 2:
+		return []string{"createSellingByBuy", selling.ObjectOfSale, pick(s.actor, selling.Seller, accounts), accounts[int(s.other)%len(accounts)]}
+	case 3:
+		status := []string{"done", "cancelled", "expired"}[int(s.status)%3]
+		return []string{"updateSelling", selling.ObjectOfSale, pick(s.actor, selling.Seller, accounts), pick(s.other, selling.Buyer, accounts), status}
+	case 4:
+		return []string{"createDonating", realEstate.RealEstateID, pick(s.actor, realEstate.Proprietor, accounts), accounts[int(s.other)%len(accounts)]}
+	default:
+		var donating model.Donating
+		if len(l.Donatings) > 0 {
+			donating = l.Donatings[int(s.target)%len(l.Donatings)]
+		}
+		status := []string{"done", "cancelled"}[int(s.status)%2]
+		return []string{"updateDonating", donating.ObjectOfDonating, pick(s.actor, donating.Donor, accounts), pick(s.other, donating.Grantee, accounts), status}
+	}
+}
+
+// runSteps 依次执行，返回违反不变量的步骤下标(-1表示全部通过)、错误以及执行过的调用
+func runSteps(t testing.TB, steps []step) (int, error, [][]string) {
+	k := testkit.New(t)
+	var calls [][]string
+	for i, s := range steps {
+		if !s.wait {
+			k.Now = k.Now.Add(-k.Tick)
+		}
+		args := s.args(k.Ledger())
+		calls = append(calls, args)
+		if _, err := k.Try(args...); err != nil {
+			return i, err, calls
+		}
+	}
+	return -1, nil, calls
+}
+
+// minimize 逐步删除不影响失败的步骤，得到仍然失败的最短序列
+func minimize(t testing.TB, steps []step) []step {
+	fails := func(steps []step) bool {
+		i, _, _ := runSteps(t, steps)
+		return i >= 0
+	}
+	for chunk := len(steps) / 2; chunk >= 1; chunk /= 2 {
+		for start := 0; start+chunk <= len(steps); {
+			candidate := append(append([]step(nil), steps[:start]...), steps[start+chunk:]...)
+			if fails(candidate) {
+				steps = candidate
+			} else {
+				start += chunk
+			}
+		}
+	}
+	return steps
+}
+
+// report 精简失败的序列并输出每一步的调用
+func report(t testing.TB, steps []step) string {
+	steps = minimize(t, steps)
+	i, err, calls := runSteps(t, steps)
+	var b strings.Builder
+	for n, args := range calls {
+		fmt.Fprintf(&b, "\n%d. %s", n+1, strings.Join(args, " "))
+		if !steps[n].wait {
+			b.WriteString(" (与上一步同一秒)")
+		}
+	}
+	return fmt.Sprintf("第%d步%s，精简后的调用序列:%s", i+1, err, b.String())
+}
+
+// 随机生成销售、捐赠调用序列，每一步之后检查全局不变量
+func Test_StateMachine(t *testing.T) {
+	seeds, length := 300, 80
+	if testing.Short() {
+		seeds = 30
+	}
+	for seed := 0; seed < seeds; seed++ {
+		data := make([]byte, length*stepSize)
+		rand.New(rand.NewSource(int64(seed))).Read(data)
+		steps := decodeSteps(data)
+		if i, _, _ := runSteps(t, steps); i >= 0 {
+			t.Fatalf("seed=%d %s", seed, report(t, steps[:i+1]))
+		}
+	}
+}
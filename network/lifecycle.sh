@@ -0,0 +1,86 @@
+#!/bin/bash
+
+# Fabric 2.x 链码生命周期：打包 -> 各组织安装 -> 各组织批准链码定义 -> 提交到通道 -> 调用 init
+# 由 start.sh 引入，lifecycle_test.sh 以替换 cli 函数的方式检查各步骤的命令
+# CC_NAME 链码名，CC_VERSION 版本号，CC_SEQUENCE 链码定义的序号，升级链码时版本号和序号都需要递增
+# ENDORSEMENT_POLICY 链码的背书策略，默认需要 Taobao 和 JD 共同背书
+CC_NAME=${CC_NAME:-fabric-realty}
+CC_VERSION=${CC_VERSION:-1.0.0}
+CC_SEQUENCE=${CC_SEQUENCE:-1}
+CC_MODE=${CC_MODE:-docker}
+ENDORSEMENT_POLICY=${ENDORSEMENT_POLICY:-"AND('TaobaoMSP.member','JDMSP.member')"}
+ORDERER="-o orderer.qq.com:7050"
+# 背书策略需要两个组织时，提交和调用都要发给两个组织的节点
+PEERS="--peerAddresses peer0.taobao.com:7051 --peerAddresses peer0.jd.com:7051"
+# cli 容器中 ./config 挂载为 /etc/hyperledger/config
+CONFIG_DIR=${CONFIG_DIR:-./config}
+
+TaobaoPeer0Cli="CORE_PEER_ADDRESS=peer0.taobao.com:7051 CORE_PEER_LOCALMSPID=TaobaoMSP CORE_PEER_MSPCONFIGPATH=/etc/hyperledger/peer/taobao.com/users/Admin@taobao.com/msp"
+TaobaoPeer1Cli="CORE_PEER_ADDRESS=peer1.taobao.com:7051 CORE_PEER_LOCALMSPID=TaobaoMSP CORE_PEER_MSPCONFIGPATH=/etc/hyperledger/peer/taobao.com/users/Admin@taobao.com/msp"
+JDPeer0Cli="CORE_PEER_ADDRESS=peer0.jd.com:7051 CORE_PEER_LOCALMSPID=JDMSP CORE_PEER_MSPCONFIGPATH=/etc/hyperledger/peer/jd.com/users/Admin@jd.com/msp"
+JDPeer1Cli="CORE_PEER_ADDRESS=peer1.jd.com:7051 CORE_PEER_LOCALMSPID=JDMSP CORE_PEER_MSPCONFIGPATH=/etc/hyperledger/peer/jd.com/users/Admin@jd.com/msp"
+
+# 在 cli 容器中执行 peer 命令
+function cli() {
+  docker exec cli bash -c "$1"
+}
+
+# approve_args 两个组织批准、检查和提交时使用相同的链码定义
+function approve_args() {
+  echo "-C appchannel -n $CC_NAME -v $CC_VERSION --sequence $CC_SEQUENCE --init-required --signature-policy \"$ENDORSEMENT_POLICY\" --collections-config /etc/hyperledger/config/collections_config.json"
+}
+
+# --path 链码目录，在 /opt/gopath/src/ 目录下
+# ccaas 模式下链码包只包含连接信息 connection.json，CC_SERVER_ADDRESS 为 peer 访问链码服务的地址
+function package_chaincode() {
+  if [ "$CC_MODE" == "ccaas" ]; then
+    CC_SERVER_ADDRESS=${CC_SERVER_ADDRESS:-host.docker.internal:9999}
+    local dir
+    dir=$(mktemp -d)
+    echo "{\"address\":\"$CC_SERVER_ADDRESS\",\"dial_timeout\":\"10s\",\"tls_required\":false}" > $dir/connection.json
+    echo "{\"type\":\"ccaas\",\"label\":\"${CC_NAME}_$CC_VERSION\"}" > $dir/metadata.json
+    tar -C $dir -czf $dir/code.tar.gz connection.json
+    tar -C $dir -czf $CONFIG_DIR/$CC_NAME.tar.gz metadata.json code.tar.gz || return 1
+    rm -rf $dir
+  else
+    cli "$TaobaoPeer0Cli peer lifecycle chaincode package /etc/hyperledger/config/$CC_NAME.tar.gz --path /opt/gopath/src/chaincode --lang golang --label ${CC_NAME}_$CC_VERSION" || return 1
+  fi
+}
+
+# install_chaincode 两个组织各自安装，从 queryinstalled 取得 PACKAGE_ID
+function install_chaincode() {
+  cli "$TaobaoPeer0Cli peer lifecycle chaincode install /etc/hyperledger/config/$CC_NAME.tar.gz"
+  cli "$JDPeer0Cli peer lifecycle chaincode install /etc/hyperledger/config/$CC_NAME.tar.gz"
+  PACKAGE_ID=$(cli "$TaobaoPeer0Cli peer lifecycle chaincode queryinstalled" | sed -n "s/^Package ID: \(${CC_NAME}_$CC_VERSION:[0-9a-f]*\), Label: .*$/\1/p")
+  if [ -z "$PACKAGE_ID" ]; then
+    echo "【警告】未找到已安装的链码包 ${CC_NAME}_$CC_VERSION"
+    return 1
+  fi
+  echo "链码包：$PACKAGE_ID"
+}
+
+# approve_chaincode 两个组织各自批准相同的链码定义，--init-required 表示提交后必须先调用 init(写入种子数据)
+# 账号名、余额和销售价格保存在私有数据集合中，集合定义是链码定义的一部分，见 collections_config.json
+function approve_chaincode() {
+  cp collections_config.json $CONFIG_DIR/collections_config.json || return 1
+  cli "$TaobaoPeer0Cli peer lifecycle chaincode approveformyorg $ORDERER $(approve_args) --package-id $PACKAGE_ID --waitForEvent" || return 1
+  cli "$JDPeer0Cli peer lifecycle chaincode approveformyorg $ORDERER $(approve_args) --package-id $PACKAGE_ID --waitForEvent" || return 1
+  cli "$TaobaoPeer0Cli peer lifecycle chaincode checkcommitreadiness $(approve_args)"
+}
+
+# commit_chaincode 两个组织都批准后提交链码定义，发给两个组织的节点
+function commit_chaincode() {
+  cli "$TaobaoPeer0Cli peer lifecycle chaincode commit $ORDERER $(approve_args) $PEERS --waitForEvent" || return 1
+  cli "$TaobaoPeer0Cli peer lifecycle chaincode querycommitted -C appchannel -n $CC_NAME"
+}
+
+# init_chaincode 以 --isInit 调用 init；设置 SEED_FILE 时把该文件作为种子数据传给 init(格式见 seed.json)，否则使用链码的默认演示账户
+# 种子中有账号名和余额，通过临时数据(--transient)传入，不写入区块
+function init_chaincode() {
+  local transient=""
+  if [ -n "$SEED_FILE" ]; then
+    transient=$(jq -c -n --rawfile seed "$SEED_FILE" '{seed: ($seed | fromjson | tojson | @base64)}') || return 1
+    transient="--transient '$transient'"
+  fi
+  cli "$TaobaoPeer0Cli peer chaincode invoke $ORDERER -C appchannel -n $CC_NAME $PEERS --isInit -c '{\"Args\":[\"init\"]}' $transient --waitForEvent"
+}
@@ -0,0 +1,130 @@
+#!/bin/bash
+
+# 检查 lifecycle.sh 中链码生命周期各步骤的 peer 命令，不需要启动网络
+# 以记录命令的 cli 函数代替 docker exec，queryinstalled 返回固定的链码包
+# 用法：cd network && ./lifecycle_test.sh
+
+cd "$(dirname "$0")" || exit 1
+FAILED=0
+
+function fail() {
+  echo "FAIL [$CASE] $1"
+  FAILED=1
+}
+
+# expect_line 第 n 条命令包含全部片段
+function expect_line() {
+  local n=$1
+  shift
+  local line
+  line=$(sed -n "${n}p" $LOG)
+  for part in "$@"; do
+    if [[ "$line" != *"$part"* ]]; then
+      fail "第 $n 条命令缺少 $part：$line"
+    fi
+  done
+}
+
+# run_lifecycle 在子 shell 中按 start.sh 的顺序执行各步骤
+function run_lifecycle() {
+  (
+    source ./lifecycle.sh
+    function cli() {
+      echo "$1" >> $LOG
+      if [[ "$1" == *"queryinstalled"* ]]; then
+        echo "Installed chaincodes on peer:"
+        echo "Package ID: ${CC_NAME}_0.9.0:0000, Label: ${CC_NAME}_0.9.0"
+        echo "Package ID: ${CC_NAME}_$CC_VERSION:abc123, Label: ${CC_NAME}_$CC_VERSION"
+      fi
+    }
+    package_chaincode && install_chaincode && approve_chaincode && commit_chaincode && init_chaincode
+  ) > /dev/null
+}
+
+WORK=$(mktemp -d)
+trap 'rm -rf $WORK' EXIT
+LOG=$WORK/commands.log
+export CONFIG_DIR=$WORK
+
+CASE=docker
+: > $LOG
+CC_MODE=docker CC_VERSION=1.1.0 CC_SEQUENCE=2 run_lifecycle || fail "生命周期未执行完成"
+expect_line 1 "peer lifecycle chaincode package" "--lang golang" "--label fabric-realty_1.1.0"
+expect_line 2 "CORE_PEER_LOCALMSPID=TaobaoMSP" "peer lifecycle chaincode install"
+expect_line 3 "CORE_PEER_LOCALMSPID=JDMSP" "peer lifecycle chaincode install"
+expect_line 4 "peer lifecycle chaincode queryinstalled"
+for n in 5 6; do
+  expect_line $n "peer lifecycle chaincode approveformyorg" "-v 1.1.0" "--sequence 2" "--init-required" \
+    "--signature-policy \"AND('TaobaoMSP.member','JDMSP.member')\"" \
+    "--collections-config /etc/hyperledger/config/collections_config.json" "--package-id fabric-realty_1.1.0:abc123"
+done
+expect_line 5 "CORE_PEER_LOCALMSPID=TaobaoMSP"
+expect_line 6 "CORE_PEER_LOCALMSPID=JDMSP"
+expect_line 7 "peer lifecycle chaincode checkcommitreadiness" "--sequence 2"
+expect_line 8 "peer lifecycle chaincode commit" "--sequence 2" "--collections-config" \
+  "--peerAddresses peer0.taobao.com:7051 --peerAddresses peer0.jd.com:7051"
+expect_line 9 "peer lifecycle chaincode querycommitted" "-n fabric-realty"
+expect_line 10 "peer chaincode invoke" "--isInit" "{\"Args\":[\"init\"]}"
+if [[ "$(sed -n 10p $LOG)" == *"--transient"* ]]; then
+  fail "未设置 SEED_FILE 时不应传入临时数据"
+fi
+if [ "$(wc -l < $LOG)" -ne 10 ]; then
+  fail "命令数量为 $(wc -l < $LOG)，应为 10"
+fi
+if [ ! -f $WORK/collections_config.json ]; then
+  fail "私有数据集合定义未复制到 config 目录"
+fi
+
+CASE=ccaas
+: > $LOG
+CC_MODE=ccaas CC_SERVER_ADDRESS=chaincode:9999 SEED_FILE=seed.json \
+  ENDORSEMENT_POLICY="OR('TaobaoMSP.member','JDMSP.member')" run_lifecycle || fail "生命周期未执行完成"
+if grep -q "chaincode package" $LOG; then
+  fail "ccaas 模式不应在 cli 中打包链码"
+fi
+expect_line 1 "CORE_PEER_LOCALMSPID=TaobaoMSP" "peer lifecycle chaincode install /etc/hyperledger/config/fabric-realty.tar.gz"
+expect_line 4 "approveformyorg" "--signature-policy \"OR('TaobaoMSP.member','JDMSP.member')\"" "--package-id fabric-realty_1.0.0:abc123"
+expect_line 9 "--isInit" "--transient"
+# 链码包中 metadata.json 的类型为 ccaas，code.tar.gz 中的 connection.json 为链码服务地址
+PKG=$WORK/fabric-realty.tar.gz
+if [ "$(tar -xzOf $PKG metadata.json | jq -r .type)" != "ccaas" ]; then
+  fail "metadata.json 的类型不是 ccaas"
+fi
+if [ "$(tar -xzOf $PKG code.tar.gz | tar -xzO connection.json | jq -r .address)" != "chaincode:9999" ]; then
+  fail "connection.json 的地址不是 CC_SERVER_ADDRESS"
+fi
+# 临时数据中的种子为 seed.json 的 base64
+SEED=$(sed -n 9p $LOG | sed -n "s/.*--transient '\([^']*\)'.*/\1/p" | jq -r .seed | base64 -d)
+if [ "$(echo "$SEED" | jq -S .)" != "$(jq -S . seed.json)" ]; then
+  fail "临时数据中的种子与 seed.json 不一致"
+fi
+
+# 外部构建器按 peer 的调用方式处理上面打出的链码包
+CASE=builder
+mkdir -p $WORK/src $WORK/meta $WORK/bld $WORK/release
+tar -xzf $PKG -C $WORK/meta metadata.json
+tar -xzOf $PKG code.tar.gz | tar -xz -C $WORK/src
+ccaas/bin/detect $WORK/src $WORK/meta || fail "detect 未识别 ccaas 链码包"
+ccaas/bin/build $WORK/src $WORK/meta $WORK/bld || fail "build 执行失败"
+ccaas/bin/release $WORK/bld $WORK/release || fail "release 执行失败"
+if [ "$(jq -r .address $WORK/release/chaincode/server/connection.json)" != "chaincode:9999" ]; then
+  fail "release 未发布 connection.json"
+fi
+echo '{"type":"golang","label":"fabric-realty_1.0.0"}' > $WORK/meta/metadata.json
+if ccaas/bin/detect $WORK/src $WORK/meta; then
+  fail "detect 不应处理 golang 链码包"
+fi
+
+CASE=missing
+: > $LOG
+(
+  source ./lifecycle.sh
+  function cli() { echo "$1" >> $LOG; }
+  install_chaincode > /dev/null && exit 1
+  exit 0
+) || fail "queryinstalled 中没有链码包时应失败"
+
+if [ $FAILED -ne 0 ]; then
+  exit 1
+fi
+echo "ok"
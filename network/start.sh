@@ -3,10 +3,10 @@
 # 检查操作系统类型
 if [[ `uname` == 'Darwin' ]]; then
   echo "当前操作系统是 Mac"
-  export PATH=${PWD}/hyperledger-fabric-darwin-amd64-1.4.12/bin:$PATH
+  export PATH=${PWD}/hyperledger-fabric-darwin-amd64-2.2.15/bin:$PATH
 elif [[ `uname` == 'Linux' ]]; then
   echo "当前操作系统是 Linux"
-  export PATH=${PWD}/hyperledger-fabric-linux-amd64-1.4.12/bin:$PATH
+  export PATH=${PWD}/hyperledger-fabric-linux-amd64-2.2.15/bin:$PATH
 else
   echo "当前操作系统不是 Mac 或 Linux，脚本无法继续执行！"
   exit 1
@@ -38,15 +38,20 @@ configtxgen -profile TwoOrgsChannel -outputAnchorPeersUpdate ./config/TaobaoAnch
 echo "六、为 JD 定义锚节点"
 configtxgen -profile TwoOrgsChannel -outputAnchorPeersUpdate ./config/JDAnchor.tx -channelID appchannel -asOrg JD
 
+# CC_MODE=ccaas 时为链码即服务的开发模式：peer 加载 ccaas 目录下的外部构建器，链码在宿主机上以独立进程运行
+CC_MODE=${CC_MODE:-docker}
+COMPOSE_FILES="-f docker-compose.yaml"
+if [ "$CC_MODE" == "ccaas" ]; then
+  COMPOSE_FILES="$COMPOSE_FILES -f docker-compose-ccaas.yaml"
+fi
+
 echo "区块链 ： 启动"
-docker-compose up -d
+docker-compose $COMPOSE_FILES up -d
 echo "正在等待节点的启动完成，等待10秒"
 sleep 10
 
-TaobaoPeer0Cli="CORE_PEER_ADDRESS=peer0.taobao.com:7051 CORE_PEER_LOCALMSPID=TaobaoMSP CORE_PEER_MSPCONFIGPATH=/etc/hyperledger/peer/taobao.com/users/Admin@taobao.com/msp"
-TaobaoPeer1Cli="CORE_PEER_ADDRESS=peer1.taobao.com:7051 CORE_PEER_LOCALMSPID=TaobaoMSP CORE_PEER_MSPCONFIGPATH=/etc/hyperledger/peer/taobao.com/users/Admin@taobao.com/msp"
-JDPeer0Cli="CORE_PEER_ADDRESS=peer0.jd.com:7051 CORE_PEER_LOCALMSPID=JDMSP CORE_PEER_MSPCONFIGPATH=/etc/hyperledger/peer/jd.com/users/Admin@jd.com/msp"
-JDPeer1Cli="CORE_PEER_ADDRESS=peer1.jd.com:7051 CORE_PEER_LOCALMSPID=JDMSP CORE_PEER_MSPCONFIGPATH=/etc/hyperledger/peer/jd.com/users/Admin@jd.com/msp"
+# 各节点的 cli 环境变量和链码生命周期各步骤见 lifecycle.sh
+source ./lifecycle.sh
 
 echo "七、创建通道"
 docker exec cli bash -c "$TaobaoPeer0Cli peer channel create -o orderer.qq.com:7050 -c appchannel -f /etc/hyperledger/config/appchannel.tx"
@@ -61,29 +66,32 @@ echo "九、更新锚节点"
 docker exec cli bash -c "$TaobaoPeer0Cli peer channel update -o orderer.qq.com:7050 -c appchannel -f /etc/hyperledger/config/TaobaoAnchor.tx"
 docker exec cli bash -c "$JDPeer0Cli peer channel update -o orderer.qq.com:7050 -c appchannel -f /etc/hyperledger/config/JDAnchor.tx"
 
-# -n 链码名，可以自己随便设置
-# -v 版本号
-# -p 链码目录，在 /opt/gopath/src/ 目录下
-echo "十、安装链码"
-docker exec cli bash -c "$TaobaoPeer0Cli peer chaincode install -n fabric-realty -v 1.0.0 -l golang -p chaincode"
-docker exec cli bash -c "$JDPeer0Cli peer chaincode install -n fabric-realty -v 1.0.0 -l golang -p chaincode"
+echo "十、打包链码"
+package_chaincode || exit 1
+
+echo "十一、安装链码"
+install_chaincode || exit 1
 
-# 只需要其中一个节点实例化
-# -n 对应上一步安装链码的名字
-# -v 版本号
-# -C 是通道，在fabric的世界，一个通道就是一条不同的链
-# -c 为传参，传入init参数
-echo "十一、实例化链码"
-docker exec cli bash -c "$TaobaoPeer0Cli peer chaincode instantiate -o orderer.qq.com:7050 -C appchannel -n fabric-realty -l golang -v 1.0.0 -c '{\"Args\":[\"init\"]}' -P \"AND ('TaobaoMSP.member','JDMSP.member')\""
+echo "十二、批准链码定义"
+approve_chaincode || exit 1
+
+echo "十三、提交链码定义"
+commit_chaincode || exit 1
+
+if [ "$CC_MODE" == "ccaas" ]; then
+  echo "请在另一个终端的 chaincode 目录下启动链码服务："
+  echo "  CHAINCODE_SERVER_ADDRESS=0.0.0.0:9999 CHAINCODE_ID=$PACKAGE_ID CHAINCODE_TLS_DISABLED=true go run ."
+  read -p "链码服务启动后按回车继续：" _
+fi
 
-echo "正在等待链码实例化完成，等待5秒"
-sleep 5
+echo "十四、初始化链码"
+init_chaincode || exit 1
 
 # 进行链码交互，验证链码是否正确安装及区块链网络能否正常工作
-echo "十二、验证链码"
-docker exec cli bash -c "$TaobaoPeer0Cli peer chaincode invoke -C appchannel -n fabric-realty -c '{\"Args\":[\"hello\"]}'"
+echo "十五、验证链码"
+docker exec cli bash -c "$TaobaoPeer0Cli peer chaincode invoke $ORDERER -C appchannel -n $CC_NAME $PEERS -c '{\"Args\":[\"hello\"]}' --waitForEvent"
 
-if docker exec cli bash -c "$JDPeer0Cli peer chaincode invoke -C appchannel -n fabric-realty -c '{\"Args\":[\"hello\"]}'" 2>&1 | grep "Chaincode invoke successful"; then
+if docker exec cli bash -c "$JDPeer0Cli peer chaincode invoke $ORDERER -C appchannel -n $CC_NAME $PEERS -c '{\"Args\":[\"Ledger:Hello\"]}' --waitForEvent" 2>&1 | grep "Chaincode invoke successful"; then
   echo "【恭喜您！】 network 部署成功，后续如需暂时停止运行，可以执行 docker-compose stop 命令（不会丢失数据）。"
   exit 0
 fi